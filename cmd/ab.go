@@ -0,0 +1,217 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/scenario"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var abVersions []string
+var abPair string
+
+type abStackResult struct {
+	Stack    string        `json:"stack" yaml:"stack"`
+	Release  string        `json:"release" yaml:"release"`
+	Passed   bool          `json:"passed" yaml:"passed"`
+	Duration time.Duration `json:"duration" yaml:"duration"`
+	Error    string        `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type abRunResult struct {
+	Pair   string                     `json:"pair" yaml:"pair"`
+	Stacks []*abStackResult           `json:"stacks" yaml:"stacks"`
+	Steps  []*scenario.StepComparison `json:"steps" yaml:"steps"`
+}
+
+var abCreateCmd = &cobra.Command{
+	Use:   "ab-create [spec_file]",
+	Short: "Create a blue/green pair of stacks to compare two FireFly releases",
+	Long: `Create a blue/green pair of stacks to compare two FireFly releases
+
+Two stacks named <name>-blue and <name>-green are created from the spec,
+which has the same format as for the plan command. The stacks differ only
+in the release each is pinned to. Both derive their keys from the same
+seed (the seed in the spec, or a random one), so members have the same
+identities and addresses on both. Use ab-run to run a scenario against
+both stacks and compare the results.
+` + fmt.Sprintf(specFilesHelp, "ab-create --versions v1.2.0,v1.3.0"),
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec, err := readSpec(args)
+		if err != nil {
+			return err
+		}
+		managers, err := stacks.CreateABPair(logger, spec, abVersions, verbose)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			results := make([]*initResult, len(managers))
+			for i, m := range managers {
+				results[i] = &initResult{
+					Name:        m.Stack.Name,
+					StackDir:    filepath.Join(constants.StacksDir, m.Stack.Name),
+					ComposeFile: filepath.Join(constants.StacksDir, m.Stack.Name, "docker-compose.yml"),
+					Endpoints:   m.GetEndpoints(),
+				}
+			}
+			return printStructured(results)
+		}
+		for _, m := range managers {
+			fmt.Printf("Stack '%s' created, pinned to release %s\n", m.Stack.Name, m.Release())
+		}
+		names := stacks.ABPairStackNames(spec.Name)
+		fmt.Printf("\nTo start both stacks, run:\n\n    ff start %s\n    ff start %s\n", names[0], names[1])
+		fmt.Printf("\nThen run a scenario against both with:\n\n    ff ab-run <scenario_file> --pair %s\n", spec.Name)
+		return nil
+	},
+}
+
+var abRunCmd = &cobra.Command{
+	Use:   "ab-run <scenario_file>",
+	Short: "Run a scenario against both stacks of a blue/green pair and compare the results",
+	Long: `Run a scenario against both stacks of a blue/green pair and compare the results
+
+The scenario runs against the blue stack, then against the green stack,
+and the outcome and duration of each step are shown side by side. The
+pair can be omitted if only one pair exists. The command fails if the
+scenario fails on either stack.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pair := abPair
+		if pair == "" {
+			pairs, err := stacks.ListABPairs()
+			if err != nil {
+				return err
+			}
+			switch len(pairs) {
+			case 0:
+				return errors.New("no blue/green pairs exist - create one with ff ab-create")
+			case 1:
+				pair = pairs[0]
+			default:
+				return fmt.Errorf("more than one pair exists, choose one with --pair: %s", strings.Join(pairs, ", "))
+			}
+		}
+		managers, err := stacks.LoadABPair(logger, pair)
+		if err != nil {
+			return err
+		}
+		s, err := scenario.LoadScenario(args[0])
+		if err != nil {
+			return err
+		}
+
+		result := &abRunResult{Pair: pair}
+		stepResults := make([][]*scenario.StepResult, len(managers))
+		var failed []string
+		for i, m := range managers {
+			logger.Info(fmt.Sprintf("running scenario against %s", m.Stack.Name))
+			runner := &scenario.Runner{
+				Log:      logger,
+				Stack:    m.Stack,
+				Scenario: s,
+			}
+			results, runErr := runner.Run()
+			stackResult := &abStackResult{
+				Stack:   m.Stack.Name,
+				Release: m.Release(),
+				Passed:  runErr == nil,
+			}
+			for _, r := range results {
+				stackResult.Duration += r.Duration
+			}
+			if runErr != nil {
+				stackResult.Error = runErr.Error()
+				failed = append(failed, m.Stack.Name)
+			}
+			stepResults[i] = results
+			result.Stacks = append(result.Stacks, stackResult)
+		}
+		result.Steps = scenario.CompareResults(s, stepResults[0], stepResults[1])
+		var runErr error
+		if len(failed) > 0 {
+			runErr = fmt.Errorf("the scenario failed on %s", strings.Join(failed, " and "))
+		}
+
+		if structuredOutput() {
+			if err := printStructured(result); err != nil {
+				return err
+			}
+			return runErr
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(w, "STEP\tMEMBER\t%s (%s)\t%s (%s)\tDELTA\n", result.Stacks[0].Stack, result.Stacks[0].Release, result.Stacks[1].Stack, result.Stacks[1].Release)
+		for _, c := range result.Steps {
+			delta := "-"
+			if c.Differs {
+				delta = "DIFFERS"
+			} else if c.A.Passed {
+				delta = formatDelta(c.Delta, c.A.Duration)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.Name, c.Member, formatStepResult(c.A), formatStepResult(c.B), delta)
+		}
+		fmt.Fprintf(w, "TOTAL\t\t%s\t%s\t%s\n", result.Stacks[0].Duration, result.Stacks[1].Duration, formatDelta(result.Stacks[1].Duration-result.Stacks[0].Duration, result.Stacks[0].Duration))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		for _, stackResult := range result.Stacks {
+			if stackResult.Error != "" {
+				fmt.Printf("\n%s: %s\n", stackResult.Stack, stackResult.Error)
+			}
+		}
+		return runErr
+	},
+}
+
+func formatStepResult(r *scenario.StepResult) string {
+	switch {
+	case r == nil:
+		return "not run"
+	case r.Passed:
+		return fmt.Sprintf("PASS %s", r.Duration)
+	default:
+		return fmt.Sprintf("FAIL %s", r.Duration)
+	}
+}
+
+func formatDelta(delta, base time.Duration) string {
+	if base == 0 {
+		return fmt.Sprintf("%+dms", delta.Milliseconds())
+	}
+	return fmt.Sprintf("%+dms (%+.0f%%)", delta.Milliseconds(), float64(delta)/float64(base)*100)
+}
+
+func init() {
+	addSpecFlags(abCreateCmd)
+	abCreateCmd.Flags().StringSliceVar(&abVersions, "versions", nil, "The two releases to compare, for the blue and green stack, e.g. v1.0.0,v1.1.0")
+	abRunCmd.Flags().StringVar(&abPair, "pair", "", "The pair to run the scenario against, if more than one exists")
+	rootCmd.AddCommand(abCreateCmd)
+	rootCmd.AddCommand(abRunCmd)
+}
@@ -0,0 +1,117 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var showPrivateKeys bool
+
+var accountsCmd = &cobra.Command{
+	Use:   "accounts",
+	Short: "Manage blockchain accounts in a stack",
+	Long:  `Manage the blockchain signing accounts in a stack`,
+}
+
+var accountsListCmd = &cobra.Command{
+	Use:     "list <stack_name>",
+	Aliases: []string{"ls"},
+	Short:   "List the blockchain accounts in a stack",
+	Long: `List the blockchain accounts in a stack
+
+This includes the signing account of each member, as well as any
+additional accounts that have been created.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+
+		accounts := stackManager.GetAccounts(showPrivateKeys)
+		if structuredOutput() {
+			return printStructured(accounts)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		if showPrivateKeys {
+			fmt.Fprintln(w, "ADDRESS\tMEMBER\tPRIVATE KEY")
+		} else {
+			fmt.Fprintln(w, "ADDRESS\tMEMBER")
+		}
+		for _, a := range accounts {
+			member := a.Member
+			if member == "" {
+				member = "-"
+			}
+			if showPrivateKeys {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", a.Address, member, a.PrivateKey)
+			} else {
+				fmt.Fprintf(w, "%s\t%s\n", a.Address, member)
+			}
+		}
+		return w.Flush()
+	},
+}
+
+var accountsCreateCmd = &cobra.Command{
+	Use:   "create <stack_name>",
+	Short: "Create a new funded blockchain account",
+	Long: `Create a new funded blockchain account
+
+The account is added to the keystore of the stack's blockchain node
+and recorded in the stack config. If the stack has been run before,
+it must be running for the account to be created.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+
+		account, err := stackManager.CreateAccount()
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(&stacks.AccountInfo{
+				Address:    account.Address,
+				PrivateKey: account.PrivateKey,
+			})
+		}
+		fmt.Printf("created account %s\n", account.Address)
+		return nil
+	},
+}
+
+func init() {
+	accountsListCmd.Flags().BoolVarP(&showPrivateKeys, "private-keys", "k", false, "Include the private key of each account")
+
+	accountsCmd.AddCommand(accountsListCmd)
+	accountsCmd.AddCommand(accountsCreateCmd)
+	rootCmd.AddCommand(accountsCmd)
+}
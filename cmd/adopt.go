@@ -0,0 +1,61 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var adoptCmd = &cobra.Command{
+	Use:   "adopt <dir>",
+	Short: "Bring an existing docker compose FireFly deployment under the management of the CLI",
+	Long: `Bring an existing docker compose FireFly deployment under the management of the CLI
+
+The docker compose file in the directory is inspected to build a stack of its
+members, ports and providers. The stack is named after the compose project of
+the directory, and runs the deployment's own compose file, so the CLI starts,
+stops, resets and inspects the same containers and volumes. Services and
+settings the CLI cannot model are reported, and changes that would regenerate
+the compose file, such as upgrades and adding members, are not supported.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no directory specified")
+		}
+		report, err := stackManager.AdoptStack(args[0], verbose)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(report)
+		}
+		for _, unsupported := range report.Unsupported {
+			fmt.Printf("WARNING: %s\n", unsupported)
+		}
+		fmt.Printf("\nStack '%s' adopted from %s, with members %s\n", report.Stack, report.Directory, strings.Join(report.Members, ", "))
+		fmt.Printf("To manage it, run:\n\n%s start %s\n\n", rootCmd.Use, report.Stack)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(adoptCmd)
+}
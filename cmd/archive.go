@@ -0,0 +1,89 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var archivePath string
+var unarchiveKeep bool
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive <stack_name>",
+	Short: "Move the data of a stack out of docker into an archive",
+	Long: `Move the data of a stack out of docker into an archive
+
+The stack is stopped and the contents of its volumes are compressed into an
+archive in the stack directory, or at --path. Its containers, networks and
+volumes are then removed, so a stack that is not in use takes no space in
+docker. The stack keeps its ports, and can be started again once it is
+brought back with 'ff unarchive'.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return errors.New("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		filename, err := stackManager.ArchiveStack(archivePath, verbose)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Stack '%s' archived to %s\n", args[0], filename)
+		if !quiet {
+			fmt.Printf("To bring it back, run:\n\n%s unarchive %s\n\n", rootCmd.Use, args[0])
+		}
+		return nil
+	},
+}
+
+var unarchiveCmd = &cobra.Command{
+	Use:   "unarchive <stack_name>",
+	Short: "Restore the data of an archived stack into docker",
+	Long: `Restore the data of an archived stack into docker
+
+The volumes of the stack are restored from its archive, which is removed
+afterwards unless --keep is given. The stack can then be started.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return errors.New("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		if err := stackManager.UnarchiveStack(unarchiveKeep, verbose); err != nil {
+			return err
+		}
+		fmt.Printf("Stack '%s' unarchived\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	archiveCmd.Flags().StringVar(&archivePath, "path", "", "File or directory to write the archive to, instead of the stack directory")
+	unarchiveCmd.Flags().BoolVar(&unarchiveKeep, "keep", false, "Keep the archive after the stack is restored from it")
+
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(unarchiveCmd)
+}
@@ -0,0 +1,79 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var awaitEventType string
+var awaitEventTag string
+var awaitEventSince int64
+var awaitEventTimeout time.Duration
+var awaitEventJSON bool
+
+var awaitEventCmd = &cobra.Command{
+	Use:   "await-event <stack_name> <member_id>",
+	Short: "Wait for a member of a stack to receive a FireFly event",
+	Long: `Wait for a member of a stack to receive a FireFly event
+
+This blocks until the member receives an event matching the filters, then
+prints it and exits, so scripts can wait for something to happen before
+moving on. Only events which arrive after the command starts are matched,
+unless --since is set to the sequence to start from, such as 0 to also
+match events the member has already received. The command fails if no
+matching event arrives within the timeout.
+
+Example:
+
+  ff await-event dev 1 --type message_confirmed --tag ping --json`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if awaitEventJSON {
+			outputFormat = "json"
+			setLogger(&log.StdoutLogger{LogLevel: log.Error})
+		}
+		stackManager := stacks.NewStackManager(logger)
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		event, err := stackManager.AwaitEvent(args[1], awaitEventType, awaitEventTag, awaitEventSince, awaitEventTimeout)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(event)
+		}
+		fmt.Printf("%s event %s (sequence %d) received, referencing %s\n", event.Type, event.ID, event.Sequence, event.Reference)
+		return nil
+	},
+}
+
+func init() {
+	awaitEventCmd.Flags().StringVarP(&awaitEventType, "type", "t", "", "The type of event to wait for, such as message_confirmed (any type if not set)")
+	awaitEventCmd.Flags().StringVarP(&awaitEventTag, "tag", "", "", "Only match message events for messages with this tag")
+	awaitEventCmd.Flags().Int64VarP(&awaitEventSince, "since", "", -1, "Only match events after this sequence, instead of events which arrive after the command starts")
+	awaitEventCmd.Flags().DurationVarP(&awaitEventTimeout, "timeout", "", 60*time.Second, "How long to wait for a matching event, or 0 to wait forever")
+	awaitEventCmd.Flags().BoolVarP(&awaitEventJSON, "json", "", false, "Print the event as JSON (the same as --output json)")
+
+	rootCmd.AddCommand(awaitEventCmd)
+}
@@ -0,0 +1,106 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var backupChunkSize string
+var backupLimitRate string
+
+var backupCmd = &cobra.Command{
+	Use:   "backup <stack_name> [archive_file]",
+	Short: "Back up the data volumes of a stopped stack",
+	Long: `Back up the data volumes of a stopped stack
+
+The contents of every volume of the stack - the databases, chain data, IPFS
+and data exchange stores - are written to an archive, along with the stack's
+data directory, so they can be restored after a reset or on another machine
+with restore. The archive is named after the stack and the time of the
+backup, unless another file is given. Services the stack uses but does not
+run, such as an external database, are not included.
+
+For large stacks, --chunk-size writes the backup to a directory as chunks
+of that size, listed with their checksums in a manifest. If the backup is
+interrupted, run it again into the same directory to carry on from the last
+chunk written. --limit-rate caps how fast the backup is written, for
+backing up over a slow link.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		stackName := args[0]
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+		var bytesPerSecond int64
+		if backupLimitRate != "" {
+			limit, err := stacks.ParseByteSize(backupLimitRate)
+			if err != nil {
+				return err
+			}
+			bytesPerSecond = limit
+		}
+
+		if backupChunkSize != "" {
+			chunkSize, err := stacks.ParseByteSize(backupChunkSize)
+			if err != nil {
+				return err
+			}
+			backupDir := fmt.Sprintf("%s-%s", stackName, time.Now().Format("20060102-150405"))
+			if len(args) > 1 {
+				backupDir = args[1]
+			}
+			if err := stackManager.BackupStackToChunks(backupDir, chunkSize, bytesPerSecond, verbose); err != nil {
+				return err
+			}
+			fmt.Printf("Stack '%s' backed up to %s\n", stackName, backupDir)
+			return nil
+		}
+
+		archiveFile := fmt.Sprintf("%s-%s.tar.gz", stackName, time.Now().Format("20060102-150405"))
+		if len(args) > 1 {
+			archiveFile = args[1]
+		}
+
+		f, err := os.Create(archiveFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := stackManager.BackupStack(stacks.NewTransferWriter(f, bytesPerSecond, logger, archiveFile), verbose); err != nil {
+			f.Close()
+			os.Remove(archiveFile)
+			return err
+		}
+		fmt.Printf("Stack '%s' backed up to %s\n", stackName, archiveFile)
+		return nil
+	},
+}
+
+func init() {
+	backupCmd.Flags().StringVarP(&backupChunkSize, "chunk-size", "", "", "Write the backup to a directory as resumable chunks of this size (e.g. 64m)")
+	backupCmd.Flags().StringVarP(&backupLimitRate, "limit-rate", "", "", "Write the backup at no more than this many bytes a second (e.g. 5m)")
+	rootCmd.AddCommand(backupCmd)
+}
@@ -0,0 +1,82 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var cloneVolumes bool
+var cloneEdgePort int
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone <stack_name> <new_stack_name>",
+	Short: "Create a copy of a stack under a new name",
+	Long: `Create a copy of a stack under a new name
+
+The clone has the same members, providers and options as the stack, on the
+next free block of ports and with its own certificates. Its members get
+new keys, so it starts a network of its own from scratch.
+
+With --volumes, the data of the stopped stack is copied too, so the clone
+branches off from the same state - its members keep their keys, as the
+chain and FireFly data belong to them.
+
+If the stack has a custom domain, the clone is served under a subdomain
+named after it. Use --edge-port if the edge proxy port of the stack is
+taken.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) < 2 {
+			return fmt.Errorf("a stack name and a name for the clone must be specified")
+		}
+		if err := validateName(args[1]); err != nil {
+			return err
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		clone, err := stackManager.CloneStack(args[1], cloneVolumes, cloneEdgePort, verbose)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(&initResult{
+				Name:        clone.Stack.Name,
+				StackDir:    filepath.Join(constants.StacksDir, clone.Stack.Name),
+				ComposeFile: filepath.Join(constants.StacksDir, clone.Stack.Name, "docker-compose.yml"),
+				Endpoints:   clone.GetEndpoints(),
+			})
+		}
+		fmt.Printf("Stack '%s' cloned from '%s'\nTo start it, run:\n\n%s start %s\n\n", clone.Stack.Name, args[0], rootCmd.Use, clone.Stack.Name)
+		if clone.Stack.Domain != "" {
+			printDomainInstructions(clone.Stack)
+		}
+		return nil
+	},
+}
+
+func init() {
+	cloneCmd.Flags().BoolVar(&cloneVolumes, "volumes", false, "Copy the data volumes of the stack to the clone")
+	cloneCmd.Flags().IntVar(&cloneEdgePort, "edge-port", 0, "Port the edge proxy of the clone listens on, if the stack has a custom domain")
+	rootCmd.AddCommand(cloneCmd)
+}
@@ -0,0 +1,77 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <stack_a> <stack_b>",
+	Short: "Show the differences between two stacks",
+	Long: `Show the differences between two stacks
+
+This compares the providers, component versions, image overrides, ports
+and the FireFly core config of each member. Keys, addresses and other
+values that are generated randomly for every stack are not compared.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackA := stacks.NewStackManager(logger)
+		if err := stackA.LoadStack(args[0]); err != nil {
+			return err
+		}
+		stackB := stacks.NewStackManager(logger)
+		if err := stackB.LoadStack(args[1]); err != nil {
+			return err
+		}
+
+		differences, err := stackA.Compare(stackB)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(differences)
+		}
+		if len(differences) == 0 {
+			fmt.Printf("stacks '%s' and '%s' are the same\n", args[0], args[1])
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(w, "FIELD\t%s\t%s\n", args[0], args[1])
+		for _, d := range differences {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", d.Field, valueOrDash(d.A), valueOrDash(d.B))
+		}
+		return w.Flush()
+	},
+}
+
+func valueOrDash(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
+
+func init() {
+	rootCmd.AddCommand(compareCmd)
+}
@@ -0,0 +1,72 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// flagsFromConfig holds the names of the flags that were set from the config file or environment
+var flagsFromConfig = make(map[string]bool)
+
+// applyConfigDefaults sets every flag that was not given on the command line from the config file, if it
+// has a value for it, and marks it as changed just as if it had been given. Global flags are set from top
+// level keys, and the flags of each command from the keys in a section named after the command, for example:
+//
+//	verbose: true
+//	init:
+//	  database: postgres
+//	  services-base-port: 6100
+//	stack:
+//	  remove-member:
+//	    force: true
+func applyConfigDefaults(cmd *cobra.Command) error {
+	section := strings.Join(strings.Fields(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name())), ".")
+	var err error
+	setFromConfig := func(key string, f *pflag.Flag) {
+		if err != nil || f.Changed || !viper.IsSet(key) {
+			return
+		}
+		value := viper.Get(key)
+		if list, ok := value.([]interface{}); ok {
+			values := make([]string, len(list))
+			for i, v := range list {
+				values[i] = fmt.Sprint(v)
+			}
+			value = strings.Join(values, ",")
+		}
+		if setErr := cmd.Flags().Set(f.Name, fmt.Sprint(value)); setErr != nil {
+			err = fmt.Errorf("invalid value for '%s' from the config file or environment: %s", key, setErr)
+			return
+		}
+		flagsFromConfig[f.Name] = true
+	}
+	cmd.InheritedFlags().VisitAll(func(f *pflag.Flag) {
+		setFromConfig(f.Name, f)
+	})
+	if section != "" {
+		cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+			setFromConfig(section+"."+f.Name, f)
+		})
+	}
+	return err
+}
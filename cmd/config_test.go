@@ -0,0 +1,65 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestApplyConfigDefaultsMarksFlagsChanged(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set("verbose", true)
+	viper.Set("init.services-base-port", 6100)
+	viper.Set("init.database", "postgres")
+
+	root := &cobra.Command{Use: "ff"}
+	root.PersistentFlags().BoolP("verbose", "v", false, "")
+	cmd := &cobra.Command{Use: "init", Run: func(*cobra.Command, []string) {}}
+	cmd.Flags().Int("services-base-port", 5100, "")
+	cmd.Flags().Int("firefly-base-port", 5000, "")
+	cmd.Flags().String("database", "sqlite3", "")
+	root.AddCommand(cmd)
+	if err := cmd.ParseFlags([]string{"--database", "sqlite3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigDefaults(cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		flag       string
+		value      string
+		changed    bool
+		fromConfig bool
+	}{
+		{flag: "verbose", value: "true", changed: true, fromConfig: true},
+		{flag: "services-base-port", value: "6100", changed: true, fromConfig: true},
+		// The command line wins over the config
+		{flag: "database", value: "sqlite3", changed: true},
+		{flag: "firefly-base-port", value: "5000"},
+	}
+	for _, test := range tests {
+		f := cmd.Flags().Lookup(test.flag)
+		if f.Value.String() != test.value || cmd.Flags().Changed(test.flag) != test.changed || flagsFromConfig[test.flag] != test.fromConfig {
+			t.Errorf("--%s: got (%s, changed %t, from config %t), expected (%s, changed %t, from config %t)", test.flag,
+				f.Value.String(), cmd.Flags().Changed(test.flag), flagsFromConfig[test.flag], test.value, test.changed, test.fromConfig)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/daemon"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var daemonListen string
+var daemonToken string
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run a local REST API for managing stacks",
+	Long: `Run a local REST API for managing stacks
+
+The daemon lets other tools list, create, start, stop, reset, upgrade and
+inspect stacks without shelling out to the CLI. Changes to a stack return
+an operation, whose progress can be streamed as newline delimited JSON
+while it runs. The daemon also serves the dashboard of the ui command.
+It listens on localhost by default, and runs until interrupted with
+Ctrl+C.
+
+Every API request must send a bearer token. Unless one is given with
+--token, a random token is generated and written to
+~/.firefly/daemon-token, readable only by you. Requests addressed to
+a host other than localhost, or sent by other web pages, are rejected,
+and the daemon only listens on other addresses when given a token.
+
+Encrypted stacks are unlocked with the keychain or the passphrase in the
+` + stacks.PassphraseEnvVar + ` environment variable, never by prompting.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDaemon(func(url, token string, generated bool) {
+			fmt.Printf("FireFly CLI daemon listening on %s/api/v1 - press Ctrl+C to stop\n", url)
+			if generated {
+				fmt.Printf("Requests must send the bearer token in %s\n", constants.DaemonTokenFile)
+			}
+		})
+	},
+}
+
+// runDaemon serves the daemon's API until interrupted, calling listening once requests can be made
+// with the token they must send
+func runDaemon(listening func(url, token string, generated bool)) error {
+	stacks.PromptPassphrase = nil
+	if daemonToken == "" {
+		daemonToken = os.Getenv("FIREFLY_CLI_DAEMON_TOKEN")
+	}
+	listener, err := net.Listen("tcp", daemonListen)
+	if err != nil {
+		return err
+	}
+	localOnly := daemon.IsLoopback(listener.Addr().String())
+	generated := false
+	if daemonToken == "" {
+		if !localOnly {
+			listener.Close()
+			return fmt.Errorf("%s is not a loopback address - set a token with --token or $FIREFLY_CLI_DAEMON_TOKEN to listen on it", daemonListen)
+		}
+		if daemonToken, err = daemon.GenerateToken(); err != nil {
+			listener.Close()
+			return err
+		}
+		if err := writeDaemonToken(daemonToken); err != nil {
+			listener.Close()
+			return err
+		}
+		generated = true
+	}
+
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	listening(fmt.Sprintf("http://%s", listener.Addr()), daemonToken, generated)
+	server := daemon.NewServer(logger, daemonToken, verbose)
+	server.LocalOnly = localOnly
+	return server.Serve(listener, stop)
+}
+
+func writeDaemonToken(token string) error {
+	if err := os.MkdirAll(filepath.Dir(constants.DaemonTokenFile), 0755); err != nil {
+		return err
+	}
+	// Replace rather than rewrite the file, so its permissions are always those it is created with
+	os.Remove(constants.DaemonTokenFile)
+	return ioutil.WriteFile(constants.DaemonTokenFile, []byte(token+"\n"), 0600)
+}
+
+func addDaemonFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&daemonListen, "listen", "l", "127.0.0.1:5999", "The address to serve the API on")
+	cmd.Flags().StringVar(&daemonToken, "token", "", "The bearer token required on every request (defaults to $FIREFLY_CLI_DAEMON_TOKEN, or a generated token)")
+}
+
+func init() {
+	addDaemonFlags(daemonCmd)
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,76 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var deployMember string
+var deployRegisteredName string
+var deployParams []string
+
+var deployCmd = &cobra.Command{
+	Use:   "deploy <stack_name> <contract_json_file>",
+	Short: "Deploy a compiled smart contract to a stack",
+	Long: `Deploy a compiled smart contract to a stack
+
+The contract file should be a compiled artifact (such as those produced
+by Truffle or Hardhat) containing the ABI and bytecode of the contract.
+The contract is deployed using the ethconnect instance of a member, and
+the address of the contract is printed once the transaction has been mined.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		stackName := args[0]
+		filename := args[1]
+
+		params := make(map[string]string)
+		for _, param := range deployParams {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid constructor param '%s' - params must be in the form name=value", param)
+			}
+			params[kv[0]] = kv[1]
+		}
+
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+		address, err := stackManager.DeployContract(filename, deployMember, deployRegisteredName, params)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(map[string]string{"address": address})
+		}
+		fmt.Printf("contract address: %s\n", address)
+		return nil
+	},
+}
+
+func init() {
+	deployCmd.Flags().StringVarP(&deployMember, "member", "m", "0", "ID of the member whose account will deploy the contract")
+	deployCmd.Flags().StringVarP(&deployRegisteredName, "register", "r", "", "Register the contract in ethconnect under this name, to generate a REST API for it")
+	deployCmd.Flags().StringArrayVarP(&deployParams, "param", "", nil, "A constructor parameter in the form name=value (can be repeated)")
+
+	rootCmd.AddCommand(deployCmd)
+}
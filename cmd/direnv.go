@@ -0,0 +1,81 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var direnvFile string
+var direnvCredentials bool
+var direnvNoGuard bool
+
+var direnvCmd = &cobra.Command{
+	Use:   "direnv <stack_name> [member_id]",
+	Short: "Write the endpoints of a stack to a direnv .envrc file",
+	Long: `Write the endpoints of a stack to a direnv .envrc file
+
+This writes a block of FIREFLY_* environment variables for one member of
+the stack (the first member by default) to .envrc in the current directory.
+Only a fixed set of endpoint variables is written, and the API credentials
+are left out unless --credentials is set. Running the command again updates
+the block in place and keeps the rest of the file as it was.
+
+The block also checks that the stack is running each time direnv loads it,
+and logs an error if it is not.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		memberID := ""
+		if len(args) > 1 {
+			memberID = args[1]
+		}
+
+		vars, err := stackManager.GetDirenvVariables(memberID, direnvCredentials)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(vars)
+		}
+		block := stackManager.DirenvBlock(vars, rootCmd.Use, !direnvNoGuard)
+		replaced, err := stacks.WriteDirenvFile(direnvFile, block)
+		if err != nil {
+			return err
+		}
+		if replaced {
+			fmt.Printf("Updated the FireFly block in %s\n", direnvFile)
+		} else {
+			fmt.Printf("Added a FireFly block to %s\n", direnvFile)
+		}
+		fmt.Println("Run 'direnv allow' to load it")
+		return nil
+	},
+}
+
+func init() {
+	direnvCmd.Flags().StringVarP(&direnvFile, "file", "f", ".envrc", "The direnv file to write")
+	direnvCmd.Flags().BoolVar(&direnvCredentials, "credentials", false, "Include the FireFly API username and password")
+	direnvCmd.Flags().BoolVar(&direnvNoGuard, "no-guard", false, "Do not check that the stack is running when direnv loads the file")
+	rootCmd.AddCommand(direnvCmd)
+}
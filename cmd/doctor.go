@@ -0,0 +1,84 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [stack_name]",
+	Short: "Check that your environment can run FireFly stacks",
+	Long: `Check that your environment can run FireFly stacks
+
+This checks that docker (or podman) and compose are installed and the
+daemon can be reached, that there is enough free disk space for images
+and volumes, and that images can be pulled. If a stack is given, its
+images are checked, along with the consistency of its stack.json and
+whether its ports are free. Each problem found is reported with the steps
+to fix it.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackName := ""
+		if len(args) > 0 {
+			stackName = args[0]
+		}
+		checks := stacks.Doctor(logger, stackName, verbose)
+
+		var failed []string
+		warnings := 0
+		for _, check := range checks {
+			switch check.Status {
+			case stacks.DoctorFailed:
+				failed = append(failed, check.Name)
+			case stacks.DoctorWarning:
+				warnings++
+			}
+		}
+		var err error
+		if len(failed) > 0 {
+			err = fmt.Errorf("%d checks failed: %s", len(failed), strings.Join(failed, ", "))
+		}
+		if structuredOutput() {
+			if printErr := printStructured(checks); printErr != nil {
+				return printErr
+			}
+			return err
+		}
+
+		for _, check := range checks {
+			fmt.Printf("%-9s %s: %s\n", fmt.Sprintf("[%s]", strings.ToUpper(check.Status)), check.Name, check.Message)
+			if check.Remediation != "" {
+				fmt.Printf("          -> %s\n", check.Remediation)
+			}
+		}
+		if err == nil && warnings == 0 {
+			fmt.Println("\nNo problems found")
+		} else if err == nil {
+			fmt.Printf("\nNo problems which stop stacks from running, but %d warnings\n", warnings)
+		}
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,83 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var exportVolumes bool
+var exportLimitRate string
+
+var exportCmd = &cobra.Command{
+	Use:   "export <stack_name> [archive_file]",
+	Short: "Package a stack into an archive, to import on another machine",
+	Long: `Package a stack into an archive, to import on another machine
+
+The archive contains the stack definition, its docker compose file, configs,
+keys and certificates, and is written to <stack_name>.tar.gz unless another
+file is given. With --volumes, the stack's data volumes are included too, so
+the imported stack carries on from the same state. The stack must be stopped
+to export its volumes. Without them, the imported stack is set up from
+scratch the first time it is started. Use --limit-rate to cap how fast the
+archive is written, for exporting over a slow link.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		stackName := args[0]
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+		var bytesPerSecond int64
+		if exportLimitRate != "" {
+			limit, err := stacks.ParseByteSize(exportLimitRate)
+			if err != nil {
+				return err
+			}
+			bytesPerSecond = limit
+		}
+		archiveFile := stackName + ".tar.gz"
+		if len(args) > 1 {
+			archiveFile = args[1]
+		}
+
+		f, err := os.Create(archiveFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := stackManager.ExportStack(stacks.NewTransferWriter(f, bytesPerSecond, logger, archiveFile), exportVolumes, verbose); err != nil {
+			f.Close()
+			os.Remove(archiveFile)
+			return err
+		}
+		fmt.Printf("Stack '%s' exported to %s\n", stackName, archiveFile)
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().BoolVar(&exportVolumes, "volumes", false, "Include the data volumes of the stack in the archive")
+	exportCmd.Flags().StringVarP(&exportLimitRate, "limit-rate", "", "", "Write the archive at no more than this many bytes a second (e.g. 5m)")
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,278 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/extensions"
+	"github.com/hyperledger/firefly-cli/internal/tracing"
+	"github.com/spf13/cobra"
+)
+
+// extensionInfo describes a user-defined subcommand, for the extensions command
+type extensionInfo struct {
+	Name        string   `json:"name" yaml:"name"`
+	Type        string   `json:"type" yaml:"type"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Steps       []string `json:"steps,omitempty" yaml:"steps,omitempty"`
+	Path        string   `json:"path,omitempty" yaml:"path,omitempty"`
+	// Set if the name is taken by a built-in command or an alias, so the subcommand cannot be run
+	HiddenBy string `json:"hiddenBy,omitempty" yaml:"hiddenBy,omitempty"`
+}
+
+var extensionsCmd = &cobra.Command{
+	Use:   "extensions",
+	Short: "List the aliases and extension executables available as subcommands",
+	Long: fmt.Sprintf(`List the aliases and extension executables available as subcommands
+
+Aliases are defined in %s, with one or more steps
+each. A step is a command of this CLI, or a shell command if it starts
+with "!". $1 to $9 and $@ in a step are replaced by the arguments the
+alias is run with - if no step uses them, the arguments are appended to
+each command of this CLI instead:
+
+  aliases:
+    up: start --no-rollback
+    fresh:
+      description: Re-create a stack from scratch and start it
+      steps:
+        - remove $1 -f
+        - init $1 2 --sandbox --non-interactive
+        - start $1
+        - "!echo started $1"
+
+Executables on the PATH named %s<name> are run as the subcommand
+<name>, with the rest of the arguments, in the same way as git. They are
+given the path of this CLI in FIREFLY_CLI and the directory of the
+stacks in FIREFLY_STACKS_DIR.
+
+Built-in commands take precedence over aliases, which take precedence
+over executables.`, constants.AliasesFile, extensions.ExecutablePrefix),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		infos, err := listExtensions()
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(infos)
+		}
+		if len(infos) == 0 {
+			fmt.Printf("no aliases in %s and no %s<name> executables on the PATH\n", constants.AliasesFile, extensions.ExecutablePrefix)
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tTYPE\tRUNS")
+		for _, info := range infos {
+			runs := info.Path
+			if info.Type == "alias" {
+				runs = strings.Join(info.Steps, "; ")
+			}
+			if info.HiddenBy != "" {
+				runs = fmt.Sprintf("%s (hidden by the %s of the same name)", runs, info.HiddenBy)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", info.Name, info.Type, runs)
+		}
+		return w.Flush()
+	},
+}
+
+// listExtensions describes the aliases and then the extension executables, noting those hidden by a
+// command of the same name
+func listExtensions() ([]*extensionInfo, error) {
+	aliases, err := extensions.LoadAliases(constants.AliasesFile)
+	if err != nil {
+		return nil, err
+	}
+	infos := []*extensionInfo{}
+	aliasNames := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		info := &extensionInfo{Name: alias.Name, Type: "alias", Description: alias.Description, Steps: alias.Steps}
+		if isBuiltinCommand(alias.Name) {
+			info.HiddenBy = "built-in command"
+		}
+		aliasNames[alias.Name] = true
+		infos = append(infos, info)
+	}
+	executables := extensions.FindExecutables()
+	for _, name := range sortedExecutableNames(executables) {
+		info := &extensionInfo{Name: name, Type: "executable", Path: executables[name]}
+		if isBuiltinCommand(name) {
+			info.HiddenBy = "built-in command"
+		} else if aliasNames[name] {
+			info.HiddenBy = "alias"
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// isBuiltinCommand checks whether a name is taken by one of the CLI's own commands, or one of their aliases
+func isBuiltinCommand(name string) bool {
+	for _, c := range rootCmd.Commands() {
+		if c.Annotations[extensionAnnotation] == "" && (c.Name() == name || c.HasAlias(name)) {
+			return true
+		}
+	}
+	return name == "help"
+}
+
+const extensionAnnotation = "extension"
+
+// registerExtensions adds the user's aliases and extension executables as subcommands. A broken aliases
+// file is reported without stopping the CLI, so the file can still be fixed with the CLI's help at hand.
+func registerExtensions() {
+	aliases, err := extensions.LoadAliases(constants.AliasesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ignoring aliases: %s\n", err)
+	}
+	aliasNames := make(map[string]bool, len(aliases))
+	for _, alias := range aliases {
+		if isBuiltinCommand(alias.Name) {
+			continue
+		}
+		aliasNames[alias.Name] = true
+		rootCmd.AddCommand(newAliasCommand(alias))
+	}
+	executables := extensions.FindExecutables()
+	for _, name := range sortedExecutableNames(executables) {
+		if isBuiltinCommand(name) || aliasNames[name] {
+			continue
+		}
+		rootCmd.AddCommand(newExecutableCommand(name, executables[name]))
+	}
+}
+
+func sortedExecutableNames(executables map[string]string) []string {
+	names := make([]string, 0, len(executables))
+	for name := range executables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newAliasCommand(alias *extensions.Alias) *cobra.Command {
+	short := alias.Description
+	if short == "" {
+		short = fmt.Sprintf("Alias for: %s", strings.Join(alias.Steps, "; "))
+	}
+	return &cobra.Command{
+		Use:                alias.Name,
+		Short:              short,
+		Annotations:        map[string]string{extensionAnnotation: "alias"},
+		DisableFlagParsing: true,
+		SilenceUsage:       true,
+		// Each step handles the global flags it is given itself
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+		RunE: func(cmd *cobra.Command, args []string) error {
+			steps, err := alias.Expand(args)
+			if err != nil {
+				return err
+			}
+			self, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			for i, step := range steps {
+				var c *exec.Cmd
+				if step.Shell {
+					c = exec.Command("sh", append([]string{"-c", step.Args[0], alias.Name}, args...)...)
+				} else {
+					c = exec.Command(self, step.Args...)
+				}
+				c.Stdin = os.Stdin
+				c.Stdout = os.Stdout
+				c.Stderr = os.Stderr
+				c.Env = extensionEnvironment(self)
+				if err := c.Run(); err != nil {
+					return fmt.Errorf("alias '%s' stopped at step %d (%s): %s", alias.Name, i+1, alias.Steps[i], err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newExecutableCommand(name, path string) *cobra.Command {
+	return &cobra.Command{
+		Use:                name,
+		Short:              fmt.Sprintf("Run the %s%s extension", extensions.ExecutablePrefix, name),
+		Annotations:        map[string]string{extensionAnnotation: "executable"},
+		DisableFlagParsing: true,
+		SilenceUsage:       true,
+		SilenceErrors:      true,
+		// The extension is given the command line, so the result file and traces can only be set in the config
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyConfigDefaults(cmd); err != nil {
+				return err
+			}
+			startResultRecording(cmd, args)
+			tracing.Start(otelEndpoint, cmd.CommandPath(), args)
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			self, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			c := exec.Command(path, args...)
+			c.Stdin = os.Stdin
+			c.Stdout = os.Stdout
+			c.Stderr = os.Stderr
+			c.Env = extensionEnvironment(self)
+			if err := c.Run(); err != nil {
+				if exitErr, ok := err.(*exec.ExitError); ok {
+					// The extension reports its own errors, so only its exit code is passed on
+					return &exitCodeError{code: exitErr.ExitCode()}
+				}
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+// exitCodeError is returned by a command whose failure has already been reported, so the CLI only exits
+// with its code, once the result file and traces have been written
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// extensionEnvironment is the environment aliases and extensions are run in, which tells them where to find
+// the CLI and its stacks
+func extensionEnvironment(self string) []string {
+	return append(os.Environ(),
+		"FIREFLY_CLI="+self,
+		"FIREFLY_STACKS_DIR="+constants.StacksDir,
+	)
+}
+
+func init() {
+	rootCmd.AddCommand(extensionsCmd)
+}
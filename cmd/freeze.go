@@ -0,0 +1,138 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var freezeCmd = &cobra.Command{
+	Use:   "freeze <stack_name> [bundle_file]",
+	Short: "Freeze a stack into a bundle that reproduces it exactly, to attach to bug reports",
+	Long: `Freeze a stack into a bundle that reproduces it exactly, to attach to bug reports
+
+The bundle contains everything export --volumes does - the stack definition,
+configs, keys and data volumes - and also the digest of the image of each
+component, and the recent operations and events of each member's FireFly
+core. Thaw recreates the stack from the bundle on another machine, running
+exactly the same images from exactly the same data.
+
+A running stack is stopped while its volumes are written, and started again
+afterwards. The history is only collected from a running stack. The bundle
+is written to <stack_name>-repro.tar.gz unless another file is given.
+
+The bundle includes the stack's keys, so only share bundles of test stacks.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		stackName := args[0]
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+		bundleFile := stackName + "-repro.tar.gz"
+		if len(args) > 1 {
+			bundleFile = args[1]
+		}
+
+		f, err := os.Create(bundleFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		frozen, err := stackManager.FreezeStack(f, verbose)
+		if err != nil {
+			f.Close()
+			os.Remove(bundleFile)
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(frozen)
+		}
+		for _, e := range frozen.Errors {
+			fmt.Printf("WARNING: %s\n", e)
+		}
+		fmt.Printf("Stack '%s' frozen to %s\n", stackName, bundleFile)
+		return nil
+	},
+}
+
+var thawCmd = &cobra.Command{
+	Use:   "thaw <bundle_file> [stack_name]",
+	Short: "Recreate a stack from a bundle written by freeze",
+	Long: `Recreate a stack from a bundle written by freeze
+
+The stack keeps the name it was frozen with, unless a new name is given. Its
+images are pinned to the digests they had when it was frozen, and are pulled
+when it is first started. The recent history of each member is extracted to
+the repro directory of the stack. If any of its ports are in use on this
+machine, the stack is moved to the next free block of ports.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no bundle file specified")
+		}
+		stackName := ""
+		if len(args) > 1 {
+			stackName = args[1]
+			if err := validateName(stackName); err != nil {
+				return err
+			}
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		frozen, err := stackManager.ThawStack(f, stackName, verbose)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(frozen)
+		}
+		fmt.Printf("Stack '%s' thawed from %s, frozen at %s\n", stackManager.Stack.Name, args[0], frozen.FrozenAt.Format("2006-01-02 15:04:05 MST"))
+		components := make([]string, 0, len(frozen.Images))
+		for component := range frozen.Images {
+			components = append(components, component)
+		}
+		sort.Strings(components)
+		for _, component := range components {
+			fmt.Printf("  %s: %s\n", component, frozen.Images[component])
+		}
+		for _, image := range frozen.Unpinned {
+			fmt.Printf("WARNING: image %s was not pinned - make sure it is available on this machine\n", image)
+		}
+		if len(frozen.History) > 0 {
+			fmt.Printf("Recent history of each member is in the repro directory of the stack\n")
+		}
+		fmt.Printf("To start it, run:\n\n%s start %s\n\n", rootCmd.Use, stackManager.Stack.Name)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(freezeCmd)
+	rootCmd.AddCommand(thawCmd)
+}
@@ -0,0 +1,108 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var goldenCmd = &cobra.Command{
+	Use:   "golden",
+	Short: "Manage golden images, from which ready-to-use stacks are created",
+	Long: `Manage golden images, from which ready-to-use stacks are created
+
+A golden image captures a stack after its first start - its chain with the
+FireFly contracts deployed, its databases migrated and its members
+registered. Stacks created from it with 'ff init --from-golden' skip first
+time setup, so they are ready in seconds. They have the same members and
+keys as the stack the image was made from, each on a chain of its own.`,
+}
+
+var goldenCreateCmd = &cobra.Command{
+	Use:   "create <stack_name> <golden_name>",
+	Short: "Save a stack that has been started as a golden image",
+	Long: `Save a stack that has been started as a golden image
+
+A running stack is stopped while the image is created, and started again
+afterwards.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return fmt.Errorf("a stack name and golden image name must be specified")
+		}
+		stackManager := stacks.NewStackManager(logger)
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		if err := stackManager.CreateGoldenImage(args[1], verbose); err != nil {
+			return err
+		}
+		fmt.Printf("Golden image '%s' created from stack '%s'\nTo create a stack from it, run:\n\n%s init <stack_name> --from-golden %s\n\n", args[1], args[0], rootCmd.Use, args[1])
+		return nil
+	},
+}
+
+var goldenListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the golden images",
+	Long:    `List the golden images, oldest first`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		images, err := stacks.ListGoldenImages()
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(images)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "GOLDEN IMAGE\tSTACK\tCREATED\tSIZE")
+		for _, image := range images {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.1f MB\n", image.Name, image.Stack, image.Created.Format("2006-01-02 15:04:05"), float64(image.Size)/(1024*1024))
+		}
+		return w.Flush()
+	},
+}
+
+var goldenDeleteCmd = &cobra.Command{
+	Use:     "delete <golden_name>",
+	Aliases: []string{"rm"},
+	Short:   "Delete a golden image",
+	Long:    `Delete a golden image - stacks already created from it are not affected`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return fmt.Errorf("no golden image specified")
+		}
+		if err := stacks.DeleteGoldenImage(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Golden image '%s' deleted\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	goldenCmd.AddCommand(goldenCreateCmd)
+	goldenCmd.AddCommand(goldenListCmd)
+	goldenCmd.AddCommand(goldenDeleteCmd)
+	rootCmd.AddCommand(goldenCmd)
+}
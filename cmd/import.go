@@ -0,0 +1,64 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <archive_file> [stack_name]",
+	Short: "Create a stack from an archive written by export",
+	Long: `Create a stack from an archive written by export
+
+The stack keeps the name it was exported with, unless a new name is given.
+If any of its ports are in use on this machine, the stack is moved to the
+next free block of ports and its configs are regenerated to match.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no archive file specified")
+		}
+		stackName := ""
+		if len(args) > 1 {
+			stackName = args[1]
+			if err := validateName(stackName); err != nil {
+				return err
+			}
+		}
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := stackManager.ImportStack(f, stackName, verbose); err != nil {
+			return err
+		}
+		fmt.Printf("Stack '%s' imported from %s\n", stackManager.Stack.Name, args[0])
+		fmt.Printf("To start it, run:\n\n%s start %s\n\n", rootCmd.Use, stackManager.Stack.Name)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+}
@@ -27,7 +27,7 @@ var infoCmd = &cobra.Command{
 	Use:   "info <stack_name>",
 	Short: "Get info about a stack",
 	Long: `Get info about a stack such as each container name
-	and image version.`,
+	and image version, and the endpoints exposed by each member.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		stackManager := stacks.NewStackManager(logger)
 		if len(args) == 0 {
@@ -43,9 +43,13 @@ var infoCmd = &cobra.Command{
 		if err := stackManager.LoadStack(stackName); err != nil {
 			return err
 		}
+		if structuredOutput() {
+			return printStructured(stackManager.GetEndpoints())
+		}
 		if err := stackManager.PrintStackInfo(verbose); err != nil {
 			return err
 		}
+		stackManager.PrintEndpoints()
 		return nil
 	},
 }
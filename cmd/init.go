@@ -19,20 +19,55 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
+	"github.com/hyperledger/firefly-cli/internal/certs"
 	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/edge"
 	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/hyperledger/firefly-cli/pkg/types"
 )
 
+type initResult struct {
+	Name        string                 `json:"name" yaml:"name"`
+	StackDir    string                 `json:"stackDir" yaml:"stackDir"`
+	ComposeFile string                 `json:"composeFile" yaml:"composeFile"`
+	Endpoints   *stacks.StackEndpoints `json:"endpoints" yaml:"endpoints"`
+	FIPS        []*stacks.FIPSStatus   `json:"fips,omitempty" yaml:"fips,omitempty"`
+}
+
 var initOptions stacks.InitOptions
 var databaseSelection string
 var blockchainProviderSelection string
-var tokensProviderSelection string
+var tokensProviderSelections []string
+var eventBridgeSelection string
+var blockchainNodesSelection string
+var signerSelection string
+var apiAuthSelection string
+var pullPolicySelection string
+var exposeNodeRPC bool
+var nodeRPCPort int
+var memoryLimit string
+var cpuLimit float64
+var serviceMemoryLimits map[string]string
+var serviceCPULimits map[string]string
+var imageOverrides = make(map[string]*string)
+var registry types.RegistryConfig
+var proxy types.ProxyConfig
+var ethconnectConfig types.EthconnectConfig
+var ipfsConfig types.IPFSConfig
+var encrypt bool
+var autoPorts bool
+var fromGolden string
 
 var initCmd = &cobra.Command{
 	Use:   "init [stack_name] [member_count]",
@@ -49,11 +84,55 @@ var initCmd = &cobra.Command{
 		if err := validateBlockchainProvider(blockchainProviderSelection); err != nil {
 			return err
 		}
-		if err := validateTokensProvider(tokensProviderSelection); err != nil {
+		if err := validateTokensProviders(tokensProviderSelections); err != nil {
+			return err
+		}
+		if err := validateEventBridge(eventBridgeSelection); err != nil {
+			return err
+		}
+		if _, err := stacks.BlockchainNodeTopologyFromString(blockchainNodesSelection); err != nil {
+			return err
+		}
+		if _, err := stacks.SignerSelectionFromString(signerSelection); err != nil {
+			return err
+		}
+		if _, err := stacks.APIAuthSelectionFromString(apiAuthSelection); err != nil {
 			return err
 		}
+		if pullPolicySelection != "" {
+			if _, err := stacks.PullPolicyFromString(pullPolicySelection); err != nil {
+				return err
+			}
+		}
+		resourceLimits, err := stacks.GetResourceLimits(memoryLimit, cpuLimit, serviceMemoryLimits, serviceCPULimits)
+		if err != nil {
+			return err
+		}
+		if len(initOptions.PostgresURLs) > 0 {
+			if cmd.Flags().Changed("database") && databaseSelection != stacks.PostgreSQL.String() {
+				return errors.New("--postgres-url can only be used with the postgres database")
+			}
+			databaseSelection = stacks.PostgreSQL.String()
+		}
+		if initOptions.PostgresReplicaLag > 0 {
+			initOptions.PostgresReplicas = true
+		}
+		if initOptions.PostgresReplicas {
+			if len(initOptions.PostgresURLs) > 0 {
+				return errors.New("--postgres-replica cannot be used with --postgres-url - the replicas follow the database containers of the stack")
+			}
+			if cmd.Flags().Changed("database") && databaseSelection != stacks.PostgreSQL.String() {
+				return errors.New("--postgres-replica can only be used with the postgres database")
+			}
+			databaseSelection = stacks.PostgreSQL.String()
+		}
+		if initOptions.Release != "" {
+			if err := core.ValidateRelease(initOptions.Release); err != nil {
+				return err
+			}
+		}
 
-		fmt.Println("initializing new FireFly stack...")
+		logger.Info("initializing new FireFly stack")
 
 		if len(args) > 0 {
 			stackName = args[0]
@@ -62,10 +141,17 @@ var initCmd = &cobra.Command{
 				return err
 			}
 		} else {
-			stackName, _ = prompt("stack name: ", validateName)
+			var err error
+			if stackName, err = prompt("stack name: ", validateName); err != nil {
+				return err
+			}
 			fmt.Println("You selected " + stackName)
 		}
 
+		if fromGolden != "" {
+			return initFromGoldenImage(cmd, stackManager, stackName, args)
+		}
+
 		var memberCountInput string
 		if len(args) > 1 {
 			memberCountInput = args[1]
@@ -73,24 +159,131 @@ var initCmd = &cobra.Command{
 				return err
 			}
 		} else {
-			memberCountInput, _ = prompt("number of members: ", validateCount)
+			var err error
+			if memberCountInput, err = prompt("number of members: ", validateCount); err != nil {
+				return err
+			}
 		}
 		memberCount, _ := strconv.Atoi(memberCountInput)
 
 		initOptions.Verbose = verbose
+		// Ports chosen by the user are only moved with --auto-ports, so a conflict is an error instead
+		initOptions.AutoAdjustPorts = autoPorts || (!cmd.Flags().Changed("firefly-base-port") && !cmd.Flags().Changed("services-base-port"))
 		initOptions.DatabaseSelection, _ = stacks.DatabaseSelectionFromString(databaseSelection)
-		initOptions.TokensProvider, _ = stacks.TokensProviderFromString(tokensProviderSelection)
+		initOptions.TokensProviders, _ = stacks.TokensProvidersFromStrings(tokensProviderSelections)
+		initOptions.EventBridge, _ = stacks.EventBridgeSelectionFromString(eventBridgeSelection)
+		initOptions.BlockchainNodes, _ = stacks.BlockchainNodeTopologyFromString(blockchainNodesSelection)
+		initOptions.Signer, _ = stacks.SignerSelectionFromString(signerSelection)
+		initOptions.APIAuth, _ = stacks.APIAuthSelectionFromString(apiAuthSelection)
+		initOptions.PullPolicy = strings.ToLower(pullPolicySelection)
+		initOptions.ResourceLimits = resourceLimits
+		if exposeNodeRPC {
+			initOptions.NodeRPCPort = nodeRPCPort
+		} else if cmd.Flags().Changed("node-rpc-port") {
+			return errors.New("--node-rpc-port requires --expose-node-rpc to be set")
+		}
+		if ethconnectConfig.MaxInFlight > 0 || ethconnectConfig.MaxTXWaitTime > 0 || ethconnectConfig.ReceiptMaxDocs > 0 {
+			initOptions.Ethconnect = &ethconnectConfig
+		}
+		if ipfsConfig != (types.IPFSConfig{}) {
+			initOptions.IPFS = &ipfsConfig
+		}
+		if proxy.HTTP != "" || proxy.HTTPS != "" {
+			initOptions.Proxy = &proxy
+		} else if proxy.NoProxy != "" {
+			return errors.New("--no-proxy requires --http-proxy or --https-proxy to be set")
+		}
+		if registry.URL != "" {
+			initOptions.Registry = &registry
+		} else if registry.Username != "" {
+			return errors.New("--registry-username requires --registry to be set")
+		}
+		initOptions.ImageOverrides = make(map[string]string)
+		for component, image := range imageOverrides {
+			initOptions.ImageOverrides[component] = *image
+		}
+		// --tokens-image applies to the ERC1155 connector, or to the ERC20 and ERC721 connector if it is the only one
+		if len(initOptions.TokensProviders) == 1 && initOptions.TokensProviders[0] == stacks.ERC20ERC721 {
+			initOptions.ImageOverrides[types.TokensERC20ERC721Component] = initOptions.ImageOverrides[types.TokensERC1155Component]
+			delete(initOptions.ImageOverrides, types.TokensERC1155Component)
+		}
+
+		if encrypt && !initOptions.UseKeychain {
+			if initOptions.Passphrase = os.Getenv(stacks.PassphraseEnvVar); initOptions.Passphrase == "" {
+				passphrase, err := promptPassphrase("passphrase to encrypt the stack: ")
+				if err != nil {
+					return err
+				}
+				confirmation, err := promptPassphrase("confirm passphrase: ")
+				if err != nil {
+					return err
+				}
+				if passphrase != confirmation {
+					return errors.New("passphrases do not match")
+				}
+				initOptions.Passphrase = passphrase
+			}
+		}
 
 		if err := stackManager.InitStack(stackName, memberCount, &initOptions); err != nil {
 			return err
 		}
 
-		fmt.Printf("Stack '%s' created!\nTo start your new stack run:\n\n%s start %s\n", stackName, rootCmd.Use, stackName)
-		fmt.Printf("\nYour docker compose file for this stack can be found at: %s\n\n", filepath.Join(constants.StacksDir, stackName, "docker-compose.yml"))
+		var fipsReport []*stacks.FIPSStatus
+		if initOptions.FIPS {
+			fipsReport = stackManager.GetFIPSReport()
+		}
+
+		if structuredOutput() {
+			return printStructured(&initResult{
+				Name:        stackName,
+				StackDir:    filepath.Join(constants.StacksDir, stackName),
+				ComposeFile: filepath.Join(constants.StacksDir, stackName, "docker-compose.yml"),
+				Endpoints:   stackManager.GetEndpoints(),
+				FIPS:        fipsReport,
+			})
+		}
+
+		for _, status := range fipsReport {
+			if status.Status != stacks.FIPSRestricted {
+				logger.Warn(fmt.Sprintf("FIPS: %s is %s - %s", status.Component, status.Status, status.Notes))
+			}
+		}
+
+		fmt.Printf("Stack '%s' created!\n", stackName)
+		if quiet {
+			return nil
+		}
+		fmt.Printf("To start your new stack run:\n\n%s start %s\n", rootCmd.Use, stackName)
+		fmt.Printf("\nYour docker compose file for this stack can be found at: %s\n", filepath.Join(constants.StacksDir, stackName, "docker-compose.yml"))
+		fmt.Printf("A Makefile with shortcuts for common commands on this stack can be found at: %s\n\n", filepath.Join(constants.StacksDir, stackName, "Makefile"))
+		if stackManager.Stack.Domain != "" {
+			printDomainInstructions(stackManager.Stack)
+		}
+		if stackManager.Stack.TLS && stackManager.Stack.Domain == "" {
+			fmt.Printf("The FireFly APIs are served over HTTPS - to call them, trust the certificate authority of the stack: %s\n\n", certs.GetCACertPath(stackManager.Stack))
+		}
 		return nil
 	},
 }
 
+func printDomainInstructions(stack *types.Stack) {
+	fmt.Printf("To resolve the URLs of the members under %s, add these entries to your hosts file:\n\n", stack.Domain)
+	for _, entry := range edge.GetHostsEntries(stack) {
+		fmt.Println(entry)
+	}
+	fmt.Printf("\nor if you use dnsmasq, add this rule to its config:\n\n%s\n", edge.GetDnsmasqConfig(stack))
+	if stack.TLS {
+		fmt.Printf("\nThen trust the certificate authority of the stack, which issued both the wildcard certificate for %s and the certificates FireFly core serves directly: %s\n\n", stack.Domain, edge.GetCACertPath(stack))
+		return
+	}
+	fmt.Printf("\nThen trust the certificate authority of the stack, which can only issue certificates for %s: %s\n\n", stack.Domain, edge.GetCACertPath(stack))
+}
+
+func initEnvVar(flagName string) string {
+	return "FIREFLY_CLI_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
 func validateName(stackName string) error {
 	if strings.TrimSpace(stackName) == "" {
 		return errors.New("stack name must not be empty")
@@ -102,6 +295,39 @@ func validateName(stackName string) error {
 	}
 }
 
+// initFromGoldenImage creates the stack from a golden image, which fixes the members and options of the stack
+func initFromGoldenImage(cmd *cobra.Command, stackManager *stacks.StackManager, stackName string, args []string) error {
+	if len(args) > 1 {
+		return errors.New("the number of members cannot be given with --from-golden, as it is set by the golden image")
+	}
+	// Defaults from the config file are for stacks created from scratch, so they are left out
+	var conflicting []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if f.Name != "from-golden" && !flagsFromConfig[f.Name] && cmd.LocalFlags().Lookup(f.Name) != nil {
+			conflicting = append(conflicting, "--"+f.Name)
+		}
+	})
+	if len(conflicting) > 0 {
+		return fmt.Errorf("%s cannot be used with --from-golden, as the options of the stack are set by the golden image", strings.Join(conflicting, ", "))
+	}
+	if err := stackManager.InitStackFromGoldenImage(stackName, fromGolden, verbose); err != nil {
+		return err
+	}
+	if structuredOutput() {
+		return printStructured(&initResult{
+			Name:        stackName,
+			StackDir:    filepath.Join(constants.StacksDir, stackName),
+			ComposeFile: filepath.Join(constants.StacksDir, stackName, "docker-compose.yml"),
+			Endpoints:   stackManager.GetEndpoints(),
+		})
+	}
+	fmt.Printf("Stack '%s' created from golden image '%s'!\n", stackName, fromGolden)
+	if !quiet {
+		fmt.Printf("It is ready to use as soon as it is started:\n\n%s start %s\n\n", rootCmd.Use, stackName)
+	}
+	return nil
+}
+
 func validateCount(input string) error {
 	if i, err := strconv.Atoi(input); err != nil {
 		return errors.New("invalid number")
@@ -133,8 +359,16 @@ func validateBlockchainProvider(input string) error {
 	return nil
 }
 
-func validateTokensProvider(input string) error {
-	_, err := stacks.TokensProviderFromString(input)
+func validateTokensProviders(input []string) error {
+	_, err := stacks.TokensProvidersFromStrings(input)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func validateEventBridge(input string) error {
+	_, err := stacks.EventBridgeSelectionFromString(input)
 	if err != nil {
 		return err
 	}
@@ -144,10 +378,83 @@ func validateTokensProvider(input string) error {
 func init() {
 	initCmd.Flags().IntVarP(&initOptions.FireFlyBasePort, "firefly-base-port", "p", 5000, "Mapped port base of FireFly core API (1 added for each member)")
 	initCmd.Flags().IntVarP(&initOptions.ServicesBasePort, "services-base-port", "s", 5100, "Mapped port base of services (100 added for each member)")
+	initCmd.Flags().BoolVarP(&autoPorts, "auto-ports", "", false, "If any ports are reserved by another stack or already in use, move the stack to the next free ports instead of failing - ports are always moved if the base ports are not set")
 	initCmd.Flags().StringVarP(&databaseSelection, "database", "d", "sqlite3", fmt.Sprintf("Database type to use. Options are: %v", stacks.DBSelectionStrings))
 	initCmd.Flags().StringVarP(&blockchainProviderSelection, "blockchain-provider", "", "geth", fmt.Sprintf("Blockchain provider to use. Options are: %v", stacks.BlockchainProviderStrings))
-	initCmd.Flags().StringVarP(&tokensProviderSelection, "tokens-provider", "", "erc1155", fmt.Sprintf("Tokens provider to use. Options are: %v", stacks.TokensProviderStrings))
+	initCmd.Flags().StringVarP(&blockchainNodesSelection, "blockchain-nodes", "", "shared", fmt.Sprintf("Whether members share one blockchain node, or each run their own node joined into a consortium network. Options are: %v", stacks.BlockchainNodeTopologyStrings))
+	initCmd.Flags().StringVarP(&signerSelection, "signer", "", stacks.NodeSigner.String(), fmt.Sprintf("What signs each member's transactions - the blockchain node, an ethsigner holding the member's key, or an ethsigner backed by a Vault server holding the keys. Options are: %v", stacks.SignerSelectionStrings))
+	initCmd.Flags().StringSliceVarP(&tokensProviderSelections, "tokens-provider", "", []string{stacks.ERC1155.String()}, fmt.Sprintf("Tokens provider to use - give more than one to run a connector for each. Options are: %v", stacks.TokensProviderStrings))
+	initCmd.Flags().StringVarP(&eventBridgeSelection, "event-bridge", "", "none", fmt.Sprintf("Republish each member's FireFly events to a local message broker. Options are: %v", stacks.EventBridgeSelectionStrings))
+	initCmd.Flags().IntVarP(&initOptions.WebhookRelayTargetPort, "webhook-relay", "", 0, "Run a relay which buffers FireFly webhook deliveries and forwards them to an app listening on this port on the host")
+	initCmd.Flags().BoolVarP(&initOptions.Monitoring, "monitoring", "", false, "Run Prometheus scraping the metrics of each member's FireFly core, and Grafana with pre-built dashboards")
+	initCmd.Flags().StringVarP(&initOptions.Domain, "domain", "", "", "Give each member a friendly HTTPS URL under this local domain (e.g. ff.test), served by an NGINX edge proxy")
+	initCmd.Flags().IntVarP(&initOptions.EdgePort, "edge-port", "", 443, "Port the edge proxy listens on when --domain is set")
+	initCmd.Flags().BoolVarP(&exposeNodeRPC, "expose-node-rpc", "", false, "Publish the JSON-RPC and WebSocket endpoints of the blockchain nodes to the host, for wallets such as Metamask and tools such as Hardhat")
+	initCmd.Flags().IntVarP(&nodeRPCPort, "node-rpc-port", "", 8545, "Host port of the blockchain node JSON-RPC endpoint when --expose-node-rpc is set - the WebSocket endpoint is on the next port, and with a node per member each member's node takes the next two ports")
+	initCmd.Flags().StringVarP(&initOptions.Release, "release", "r", "", "Pin the version of each FireFly component to a release. Options are: stable, head, or a version in the form vX.Y.Z (tracks the latest images if not set)")
+	for flag, component := range map[string]string{
+		"core-image":         types.FireFlyComponent,
+		"ethconnect-image":   types.EthconnectComponent,
+		"dataexchange-image": types.DataExchangeComponent,
+		"tokens-image":       types.TokensERC1155Component,
+		"geth-image":         types.GethComponent,
+		"ipfs-image":         types.IPFSComponent,
+		"postgres-image":     types.PostgresComponent,
+		"ethsigner-image":    types.EthsignerComponent,
+		"vault-image":        types.VaultComponent,
+		"sandbox-image":      types.SandboxComponent,
+	} {
+		imageOverrides[component] = initCmd.Flags().String(flag, "", fmt.Sprintf("Run this image for the %s service(s) instead of the default", component))
+	}
+	initCmd.Flags().StringVarP(&registry.URL, "registry", "", "", "Pull all images from this private registry mirror (e.g. registry.example.com/firefly) instead of the public registries")
+	initCmd.Flags().StringVarP(&registry.Username, "registry-username", "", "", "Username for the private registry - if not set, the credentials already configured in docker are used")
+	initCmd.Flags().StringVarP(&registry.Password, "registry-password", "", "", "Password for the private registry, or a secret:// reference to it")
+	initCmd.Flags().StringVarP(&proxy.HTTP, "http-proxy", "", "", "Proxy for HTTP requests made by the services of the stack and by the CLI (e.g. http://proxy.example.com:3128)")
+	initCmd.Flags().StringVarP(&proxy.HTTPS, "https-proxy", "", "", "Proxy for HTTPS requests made by the services of the stack and by the CLI")
+	initCmd.Flags().StringVarP(&proxy.NoProxy, "no-proxy", "", "", "Comma separated hosts, domains and CIDR ranges reached without the proxy - the services of the stack and localhost always are")
+	initCmd.Flags().IntVarP(&ethconnectConfig.MaxInFlight, "ethconnect-max-inflight", "", 0, "Maximum number of transactions each member's ethconnect has in flight at once")
+	initCmd.Flags().IntVarP(&ethconnectConfig.MaxTXWaitTime, "ethconnect-max-tx-wait", "", 0, "Seconds each member's ethconnect waits for a transaction receipt before replying without one")
+	initCmd.Flags().IntVarP(&ethconnectConfig.ReceiptMaxDocs, "ethconnect-receipt-max-docs", "", 0, "Number of transaction receipts each member's ethconnect keeps")
+	initCmd.Flags().StringVarP(&initOptions.EthconnectConfig, "ethconnect-config", "", "", "YAML file of ethconnect config to deep-merge into the generated config of each member's ethconnect, such as gas and REST gateway options")
+	initCmd.Flags().StringVarP(&initOptions.CoreConfig, "core-config", "", "", "YAML file of FireFly core config to deep-merge into the generated config of each member, which is kept in the stack directory and reapplied whenever the config is regenerated")
+	initCmd.Flags().StringVarP(&initOptions.Seed, "seed", "", "", "Derive all generated keys and certificates from this seed, such as a mnemonic phrase, so the same stack files are generated on every machine - for testing only, as anyone with the seed can recreate the keys")
+	initCmd.Flags().BoolVarP(&encrypt, "encrypt", "", false, fmt.Sprintf("Encrypt the stack's keys and credentials at rest with a passphrase, read from %s or prompted for", stacks.PassphraseEnvVar))
+	initCmd.Flags().BoolVarP(&initOptions.UseKeychain, "keychain", "", false, "Encrypt the stack's keys and credentials at rest with a passphrase stored in the OS keychain")
+	initCmd.Flags().BoolVarP(&initOptions.SharedIPFS, "shared-ipfs", "", false, "Run one IPFS node shared by all members instead of a node for each member, to save memory")
+	initCmd.Flags().StringVarP(&ipfsConfig.StorageMax, "ipfs-storage-max", "", "", "Size each IPFS node's datastore is kept under by garbage collection (e.g. 1GB)")
+	initCmd.Flags().IntVarP(&ipfsConfig.LowWater, "ipfs-low-water", "", 0, "Number of connections each IPFS node trims its connections down to")
+	initCmd.Flags().IntVarP(&ipfsConfig.HighWater, "ipfs-high-water", "", 0, "Number of connections above which each IPFS node trims its connections")
+	initCmd.Flags().BoolVarP(&ipfsConfig.DisableMDNS, "ipfs-disable-mdns", "", false, "Stop the IPFS nodes looking for peers on the local network with mDNS")
+	initCmd.Flags().BoolVarP(&initOptions.Gateway, "gateway", "", false, "Run each member as a single-org gateway, without data exchange or multiparty networking, for apps that only use the blockchain (requires FireFly v1.1 or later)")
+	initCmd.Flags().BoolVarP(&initOptions.NoUI, "no-ui", "", false, "Do not serve the FireFly UI, for headless and CI use")
+	initCmd.Flags().StringVarP(&initOptions.UIPath, "ui-path", "", "", "Serve the FireFly UI from this directory, such as the build directory of a local checkout of the UI, instead of the UI bundled with FireFly core")
+	initCmd.Flags().BoolVarP(&initOptions.Sandbox, "sandbox", "", false, "Run the FireFly Sandbox web app for each member, to try out the FireFly API from the browser")
+	initCmd.Flags().BoolVarP(&initOptions.SharedSandbox, "shared-sandbox", "", false, "Run one FireFly Sandbox for the first member instead of one for each member (implies --sandbox)")
+	initCmd.Flags().StringVarP(&memoryLimit, "memory-limit", "", "", "Limit the memory of every container in the stack (e.g. 512m)")
+	initCmd.Flags().Float64VarP(&cpuLimit, "cpu-limit", "", 0, "Limit the number of CPUs every container in the stack can use (e.g. 0.5)")
+	initCmd.Flags().StringToStringVarP(&serviceMemoryLimits, "service-memory-limit", "", nil, "Limit the memory of a service, or of every service whose name starts with the prefix (e.g. geth=1g,firefly_core=256m)")
+	initCmd.Flags().StringToStringVarP(&serviceCPULimits, "service-cpu-limit", "", nil, "Limit the number of CPUs of a service, or of every service whose name starts with the prefix (e.g. geth=1)")
+	initCmd.Flags().IntVarP(&initOptions.PrefundedAccounts, "prefunded-accounts", "", 0, "Number of extra accounts to generate and fund in the genesis block, beyond the members' own accounts - they are kept in the stack's stack.json with their private keys")
+	initCmd.Flags().StringVarP(&initOptions.InitialBalance, "initial-balance", "", "", "Balance of each prefunded account in wei, in decimal or 0x hex (a very large balance if not set)")
+	initCmd.Flags().IntVarP(&initOptions.ChainID, "chain-id", "", 0, fmt.Sprintf("Chain ID of the blockchain, also used as its network ID (default %d)", ethereum.DefaultChainID))
+	initCmd.Flags().IntVarP(&initOptions.BlockPeriod, "block-period", "", 0, "Seconds between the blocks sealed by the clique signers - 0 seals a block as soon as there is a transaction")
+	initCmd.Flags().Uint64VarP(&initOptions.GasLimit, "gas-limit", "", 0, fmt.Sprintf("Gas limit of every block, kept fixed as on a production network (by default it starts at %d and rises)", ethereum.DefaultGasLimit))
+	initCmd.Flags().StringVarP(&initOptions.GenesisFragment, "genesis-fragment", "", "", "JSON file of genesis parameters, such as fork blocks in config, to merge into the generated genesis.json")
+	initCmd.Flags().StringVar(&pullPolicySelection, "pull", "", fmt.Sprintf("When to pull images before the stack is started - by default they are pulled on first start only. Options are: %v", stacks.PullPolicyStrings))
+	initCmd.Flags().StringVarP(&apiAuthSelection, "api-auth", "", "none", fmt.Sprintf("Require credentials, generated for each member, on the FireFly API and admin API. Options are: %v", stacks.APIAuthSelectionStrings))
+	initCmd.Flags().BoolVarP(&initOptions.TLS, "tls", "", false, "Serve the FireFly APIs over HTTPS, with certificates issued by a CA generated for the stack")
+	initCmd.Flags().BoolVarP(&initOptions.FIPS, "fips", "", false, "Restrict services to FIPS-approved TLS cipher suites where supported, and report components that cannot comply")
+	initCmd.Flags().StringSliceVarP(&initOptions.PostgresURLs, "postgres-url", "", nil, "Use external PostgreSQL servers instead of database containers - give one URL for each member, or one for a server shared by all members with a schema for each. A URL can be a secret:// reference")
+	initCmd.Flags().BoolVarP(&initOptions.PostgresReplicas, "postgres-replica", "", false, "Run a streaming read replica of each member's database container, to test how an app behaves with replication lag")
+	initCmd.Flags().DurationVarP(&initOptions.PostgresReplicaLag, "postgres-replica-lag", "", 0, "Delay with which the read replicas apply changes from their primary (e.g. 2s) - implies --postgres-replica")
+	initCmd.Flags().StringVarP(&fromGolden, "from-golden", "", "", "Create the stack from a golden image made with 'ff golden create', with its chain, contracts and members already set up")
 	initCmd.Flags().IntVarP(&initOptions.ExternalProcesses, "external", "e", 0, "Manage a number of FireFly core processes outside of the docker-compose stack - useful for development and debugging")
 
+	// Every option can also be set with an environment variable named after the flag, such as
+	// FIREFLY_CLI_DATABASE or FIREFLY_CLI_BLOCKCHAIN_PROVIDER, which is applied with the config file
+	initCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		_ = viper.BindEnv("init."+f.Name, initEnvVar(f.Name))
+	})
+
 	rootCmd.AddCommand(initCmd)
 }
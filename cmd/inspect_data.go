@@ -0,0 +1,80 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var inspectDataComponent string
+var inspectDataQuery string
+
+var inspectDataCmd = &cobra.Command{
+	Use:   "inspect-data <stack_name> [member_id] [-- command...]",
+	Short: "Open a shell on the data of a stopped stack",
+	Long: fmt.Sprintf(`Open a shell on the data of a stopped stack
+
+The volume holding the data of one component of a member (the first member
+by default) is mounted read-only into a throwaway container, which is
+removed on exit. The component is one of %v:
+
+  database      psql for PostgreSQL, or sqlite3 for SQLite
+  ipfs          a shell with the ipfs command working on the repo offline
+  dataexchange  a shell in the data exchange's data directory
+
+--query runs a single SQL query against the database instead, and a command
+given after -- is run instead of the shell. PostgreSQL and IPFS cannot open
+read-only data, so they work on a copy made inside the container - nothing
+is ever written back to the stack's volumes.`, stacks.DataComponentStrings),
+	Example: `  ff inspect-data dev --component database
+  ff inspect-data dev 1 --component database --query "SELECT count(*) FROM messages"
+  ff inspect-data dev --component dataexchange -- ls -lR /data`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		positional, command := args, []string{}
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			positional, command = args[:dash], args[dash:]
+		}
+		if len(positional) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if len(positional) > 2 {
+			return fmt.Errorf("too many arguments - give a command to run after --")
+		}
+		component, err := stacks.DataComponentFromString(inspectDataComponent)
+		if err != nil {
+			return err
+		}
+		stackManager := stacks.NewStackManager(logger)
+		if err := stackManager.LoadStack(positional[0]); err != nil {
+			return err
+		}
+		memberID := ""
+		if len(positional) > 1 {
+			memberID = positional[1]
+		}
+		return stackManager.InspectData(memberID, component, inspectDataQuery, command, verbose)
+	},
+}
+
+func init() {
+	inspectDataCmd.Flags().StringVarP(&inspectDataComponent, "component", "c", "database", fmt.Sprintf("Component whose data to inspect. Options are: %v", stacks.DataComponentStrings))
+	inspectDataCmd.Flags().StringVar(&inspectDataQuery, "query", "", "SQL query to run against the database instead of opening a shell")
+	rootCmd.AddCommand(inspectDataCmd)
+}
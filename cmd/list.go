@@ -18,6 +18,8 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 
@@ -31,19 +33,32 @@ var listCommand = &cobra.Command{
 	Long:    `List stacks`,
 	Args:    cobra.MaximumNArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if stacks, err := stacks.ListStacks(); err != nil {
-			return err
-		} else {
-			fmt.Print("FireFly Stacks:\n\n")
-			for _, s := range stacks {
-				fmt.Println(s)
-			}
-			fmt.Print("\n")
-		}
-		return nil
+		return printStacks()
 	},
 }
 
+func printStacks() error {
+	states, err := stacks.ListStackStates()
+	if err != nil {
+		return err
+	}
+	if structuredOutput() {
+		return printStructured(states)
+	}
+	fmt.Print("FireFly Stacks:\n\n")
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, s := range states {
+		state := s.State
+		if state == "" {
+			state = "unknown"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, state, s.Error)
+	}
+	w.Flush()
+	fmt.Print("\n")
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(listCommand)
 }
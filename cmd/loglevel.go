@@ -0,0 +1,97 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var logLevelCmd = &cobra.Command{
+	Use:   "loglevel",
+	Short: "Change the log level of the services in a stack",
+	Long: `Change the log level of the services in a stack
+
+The log level of the FireFly core of each member, and of the data exchange
+and IPFS services, can be changed without editing the compose file. The
+level is kept with the stack, so it survives restarts and upgrades, until
+it is reset.`,
+}
+
+var logLevelSetCmd = &cobra.Command{
+	Use:   "set <stack_name> <member_id|service> <level>",
+	Short: "Set the log level of a service, or of the FireFly core of a member",
+	Long: fmt.Sprintf(`Set the log level of a service, or of the FireFly core of a member
+
+The level is one of %s. If the service is running, it is restarted to pick
+up the new level.`, strings.Join(stacks.LogLevelStrings, ", ")),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) < 3 {
+			return fmt.Errorf("a stack name, member or service, and log level must be specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		service, err := stackManager.SetLogLevel(args[1], args[2], verbose)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("log level of %s set to %s\n", service, strings.ToLower(args[2]))
+		return nil
+	},
+}
+
+var logLevelResetCmd = &cobra.Command{
+	Use:   "reset <stack_name> [member_id|service]",
+	Short: "Return services to their default log level",
+	Long: `Return a service, or the FireFly core of a member, to its default log level
+
+With no member or service, every service whose log level was set is reset.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		target := ""
+		if len(args) > 1 {
+			target = args[1]
+		}
+		services, err := stackManager.ResetLogLevel(target, verbose)
+		if err != nil {
+			return err
+		}
+		if len(services) == 0 {
+			fmt.Println("no log levels to reset")
+			return nil
+		}
+		fmt.Printf("log level of %s reset\n", strings.Join(services, ", "))
+		return nil
+	},
+}
+
+func init() {
+	logLevelCmd.AddCommand(logLevelSetCmd)
+	logLevelCmd.AddCommand(logLevelResetCmd)
+	rootCmd.AddCommand(logLevelCmd)
+}
@@ -58,7 +58,7 @@ output with the -f flag.`,
 		if follow {
 			commandLine = append(commandLine, "-f")
 		}
-		docker.RunDockerComposeCommand(stackDir, verbose, true, commandLine...)
+		docker.RunDockerComposeCommandAttached(stackDir, verbose, commandLine...)
 		return nil
 	},
 }
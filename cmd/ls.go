@@ -17,11 +17,7 @@
 package cmd
 
 import (
-	"fmt"
-
 	"github.com/spf13/cobra"
-
-	"github.com/hyperledger/firefly-cli/internal/stacks"
 )
 
 var lsCmd = &cobra.Command{
@@ -30,16 +26,7 @@ var lsCmd = &cobra.Command{
 	Long:  `List stacks`,
 	Args:  cobra.MaximumNArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if stacks, err := stacks.ListStacks(); err != nil {
-			return err
-		} else {
-			fmt.Print("FireFly Stacks:\n\n")
-			for _, s := range stacks {
-				fmt.Println(s)
-			}
-			fmt.Print("\n")
-		}
-		return nil
+		return printStacks()
 	},
 }
 
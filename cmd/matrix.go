@@ -0,0 +1,139 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/matrix"
+	"github.com/spf13/cobra"
+)
+
+var matrixKeepFailed bool
+var matrixDryRun bool
+
+var matrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "Test combinations of providers and FireFly releases",
+	Long:  `Test combinations of providers and FireFly releases`,
+}
+
+var matrixRunCmd = &cobra.Command{
+	Use:   "run <matrix_file>",
+	Short: "Create, start and test a stack for each combination in a matrix file",
+	Long: `Create, start and test a stack for each combination in a matrix file
+
+The matrix file lists the values of each dimension to test. For every
+combination of them, one at a time, a stack is created, started and
+waited on until it is ready, a scenario is run against it, and the stack
+is removed. Without a scenario, a smoke test checks that a message
+broadcast by the first member is confirmed by every member. A dimension
+that is not listed takes the default of the init command. The command
+fails if any combination fails.
+
+Example:
+
+  name: compat
+  members: 2
+  blockchain-provider: [geth, besu]
+  database: [sqlite3, postgres]
+  tokens-provider: [erc1155, erc20_erc721]
+  release: [latest, v1.0.0]
+  timeout: 5m
+  exclude:
+    - blockchain-provider: besu
+      tokens-provider: erc20_erc721`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := matrix.LoadMatrix(args[0])
+		if err != nil {
+			return err
+		}
+		runner := &matrix.Runner{
+			Log:        logger,
+			Matrix:     m,
+			Verbose:    verbose,
+			KeepFailed: matrixKeepFailed,
+		}
+
+		if matrixDryRun {
+			combinations := m.Combinations()
+			if structuredOutput() {
+				return printStructured(combinations)
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "STACK\tBLOCKCHAIN\tDATABASE\tTOKENS\tRELEASE")
+			for i, c := range combinations {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", runner.StackName(i), c.BlockchainProvider, c.Database, c.TokensProvider, c.Release)
+			}
+			return w.Flush()
+		}
+
+		results, err := runner.Run()
+		if err != nil {
+			return err
+		}
+		failed := 0
+		for _, r := range results {
+			if !r.Passed {
+				failed++
+			}
+		}
+		var runErr error
+		if failed > 0 {
+			runErr = fmt.Errorf("%d of %d combinations failed", failed, len(results))
+		}
+		if structuredOutput() {
+			if err := printStructured(results); err != nil {
+				return err
+			}
+			return runErr
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "STACK\tBLOCKCHAIN\tDATABASE\tTOKENS\tRELEASE\tRESULT\tDURATION")
+		for _, r := range results {
+			result := "PASS"
+			if !r.Passed {
+				result = fmt.Sprintf("FAIL (%s)", r.Stage)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Stack, r.BlockchainProvider, r.Database, r.TokensProvider, r.Release, result, r.Duration)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Printf("\n%s: %s\n", r.Stack, r.Error)
+			}
+			if r.Kept {
+				fmt.Printf("%s was kept - remove it with: %s remove %s\n", r.Stack, rootCmd.Use, r.Stack)
+			}
+		}
+		return runErr
+	},
+}
+
+func init() {
+	matrixRunCmd.Flags().BoolVar(&matrixKeepFailed, "keep-failed", false, "Keep the stacks of combinations that fail, to investigate the failure")
+	matrixRunCmd.Flags().BoolVar(&matrixDryRun, "dry-run", false, "List the combinations that would be tested, without creating any stacks")
+
+	matrixCmd.AddCommand(matrixRunCmd)
+	rootCmd.AddCommand(matrixCmd)
+}
@@ -0,0 +1,79 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var monitorCmd = &cobra.Command{
+	Use:   "monitor <stack_name>",
+	Short: "Report containers in a stack that run out of memory or crash",
+	Long: `Report containers in a stack that run out of memory or crash
+
+This command first reports any containers in the stack which have
+already been OOM-killed or have exited with an error, then watches the
+stack and reports each time a container is OOM-killed or starts
+crash-looping. It runs until interrupted with Ctrl+C.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+
+		report := func(p *stacks.ContainerProblem) {
+			if structuredOutput() {
+				printStructured(p)
+				return
+			}
+			fmt.Printf("%s %s\n", time.Now().Format(time.RFC3339), p)
+		}
+
+		problems, err := stackManager.GetContainerProblems(verbose)
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			report(p)
+		}
+
+		stop := make(chan struct{})
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			close(stop)
+		}()
+
+		if !structuredOutput() {
+			fmt.Printf("monitoring stack '%s' - press Ctrl+C to stop\n", args[0])
+		}
+		return stackManager.MonitorStack(verbose, stop, report)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(monitorCmd)
+}
@@ -0,0 +1,156 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/notify"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var notifyType string
+var notifyEvents []string
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Manage where the lifecycle events of a stack are posted",
+	Long: `Manage where the lifecycle events of a stack are posted
+
+Starting, stopping, resetting, upgrading and recovering a stack, whether
+from the CLI or the daemon, can be posted to Slack or to a generic
+webhook - useful for shared stacks maintained by a team. A notification
+that cannot be delivered is logged as a warning, and never fails the
+command.`,
+}
+
+var notifyAddCmd = &cobra.Command{
+	Use:   "add <stack_name> <url>",
+	Short: "Post the lifecycle events of a stack to a URL",
+	Long: fmt.Sprintf(`Post the lifecycle events of a stack to a URL
+
+For Slack, give the URL of an incoming webhook. Generic webhooks are sent
+each event as JSON, with the stack name, event, message, any error, and
+the host the CLI ran on. All events are sent unless --events is given.
+Events are: %s`, strings.Join(notify.EventTypes, ", ")),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) < 2 {
+			return fmt.Errorf("a stack name and URL must be specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		target := &types.NotificationTarget{
+			Type:   notifyType,
+			URL:    args[1],
+			Events: notifyEvents,
+		}
+		if err := stackManager.AddNotificationTarget(target); err != nil {
+			return err
+		}
+		fmt.Printf("events of stack '%s' will be posted to %s\n", args[0], args[1])
+		return nil
+	},
+}
+
+var notifyListCmd = &cobra.Command{
+	Use:     "list <stack_name>",
+	Aliases: []string{"ls"},
+	Short:   "List where the lifecycle events of a stack are posted",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		targets := stackManager.Stack.Notifications
+		if structuredOutput() {
+			if targets == nil {
+				targets = []*types.NotificationTarget{}
+			}
+			return printStructured(targets)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tURL\tEVENTS")
+		for _, t := range targets {
+			events := "all"
+			if len(t.Events) > 0 {
+				events = strings.Join(t.Events, ",")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Type, t.URL, events)
+		}
+		return w.Flush()
+	},
+}
+
+var notifyRemoveCmd = &cobra.Command{
+	Use:     "remove <stack_name> <url>",
+	Aliases: []string{"rm"},
+	Short:   "Stop posting the lifecycle events of a stack to a URL",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) < 2 {
+			return fmt.Errorf("a stack name and URL must be specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		if err := stackManager.RemoveNotificationTarget(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("events of stack '%s' will no longer be posted to %s\n", args[0], args[1])
+		return nil
+	},
+}
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "test <stack_name>",
+	Short: "Send a test event to every notification target of a stack",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		if err := stackManager.TestNotifications(); err != nil {
+			return err
+		}
+		fmt.Printf("sent a test event to %d notification targets\n", len(stackManager.Stack.Notifications))
+		return nil
+	},
+}
+
+func init() {
+	notifyAddCmd.Flags().StringVarP(&notifyType, "type", "t", notify.Webhook, fmt.Sprintf("Type of the target. Options are: %v", notify.TargetTypes))
+	notifyAddCmd.Flags().StringSliceVarP(&notifyEvents, "events", "", nil, "Only post these events (all events if not set)")
+
+	notifyCmd.AddCommand(notifyAddCmd)
+	notifyCmd.AddCommand(notifyListCmd)
+	notifyCmd.AddCommand(notifyRemoveCmd)
+	notifyCmd.AddCommand(notifyTestCmd)
+	rootCmd.AddCommand(notifyCmd)
+}
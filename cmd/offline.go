@@ -0,0 +1,67 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var offlineCmd = &cobra.Command{
+	Use:   "offline",
+	Short: "Prepare stacks to be used without network access",
+	Long: `Prepare stacks to be used without network access
+
+Commands run with --offline never reach out to registries or download
+anything. Stacks are created and started only from images already pulled
+and release manifests already cached, and fail straight away if something
+is missing.`,
+}
+
+var offlinePrepareCmd = &cobra.Command{
+	Use:   "prepare <stack_name>...",
+	Short: "Pull everything the stacks need to run offline",
+	Long: `Pull everything the stacks need to run offline
+
+Every image of each stack is pulled, along with the utility image used to
+set up its volumes, and the release manifest of the stack is cached so new
+stacks can be created with the same release while offline.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("no stack specified")
+		}
+		for _, stackName := range args {
+			stackManager := stacks.NewStackManager(logger)
+			if err := stackManager.LoadStack(stackName); err != nil {
+				return err
+			}
+			if err := stackManager.PrepareOffline(verbose); err != nil {
+				return err
+			}
+			fmt.Printf("Stack '%s' is ready to run offline\n", stackName)
+		}
+		return nil
+	},
+}
+
+func init() {
+	offlineCmd.AddCommand(offlinePrepareCmd)
+	rootCmd.AddCommand(offlineCmd)
+}
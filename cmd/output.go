@@ -0,0 +1,62 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var outputFormat string
+
+var OutputFormatStrings = []string{"text", "json", "yaml"}
+
+func validateOutputFormat(input string) error {
+	for _, f := range OutputFormatStrings {
+		if strings.ToLower(input) == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("\"%s\" is not a valid output format. valid options are: %v", input, OutputFormatStrings)
+}
+
+// structuredOutput returns true when the user has asked for machine-readable
+// output, in which case commands should print nothing but the final result
+func structuredOutput() bool {
+	return strings.ToLower(outputFormat) == "json" || strings.ToLower(outputFormat) == "yaml"
+}
+
+func printStructured(v interface{}) error {
+	switch strings.ToLower(outputFormat) {
+	case "yaml":
+		bytes, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(bytes))
+	default:
+		bytes, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(bytes))
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan [spec_file]",
+	Short: "Preview what creating a stack would consume",
+	Long: `Preview what creating a stack would consume, without creating anything
+
+The spec is a YAML file with the same keys as the flags of the init
+command, plus the stack name and number of members:
+
+	name: dev
+	members: 2
+	database: postgres
+	monitoring: true
+
+The containers, volumes and ports the stack would use are reported, along
+with rough estimates of its memory and disk usage, and any conflicts with
+existing stacks or processes already listening on its ports.
+` + fmt.Sprintf(specFilesHelp, "plan"),
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		spec, err := readSpec(args)
+		if err != nil {
+			return err
+		}
+		stackManager := stacks.NewStackManager(logger)
+		plan, err := stackManager.PlanStack(spec)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(plan)
+		}
+
+		fmt.Printf("Stack '%s' would create %d containers and %d volumes:\n\n", plan.Name, len(plan.Services), len(plan.Volumes))
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "CONTAINER\tIMAGE\tPORTS\tMEMORY")
+		for _, service := range plan.Services {
+			ports := make([]string, len(service.Ports))
+			for i, port := range service.Ports {
+				ports[i] = fmt.Sprint(port)
+			}
+			if len(ports) == 0 {
+				ports = []string{"-"}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t~%d MB\n", docker.ContainerName(plan.Name, service.Name), service.Image, strings.Join(ports, ","), service.MemoryMB)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		fmt.Println("\nVolumes:")
+		for _, volume := range plan.Volumes {
+			fmt.Printf("  %s\n", volume)
+		}
+		ports := make([]string, len(plan.Ports))
+		for i, port := range plan.Ports {
+			ports[i] = fmt.Sprint(port)
+		}
+		fmt.Printf("\nReserved ports: %s\n", strings.Join(ports, ", "))
+		fmt.Printf("Estimated memory: ~%d MB\n", plan.MemoryMB)
+		fmt.Printf("Estimated disk: ~%d MB (images and initial data)\n", plan.DiskMB)
+
+		for _, warning := range plan.Warnings {
+			fmt.Printf("\nWARNING: %s", warning)
+		}
+		if len(plan.Conflicts) == 0 {
+			fmt.Println("\nNo conflicts found")
+			return nil
+		}
+		fmt.Printf("\n%d conflicts found:\n", len(plan.Conflicts))
+		for _, conflict := range plan.Conflicts {
+			fmt.Printf("  %s: %s\n", conflict.Resource, conflict.Reason)
+		}
+		return nil
+	},
+}
+
+func init() {
+	addSpecFlags(planCmd)
+	rootCmd.AddCommand(planCmd)
+}
@@ -20,10 +20,16 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"os/exec"
 	"strings"
+
+	"github.com/mattn/go-isatty"
 )
 
 func prompt(promptText string, validate func(string) error) (string, error) {
+	if nonInteractive {
+		return "", fmt.Errorf("%s is required when running with --non-interactive", strings.TrimSuffix(promptText, ": "))
+	}
 	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Print(promptText)
@@ -45,6 +51,9 @@ func prompt(promptText string, validate func(string) error) (string, error) {
 }
 
 func confirm(promptText string) error {
+	if nonInteractive {
+		return nil
+	}
 	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Printf("%s [y/N] ", promptText)
@@ -60,3 +69,28 @@ func confirm(promptText string) error {
 		}
 	}
 }
+
+// promptPassphrase reads a line from stdin without echoing it, if stdin is a terminal that supports stty
+func promptPassphrase(promptText string) (string, error) {
+	if nonInteractive {
+		return "", fmt.Errorf("%s is required when running with --non-interactive", strings.TrimSuffix(promptText, ": "))
+	}
+	fmt.Print(promptText)
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		stty := exec.Command("stty", "-echo")
+		stty.Stdin = os.Stdin
+		if err := stty.Run(); err == nil {
+			defer func() {
+				restore := exec.Command("stty", "echo")
+				restore.Stdin = os.Stdin
+				restore.Run()
+				fmt.Println()
+			}()
+		}
+	}
+	str, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(str, "\r\n"), nil
+}
@@ -0,0 +1,82 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var providersJSON bool
+var providersType string
+
+var providersCmd = &cobra.Command{
+	Use:   "providers",
+	Short: "Discover the providers that can be used in a stack",
+	Long:  `Discover the providers that can be used in a stack`,
+}
+
+var providersListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the available blockchain, database, tokens and storage providers",
+	Long: `List the available blockchain, database, tokens and storage providers
+
+For each provider, this shows its status (stable, experimental, or
+unavailable), whether it is the default, the features it supports,
+and the init options that configure it. Use --json to build tooling
+on top of this list.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		providers := make([]*stacks.ProviderInfo, 0)
+		for _, p := range stacks.ListProviders() {
+			if providersType == "" || p.Type == providersType {
+				providers = append(providers, p)
+			}
+		}
+
+		if providersJSON {
+			outputFormat = "json"
+		}
+		if structuredOutput() {
+			return printStructured(providers)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "TYPE\tNAME\tSTATUS\tDEFAULT\tFEATURES\tDESCRIPTION")
+		for _, p := range providers {
+			isDefault := ""
+			if p.Default {
+				isDefault = "yes"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Type, p.Name, p.Status, isDefault, strings.Join(p.Features, ","), p.Description)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	providersListCmd.Flags().BoolVarP(&providersJSON, "json", "", false, "Output the list as JSON (the same as --output json)")
+	providersListCmd.Flags().StringVarP(&providersType, "type", "t", "", "Only list providers of this type (blockchain, database, tokens, storage or eventBridge)")
+
+	providersCmd.AddCommand(providersListCmd)
+	rootCmd.AddCommand(providersCmd)
+}
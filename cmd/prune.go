@@ -0,0 +1,115 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var pruneDryRun bool
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove containers, volumes and networks left behind by deleted stacks",
+	Long: `Remove containers, volumes and networks left behind by deleted stacks
+
+Stacks that failed to be created, or whose removal was interrupted, can
+leave docker resources behind. A docker compose project is taken to be a
+stack if its containers were created from the stacks directory, and its
+resources are removed if there is no stack of that name. Projects with
+running containers are listed but left alone. Use --dry-run to list the
+resources without removing anything.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		resources, running, err := stacks.FindOrphanedResources(verbose)
+		if err != nil {
+			return err
+		}
+		for _, project := range running {
+			fmt.Fprintf(os.Stderr, "skipping %s, which has no stack but still has running containers - stop them and prune again\n", project)
+		}
+		if len(resources) == 0 && !structuredOutput() {
+			fmt.Println("Nothing to prune")
+			return nil
+		}
+		if !structuredOutput() {
+			w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "TYPE\tNAME\tSTACK")
+			for _, r := range resources {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", r.Type, r.Name, r.Project)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			fmt.Printf("\n%s\n", describeResources(resources))
+		}
+		if pruneDryRun || len(resources) == 0 {
+			if structuredOutput() {
+				return printStructured(resources)
+			}
+			return nil
+		}
+		if !force {
+			if err := confirm("remove these resources"); err != nil {
+				cancel()
+			}
+		}
+		removed, failed := stackManager.PruneResources(resources, verbose)
+		if structuredOutput() {
+			return printStructured(resources)
+		}
+		if failed > 0 {
+			return fmt.Errorf("removed %d of %d resources - %d could not be removed", removed, len(resources), failed)
+		}
+		fmt.Printf("Removed %d resources\n", removed)
+		return nil
+	},
+}
+
+// describeResources counts the resources of each type, such as "2 containers, 5 volumes, 1 network"
+func describeResources(resources []*docker.ComposeResource) string {
+	counts := make(map[string]int)
+	for _, r := range resources {
+		counts[r.Type]++
+	}
+	description := ""
+	for _, resourceType := range []string{docker.ContainerResource, docker.VolumeResource, docker.NetworkResource} {
+		if counts[resourceType] == 0 {
+			continue
+		}
+		if description != "" {
+			description += ", "
+		}
+		description += fmt.Sprintf("%d %s", counts[resourceType], resourceType)
+		if counts[resourceType] > 1 {
+			description += "s"
+		}
+	}
+	return description
+}
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "List the resources that would be removed, without removing them")
+	pruneCmd.Flags().BoolVarP(&force, "force", "f", false, "Remove the resources without prompting for confirmation")
+	rootCmd.AddCommand(pruneCmd)
+}
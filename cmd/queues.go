@@ -0,0 +1,99 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var queuesCmd = &cobra.Command{
+	Use:   "queues <stack_name> [member_id]",
+	Short: "Show the work waiting on each member of a stack",
+	Long: `Show the work waiting on each member of a stack
+
+For each member, this shows the FireFly core operations that are still
+pending (by type), the transactions submitted to ethconnect that have no
+receipt yet, and the messages and blobs queued by data exchange to be
+sent to other members. This helps to find out where a message is stuck.
+A source that cannot be queried is reported, and the others are still
+shown.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		memberID := ""
+		if len(args) > 1 {
+			memberID = args[1]
+		}
+
+		queues, err := stackManager.GetQueueDepths(memberID)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(queues)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "MEMBER\tPENDING OPERATIONS\tETHCONNECT IN-FLIGHT\tDX PENDING")
+		for _, q := range queues {
+			total := 0
+			for _, count := range q.PendingOperations {
+				total += count
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", q.Member,
+				queueDepth(q, stacks.QueueSourceCore, total),
+				queueDepth(q, stacks.QueueSourceEthconnect, q.InFlightTransactions),
+				queueDepth(q, stacks.QueueSourceDataExchange, q.PendingDataExchangeTransfers))
+
+			opTypes := make([]string, 0, len(q.PendingOperations))
+			for opType := range q.PendingOperations {
+				opTypes = append(opTypes, opType)
+			}
+			sort.Strings(opTypes)
+			for _, opType := range opTypes {
+				fmt.Fprintf(w, "\t  %s: %d\t\t\n", opType, q.PendingOperations[opType])
+			}
+			for _, source := range []string{stacks.QueueSourceCore, stacks.QueueSourceEthconnect, stacks.QueueSourceDataExchange} {
+				if err, ok := q.Errors[source]; ok {
+					fmt.Fprintf(w, "\t  %s error: %s\t\t\n", source, err)
+				}
+			}
+		}
+		return w.Flush()
+	},
+}
+
+// queueDepth shows the depth of a queue, or that its source could not be queried
+func queueDepth(q *stacks.QueueDepths, source string, depth int) string {
+	if _, ok := q.Errors[source]; ok {
+		return "-"
+	}
+	return fmt.Sprint(depth)
+}
+
+func init() {
+	rootCmd.AddCommand(queuesCmd)
+}
@@ -0,0 +1,115 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+// Commands which need the stack's containers, and so offer to recover it first if they have disappeared
+var autoRecoverCommands = map[string]bool{
+	"ff info":             true,
+	"ff ps":               true,
+	"ff logs":             true,
+	"ff monitor":          true,
+	"ff queues":           true,
+	"ff deploy":           true,
+	"ff simulate":         true,
+	"ff scenario run":     true,
+	"ff accounts create":  true,
+	"ff stack add-member": true,
+	"ff tenant add":       true,
+}
+
+var recoverCmd = &cobra.Command{
+	Use:   "recover <stack_name>",
+	Short: "Re-create the containers of a stack after Docker restarts",
+	Long: `Re-create the containers of a stack after Docker restarts
+
+When the Docker VM is restarted or recreated, for example by Docker
+Desktop, the containers of a stack can disappear while its volumes
+survive. This re-creates any missing containers against the existing
+volumes, without the full reset that would otherwise be needed.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		check, err := stackManager.RecoverStack(verbose)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(check)
+		}
+		if !check.NeedsRecovery() {
+			fmt.Printf("stack '%s' has no missing containers\n", args[0])
+		} else {
+			fmt.Printf("recovered stack '%s' - re-created %d containers\n", args[0], len(check.MissingContainers))
+		}
+		return nil
+	},
+}
+
+// offerRecovery checks whether the containers of the stack the command operates on have disappeared, and if
+// so offers to recover it before running the command. With --non-interactive, it is recovered automatically.
+// With structured output, nothing is written to stdout and there is no prompt, so the stack is only recovered
+// with --non-interactive.
+func offerRecovery(cmd *cobra.Command, args []string) error {
+	if !autoRecoverCommands[cmd.CommandPath()] || len(args) == 0 {
+		return nil
+	}
+	if exists, err := stacks.CheckExists(args[0]); err != nil || !exists {
+		return nil
+	}
+	if missing, err := stacks.ContainersMissing(args[0], verbose); err != nil || !missing {
+		return nil
+	}
+	out := os.Stdout
+	if structuredOutput() {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "The containers of stack '%s' are missing, probably because Docker was restarted.\n", args[0])
+	if structuredOutput() && !nonInteractive {
+		fmt.Fprintf(out, "run '%s recover %s' to re-create them\n", cmd.Root().Use, args[0])
+		return nil
+	}
+	if err := confirm("re-create them now"); err != nil {
+		fmt.Fprintf(out, "run '%s recover %s' to re-create them later\n", cmd.Root().Use, args[0])
+		return nil
+	}
+	stackManager := stacks.NewStackManager(logger)
+	if err := stackManager.LoadStack(args[0]); err != nil {
+		return err
+	}
+	if _, err := stackManager.RecoverStack(verbose); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "recovered stack '%s'\n", args[0])
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(recoverCmd)
+}
@@ -0,0 +1,86 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var reloadDryRun bool
+var reloadTimeout time.Duration
+
+var reloadCmd = &cobra.Command{
+	Use:   "reload <stack_name>",
+	Short: "Apply config changes by restarting only the services they affect",
+	Long: `Apply config changes by restarting only the services they affect
+
+This regenerates the compose file and configs of the stack, for example after
+editing its FireFly core or ethconnect config overrides, and finds the services
+whose definition or config changed. Only those services are restarted, along with
+every service that depends on them, in dependency order. Each service must be
+healthy (or running, if it has no health check) before the services that depend
+on it are restarted. Services whose compose definition changed are recreated.
+
+If the stack is not running, the changes are written and take effect when it is
+next started.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		plan, err := stackManager.ReloadStack(reloadDryRun, reloadTimeout, verbose)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(plan)
+		}
+		if len(plan) == 0 {
+			fmt.Printf("No services of stack '%s' are affected by the changes\n", args[0])
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "SERVICE\tACTION\tREASON")
+		for _, reload := range plan {
+			action := "restart"
+			if reload.Recreate {
+				action = "recreate"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", reload.Service, action, reload.Reason)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if reloadDryRun {
+			fmt.Println("\nDry run - nothing was changed")
+		}
+		return nil
+	},
+}
+
+func init() {
+	reloadCmd.Flags().BoolVar(&reloadDryRun, "dry-run", false, "Show the services that would be restarted, without changing anything")
+	reloadCmd.Flags().DurationVar(&reloadTimeout, "timeout", 2*time.Minute, "How long to wait for each restarted service to become healthy")
+	rootCmd.AddCommand(reloadCmd)
+}
@@ -57,15 +57,12 @@ and configuration.`,
 		if err := stackManager.LoadStack(stackName); err != nil {
 			return err
 		}
-		fmt.Printf("deleting FireFly stack '%s'... ", stackName)
-		if err := stackManager.StopStack(verbose); err != nil {
-			return err
-		}
+		logger.Info(fmt.Sprintf("deleting FireFly stack '%s'", stackName))
 		if err := stackManager.RemoveStack(verbose); err != nil {
 			return err
 		}
 		os.RemoveAll(filepath.Join(constants.StacksDir, stackName))
-		fmt.Println("done")
+		fmt.Printf("Stack '%s' removed\n", stackName)
 		return nil
 	},
 }
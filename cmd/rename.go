@@ -0,0 +1,57 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename <stack_name> <new_stack_name>",
+	Short: "Rename a stack",
+	Long: `Rename a stack
+
+The containers of the stack are removed and its directory, definition,
+docker compose file and configs are rewritten under the new name. Docker
+cannot rename volumes, so the data volumes are copied to volumes named
+after the new stack, and the old ones are removed once copied. A running
+stack is started again under the new name.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) < 2 {
+			return fmt.Errorf("a stack name and a new name must be specified")
+		}
+		if err := validateName(args[1]); err != nil {
+			return err
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		if err := stackManager.RenameStack(args[1], verbose); err != nil {
+			return err
+		}
+		fmt.Printf("Stack '%s' renamed to '%s'\n", args[0], args[1])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd)
+}
@@ -57,14 +57,14 @@ Note: this will also stop the stack if it is running.
 			return err
 		}
 
-		fmt.Printf("resetting FireFly stack '%s'... ", stackName)
-		if err := stackManager.StopStack(verbose); err != nil {
-			return err
-		}
+		logger.Info(fmt.Sprintf("resetting FireFly stack '%s'", stackName))
 		if err := stackManager.ResetStack(verbose); err != nil {
 			return err
 		}
-		fmt.Printf("done\n\nYour stack has been reset. To start your stack run:\n\n%s start %s\n\n", rootCmd.Use, stackName)
+		fmt.Printf("Stack '%s' has been reset\n", stackName)
+		if !quiet {
+			fmt.Printf("To start your stack run:\n\n%s start %s\n\n", rootCmd.Use, stackName)
+		}
 
 		return nil
 	},
@@ -0,0 +1,78 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <stack_name> <archive_file>",
+	Short: "Replace the data of a stopped stack with a backup",
+	Long: `Replace the data of a stopped stack with a backup
+
+All of the current data of the stack is removed, and its volumes are
+recreated from the backup. The backup can be of the same stack, or of the
+stack it was imported from on another machine, but not of a stack that was
+created separately, as its chain would not match. A chunked backup, written
+by backup --chunk-size, is restored from its directory once every chunk has
+been checked.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) < 2 {
+			return fmt.Errorf("a stack and a backup archive must be specified")
+		}
+		stackName := args[0]
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+		chunked := stacks.IsChunkedBackup(args[1])
+		var f *os.File
+		if !chunked {
+			var err error
+			if f, err = os.Open(args[1]); err != nil {
+				return err
+			}
+			defer f.Close()
+		}
+
+		if !force {
+			fmt.Println("WARNING: This will replace all transactions and data in your FireFly stack with the backup. Are you sure you want to do that?")
+			if err := confirm(fmt.Sprintf("replace all data in FireFly stack '%s'", stackName)); err != nil {
+				cancel()
+			}
+		}
+		if chunked {
+			if err := stackManager.RestoreStackFromChunks(args[1], verbose); err != nil {
+				return err
+			}
+		} else if err := stackManager.RestoreStack(f, verbose); err != nil {
+			return err
+		}
+		fmt.Printf("Stack '%s' restored from %s\n", stackName, args[1])
+		return nil
+	},
+}
+
+func init() {
+	restoreCmd.Flags().BoolVarP(&force, "force", "f", false, "Replace the data of the stack without prompting for confirmation")
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -0,0 +1,118 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var resultFile string
+
+// resultRecorder records the phases and warnings of the command when --result-file is set
+var resultRecorder *log.RecordingLogger
+var resultCommand *cobra.Command
+var resultArgs []string
+var resultStartTime time.Time
+
+type commandResult struct {
+	Command    string            `json:"command"`
+	Args       []string          `json:"args"`
+	Success    bool              `json:"success"`
+	Error      string            `json:"error,omitempty"`
+	StartTime  time.Time         `json:"startTime"`
+	DurationMS int64             `json:"durationMs"`
+	Phases     []*log.Phase      `json:"phases"`
+	Warnings   []string          `json:"warnings"`
+	CLIVersion string            `json:"cliVersion,omitempty"`
+	Images     map[string]string `json:"images,omitempty"`
+}
+
+func startResultRecording(cmd *cobra.Command, args []string) {
+	if resultFile == "" {
+		return
+	}
+	resultCommand = cmd
+	resultArgs = args
+	resultStartTime = time.Now()
+	resultRecorder = &log.RecordingLogger{Logger: logger}
+	logger = resultRecorder
+}
+
+// setLogger replaces the logger used by the command, keeping the phases and warnings already recorded
+func setLogger(l log.Logger) {
+	if resultRecorder != nil {
+		resultRecorder.Logger = l
+	} else {
+		logger = l
+	}
+}
+
+// writeResultFile writes a summary of the command to the file given with --result-file, if any. The
+// images of the stack the command ran against are included, if the stack can be read without a prompt.
+func writeResultFile(err error) {
+	if resultRecorder == nil {
+		return
+	}
+	resultRecorder.Finish()
+	result := &commandResult{
+		Command:    resultCommand.CommandPath(),
+		Args:       resultArgs,
+		Success:    err == nil,
+		StartTime:  resultStartTime,
+		DurationMS: time.Since(resultStartTime).Milliseconds(),
+		Phases:     resultRecorder.Phases,
+		Warnings:   resultRecorder.Warnings,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if result.Phases == nil {
+		result.Phases = []*log.Phase{}
+	}
+	if result.Warnings == nil {
+		result.Warnings = []string{}
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		result.CLIVersion = info.Main.Version
+	}
+	if len(resultArgs) > 0 {
+		if exists, _ := stacks.CheckExists(resultArgs[0]); exists {
+			stacks.PromptPassphrase = nil
+			stackManager := stacks.NewStackManager(&log.StdoutLogger{LogLevel: log.Error})
+			if loadErr := stackManager.LoadStack(resultArgs[0]); loadErr == nil {
+				result.Images = stackManager.GetServiceImages()
+			}
+		}
+	}
+
+	bytes, jsonErr := json.MarshalIndent(result, "", "  ")
+	if jsonErr == nil {
+		jsonErr = ioutil.WriteFile(resultFile, bytes, 0644)
+	}
+	if jsonErr != nil {
+		fmt.Fprintf(os.Stderr, "failed to write result file: %s\n", jsonErr)
+	}
+}
@@ -17,8 +17,11 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
@@ -26,14 +29,25 @@ import (
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
 
+	"github.com/hyperledger/firefly-cli/internal/docker"
 	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/hyperledger/firefly-cli/internal/tracing"
 )
 
 var cfgFile string
 var ansi string
 var fancyFeatures bool
 var verbose bool
+var quiet bool
+var logLevelSelection string
 var force bool
+var nonInteractive bool
+var containerEngine string
+var componentOutput string
+var stateStoreSelection string
+var offline bool
+var otelEndpoint string
 var logger log.Logger = &log.StdoutLogger{
 	LogLevel: log.Debug,
 }
@@ -63,14 +77,47 @@ commands to manage the lifecycle of stacks.
 
 To get started run: ff init
 	`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		if ansi == "always" {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfigDefaults(cmd); err != nil {
+			return err
+		}
+		if err := validateOutputFormat(outputFormat); err != nil {
+			return err
+		}
+		if err := docker.SetContainerEngine(containerEngine); err != nil {
+			return err
+		}
+		if err := docker.SetComponentOutput(componentOutput); err != nil {
+			return err
+		}
+		if err := stacks.SetStateStore(stateStoreSelection); err != nil {
+			return err
+		}
+		stacks.SetOffline(offline)
+		if !nonInteractive {
+			stacks.PromptPassphrase = promptPassphrase
+		}
+		if err := setLogLevel(); err != nil {
+			return err
+		}
+		if structuredOutput() {
+			// Keep stdout clean so the output can be parsed by other tools
+			logger = &log.StdoutLogger{
+				LogLevel: log.Error,
+			}
+			fancyFeatures = false
+		} else if quiet {
+			fancyFeatures = false
+		} else if ansi == "always" {
 			fancyFeatures = true
 		} else if ansi == "auto" && (isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())) {
 			fancyFeatures = true
 		} else {
 			fancyFeatures = false
 		}
+		startResultRecording(cmd, args)
+		tracing.Start(otelEndpoint, cmd.CommandPath(), args)
+		return offerRecovery(cmd, args)
 	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
@@ -80,16 +127,64 @@ To get started run: ff init
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "", "", "config file with default options for commands (default is $HOME/.firefly/config.yaml)")
 	rootCmd.PersistentFlags().StringVarP(&ansi, "ansi", "", "auto", "control when to print ANSI control characters (\"never\"|\"always\"|\"auto\") (default \"auto\")")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose log output")
-	cobra.CheckErr(rootCmd.Execute())
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print errors and the results of commands")
+	rootCmd.PersistentFlags().StringVarP(&logLevelSelection, "log-level", "", log.Info.String(), fmt.Sprintf("Lowest level of messages to print - --verbose lowers it to debug, and --quiet raises it to error. Options are: %v", log.LogLevelStrings))
+	rootCmd.PersistentFlags().BoolVarP(&nonInteractive, "non-interactive", "", false, "Never prompt for input - missing arguments are an error, and confirmations are accepted automatically")
+	rootCmd.PersistentFlags().StringVarP(&componentOutput, "component-output", "", "auto", fmt.Sprintf("How much output of docker, docker compose and the tools they run is shown, separately from --verbose - \"auto\" shows it all with --verbose. Options are: %v", docker.ComponentOutputStrings))
+	rootCmd.PersistentFlags().StringVarP(&containerEngine, "engine", "", "auto", fmt.Sprintf("Container engine used to run stacks. Options are: %v", docker.ContainerEngineStrings))
+	rootCmd.PersistentFlags().StringVarP(&stateStoreSelection, "state-store", "", "files", fmt.Sprintf("Where the state of each stack is kept - its own stack.json file, or one file shared by all stacks. Options are: %v", stacks.StateStoreStrings))
+	rootCmd.PersistentFlags().BoolVarP(&offline, "offline", "", false, "Never reach out to registries or download anything - only images already pulled and release manifests already cached are used")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "text", fmt.Sprintf("Output format for command results. Options are: %v", OutputFormatStrings))
+	rootCmd.PersistentFlags().StringVarP(&resultFile, "result-file", "", "", "Write a JSON summary of the command to this file, with the duration of each phase, the images used and any warnings")
+	rootCmd.PersistentFlags().StringVarP(&otelEndpoint, "otel-endpoint", "", "", "Export the phases of the command as OpenTelemetry spans to this OTLP/HTTP endpoint, such as http://localhost:4318")
+	registerExtensions()
+	err := rootCmd.Execute()
+	writeResultFile(err)
+	exportTraces(err)
+	var exitErr *exitCodeError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
+	}
+	cobra.CheckErr(err)
 }
 
 func init() {
 	cobra.OnInitialize(initConfig)
 }
 
+// setLogLevel creates the logger of the command at the level selected with --log-level, --verbose or --quiet
+func setLogLevel() error {
+	level, err := log.LogLevelFromString(logLevelSelection)
+	if err != nil {
+		return err
+	}
+	switch {
+	case quiet && verbose:
+		return errors.New("--quiet and --verbose cannot be used together")
+	case quiet:
+		level = log.Error
+	case verbose && level > log.Debug:
+		level = log.Debug
+	}
+	logger = &log.StdoutLogger{
+		LogLevel: level,
+	}
+	return nil
+}
+
+// exportTraces sends the spans of the command to the collector given with --otel-endpoint, if any
+func exportTraces(err error) {
+	if exportErr := tracing.Finish(err); exportErr != nil {
+		fmt.Fprintf(os.Stderr, "failed to export traces to %s: %s\n", otelEndpoint, exportErr)
+	}
+}
+
 func cancel() {
+	writeResultFile(errors.New("canceled"))
+	exportTraces(errors.New("canceled"))
 	fmt.Println("canceled")
 	os.Exit(1)
 }
@@ -104,13 +199,20 @@ func initConfig() {
 		home, err := homedir.Dir()
 		cobra.CheckErr(err)
 
-		// Search config in home directory with name ".firefly-cli" (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigName(".firefly-cli")
+		// Search config in ~/.firefly with name "config" (without extension).
+		viper.AddConfigPath(filepath.Join(home, ".firefly"))
+		viper.SetConfigName("config")
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	// Read in environment variables that match, such as FF_VERBOSE or FF_INIT_DATABASE
+	viper.SetEnvPrefix("ff")
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
 
 	// If a config file is found, read it in.
-	viper.ReadInConfig()
+	if err := viper.ReadInConfig(); err != nil {
+		if _, notFound := err.(viper.ConfigFileNotFoundError); !notFound {
+			cobra.CheckErr(fmt.Errorf("failed to read config file: %s", err))
+		}
+	}
 }
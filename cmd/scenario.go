@@ -0,0 +1,104 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/scenario"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var scenarioCmd = &cobra.Command{
+	Use:   "scenario",
+	Short: "Run scripted multi-party flows against a stack",
+	Long:  `Run scripted multi-party flows against a stack`,
+}
+
+var scenarioRunCmd = &cobra.Command{
+	Use:   "run <stack_name> <scenario_file>",
+	Short: "Run a scenario file against a running stack",
+	Long: `Run a scenario file against a running stack
+
+A scenario is a YAML file describing a list of steps, each taken by
+a member of the stack. A step either performs an action (broadcast,
+private, transfer or invoke) or asserts that the member receives an
+event (expect) within a timeout. Steps run in order, and the scenario
+stops at the first step that fails.
+
+Example:
+
+  name: ping pong
+  timeout: 30s
+  steps:
+    - member: "0"
+      private:
+        to: ["1"]
+        tag: ping
+        value: hello
+    - member: "1"
+      expect:
+        event: message_confirmed
+        tag: ping
+        timeout: 10s`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		s, err := scenario.LoadScenario(args[1])
+		if err != nil {
+			return err
+		}
+
+		runner := &scenario.Runner{
+			Log:      logger,
+			Stack:    stackManager.Stack,
+			Scenario: s,
+		}
+		results, runErr := runner.Run()
+		if structuredOutput() {
+			if err := printStructured(results); err != nil {
+				return err
+			}
+			return runErr
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "STEP\tMEMBER\tRESULT\tDURATION")
+		for _, r := range results {
+			result := "PASS"
+			if !r.Passed {
+				result = "FAIL"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.Member, result, r.Duration)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		return runErr
+	},
+}
+
+func init() {
+	scenarioCmd.AddCommand(scenarioRunCmd)
+	rootCmd.AddCommand(scenarioCmd)
+}
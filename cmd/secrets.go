@@ -0,0 +1,85 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage secrets kept in the OS keychain for stacks to refer to",
+	Long: `Manage secrets kept in the OS keychain for stacks to refer to
+
+Values such as --postgres-url, --registry-password, and values in core
+and ethconnect config overrides, can be given as a reference to a secret
+kept on the host instead of the secret itself:
+
+  secret://keychain/<name>  a secret stored in the OS keychain with
+                            'secrets set <name>'
+  secret://env/<NAME>       the value of an environment variable
+
+The reference is stored in the stack, and the secret is only resolved
+when it is used, so it is never written to the stack's directory.`,
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Store a secret in the OS keychain",
+	Long: `Store a secret in the OS keychain
+
+The secret is read from stdin, without echoing it if stdin is a
+terminal, and replaces any secret already stored with the name.
+Stacks refer to it as secret://keychain/<name>.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		secret, err := promptPassphrase(fmt.Sprintf("secret for '%s': ", args[0]))
+		if err != nil {
+			return err
+		}
+		if secret == "" {
+			return fmt.Errorf("the secret cannot be empty")
+		}
+		if err := secrets.Set(args[0], secret); err != nil {
+			return err
+		}
+		fmt.Printf("stored secret '%s' - refer to it as secret://keychain/%s\n", args[0], args[0])
+		return nil
+	},
+}
+
+var secretsDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Remove a secret from the OS keychain",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := secrets.Delete(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("deleted secret '%s'\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsDeleteCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
@@ -0,0 +1,76 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var simulateReplyText string
+var simulateInterval time.Duration
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate <stack_name> <member_id>",
+	Short: "Run a mock counterparty for a member of a stack",
+	Long: `Run a mock counterparty for a member of a stack
+
+The simulator acts on behalf of the given member, so two-party flows
+can be tested while only driving one member yourself. Any private
+message received by the member is answered with a reply to the same
+group, and token transfers received by the member are accepted and
+reported. The simulator runs until interrupted with Ctrl+C.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if err := cobra.ExactArgs(2)(cmd, args); err != nil {
+			return err
+		}
+		if simulateInterval <= 0 {
+			return fmt.Errorf("the interval must be greater than zero")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+
+		stop := make(chan struct{})
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-signals
+			close(stop)
+		}()
+
+		fmt.Printf("simulating member %s of stack '%s' - press Ctrl+C to stop\n", args[1], args[0])
+		return stackManager.SimulateMember(args[1], simulateReplyText, simulateInterval, stop)
+	},
+}
+
+func init() {
+	simulateCmd.Flags().StringVarP(&simulateReplyText, "reply", "r", "ack", "The value to send in reply to each private message")
+	simulateCmd.Flags().DurationVarP(&simulateInterval, "interval", "i", time.Second, "How often to poll the member for new events")
+
+	rootCmd.AddCommand(simulateCmd)
+}
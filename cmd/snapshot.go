@@ -0,0 +1,141 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage named checkpoints of the data of a stack",
+	Long: `Manage named checkpoints of the data of a stack
+
+A snapshot captures the data of a stack at a point in time, such as after
+onboarding or after deploying a contract, so it can be rolled back to that
+point between test scenarios. Snapshots are kept with the stack, survive a
+reset, and are removed along with the stack. A running stack is stopped
+while a snapshot is created or restored, and started again afterwards.`,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create <stack_name> <snapshot_name>",
+	Short: "Save the data of a stack as a named snapshot",
+	Long:  `Save the data of a stack as a named snapshot`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager, err := loadSnapshotStack(args)
+		if err != nil {
+			return err
+		}
+		if err := stackManager.CreateSnapshot(args[1], verbose); err != nil {
+			return err
+		}
+		fmt.Printf("Snapshot '%s' of stack '%s' created\n", args[1], args[0])
+		return nil
+	},
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <stack_name> <snapshot_name>",
+	Short: "Roll the data of a stack back to a snapshot",
+	Long: `Roll the data of a stack back to a snapshot
+
+All data added to the stack since the snapshot was created is lost.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager, err := loadSnapshotStack(args)
+		if err != nil {
+			return err
+		}
+		if err := stackManager.RestoreSnapshot(args[1], verbose); err != nil {
+			return err
+		}
+		fmt.Printf("Stack '%s' restored to snapshot '%s'\n", args[0], args[1])
+		return nil
+	},
+}
+
+var snapshotDeleteCmd = &cobra.Command{
+	Use:     "delete <stack_name> <snapshot_name>",
+	Aliases: []string{"rm"},
+	Short:   "Delete a snapshot of a stack",
+	Long:    `Delete a snapshot of a stack`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager, err := loadSnapshotStack(args)
+		if err != nil {
+			return err
+		}
+		if err := stackManager.DeleteSnapshot(args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Snapshot '%s' of stack '%s' deleted\n", args[1], args[0])
+		return nil
+	},
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:     "list <stack_name>",
+	Aliases: []string{"ls"},
+	Short:   "List the snapshots of a stack",
+	Long:    `List the snapshots of a stack, oldest first`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		snapshots, err := stackManager.ListSnapshots()
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(snapshots)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "SNAPSHOT\tCREATED\tSIZE")
+		for _, s := range snapshots {
+			fmt.Fprintf(w, "%s\t%s\t%.1f MB\n", s.Name, s.Created.Format("2006-01-02 15:04:05"), float64(s.Size)/(1024*1024))
+		}
+		return w.Flush()
+	},
+}
+
+func loadSnapshotStack(args []string) (*stacks.StackManager, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("a stack name and snapshot name must be specified")
+	}
+	stackManager := stacks.NewStackManager(logger)
+	if err := stackManager.LoadStack(args[0]); err != nil {
+		return nil, err
+	}
+	return stackManager, nil
+}
+
+func init() {
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+	snapshotCmd.AddCommand(snapshotDeleteCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
@@ -0,0 +1,51 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var specFiles []string
+var specValuesFiles []string
+var specSettings []string
+
+const specFilesHelp = `
+Several files can be combined, such as a base spec and an overlay for each
+environment, by giving each one with -f. The files are merged in order:
+maps are merged key by key, any other value in a later file replaces the
+value in an earlier one, and a key set to null is removed. Each file can
+also use Go template variables, such as {{ .member_count }}, which are set
+from YAML files given with --values and with --set name=value:
+
+	ff %s -f base.yaml -f overrides.dev.yaml --set member_count=3`
+
+func addSpecFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVarP(&specFiles, "file", "f", nil, "A spec file, or an overlay merged over the files before it - can be given more than once")
+	cmd.Flags().StringArrayVar(&specValuesFiles, "values", nil, "A YAML file of variables for the spec files - can be given more than once")
+	cmd.Flags().StringArrayVar(&specSettings, "set", nil, "Set a variable for the spec files, as name=value - can be given more than once")
+}
+
+// readSpec reads the stack spec from the spec file given as an argument, if any, followed by the files given with -f
+func readSpec(args []string) (*stacks.StackSpec, error) {
+	vars, err := stacks.ReadSpecVariables(specValuesFiles, specSettings)
+	if err != nil {
+		return nil, err
+	}
+	return stacks.ReadStackSpecFiles(append(args, specFiles...), vars)
+}
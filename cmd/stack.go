@@ -0,0 +1,176 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var addMemberPostgresURL string
+
+var stackCmd = &cobra.Command{
+	Use:   "stack",
+	Short: "Change the members and service aliases of an existing stack",
+	Long:  `Change the members of an existing stack without re-initializing it`,
+}
+
+var stackAddMemberCmd = &cobra.Command{
+	Use:   "add-member <stack_name>",
+	Short: "Add a new member to a stack",
+	Long: `Add a new member to a stack
+
+The new member is allocated the next block of ports, and gets its own
+signing key, data exchange certificate and set of services. If the
+stack has been run before, it must be running - the new member's
+services are started and its org and node are registered with the
+existing network.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+
+		member, err := stackManager.AddMember(addMemberPostgresURL, verbose)
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(member)
+		}
+		fmt.Printf("added member %s to stack '%s'\n\n", member.ID, args[0])
+		fmt.Printf("FireFly API for the new member: %s/api\n\n", member.FireflyURL())
+		return nil
+	},
+}
+
+var stackRemoveMemberCmd = &cobra.Command{
+	Use:   "remove-member <stack_name> <member_id>",
+	Short: "Remove a member from a stack",
+	Long: `Remove a member from a stack
+
+The member's containers, volumes and local data are deleted. Its org
+and node remain registered on the network, as identities cannot be
+unregistered once they have been broadcast.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) < 2 {
+			return fmt.Errorf("a stack name and member ID must be specified")
+		}
+		stackName := args[0]
+		memberID := args[1]
+
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+
+		if !force {
+			fmt.Printf("WARNING: This will delete all data belonging to member %s. Are you sure you want to do that?\n", memberID)
+			if err := confirm(fmt.Sprintf("remove member %s from FireFly stack '%s'", memberID, stackName)); err != nil {
+				cancel()
+			}
+		}
+
+		if err := stackManager.RemoveMember(memberID, verbose); err != nil {
+			return err
+		}
+		fmt.Printf("removed member %s from stack '%s'\n", memberID, stackName)
+		return nil
+	},
+}
+
+var stackAliasesCmd = &cobra.Command{
+	Use:   "aliases <stack_name>",
+	Short: "List the hostnames services use to reach each other",
+	Long: `List the hostnames services use to reach each other
+
+Every service in a stack has an alias in the firefly.internal domain,
+such as core-0.firefly.internal, and all of the generated configs refer
+to services by their aliases. The host column shows where each alias
+currently points.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		aliases := stackManager.GetHostAliases()
+		if structuredOutput() {
+			return printStructured(aliases)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "ALIAS\tSERVICE\tHOST")
+		for _, a := range aliases {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", a.Alias, a.Service, a.Host)
+		}
+		return w.Flush()
+	},
+}
+
+var stackSetAliasCmd = &cobra.Command{
+	Use:   "set-alias <stack_name> <alias> [host]",
+	Short: "Point a service alias at an external host",
+	Long: `Point a service alias at an external host
+
+All of the generated configs are rewritten to reach the service at the
+given host, which must listen on the same port as the service. Leave
+out the host to point the alias back at the stack's own container. If
+the stack has been run before, its containers are recreated to pick up
+the new configs.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) < 2 {
+			return fmt.Errorf("a stack name and alias must be specified")
+		}
+		host := ""
+		if len(args) > 2 {
+			host = args[2]
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+		if err := stackManager.SetHostAlias(args[1], host, verbose); err != nil {
+			return err
+		}
+		if host == "" {
+			fmt.Printf("%s now points at its container in stack '%s'\n", args[1], args[0])
+		} else {
+			fmt.Printf("%s now points at %s in stack '%s'\n", args[1], host, args[0])
+		}
+		return nil
+	},
+}
+
+func init() {
+	stackAddMemberCmd.Flags().StringVarP(&addMemberPostgresURL, "postgres-url", "", "", "Use this external PostgreSQL server for the new member's database instead of a container")
+	stackRemoveMemberCmd.Flags().BoolVarP(&force, "force", "f", false, "Remove the member without prompting for confirmation")
+
+	stackCmd.AddCommand(stackAddMemberCmd)
+	stackCmd.AddCommand(stackRemoveMemberCmd)
+	stackCmd.AddCommand(stackAliasesCmd)
+	stackCmd.AddCommand(stackSetAliasCmd)
+	rootCmd.AddCommand(stackCmd)
+}
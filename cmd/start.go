@@ -19,37 +19,69 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/briandowns/spinner"
 	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/internal/sandbox"
 	"github.com/hyperledger/firefly-cli/internal/stacks"
 	"github.com/spf13/cobra"
 )
 
 var startOptions stacks.StartOptions
+var startAfter []string
 
 var startCmd = &cobra.Command{
-	Use:   "start <stack_name>",
+	Use:   "start <stack_name>...",
 	Short: "Start a stack",
 	Long: `Start a stack
 
 This command will start a stack and run it in the background.
+
+Several stacks can be started at once. Use --after to start a stack only
+once the stacks it depends on are ready - for example, start the stack that
+owns a chain before the stacks that join it with --after joiner=owner.
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if cmd.Flags().Changed("timeout") {
+			startOptions.Wait = true
+		}
+		if startOptions.WaitTimeout < 0 {
+			return errors.New("--timeout cannot be negative")
+		}
+		if startOptions.PullPolicy != "" {
+			if startOptions.NoPull {
+				return errors.New("--no-pull cannot be used with --pull")
+			}
+			policy, err := stacks.PullPolicyFromString(startOptions.PullPolicy)
+			if err != nil {
+				return err
+			}
+			startOptions.PullPolicy = policy.String()
+		}
+		if len(args) > 1 || len(startAfter) > 0 {
+			return startStacks(args)
+		}
 		var spin *spinner.Spinner
-		if fancyFeatures && !verbose {
+		if fancyFeatures && !verbose && !quiet {
 			spin = spinner.New(spinner.CharSets[11], 100*time.Millisecond)
 			spin.FinalMSG = "done"
-			logger = &log.SpinnerLogger{
+			setLogger(&log.SpinnerLogger{
 				Spinner: spin,
-			}
+			})
 		}
 
 		stackManager := stacks.NewStackManager(logger)
 		if len(args) == 0 {
 			return errors.New("no stack specified")
 		}
+		if startOptions.PullConcurrency < 1 {
+			return errors.New("--pull-concurrency must be at least 1")
+		}
 		stackName := args[0]
 
 		if err := stackManager.LoadStack(stackName); err != nil {
@@ -59,7 +91,7 @@ This command will start a stack and run it in the background.
 		if runBefore, err := stackManager.StackHasRunBefore(); err != nil {
 			return err
 		} else if !runBefore {
-			fmt.Println("this will take a few seconds longer since this is the first time you're running this stack...")
+			logger.Info("this will take a few seconds longer since this is the first time you're running this stack")
 		}
 
 		if spin != nil {
@@ -71,17 +103,112 @@ This command will start a stack and run it in the background.
 		if spin != nil {
 			spin.Stop()
 		}
-		fmt.Print("\n\n")
+		if spin != nil {
+			fmt.Print("\n")
+		}
+		if problems, err := stackManager.GetContainerProblems(verbose); err == nil {
+			for _, p := range problems {
+				logger.Warn(p.String())
+			}
+		}
+		fmt.Printf("Stack '%s' started\n", stackName)
+		if quiet {
+			return nil
+		}
+		fmt.Print("\n")
 		for _, member := range stackManager.Stack.Members {
-			fmt.Printf("Web UI for member '%v': http://127.0.0.1:%v/ui\n", member.ID, member.ExposedFireflyPort)
+			if !stackManager.Stack.NoUI {
+				fmt.Printf("Web UI for member '%v': %s/ui\n", member.ID, member.FireflyURL())
+			}
+			if url := sandbox.GetSandboxURL(member); url != "" {
+				fmt.Printf("Sandbox for member '%v': %s\n", member.ID, url)
+			}
 		}
 		fmt.Printf("\nTo see logs for your stack run:\n\n%s logs %s\n\n", rootCmd.Use, stackName)
 		return nil
 	},
 }
 
+// startStacks starts several stacks at once, and reports whether each of them is ready
+func startStacks(stackNames []string) error {
+	if len(stackNames) == 0 {
+		return errors.New("no stack specified")
+	}
+	if startOptions.PullConcurrency < 1 {
+		return errors.New("--pull-concurrency must be at least 1")
+	}
+	after := make(map[string][]string, len(startAfter))
+	for _, order := range startAfter {
+		parts := strings.SplitN(order, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid start order '%s' - use <stack_name>=<stack_it_waits_for>[,...]", order)
+		}
+		after[parts[0]] = append(after[parts[0]], strings.Split(parts[1], ",")...)
+	}
+	results, err := stacks.StartStacks(logger, stackNames, after, verbose, &startOptions)
+	if err != nil {
+		return err
+	}
+
+	notStarted := 0
+	for _, result := range results {
+		if result.Status == stacks.MultiStartFailed || result.Status == stacks.MultiStartSkipped {
+			notStarted++
+		}
+	}
+	if structuredOutput() {
+		if err := printStructured(results); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print("\n")
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "STACK\tSTATUS\tTIME\tDETAILS")
+		for _, result := range results {
+			details := result.Error
+			if len(result.Problems) > 0 {
+				details = strings.Join(result.Problems, "; ")
+			}
+			duration := "-"
+			if result.DurationMS > 0 {
+				duration = (time.Duration(result.DurationMS) * time.Millisecond).Round(time.Second).String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.Stack, result.Status, duration, details)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if !quiet {
+			fmt.Print("\n")
+			for _, result := range results {
+				ids := make([]string, 0, len(result.UI))
+				for id := range result.UI {
+					ids = append(ids, id)
+				}
+				sort.Strings(ids)
+				for _, id := range ids {
+					fmt.Printf("Web UI for member '%s' of '%s': %s\n", id, result.Stack, result.UI[id])
+					if url, ok := result.Sandbox[id]; ok {
+						fmt.Printf("Sandbox for member '%s' of '%s': %s\n", id, result.Stack, url)
+					}
+				}
+			}
+			fmt.Print("\n")
+		}
+	}
+	if notStarted > 0 {
+		return fmt.Errorf("%d of %d stacks did not start", notStarted, len(results))
+	}
+	return nil
+}
+
 func init() {
+	startCmd.Flags().StringArrayVar(&startAfter, "after", nil, "When starting several stacks, start a stack only once the stacks it waits for are ready, given as <stack_name>=<stack_it_waits_for>[,...]")
 	startCmd.Flags().BoolVarP(&startOptions.NoPull, "no-pull", "n", false, "Do not pull latest images when starting")
+	startCmd.Flags().StringVar(&startOptions.PullPolicy, "pull", "", fmt.Sprintf("When to pull images before starting, instead of the pull policy of the stack. Options are: %v", stacks.PullPolicyStrings))
+	startCmd.Flags().IntVar(&startOptions.PullConcurrency, "pull-concurrency", stacks.DefaultPullConcurrency, "Number of images to pull at once before first time setup")
+	startCmd.Flags().BoolVar(&startOptions.Wait, "wait", false, "Wait until the FireFly node of each member is ready to use, failing if it is not ready within --timeout")
+	startCmd.Flags().DurationVar(&startOptions.WaitTimeout, "timeout", 2*time.Minute, "How long to wait for the stack to be ready - implies --wait, and 0 waits forever")
 	startCmd.Flags().BoolVarP(&startOptions.NoRollback, "no-rollback", "b", false, "Do not automatically rollback changes if first time setup fails")
 
 	rootCmd.AddCommand(startCmd)
@@ -0,0 +1,67 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Manage where the state of stacks is kept",
+	Long: `Manage where the state of stacks is kept
+
+By default, the state of each stack is kept in the stack.json file in
+its directory. With the single-file state store, the state of every
+stack is kept in ~/.firefly/state.json instead, which is locked while it
+is changed, and only that file is read to list the stacks. The state
+store is chosen with --state-store, usually set once in the config file.`,
+}
+
+var stateMigrateCmd = &cobra.Command{
+	Use:   "migrate <state_store>",
+	Short: "Move the state of every stack to another state store",
+	Long: `Move the state of every stack to another state store
+
+The state of each stack is moved from the current state store (chosen
+with --state-store) to the given one. Afterwards, set state-store in
+the config file so that every command uses the new store.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if args[0] == stateStoreSelection {
+			return fmt.Errorf("the state of stacks is already kept in the %s state store", args[0])
+		}
+		moved, err := stacks.MigrateState(args[0])
+		if err != nil {
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(moved)
+		}
+		fmt.Printf("moved the state of %d stacks to the %s state store\n", len(moved), args[0])
+		fmt.Printf("\nTo keep using these stacks, add this line to your config file (~/.firefly/config.yaml by default):\n\n    state-store: %s\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	stateCmd.AddCommand(stateMigrateCmd)
+	rootCmd.AddCommand(stateCmd)
+}
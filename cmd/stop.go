@@ -44,11 +44,11 @@ var stopCmd = &cobra.Command{
 			return err
 		}
 
-		fmt.Printf("stopping stack '%s'... ", stackName)
+		logger.Info(fmt.Sprintf("stopping stack '%s'", stackName))
 		if err := stackManager.StopStack(verbose); err != nil {
 			return err
 		}
-		fmt.Print("done\n")
+		fmt.Printf("Stack '%s' stopped\n", stackName)
 		return nil
 	},
 }
@@ -0,0 +1,90 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var supportBundleRedact bool
+
+var supportBundleCmd = &cobra.Command{
+	Use:   "support-bundle <stack_name> [archive_file]",
+	Short: "Collect diagnostics from every member into an archive, for filing FireFly core bugs",
+	Long: `Collect diagnostics from every member into an archive, for filing FireFly core bugs
+
+The archive contains the stack definition, compose file and FireFly core
+configs with their keys and passwords removed, the state and recent logs of
+every container, and from the FireFly core of each member its status, batch
+manager and plugin states, recent operations, events, messages and
+transactions, and the number of rows in each collection. With --redact, the
+payloads of data, operations and blockchain events are removed as well. The
+logs are included as they are.
+
+The archive is named after the stack and the time it was collected, unless
+another file is given. Members that cannot be reached are listed in
+bundle.json, rather than stopping the collection.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		stackName := args[0]
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+		archiveFile := fmt.Sprintf("%s-support-%s.tar.gz", stackName, time.Now().Format("20060102-150405"))
+		if len(args) > 1 {
+			archiveFile = args[1]
+		}
+
+		f, err := os.Create(archiveFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		bundle, err := stackManager.WriteSupportBundle(f, supportBundleRedact, verbose)
+		if err != nil {
+			f.Close()
+			os.Remove(archiveFile)
+			return err
+		}
+		if structuredOutput() {
+			return printStructured(bundle)
+		}
+		for _, m := range bundle.Members {
+			for _, e := range m.Errors {
+				fmt.Printf("WARNING: member %s: %s\n", m.Member, e)
+			}
+		}
+		for _, e := range bundle.Errors {
+			fmt.Printf("WARNING: %s\n", e)
+		}
+		fmt.Printf("Support bundle for stack '%s' written to %s\n", stackName, archiveFile)
+		return nil
+	},
+}
+
+func init() {
+	supportBundleCmd.Flags().BoolVar(&supportBundleRedact, "redact", false, "Remove the payloads of data, operations and blockchain events")
+	rootCmd.AddCommand(supportBundleCmd)
+}
@@ -0,0 +1,119 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"github.com/spf13/cobra"
+)
+
+var tenantCredentials bool
+
+var tenantCmd = &cobra.Command{
+	Use:   "tenant",
+	Short: "Manage developers sharing a stack",
+	Long: `Manage developers sharing a stack
+
+Each tenant gets their own FireFly namespace, so a team can share one
+long-lived stack without their messages, data and tokens getting mixed up.`,
+}
+
+var tenantAddCmd = &cobra.Command{
+	Use:   "add <stack_name> <dev_name>",
+	Short: "Add a developer to a shared stack",
+	Long: `Add a developer to a shared stack
+
+A namespace named after the developer is created on the network. If the
+stack has been run before, it must be running, otherwise the namespace is
+created when the stack is first started.
+
+With --credentials, a username and password are generated, and the edge
+proxy requires them for any request to the developer's namespace. This
+needs a stack initialized with --domain, and only applies to requests
+made through the proxy.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) < 2 {
+			return fmt.Errorf("a stack name and developer name must be specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+
+		tenant, err := stackManager.AddTenant(args[1], tenantCredentials, verbose)
+		if err != nil {
+			return err
+		}
+		info := stackManager.GetTenantInfo(tenant)
+		if structuredOutput() {
+			return printStructured(info)
+		}
+		fmt.Printf("added tenant %s to stack '%s'\n\n", info.Name, args[0])
+		for _, api := range info.APIs {
+			fmt.Printf("FireFly API: %s\n", api)
+		}
+		if info.Username != "" {
+			fmt.Printf("\nUsername: %s\nPassword: %s\n", info.Username, info.Password)
+		}
+		fmt.Println()
+		return nil
+	},
+}
+
+var tenantListCmd = &cobra.Command{
+	Use:     "list <stack_name>",
+	Aliases: []string{"ls"},
+	Short:   "List the developers sharing a stack",
+	Long:    `List the developers sharing a stack, and their namespaces`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		stackManager := stacks.NewStackManager(logger)
+		if len(args) == 0 {
+			return fmt.Errorf("no stack specified")
+		}
+		if err := stackManager.LoadStack(args[0]); err != nil {
+			return err
+		}
+
+		tenants := stackManager.GetTenants()
+		if structuredOutput() {
+			return printStructured(tenants)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "TENANT\tNAMESPACE\tCREDENTIALS")
+		for _, t := range tenants {
+			credentials := "-"
+			if t.Username != "" {
+				credentials = t.Username
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", t.Name, t.Namespace, credentials)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	tenantAddCmd.Flags().BoolVar(&tenantCredentials, "credentials", false, "Generate credentials that the edge proxy requires for the tenant's namespace")
+
+	tenantCmd.AddCommand(tenantAddCmd)
+	tenantCmd.AddCommand(tenantListCmd)
+	rootCmd.AddCommand(tenantCmd)
+}
@@ -0,0 +1,70 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+var noBrowser bool
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Open a web dashboard for managing stacks",
+	Long: `Open a web dashboard for managing stacks
+
+The dashboard lists your stacks and their status, creates stacks from
+templates, shows the endpoints and logs of a stack, and starts, stops,
+resets and upgrades stacks while showing their progress. It is served
+by the same local API as the daemon command, and runs until interrupted
+with Ctrl+C. The browser is given the API's token when it is opened, and
+every request the dashboard makes sends it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDaemon(func(url, token string, generated bool) {
+			fmt.Printf("FireFly dashboard running at %s - press Ctrl+C to stop\n", url)
+			if !noBrowser {
+				// The token is passed in the fragment, which the browser never sends to the server, and
+				// the dashboard removes from the address bar as soon as it has read it
+				if err := openBrowser(fmt.Sprintf("%s/#token=%s", url, token)); err != nil {
+					fmt.Printf("unable to open a browser: %s\n", err)
+				}
+			}
+		})
+	},
+}
+
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+func init() {
+	addDaemonFlags(uiCmd)
+	uiCmd.Flags().BoolVar(&noBrowser, "no-browser", false, "Do not open the dashboard in a browser")
+	rootCmd.AddCommand(uiCmd)
+}
@@ -18,24 +18,58 @@ package cmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/hyperledger/firefly-cli/internal/stacks"
 	"github.com/spf13/cobra"
 )
 
+var statelessOnly bool
+var upgradeRelease string
+var skipAPICheck bool
+var apiCheckTimeout time.Duration
+
 var upgradeCmd = &cobra.Command{
 	Use:   "upgrade <stack_name>",
 	Short: "Upgrade a stack",
 	Long: `Upgrade a stack by pulling newer images.
-	This operation will restart the stack if running.
-	If certain containers were pinned to a specific image at init,
-	this command will have no effect on those containers.`,
+
+The stack configuration and docker compose file are migrated to the
+format used by this version of the CLI, and FireFly core config is
+regenerated. If the stack is running, its containers are recreated
+from the new images. All data is kept, as it is stored in volumes.
+If certain containers were pinned to a specific image at init,
+this command will have no effect on those containers.
+
+Stacks pinned to the stable or head release move to its current
+component versions, and --release pins a stack to another release.
+The release notes of each component whose version changes are shown
+first, and if any mention breaking changes, the upgrade must be
+confirmed.
+
+Stateless services, such as the tokens connectors, are upgraded
+first and without downtime: a standby container serves requests from
+the rest of the stack while each one is recreated. Use --stateless-only
+to upgrade just those services and leave the rest of the stack as it
+is.
+
+If the stack is running, the namespaces, token connectors and contract
+APIs offered by the FireFly API of each member are recorded before the
+upgrade, and checked once the upgraded nodes are ready. Anything that no
+longer works is reported, and the command fails. Use --skip-api-check to
+upgrade without the check.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		stackManager := stacks.NewStackManager(logger)
 		if len(args) == 0 {
 			return fmt.Errorf("no stack specified")
 		}
 		stackName := args[0]
+		if offline {
+			return fmt.Errorf("cannot upgrade stack '%s' while running offline, as the new images must be pulled", stackName)
+		}
 		if exists, err := stacks.CheckExists(stackName); err != nil {
 			return err
 		} else if !exists {
@@ -45,15 +79,82 @@ var upgradeCmd = &cobra.Command{
 		if err := stackManager.LoadStack(stackName); err != nil {
 			return err
 		}
+		manifest, err := stackManager.GetUpgradeManifest(upgradeRelease)
+		if err != nil {
+			return err
+		}
+		changes := stackManager.GetVersionChanges(manifest)
+		printVersionChanges(changes)
+		if stacks.HasBreakingChanges(changes) && !force {
+			if err := confirm(fmt.Sprintf("upgrade stack '%s' despite breaking changes", stackName)); err != nil {
+				cancel()
+			}
+		}
+
+		checkAPI := false
+		if !skipAPICheck {
+			if state, err := stackManager.State(verbose); err != nil {
+				return err
+			} else if state == stacks.StateRunning {
+				if err := stackManager.RecordAPIExpectations(); err != nil {
+					logger.Warn(fmt.Sprintf("the FireFly API will not be checked after the upgrade: %s", err))
+				} else {
+					checkAPI = true
+				}
+			}
+		}
+
 		fmt.Printf("upgrading stack '%s'... ", stackName)
-		if err := stackManager.UpgradeStack(verbose); err != nil {
+		if err := stackManager.UpgradeStack(manifest, statelessOnly, verbose); err != nil {
 			return err
 		}
-		fmt.Printf("done\n\nYour stack has been upgraded. To start your upgraded stack run:\n\n%s start %s\n\n", rootCmd.Use, stackName)
+		fmt.Printf("done\n\nYour stack has been upgraded. If it was not running, start your upgraded stack with:\n\n%s start %s\n\n", rootCmd.Use, stackName)
+		if checkAPI {
+			incompatibilities, err := stackManager.CheckAPICompatibility(apiCheckTimeout)
+			if err != nil {
+				return fmt.Errorf("failed to check the FireFly API after upgrading stack '%s': %s", stackName, err)
+			}
+			if len(incompatibilities) > 0 {
+				printAPIIncompatibilities(incompatibilities)
+				return fmt.Errorf("%d things offered by the FireFly API before upgrading stack '%s' no longer work", len(incompatibilities), stackName)
+			}
+			fmt.Println("The FireFly API of each member offers everything it did before the upgrade")
+		}
 		return nil
 	},
 }
 
+func printVersionChanges(changes []*stacks.VersionChange) {
+	for _, change := range changes {
+		fmt.Printf("%s: %s -> %s\n", change.Component, change.From, change.To)
+		for _, notes := range change.ReleaseNotes {
+			fmt.Printf("\n  %s %s\n", notes.Tag, notes.URL)
+			for _, breaking := range notes.BreakingChanges {
+				fmt.Printf("  BREAKING: %s\n", breaking)
+			}
+			if notes.Body != "" {
+				fmt.Printf("\n    %s\n", strings.ReplaceAll(notes.Body, "\n", "\n    "))
+			}
+		}
+		fmt.Println()
+	}
+}
+
+func printAPIIncompatibilities(incompatibilities []*stacks.APIIncompatibility) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "MEMBER\tKIND\tNAME\tPROBLEM")
+	for _, incompatibility := range incompatibilities {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", incompatibility.Member, incompatibility.Kind, incompatibility.Name, incompatibility.Problem)
+	}
+	w.Flush()
+	fmt.Println()
+}
+
 func init() {
+	upgradeCmd.Flags().StringVarP(&upgradeRelease, "release", "r", "", "Pin the stack to a release. Options are: stable, head, or a version in the form vX.Y.Z")
+	upgradeCmd.Flags().BoolVarP(&force, "force", "f", false, "Upgrade without prompting for confirmation of breaking changes")
+	upgradeCmd.Flags().BoolVarP(&statelessOnly, "stateless-only", "", false, "Only upgrade stateless services, each without downtime")
+	upgradeCmd.Flags().BoolVarP(&skipAPICheck, "skip-api-check", "", false, "Do not check that the FireFly API of each member still offers what it did before the upgrade")
+	upgradeCmd.Flags().DurationVarP(&apiCheckTimeout, "api-check-timeout", "", 2*time.Minute, "How long to wait for the upgraded FireFly nodes to be ready before checking their API")
 	rootCmd.AddCommand(upgradeCmd)
 }
@@ -30,4 +30,9 @@ type IBlockchainProvider interface {
 	PostStart() error
 	GetDockerServiceDefinitions() []*docker.ServiceDefinition
 	GetFireflyConfig(m *types.Member) *core.BlockchainConfig
+	ImportAccount(account *types.Account) error
+	AddMember(member *types.Member) error
+	// GetInFlightTransactionCount returns how many of the requests that FireFly core has sent to the member's
+	// blockchain connector, by request ID, have no receipt yet
+	GetInFlightTransactionCount(member *types.Member, requestIDs []string) (int, error)
 }
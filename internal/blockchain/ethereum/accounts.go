@@ -0,0 +1,73 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethereum
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	secp256k1 "github.com/btcsuite/btcd/btcec"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+	"golang.org/x/crypto/sha3"
+)
+
+func GenerateAccount() *types.Account {
+	return GenerateAccountFromSource(rand.Reader)
+}
+
+// GenerateAccountFromSource generates an account whose private key is read from the given source of
+// randomness, so a deterministic source always generates the same accounts
+func GenerateAccountFromSource(random io.Reader) *types.Account {
+	curve := secp256k1.S256()
+	keyBytes := make([]byte, 32)
+	d := new(big.Int)
+	for d.Sign() == 0 || d.Cmp(curve.N) >= 0 {
+		if _, err := io.ReadFull(random, keyBytes); err != nil {
+			panic(err)
+		}
+		d.SetBytes(keyBytes)
+	}
+	privateKey, _ := secp256k1.PrivKeyFromBytes(curve, keyBytes)
+	privateKeyBytes := privateKey.Serialize()
+	encodedPrivateKey := "0x" + hex.EncodeToString(privateKeyBytes)
+	// Remove the "04" Suffix byte when computing the address. This byte indicates that it is an uncompressed public key.
+	publicKeyBytes := privateKey.PubKey().SerializeUncompressed()[1:]
+	// Take the hash of the public key to generate the address
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(publicKeyBytes)
+	// Ethereum addresses only use the lower 20 bytes, so toss the rest away
+	encodedAddress := "0x" + hex.EncodeToString(hash.Sum(nil)[12:32])
+	return &types.Account{
+		Address:    encodedAddress,
+		PrivateKey: encodedPrivateKey,
+	}
+}
+
+// NodeID returns the ID in the enode URL of a node with the given node key, which is its uncompressed
+// public key without the "04" prefix
+func NodeID(nodeKey string) (string, error) {
+	keyBytes, err := hex.DecodeString(strings.TrimPrefix(nodeKey, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("invalid node key: %s", err)
+	}
+	privateKey, _ := secp256k1.PrivKeyFromBytes(secp256k1.S256(), keyBytes)
+	return hex.EncodeToString(privateKey.PubKey().SerializeUncompressed()[1:]), nil
+}
@@ -17,6 +17,8 @@
 package besu
 
 import (
+	"errors"
+
 	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
 	"github.com/hyperledger/firefly-cli/internal/core"
 	"github.com/hyperledger/firefly-cli/internal/docker"
@@ -62,3 +64,15 @@ func (p *BesuProvider) GetDockerServiceDefinitions() []*docker.ServiceDefinition
 func (p *BesuProvider) GetFireflyConfig(m *types.Member) *core.BlockchainConfig {
 	return &core.BlockchainConfig{}
 }
+
+func (p *BesuProvider) ImportAccount(account *types.Account) error {
+	return errors.New("creating accounts is not yet supported for besu")
+}
+
+func (p *BesuProvider) AddMember(member *types.Member) error {
+	return errors.New("adding members is not yet supported for besu")
+}
+
+func (p *BesuProvider) GetInFlightTransactionCount(member *types.Member, requestIDs []string) (int, error) {
+	return 0, errors.New("inspecting pending transactions is not yet supported for besu")
+}
@@ -34,7 +34,7 @@ func DeployContracts(s *types.Stack, log log.Logger, verbose bool) error {
 	var containerName string
 	for _, member := range s.Members {
 		if !member.External {
-			containerName = fmt.Sprintf("%s_firefly_core_%s_1", s.Name, member.ID)
+			containerName = docker.ContainerName(s.Name, fmt.Sprintf("firefly_core_%s", member.ID))
 			break
 		}
 	}
@@ -52,34 +52,77 @@ func DeployContracts(s *types.Stack, log log.Logger, verbose bool) error {
 		return err
 	}
 
-	var fireflyContractAddress string
+	// TODO: version the registered name
+	return DeployOrRegisterContract(s, fireflyContract, "firefly", map[string]string{}, log)
+}
+
+// DeployOrRegisterContract deploys the contract using the first member, and registers it under the
+// same name with the ethconnect instance of every other member. Members which already have the
+// contract registered are skipped, so this is safe to call again after members are added to a stack.
+func DeployOrRegisterContract(s *types.Stack, contract *types.Contract, name string, args map[string]string, log log.Logger) error {
+	var contractAddress string
+	registered := make(map[string]bool)
 	for _, member := range s.Members {
-		if fireflyContractAddress == "" {
-			// TODO: version the registered name
-			log.Info(fmt.Sprintf("deploying firefly contract on '%s'", member.ID))
-			fireflyContractAddress, err = DeployContract(member, fireflyContract, "firefly", map[string]string{})
+		address, err := GetRegisteredContractAddress(member, name)
+		if err != nil {
+			return err
+		}
+		if address != "" {
+			contractAddress = address
+			registered[member.ID] = true
+		}
+	}
+
+	for _, member := range s.Members {
+		if registered[member.ID] {
+			continue
+		}
+		if contractAddress == "" {
+			log.Info(fmt.Sprintf("deploying %s contract on '%s'", name, member.ID))
+			address, err := DeployContract(member, contract, name, args)
 			if err != nil {
 				return err
 			}
+			contractAddress = address
 		} else {
-			log.Info(fmt.Sprintf("registering firefly contract on '%s'", member.ID))
-			err = RegisterContract(member, fireflyContract, fireflyContractAddress, "firefly", map[string]string{})
-			if err != nil {
+			log.Info(fmt.Sprintf("registering %s contract on '%s'", name, member.ID))
+			if err := RegisterContract(member, contract, contractAddress, name, args); err != nil {
 				return err
 			}
 		}
 	}
-
 	return nil
 }
 
+// GetRegisteredContractAddress returns the address of the contract registered under the given name
+// with the ethconnect instance of the member, or an empty string if there is no such contract
+func GetRegisteredContractAddress(member *types.Member, name string) (string, error) {
+	ethconnectUrl := fmt.Sprintf("http://%s:%v", member.Host(), member.ExposedEthconnectPort)
+	contracts, err := ethconnect.GetContracts(ethconnectUrl)
+	if err != nil {
+		return "", err
+	}
+	for _, contract := range contracts {
+		if contract.RegisteredAs == name {
+			return contract.Address, nil
+		}
+	}
+	return "", nil
+}
+
 func ReadCompiledContract(filePath string) (*types.Contract, error) {
-	d, _ := ioutil.ReadFile(filePath)
+	d, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
 	var contract *types.Contract
-	err := json.Unmarshal(d, &contract)
+	err = json.Unmarshal(d, &contract)
 	if err != nil {
 		return nil, err
 	}
+	if contract.Bytecode == "" || contract.Bytecode == "0x" {
+		return nil, fmt.Errorf("%s does not contain any bytecode", filePath)
+	}
 	return contract, nil
 }
 
@@ -92,7 +135,7 @@ func ExtractContracts(stackName string, containerName string, dirName string, ve
 }
 
 func DeployContract(member *types.Member, contract *types.Contract, name string, args map[string]string) (string, error) {
-	ethconnectUrl := fmt.Sprintf("http://127.0.0.1:%v", member.ExposedEthconnectPort)
+	ethconnectUrl := fmt.Sprintf("http://%s:%v", member.Host(), member.ExposedEthconnectPort)
 	abiResponse, err := ethconnect.PublishABI(ethconnectUrl, contract)
 	if err != nil {
 		return "", err
@@ -105,7 +148,7 @@ func DeployContract(member *types.Member, contract *types.Contract, name string,
 }
 
 func RegisterContract(member *types.Member, contract *types.Contract, contractAddress string, name string, args map[string]string) error {
-	ethconnectUrl := fmt.Sprintf("http://127.0.0.1:%v", member.ExposedEthconnectPort)
+	ethconnectUrl := fmt.Sprintf("http://%s:%v", member.Host(), member.ExposedEthconnectPort)
 	abiResponse, err := ethconnect.PublishABI(ethconnectUrl, contract)
 	if err != nil {
 		return err
@@ -178,3 +178,102 @@ func RegisterContract(ethconnectUrl string, abiId string, contractAddress string
 	json.Unmarshal(responseBody, &registerResponseBody)
 	return registerResponseBody, nil
 }
+
+// HasReceipt returns whether ethconnect has stored a receipt for the request with the ID
+func HasReceipt(ethconnectUrl string, requestID string) (bool, error) {
+	u, err := url.Parse(ethconnectUrl)
+	if err != nil {
+		return false, err
+	}
+	u, err = u.Parse(path.Join("replies", requestID))
+	if err != nil {
+		return false, err
+	}
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		responseBody, _ := ioutil.ReadAll(resp.Body)
+		return false, fmt.Errorf("%d %s", resp.StatusCode, responseBody)
+	}
+}
+
+type ContractInfo struct {
+	Address      string `json:"address,omitempty"`
+	Path         string `json:"path,omitempty"`
+	ABI          string `json:"abi,omitempty"`
+	OpenAPI      string `json:"openapi,omitempty"`
+	RegisteredAs string `json:"registeredAs,omitempty"`
+}
+
+func GetContracts(ethconnectUrl string) ([]*ContractInfo, error) {
+	u, err := url.Parse(ethconnectUrl)
+	if err != nil {
+		return nil, err
+	}
+	u, err = u.Parse("contracts")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%d %s", resp.StatusCode, responseBody)
+	}
+	var contracts []*ContractInfo
+	err = json.Unmarshal(responseBody, &contracts)
+	return contracts, err
+}
+
+// InvokeContract sends a transaction to a method of a contract registered under the given name, and waits for it to be mined
+func InvokeContract(ethconnectUrl string, registeredName string, method string, fromAddress string, params map[string]interface{}) (map[string]interface{}, error) {
+	u, err := url.Parse(ethconnectUrl)
+	if err != nil {
+		return nil, err
+	}
+	u, err = u.Parse(path.Join("contracts", registeredName, method))
+	if err != nil {
+		return nil, err
+	}
+	requestBody, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", u.String(), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-firefly-from", fromAddress)
+	req.Header.Set("x-firefly-sync", "true")
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("%d %s", resp.StatusCode, responseBody)
+	}
+	var receipt map[string]interface{}
+	err = json.Unmarshal(responseBody, &receipt)
+	return receipt, err
+}
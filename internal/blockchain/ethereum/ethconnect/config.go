@@ -0,0 +1,121 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethconnect
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/secrets"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigOverridesFile is the file in the stack directory whose ethconnect config is merged into the
+// generated config of each member's ethconnect, every time it is written
+const ConfigOverridesFile = "ethconnect-config-overrides.yml"
+
+func overridesPath(stack *types.Stack) string {
+	return filepath.Join(constants.StacksDir, stack.Name, ConfigOverridesFile)
+}
+
+// ConfigPath is the file the config of the member's ethconnect is generated in
+func ConfigPath(stack *types.Stack, member *types.Member) string {
+	return filepath.Join(constants.StacksDir, stack.Name, "blockchain", fmt.Sprintf("ethconnect_%s.yml", member.ID))
+}
+
+// HasConfig returns whether ethconnect is tuned for the stack, in which case each member's ethconnect is
+// started with a config file of its own
+func HasConfig(stack *types.Stack) bool {
+	if stack.Ethconnect != nil {
+		return true
+	}
+	_, err := os.Stat(overridesPath(stack))
+	return err == nil
+}
+
+// ReadConfigOverrides reads a YAML file of ethconnect config to merge into generated configs
+func ReadConfigOverrides(filePath string) (map[interface{}]interface{}, error) {
+	d, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var overrides map[interface{}]interface{}
+	if err := yaml.Unmarshal(d, &overrides); err != nil {
+		return nil, fmt.Errorf("%s is not a YAML map of ethconnect config: %s", filePath, err)
+	}
+	return overrides, nil
+}
+
+// BuildConfig returns the YAML config of the ethconnect of every member, with the stack's overrides merged in
+func BuildConfig(stack *types.Stack) ([]byte, error) {
+	overrides, err := ReadConfigOverrides(overridesPath(stack))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	rest := map[interface{}]interface{}{}
+	if settings := stack.Ethconnect; settings != nil {
+		if settings.MaxInFlight > 0 {
+			rest["maxInFlight"] = settings.MaxInFlight
+		}
+		if settings.MaxTXWaitTime > 0 {
+			rest["maxTXWaitTime"] = settings.MaxTXWaitTime
+		}
+		if settings.ReceiptMaxDocs > 0 {
+			rest["memstore"] = map[interface{}]interface{}{"maxDocs": settings.ReceiptMaxDocs}
+		}
+	}
+	return yaml.Marshal(core.MergeConfigMaps(map[interface{}]interface{}{"rest": rest}, overrides))
+}
+
+// WriteConfig writes the config file of each member's ethconnect, with the stack's overrides merged in
+func WriteConfig(stack *types.Stack) error {
+	if !HasConfig(stack) {
+		return nil
+	}
+	d, err := BuildConfig(stack)
+	if err != nil {
+		return err
+	}
+	for _, member := range stack.Members {
+		if err := ioutil.WriteFile(ConfigPath(stack, member), d, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CopyConfigToVolumes copies the config file of each member's ethconnect into the volume it is read from
+func CopyConfigToVolumes(stack *types.Stack, verbose bool) error {
+	if !HasConfig(stack) {
+		return nil
+	}
+	for _, member := range stack.Members {
+		if member.External {
+			continue
+		}
+		volumeName := fmt.Sprintf("%s_ethconnect_config_%s", stack.Name, member.ID)
+		if err := secrets.CopyFileToVolume(volumeName, ConfigPath(stack, member), "ethconnect.yml", verbose); err != nil {
+			return err
+		}
+	}
+	return nil
+}
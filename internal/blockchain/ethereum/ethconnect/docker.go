@@ -23,15 +23,18 @@ import (
 	"github.com/hyperledger/firefly-cli/pkg/types"
 )
 
-func GetEthconnectServiceDefinitions(members []*types.Member) []*docker.ServiceDefinition {
-	serviceDefinitions := make([]*docker.ServiceDefinition, len(members))
-	for i, member := range members {
+func GetEthconnectServiceDefinitions(stack *types.Stack) []*docker.ServiceDefinition {
+	serviceDefinitions := make([]*docker.ServiceDefinition, len(stack.Members))
+	for i, member := range stack.Members {
+		// Transactions go to the member's signer, if it has one, which submits them to the member's node
+		endpoint := stack.TransactionEndpoint(member)
+		command := fmt.Sprintf("rest -U http://127.0.0.1:8080 -I ./abis -r http://%s:8545 -E ./events -d 3", stack.ServiceHost(endpoint))
 		serviceDefinitions[i] = &docker.ServiceDefinition{
 			ServiceName: "ethconnect_" + member.ID,
 			Service: &docker.Service{
-				Image:     "ghcr.io/hyperledger/firefly-ethconnect:latest",
-				Command:   "rest -U http://127.0.0.1:8080 -I ./abis -r http://geth:8545 -E ./events -d 3",
-				DependsOn: map[string]map[string]string{"geth": {"condition": "service_started"}},
+				Image:     stack.GetImage(types.EthconnectComponent),
+				Command:   command,
+				DependsOn: map[string]map[string]string{endpoint: {"condition": "service_started"}},
 				Ports:     []string{fmt.Sprintf("%d:8080", member.ExposedEthconnectPort)},
 				Volumes: []string{
 					fmt.Sprintf("ethconnect_abis_%s:/ethconnect/abis", member.ID),
@@ -41,6 +44,12 @@ func GetEthconnectServiceDefinitions(members []*types.Member) []*docker.ServiceD
 			},
 			VolumeNames: []string{"ethconnect_abis_" + member.ID, "ethconnect_events_" + member.ID},
 		}
+		if HasConfig(stack) {
+			service := serviceDefinitions[i].Service
+			service.Command = command + " -f ./config/ethconnect.yml"
+			service.Volumes = append(service.Volumes, fmt.Sprintf("ethconnect_config_%s:/ethconnect/config", member.ID))
+			serviceDefinitions[i].VolumeNames = append(serviceDefinitions[i].VolumeNames, "ethconnect_config_"+member.ID)
+		}
 	}
 	return serviceDefinitions
 }
@@ -0,0 +1,123 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ethsigner
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// vaultScript starts a Vault dev server, whose storage is in memory, and loads the key of each member into
+// it every time the container starts. The container is healthy once the keys are loaded.
+const vaultScript = `export VAULT_TOKEN=$$(cat /keys/token)
+vault server -dev -dev-root-token-id="$$VAULT_TOKEN" -dev-listen-address=0.0.0.0:8200 &
+until vault status > /dev/null 2>&1; do sleep 1; done
+for key in /keys/member_*; do vault kv put secret/firefly/$$(basename $$key) value=$$(cat $$key) > /dev/null; done
+touch /tmp/ready
+wait`
+
+// keystoreScript converts a member's raw private key to the encrypted V3 keystore file ethsigner reads
+const keystoreScript = `geth --nousb account import --password /data/password --keystore /tmp/keystore /data/keyfile > /dev/null && mv /tmp/keystore/UTC--* /data/key.json && rm /data/keyfile`
+
+// FirstTimeSetup gives each member's signer its key. The key directory holds the private key of each member
+// in <member>/keyfile, the password to encrypt them with, and the token of the stack's Vault server, as
+// written by the blockchain provider.
+func FirstTimeSetup(stack *types.Stack, keyDir string, verbose bool) error {
+	if stack.Signer == types.SignerVault {
+		volumeName := fmt.Sprintf("%s_vault_keys", stack.Name)
+		if err := docker.CopyFileToVolume(volumeName, filepath.Join(keyDir, "vault-token"), "token", verbose); err != nil {
+			return err
+		}
+		for _, member := range stack.Members {
+			if err := docker.CopyFileToVolume(volumeName, filepath.Join(keyDir, member.ID, "keyfile"), "member_"+member.ID, verbose); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, member := range stack.Members {
+		volumeName := fmt.Sprintf("%s_ethsigner_%s", stack.Name, member.ID)
+		// The key and password are copied into the signer's volume, as the key directory is not on the docker
+		// host when it is remote
+		if err := docker.CopyFileToVolume(volumeName, filepath.Join(keyDir, "password"), "password", verbose); err != nil {
+			return err
+		}
+		if err := docker.CopyFileToVolume(volumeName, filepath.Join(keyDir, member.ID, "keyfile"), "keyfile", verbose); err != nil {
+			return err
+		}
+		if err := docker.RunDockerCommand(constants.StacksDir, verbose, verbose, "run", "--rm", "-v", fmt.Sprintf("%s:/data", volumeName), "--entrypoint", "sh", stack.GetImage(types.GethComponent), "-c", keystoreScript); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetServiceDefinitions returns an ethsigner for each member, which signs the member's transactions and
+// submits them to the member's blockchain node, and the Vault server holding the keys if the stack has one
+func GetServiceDefinitions(stack *types.Stack) []*docker.ServiceDefinition {
+	if stack.Signer == "" {
+		return nil
+	}
+	serviceDefinitions := make([]*docker.ServiceDefinition, 0, len(stack.Members)+1)
+	if stack.Signer == types.SignerVault {
+		serviceDefinitions = append(serviceDefinitions, &docker.ServiceDefinition{
+			ServiceName: "vault",
+			Service: &docker.Service{
+				Image:       stack.GetImage(types.VaultComponent),
+				Entrypoint:  []string{"sh", "-c", vaultScript},
+				Environment: map[string]string{"VAULT_ADDR": "http://127.0.0.1:8200", "SKIP_SETCAP": "true"},
+				Volumes:     []string{"vault_keys:/keys"},
+				HealthCheck: &docker.HealthCheck{
+					Test:     []string{"CMD-SHELL", "test -f /tmp/ready"},
+					Interval: "2s",
+					Timeout:  "2s",
+					Retries:  30,
+				},
+				Logging: docker.StandardLogOptions,
+			},
+			VolumeNames: []string{"vault_keys"},
+		})
+	}
+	for _, member := range stack.Members {
+		nodeName, _ := stack.BlockchainNode(member)
+		command := fmt.Sprintf("--chain-id=%d --downstream-http-host=%s --downstream-http-port=8545 --http-listen-host=0.0.0.0 --http-listen-port=8545 --http-host-allowlist=*", ethereum.GetChainID(stack), stack.ServiceHost(nodeName))
+		service := &docker.ServiceDefinition{
+			ServiceName: "ethsigner_" + member.ID,
+			Service: &docker.Service{
+				Image:     stack.GetImage(types.EthsignerComponent),
+				DependsOn: map[string]map[string]string{nodeName: {"condition": "service_started"}},
+				Logging:   docker.StandardLogOptions,
+			},
+		}
+		if stack.Signer == types.SignerVault {
+			service.Service.Command = fmt.Sprintf("%s hashicorp-signer --host=%s --port=8200 --auth-file=/keys/token --signing-key-path=/v1/secret/data/firefly/member_%s --tls-enabled=false", command, stack.ServiceHost("vault"), member.ID)
+			service.Service.Volumes = []string{"vault_keys:/keys"}
+			service.Service.DependsOn["vault"] = map[string]string{"condition": "service_healthy"}
+		} else {
+			service.Service.Command = command + " file-based-signer --key-file=/data/key.json --password-file=/data/password"
+			service.Service.Volumes = []string{fmt.Sprintf("ethsigner_%s:/data", member.ID)}
+			service.VolumeNames = []string{"ethsigner_" + member.ID}
+		}
+		serviceDefinitions = append(serviceDefinitions, service)
+	}
+	return serviceDefinitions
+}
@@ -22,8 +22,24 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"strings"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// Parameters of the chain of a stack that does not set its own
+const (
+	DefaultChainID  = 2021
+	DefaultGasLimit = 0x47b760
 )
 
+// GetChainID returns the ID of the stack's chain, which wallets need to connect to the node
+func GetChainID(stack *types.Stack) int {
+	if stack.ChainID != 0 {
+		return stack.ChainID
+	}
+	return DefaultChainID
+}
+
 type Genesis struct {
 	Config     *GenesisConfig    `json:"config"`
 	Nonce      string            `json:"nonce"`
@@ -59,25 +75,67 @@ type CliqueConfig struct {
 }
 
 type Alloc struct {
-	Balance string `json:"balance"`
+	Balance string            `json:"balance"`
+	Code    string            `json:"code,omitempty"`
+	Storage map[string]string `json:"storage,omitempty"`
 }
 
-func CreateGenesisJson(addresses []string) *Genesis {
+const fundedBalance = "0x200000000000000000000000000000000000000000000000000000000000000"
+
+// CreateGenesisJson funds every address, the stack's members and its accounts, and makes the signer addresses
+// the initial clique signers. Accounts get their own balance if they have one. Genesis accounts are allocated as given -
+// those without a balance are funded, unless they hold contract code.
+func CreateGenesisJson(signerAddresses []string, stack *types.Stack) *Genesis {
 
 	extraData := "0x0000000000000000000000000000000000000000000000000000000000000000"
 	alloc := make(map[string]*Alloc)
 
-	for _, address := range addresses {
+	for _, address := range signerAddresses {
 		alloc[address] = &Alloc{
-			Balance: "0x200000000000000000000000000000000000000000000000000000000000000",
+			Balance: fundedBalance,
 		}
 		extraData = extraData + address
 	}
+	// Members whose transactions are signed externally are not signers
+	for _, member := range stack.Members {
+		if address := strings.TrimPrefix(member.Address, "0x"); alloc[address] == nil {
+			alloc[address] = &Alloc{
+				Balance: fundedBalance,
+			}
+		}
+	}
+	for _, account := range stack.Accounts {
+		balance := account.Balance
+		if balance == "" {
+			balance = fundedBalance
+		}
+		alloc[strings.TrimPrefix(account.Address, "0x")] = &Alloc{
+			Balance: balance,
+		}
+	}
+	for _, account := range stack.GenesisAccounts {
+		balance := account.Balance
+		if balance == "" {
+			balance = fundedBalance
+			if account.Code != "" {
+				balance = "0x0"
+			}
+		}
+		alloc[strings.TrimPrefix(account.Address, "0x")] = &Alloc{
+			Balance: balance,
+			Code:    account.Code,
+			Storage: account.Storage,
+		}
+	}
 	extraData = strings.ReplaceAll(fmt.Sprintf("%-236s", extraData), " ", "0")
+	gasLimit := stack.GasLimit
+	if gasLimit == 0 {
+		gasLimit = DefaultGasLimit
+	}
 
 	return &Genesis{
 		Config: &GenesisConfig{
-			ChainId:             2021,
+			ChainId:             GetChainID(stack),
 			HomesteadBlock:      0,
 			Eip150Block:         0,
 			Eip150Hash:          "0x0000000000000000000000000000000000000000000000000000000000000000",
@@ -87,14 +145,14 @@ func CreateGenesisJson(addresses []string) *Genesis {
 			ConstantinopleBlock: 0,
 			IstanbulBlock:       0,
 			Clique: &CliqueConfig{
-				Period: 0,
+				Period: stack.BlockPeriod,
 				Epoch:  30000,
 			},
 		},
 		Nonce:      "0x0",
 		Timestamp:  "0x60edb1c7",
 		ExtraData:  extraData,
-		GasLimit:   "0x47b760",
+		GasLimit:   fmt.Sprintf("0x%x", gasLimit),
 		Difficulty: "0x1",
 		MixHash:    "0x0000000000000000000000000000000000000000000000000000000000000000",
 		Coinbase:   "0x0000000000000000000000000000000000000000",
@@ -105,10 +163,32 @@ func CreateGenesisJson(addresses []string) *Genesis {
 	}
 }
 
-func (g *Genesis) WriteGenesisJson(filename string) error {
+// WriteGenesisJson writes the genesis file, with the overrides merged into it if there are any
+func (g *Genesis) WriteGenesisJson(filename string, overrides map[string]interface{}) error {
 	genesisJsonBytes, _ := json.MarshalIndent(g, "", " ")
+	if len(overrides) > 0 {
+		var merged map[string]interface{}
+		if err := json.Unmarshal(genesisJsonBytes, &merged); err != nil {
+			return err
+		}
+		mergeJSONMaps(merged, overrides)
+		genesisJsonBytes, _ = json.MarshalIndent(merged, "", " ")
+	}
 	if err := ioutil.WriteFile(filepath.Join(filename), genesisJsonBytes, 0755); err != nil {
 		return err
 	}
 	return nil
 }
+
+// mergeJSONMaps merges the overlay into the base, replacing everything but objects, which are merged key by key
+func mergeJSONMaps(base, overlay map[string]interface{}) {
+	for key, value := range overlay {
+		overlayMap, isMap := value.(map[string]interface{})
+		baseMap, baseIsMap := base[key].(map[string]interface{})
+		if isMap && baseIsMap {
+			mergeJSONMaps(baseMap, overlayMap)
+		} else {
+			base[key] = value
+		}
+	}
+}
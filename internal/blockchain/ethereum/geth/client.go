@@ -29,10 +29,28 @@ type GethClient struct {
 }
 
 type RpcRequest struct {
-	JsonRPC string   `json:"jsonrpc"`
-	ID      int      `json:"id"`
-	Method  string   `json:"method"`
-	Params  []string `json:"params"`
+	JsonRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type RpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type RpcResponse struct {
+	JsonRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RpcError       `json:"error,omitempty"`
+}
+
+type SendTransactionRequest struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value string `json:"value"`
 }
 
 func NewGethClient(rpcUrl string) *GethClient {
@@ -42,11 +60,33 @@ func NewGethClient(rpcUrl string) *GethClient {
 }
 
 func (g *GethClient) UnlockAccount(address string, password string) error {
+	return g.call("personal_unlockAccount", []interface{}{address, password}, nil)
+}
+
+func (g *GethClient) ImportRawKey(privateKey string, password string) (string, error) {
+	var address string
+	err := g.call("personal_importRawKey", []interface{}{privateKey, password}, &address)
+	return address, err
+}
+
+// AddPeer connects the node to another node, given its enode URL
+func (g *GethClient) AddPeer(enode string) error {
+	var added bool
+	return g.call("admin_addPeer", []interface{}{enode}, &added)
+}
+
+func (g *GethClient) SendTransaction(from string, to string, value string) (string, error) {
+	var txHash string
+	err := g.call("eth_sendTransaction", []interface{}{&SendTransactionRequest{From: from, To: to, Value: value}}, &txHash)
+	return txHash, err
+}
+
+func (g *GethClient) call(method string, params []interface{}, result interface{}) error {
 	requestBody, err := json.Marshal(&RpcRequest{
 		JsonRPC: "2.0",
 		ID:      0,
-		Method:  "personal_unlockAccount",
-		Params:  []string{address, password},
+		Method:  method,
+		Params:  params,
 	})
 	if err != nil {
 		return err
@@ -69,5 +109,15 @@ func (g *GethClient) UnlockAccount(address string, password string) error {
 	if resp.StatusCode != 200 {
 		return fmt.Errorf("%d %s", resp.StatusCode, responseBody)
 	}
+	var rpcResponse *RpcResponse
+	if err := json.Unmarshal(responseBody, &rpcResponse); err != nil {
+		return err
+	}
+	if rpcResponse.Error != nil {
+		return fmt.Errorf("%s failed: %s", method, rpcResponse.Error.Message)
+	}
+	if result != nil {
+		return json.Unmarshal(rpcResponse.Result, result)
+	}
 	return nil
 }
@@ -17,14 +17,17 @@
 package geth
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path"
 	"path/filepath"
 	"time"
 
 	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
 	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum/ethconnect"
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum/ethsigner"
 	"github.com/hyperledger/firefly-cli/internal/constants"
 	"github.com/hyperledger/firefly-cli/internal/core"
 	"github.com/hyperledger/firefly-cli/internal/docker"
@@ -38,11 +41,54 @@ type GethProvider struct {
 	Stack   *types.Stack
 }
 
-func (p *GethProvider) WriteConfig() error {
-	stackDir := filepath.Join(constants.StacksDir, p.Stack.Name)
+// writeKeyfiles writes the private keys of all members and accounts, and the password used to encrypt
+// them in the geth keystore, to the directory that is mounted to import them
+func (p *GethProvider) writeKeyfiles(keyDir string) error {
 	for _, member := range p.Stack.Members {
+		if err := os.MkdirAll(filepath.Join(keyDir, member.ID), 0755); err != nil {
+			return err
+		}
 		// Drop the 0x on the front of the private key here because that's what geth is expecting in the keyfile
-		if err := ioutil.WriteFile(filepath.Join(stackDir, "blockchain", member.ID, "keyfile"), []byte(member.PrivateKey[2:]), 0755); err != nil {
+		if err := ioutil.WriteFile(filepath.Join(keyDir, member.ID, "keyfile"), []byte(member.PrivateKey[2:]), 0755); err != nil {
+			return err
+		}
+		if member.SealerPrivateKey != "" {
+			if err := ioutil.WriteFile(filepath.Join(keyDir, member.ID, "sealerkey"), []byte(member.SealerPrivateKey[2:]), 0755); err != nil {
+				return err
+			}
+		}
+		if member.NodeKey != "" {
+			if err := ioutil.WriteFile(filepath.Join(keyDir, member.ID, "nodekey"), []byte(member.NodeKey[2:]), 0755); err != nil {
+				return err
+			}
+		}
+	}
+	for _, account := range p.Stack.Accounts {
+		accountDir := filepath.Join(keyDir, "accounts", account.Address)
+		if err := os.MkdirAll(accountDir, 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(accountDir, "keyfile"), []byte(account.PrivateKey[2:]), 0755); err != nil {
+			return err
+		}
+	}
+
+	if p.Stack.VaultToken != "" {
+		if err := ioutil.WriteFile(filepath.Join(keyDir, "vault-token"), []byte(p.Stack.VaultToken), 0755); err != nil {
+			return err
+		}
+	}
+
+	// Write the password that will be used to encrypt the private key
+	// TODO: Probably randomize this and make it differnet per member?
+	return ioutil.WriteFile(filepath.Join(keyDir, "password"), []byte("correcthorsebatterystaple"), 0755)
+}
+
+func (p *GethProvider) WriteConfig() error {
+	stackDir := filepath.Join(constants.StacksDir, p.Stack.Name)
+	// The keys of an encrypted stack are only written to a temporary directory while they are imported
+	if !p.Stack.Encrypted {
+		if err := p.writeKeyfiles(filepath.Join(stackDir, "blockchain")); err != nil {
 			return err
 		}
 	}
@@ -51,29 +97,126 @@ func (p *GethProvider) WriteConfig() error {
 	addresses := make([]string, len(p.Stack.Members))
 	for i, member := range p.Stack.Members {
 		// Drop the 0x on the front of the address here because that's what geth is expecting in the genesis.json
-		addresses[i] = member.Address[2:]
+		address, _ := member.NodeAccount()
+		addresses[i] = address[2:]
 	}
-	genesis := ethereum.CreateGenesisJson(addresses)
-	if err := genesis.WriteGenesisJson(filepath.Join(stackDir, "blockchain", "genesis.json")); err != nil {
+	genesis := ethereum.CreateGenesisJson(addresses, p.Stack)
+	if err := genesis.WriteGenesisJson(filepath.Join(stackDir, "blockchain", "genesis.json"), p.Stack.GenesisOverrides); err != nil {
 		return err
 	}
-
-	// Write the password that will be used to encrypt the private key
-	// TODO: Probably randomize this and make it differnet per member?
-	if err := ioutil.WriteFile(filepath.Join(stackDir, "blockchain", "password"), []byte("correcthorsebatterystaple"), 0755); err != nil {
+	if err := ethconnect.WriteConfig(p.Stack); err != nil {
 		return err
 	}
 
+	if p.nodePerMember() {
+		return p.writeStaticNodes(filepath.Join(stackDir, "blockchain", "static-nodes.json"))
+	}
 	return nil
 }
 
+func (p *GethProvider) nodePerMember() bool {
+	return p.Stack.BlockchainNodes == types.BlockchainNodePerMember
+}
+
+// getEnode returns the URL the other nodes of a consortium network use to connect to the member's node
+func (p *GethProvider) getEnode(member *types.Member) (string, error) {
+	nodeID, err := ethereum.NodeID(member.NodeKey)
+	if err != nil {
+		return "", err
+	}
+	nodeName, _ := p.Stack.BlockchainNode(member)
+	return fmt.Sprintf("enode://%s@%s:30311", nodeID, p.Stack.ServiceHost(nodeName)), nil
+}
+
+func (p *GethProvider) writeStaticNodes(filename string) error {
+	enodes := make([]string, len(p.Stack.Members))
+	for i, member := range p.Stack.Members {
+		enode, err := p.getEnode(member)
+		if err != nil {
+			return err
+		}
+		enodes[i] = enode
+	}
+	staticNodesBytes, _ := json.MarshalIndent(enodes, "", " ")
+	return ioutil.WriteFile(filename, staticNodesBytes, 0755)
+}
+
 func (p *GethProvider) FirstTimeSetup() error {
-	volumeName := fmt.Sprintf("%s_geth", p.Stack.Name)
 	gethConfigDir := path.Join(constants.StacksDir, p.Stack.Name, "blockchain")
+	keyDir := gethConfigDir
+	if p.Stack.Encrypted {
+		tempDir, err := ioutil.TempDir("", "firefly-keys")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tempDir)
+		if err := p.writeKeyfiles(tempDir); err != nil {
+			return err
+		}
+		keyDir = tempDir
+	}
 
-	// Mount the directory containing all members' private keys and password, and import the accounts using the geth CLI
-	for _, member := range p.Stack.Members {
-		if err := docker.RunDockerCommand(constants.StacksDir, p.Verbose, p.Verbose, "run", "--rm", "-v", fmt.Sprintf("%s:/geth", gethConfigDir), "-v", fmt.Sprintf("%s:/data", volumeName), "ethereum/client-go:release-1.9", "--nousb", "account", "import", "--password", "/geth/password", "--keystore", "/data/keystore", fmt.Sprintf("/geth/%s/keyfile", member.ID)); err != nil {
+	if err := ethconnect.CopyConfigToVolumes(p.Stack, p.Verbose); err != nil {
+		return err
+	}
+	if p.Stack.Signer != "" {
+		if err := ethsigner.FirstTimeSetup(p.Stack, keyDir, p.Verbose); err != nil {
+			return err
+		}
+	}
+	if !p.nodePerMember() {
+		return p.initNode("geth", keyDir, p.Stack.Members, p.Stack.Accounts, nil)
+	}
+	for i, member := range p.Stack.Members {
+		// Accounts are kept on the first member's node
+		var accounts []*types.Account
+		if i == 0 {
+			accounts = p.Stack.Accounts
+		}
+		nodeName, _ := p.Stack.BlockchainNode(member)
+		if err := p.initNode(nodeName, keyDir, []*types.Member{member}, accounts, member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initNode imports the keys of the members and accounts into the keystore in the volume of a geth
+// node, and initializes the genesis block. The owner of a node in a consortium network is given its
+// node key and the list of the other nodes.
+func (p *GethProvider) initNode(serviceName, keyDir string, members []*types.Member, accounts []*types.Account, owner *types.Member) error {
+	volumeName := fmt.Sprintf("%s_%s", p.Stack.Name, serviceName)
+	gethConfigDir := path.Join(constants.StacksDir, p.Stack.Name, "blockchain")
+
+	// Copy the password (to be used for decrypting private keys)
+	if err := docker.CopyFileToVolume(volumeName, path.Join(keyDir, "password"), "password", p.Verbose); err != nil {
+		return err
+	}
+
+	// Copy each private key into the volume in turn, rather than mounting the key directory, which is not on the
+	// docker host when it is remote, and import the accounts using the geth CLI. The node of a member whose
+	// transactions are signed externally only has its sealer key.
+	keyfiles := make([]string, 0, len(members)+len(accounts))
+	for _, member := range members {
+		keyfile := "keyfile"
+		if member.SealerPrivateKey != "" {
+			keyfile = "sealerkey"
+		}
+		keyfiles = append(keyfiles, path.Join(keyDir, member.ID, keyfile))
+	}
+	for _, account := range accounts {
+		keyfiles = append(keyfiles, path.Join(keyDir, "accounts", account.Address, "keyfile"))
+	}
+	for _, keyfile := range keyfiles {
+		if err := docker.CopyFileToVolume(volumeName, keyfile, "import_keyfile", p.Verbose); err != nil {
+			return err
+		}
+		if err := docker.RunDockerCommand(constants.StacksDir, p.Verbose, p.Verbose, "run", "--rm", "-v", fmt.Sprintf("%s:/data", volumeName), p.Stack.GetImage(types.GethComponent), "--nousb", "account", "import", "--password", "/data/password", "--keystore", "/data/keystore", "/data/import_keyfile"); err != nil {
+			return err
+		}
+	}
+	if len(keyfiles) > 0 {
+		if err := docker.RemoveFromVolume(volumeName, "import_keyfile", p.Verbose); err != nil {
 			return err
 		}
 	}
@@ -83,16 +226,23 @@ func (p *GethProvider) FirstTimeSetup() error {
 		return err
 	}
 
-	// Copy the password (to be used for decrypting private keys)
-	if err := docker.CopyFileToVolume(volumeName, path.Join(gethConfigDir, "password"), "password", p.Verbose); err != nil {
-		return err
+	if owner != nil {
+		if err := docker.CopyFileToVolume(volumeName, path.Join(keyDir, owner.ID, "nodekey"), "nodekey", p.Verbose); err != nil {
+			return err
+		}
 	}
 
 	// Initialize the genesis block
-	if err := docker.RunDockerCommand(constants.StacksDir, p.Verbose, p.Verbose, "run", "--rm", "-v", fmt.Sprintf("%s:/data", volumeName), "ethereum/client-go:release-1.9", "--datadir", "/data", "--nousb", "init", "/data/genesis.json"); err != nil {
+	if err := docker.RunDockerCommand(constants.StacksDir, p.Verbose, p.Verbose, "run", "--rm", "-v", fmt.Sprintf("%s:/data", volumeName), p.Stack.GetImage(types.GethComponent), "--datadir", "/data", "--nousb", "init", "/data/genesis.json"); err != nil {
 		return err
 	}
 
+	if owner != nil {
+		// geth reads the static nodes from its data directory, which is created by the init above
+		if err := docker.CopyFileToVolume(volumeName, path.Join(gethConfigDir, "static-nodes.json"), "geth/static-nodes.json", p.Verbose); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -102,14 +252,15 @@ func (p *GethProvider) PreStart() error {
 
 func (p *GethProvider) PostStart() error {
 	// Unlock accounts
-	gethClient := NewGethClient(fmt.Sprintf("http://127.0.0.1:%v", p.Stack.ExposedBlockchainPort))
 	for _, m := range p.Stack.Members {
+		gethClient := p.getClient(m)
+		address, _ := m.NodeAccount()
 		retries := 10
 		p.Log.Info(fmt.Sprintf("unlocking account for member %s", m.ID))
 		for {
-			if err := gethClient.UnlockAccount(m.Address, "correcthorsebatterystaple"); err != nil {
+			if err := gethClient.UnlockAccount(address, "correcthorsebatterystaple"); err != nil {
 				if retries == 0 {
-					return fmt.Errorf("unable to unlock account %s for member %s", m.Address, m.ID)
+					return fmt.Errorf("unable to unlock account %s for member %s", address, m.ID)
 				}
 				time.Sleep(time.Second * 1)
 				retries--
@@ -118,36 +269,119 @@ func (p *GethProvider) PostStart() error {
 			}
 		}
 	}
+	gethClient := p.getClient(p.Stack.Members[0])
+	for _, account := range p.Stack.Accounts {
+		p.Log.Info(fmt.Sprintf("unlocking account %s", account.Address))
+		if err := gethClient.UnlockAccount(account.Address, "correcthorsebatterystaple"); err != nil {
+			return fmt.Errorf("unable to unlock account %s: %s", account.Address, err)
+		}
+	}
+	if p.nodePerMember() {
+		return p.connectPeers()
+	}
+	return nil
+}
+
+// connectPeers connects every node of a consortium network to each other. The static nodes list only
+// takes effect if the names of the other nodes resolve when geth starts, which depends on the order the
+// containers start in, so the peers are also added to each running node.
+func (p *GethProvider) connectPeers() error {
+	p.Log.Info("connecting blockchain nodes")
+	for _, member := range p.Stack.Members {
+		gethClient := p.getClient(member)
+		for _, peer := range p.Stack.Members {
+			if peer == member {
+				continue
+			}
+			enode, err := p.getEnode(peer)
+			if err != nil {
+				return err
+			}
+			if err := gethClient.AddPeer(enode); err != nil {
+				return fmt.Errorf("unable to connect the blockchain node of member %s to member %s: %s", member.ID, peer.ID, err)
+			}
+		}
+	}
 	return nil
 }
 
+func (p *GethProvider) getClient(member *types.Member) *GethClient {
+	_, port := p.Stack.BlockchainNode(member)
+	return NewGethClient(fmt.Sprintf("http://%s:%v", p.Stack.Host(), port))
+}
+
 func (p *GethProvider) DeploySmartContracts() error {
 	return ethereum.DeployContracts(p.Stack, p.Log, p.Verbose)
 }
 
+func getGethCommand(stack *types.Stack, addresses string, ws bool) string {
+	// Unless the stack sets its gas limit, the limit rises from the genesis block towards a very high target.
+	// A set limit is kept in every block, as on the network the stack mimics.
+	gasFlags := "--miner.gastarget 804247552"
+	if stack.GasLimit != 0 {
+		gasFlags = fmt.Sprintf("--miner.gastarget %d --miner.gaslimit %d", stack.GasLimit, stack.GasLimit)
+	}
+	command := fmt.Sprintf(`--datadir /data --syncmode 'full' --port 30311 --rpcvhosts=* --rpccorsdomain "*" %s --rpc --rpcaddr "0.0.0.0" --rpcport 8545 --rpcapi 'admin,personal,db,eth,net,web3,txpool,miner,clique' --networkid %d --miner.gasprice 0 --unlock '%s' --password /data/password --mine --nousb --allow-insecure-unlock --nodiscover`, gasFlags, ethereum.GetChainID(stack), addresses)
+	if ws {
+		command += ` --ws --wsaddr "0.0.0.0" --wsport 8546 --wsorigins "*" --wsapi 'eth,net,web3,txpool'`
+	}
+	return command
+}
+
+// getNodePorts publishes the node's RPC endpoint on the stack's blockchain port, and its JSON-RPC and
+// WebSocket endpoints on the node RPC ports if they are exposed
+func getNodePorts(port int, rpcPort int, wsPort int) []string {
+	ports := []string{fmt.Sprintf("%d:8545", port)}
+	if rpcPort != 0 {
+		ports = append(ports, fmt.Sprintf("%d:8545", rpcPort), fmt.Sprintf("%d:8546", wsPort))
+	}
+	return ports
+}
+
 func (p *GethProvider) GetDockerServiceDefinitions() []*docker.ServiceDefinition {
-	addresses := ""
-	for i, member := range p.Stack.Members {
-		addresses = addresses + member.Address
-		if i+1 < len(p.Stack.Members) {
-			addresses = addresses + ","
-		}
-	}
-	gethCommand := fmt.Sprintf(`--datadir /data --syncmode 'full' --port 30311 --rpcvhosts=* --rpccorsdomain "*" --miner.gastarget 804247552 --rpc --rpcaddr "0.0.0.0" --rpcport 8545 --rpcapi 'admin,personal,db,eth,net,web3,txpool,miner,clique' --networkid 2021 --miner.gasprice 0 --unlock '%s' --password /data/password --mine --nousb --allow-insecure-unlock --nodiscover`, addresses)
-
-	serviceDefinitions := make([]*docker.ServiceDefinition, 1)
-	serviceDefinitions[0] = &docker.ServiceDefinition{
-		ServiceName: "geth",
-		Service: &docker.Service{
-			Image:   "ethereum/client-go:release-1.9",
-			Command: gethCommand,
-			Volumes: []string{"geth:/data"},
-			Logging: docker.StandardLogOptions,
-			Ports:   []string{fmt.Sprintf("%d:8545", p.Stack.ExposedBlockchainPort)},
-		},
-		VolumeNames: []string{"geth"},
+	serviceDefinitions := make([]*docker.ServiceDefinition, 0, len(p.Stack.Members))
+	if !p.nodePerMember() {
+		addresses := ""
+		for i, member := range p.Stack.Members {
+			address, _ := member.NodeAccount()
+			addresses = addresses + address
+			if i+1 < len(p.Stack.Members) {
+				addresses = addresses + ","
+			}
+		}
+		serviceDefinitions = append(serviceDefinitions, &docker.ServiceDefinition{
+			ServiceName: "geth",
+			Service: &docker.Service{
+				Image:   p.Stack.GetImage(types.GethComponent),
+				Command: getGethCommand(p.Stack, addresses, p.Stack.ExposedNodeWSPort != 0),
+				Volumes: []string{"geth:/data"},
+				Logging: docker.StandardLogOptions,
+				Ports:   getNodePorts(p.Stack.ExposedBlockchainPort, p.Stack.ExposedNodeRPCPort, p.Stack.ExposedNodeWSPort),
+			},
+			VolumeNames: []string{"geth"},
+		})
+	} else {
+		// Each member's node signs blocks with the member's key, or its sealer key if the member's transactions are
+		// signed externally, as one of the clique signers in the genesis block
+		for _, member := range p.Stack.Members {
+			nodeName, port := p.Stack.BlockchainNode(member)
+			rpcPort, wsPort := p.Stack.NodeRPCPorts(member)
+			address, _ := member.NodeAccount()
+			serviceDefinitions = append(serviceDefinitions, &docker.ServiceDefinition{
+				ServiceName: nodeName,
+				Service: &docker.Service{
+					Image:   p.Stack.GetImage(types.GethComponent),
+					Command: fmt.Sprintf("%s --miner.etherbase '%s' --nodekey /data/nodekey", getGethCommand(p.Stack, address, wsPort != 0), address),
+					Volumes: []string{nodeName + ":/data"},
+					Logging: docker.StandardLogOptions,
+					Ports:   getNodePorts(port, rpcPort, wsPort),
+				},
+				VolumeNames: []string{nodeName},
+			})
+		}
 	}
-	serviceDefinitions = append(serviceDefinitions, ethconnect.GetEthconnectServiceDefinitions(p.Stack.Members)...)
+	serviceDefinitions = append(serviceDefinitions, ethsigner.GetServiceDefinitions(p.Stack)...)
+	serviceDefinitions = append(serviceDefinitions, ethconnect.GetEthconnectServiceDefinitions(p.Stack)...)
 	return serviceDefinitions
 }
 
@@ -164,10 +398,56 @@ func (p *GethProvider) GetFireflyConfig(m *types.Member) *core.BlockchainConfig
 	}
 }
 
+// ImportAccount adds the account to the keystore of the running geth node, and funds it from the account of the first
+// member's node. In a consortium network, accounts are kept on the first member's node.
+func (p *GethProvider) ImportAccount(account *types.Account) error {
+	gethClient := p.getClient(p.Stack.Members[0])
+	funder, _ := p.Stack.Members[0].NodeAccount()
+	if _, err := gethClient.ImportRawKey(account.PrivateKey[2:], "correcthorsebatterystaple"); err != nil {
+		return err
+	}
+	if err := gethClient.UnlockAccount(account.Address, "correcthorsebatterystaple"); err != nil {
+		return err
+	}
+	p.Log.Info(fmt.Sprintf("funding account %s", account.Address))
+	// 1000 ether
+	if _, err := gethClient.SendTransaction(funder, account.Address, "0x3635c9adc5dea00000"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// AddMember imports the signing key of a new member into the keystore of the running geth node
+func (p *GethProvider) AddMember(member *types.Member) error {
+	gethClient := p.getClient(member)
+	address, privateKey := member.NodeAccount()
+	if _, err := gethClient.ImportRawKey(privateKey[2:], "correcthorsebatterystaple"); err != nil {
+		return err
+	}
+	return gethClient.UnlockAccount(address, "correcthorsebatterystaple")
+}
+
+// GetInFlightTransactionCount checks ethconnect's receipt store for each request, which only has a receipt once the
+// request's transaction has been mined or has failed
+func (p *GethProvider) GetInFlightTransactionCount(member *types.Member, requestIDs []string) (int, error) {
+	ethconnectURL := fmt.Sprintf("http://%s:%v", member.Host(), member.ExposedEthconnectPort)
+	count := 0
+	for _, requestID := range requestIDs {
+		hasReceipt, err := ethconnect.HasReceipt(ethconnectURL, requestID)
+		if err != nil {
+			return 0, err
+		}
+		if !hasReceipt {
+			count++
+		}
+	}
+	return count, nil
+}
+
 func (p *GethProvider) getEthconnectURL(member *types.Member) string {
 	if !member.External {
-		return fmt.Sprintf("http://ethconnect_%s:8080", member.ID)
+		return fmt.Sprintf("http://%s:8080", p.Stack.ServiceHost("ethconnect_"+member.ID))
 	} else {
-		return fmt.Sprintf("http://127.0.0.1:%v", member.ExposedEthconnectPort)
+		return fmt.Sprintf("http://%s:%v", member.Host(), member.ExposedEthconnectPort)
 	}
 }
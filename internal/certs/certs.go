@@ -0,0 +1,284 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+func GetTLSDir(stack *types.Stack) string {
+	return filepath.Join(constants.StacksDir, stack.Name, "tls")
+}
+
+func GetCACertPath(stack *types.Stack) string {
+	return filepath.Join(GetTLSDir(stack), "ca.pem")
+}
+
+// GetServiceCertDir returns the directory with the certificate and key a service serves
+func GetServiceCertDir(stack *types.Stack, serviceName string) string {
+	return filepath.Join(GetTLSDir(stack), serviceName)
+}
+
+// WriteCertificates creates a CA for the stack, and a certificate signed by it for the FireFly core of
+// each member. Existing certificates are kept, and the CA key is kept so members added later can be
+// issued certificates by the same CA.
+func WriteCertificates(stack *types.Stack) error {
+	if !stack.TLS {
+		return nil
+	}
+	tlsDir := GetTLSDir(stack)
+	if err := os.MkdirAll(tlsDir, 0755); err != nil {
+		return err
+	}
+	caCert, caKey, err := loadOrCreateCA(tlsDir, stack.Name, stack.CertificateSeed)
+	if err != nil {
+		return err
+	}
+	for _, member := range stack.Members {
+		serviceName := "firefly_core_" + member.ID
+		hosts := []string{serviceName, types.ServiceAlias(serviceName), "localhost", "127.0.0.1"}
+		if host := member.FireflyHost(); host != "127.0.0.1" {
+			hosts = append(hosts, host)
+		}
+		if err := writeServiceCertificate(GetServiceCertDir(stack, serviceName), hosts, caCert, caKey, stack.CertificateSeed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteWildcardCertificate writes a certificate for the domain and every hostname directly under it to certDir,
+// signed by the CA of the stack, along with a copy of the CA certificate. A certificate issued by another CA,
+// such as one written before the stack had TLS enabled, is replaced.
+func WriteWildcardCertificate(stack *types.Stack, certDir string, domain string) error {
+	tlsDir := GetTLSDir(stack)
+	if err := os.MkdirAll(tlsDir, 0755); err != nil {
+		return err
+	}
+	caCert, caKey, err := loadOrCreateCA(tlsDir, stack.Name, stack.CertificateSeed)
+	if err != nil {
+		return err
+	}
+	certPath := filepath.Join(certDir, "cert.pem")
+	if !isSignedBy(certPath, caCert) {
+		if err := os.RemoveAll(certPath); err != nil {
+			return err
+		}
+	}
+	if err := writeServiceCertificate(certDir, []string{"*." + domain, domain}, caCert, caKey, stack.CertificateSeed); err != nil {
+		return err
+	}
+	return writePEM(filepath.Join(certDir, "ca.pem"), "CERTIFICATE", caCert.Raw, 0644)
+}
+
+func isSignedBy(certPath string, caCert *x509.Certificate) bool {
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return false
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	return err == nil && cert.CheckSignatureFrom(caCert) == nil
+}
+
+func loadOrCreateCA(tlsDir string, stackName string, seed string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPath := filepath.Join(tlsDir, "ca.pem")
+	keyPath := filepath.Join(tlsDir, "ca-key.pem")
+	if certPEM, err := ioutil.ReadFile(certPath); err == nil {
+		keyPEM, err := ioutil.ReadFile(keyPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return parseCA(certPEM, keyPEM)
+	}
+
+	caKey, err := newKey(seed, "ca")
+	if err != nil {
+		return nil, nil, err
+	}
+	notBefore, notAfter := validity(seed, func(now time.Time) time.Time { return now.AddDate(10, 0, 0) })
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "FireFly CLI CA for stack " + stackName, Organization: []string{"FireFly CLI"}},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, signer(caKey, seed))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(certPath, "CERTIFICATE", caDER, 0644); err != nil {
+		return nil, nil, err
+	}
+	if err := writePEM(keyPath, "EC PRIVATE KEY", keyDER, 0600); err != nil {
+		return nil, nil, err
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	return caCert, caKey, err
+}
+
+func parseCA(certPEM, keyPEM []byte) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, fmt.Errorf("the CA certificate or key of the stack is not valid PEM")
+	}
+	caCert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	caKey, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return caCert, caKey, nil
+}
+
+func writeServiceCertificate(certDir string, hosts []string, caCert *x509.Certificate, caKey *ecdsa.PrivateKey, seed string) error {
+	if _, err := os.Stat(filepath.Join(certDir, "cert.pem")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(certDir, 0755); err != nil {
+		return err
+	}
+	key, err := newKey(seed, hosts[0])
+	if err != nil {
+		return err
+	}
+	serial, err := newSerial(seed, hosts[0])
+	if err != nil {
+		return err
+	}
+	// Browsers reject server certificates valid for more than 398 days
+	notBefore, notAfter := validity(seed, func(now time.Time) time.Time { return now.AddDate(0, 0, 397) })
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, signer(caKey, seed))
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := writePEM(filepath.Join(certDir, "cert.pem"), "CERTIFICATE", certDER, 0644); err != nil {
+		return err
+	}
+	// The key is read by the service's user inside its container, which is not the owner of the file
+	return writePEM(filepath.Join(certDir, "key.pem"), "EC PRIVATE KEY", keyDER, 0644)
+}
+
+// WriteSelfSignedCertificate writes a self-signed certificate and its key to certDir, derived from the seed if
+// there is one, for services that identify themselves to their peers with their own certificate
+func WriteSelfSignedCertificate(certDir string, commonName string, organization string, seed string) error {
+	key, err := newKey(seed, commonName)
+	if err != nil {
+		return err
+	}
+	serial, err := newSerial(seed, commonName)
+	if err != nil {
+		return err
+	}
+	notBefore, notAfter := validity(seed, func(now time.Time) time.Time { return now.AddDate(1, 0, 0) })
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName, Organization: []string{organization}},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, signer(key, seed))
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := writePEM(filepath.Join(certDir, "cert.pem"), "CERTIFICATE", certDER, 0644); err != nil {
+		return err
+	}
+	return writePEM(filepath.Join(certDir, "key.pem"), "EC PRIVATE KEY", keyDER, 0644)
+}
+
+func writePEM(filename string, blockType string, der []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), perm)
+}
+
+// NewClient returns an HTTP client for the CLI's own requests to a stack, which trusts the CA of the stack
+// on top of the system roots. Other clients in the process do not trust the CA.
+func NewClient(stack *types.Stack) (*http.Client, error) {
+	caPEM, err := ioutil.ReadFile(GetCACertPath(stack))
+	if err != nil {
+		return nil, err
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("failed to read the CA certificate of stack '%s'", stack.Name)
+	}
+	transport := &http.Transport{}
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	return &http.Client{Transport: transport}, nil
+}
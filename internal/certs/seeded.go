@@ -0,0 +1,141 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"time"
+)
+
+// The certificates of a stack created from a seed are derived from the seed, so that they are the same
+// wherever the stack is created: keys and serial numbers come from the seed, signatures use deterministic
+// nonces, and every certificate is valid for the same fixed period.
+var (
+	seededNotBefore = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	seededNotAfter  = time.Date(2051, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// seededBytes returns SHA-256(seed || 0 || label || counter), the stream the values for a label are drawn from
+func seededBytes(seed string, label string, counter uint64) []byte {
+	counterBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(counterBytes, counter)
+	h := sha256.New()
+	h.Write([]byte(seed))
+	h.Write([]byte{0})
+	h.Write([]byte(label))
+	h.Write(counterBytes)
+	return h.Sum(nil)
+}
+
+// newKey generates a P-256 key, derived from the seed and label if there is a seed. The key is derived by hand,
+// as ecdsa.GenerateKey does not promise to be deterministic for a given source of randomness.
+func newKey(seed string, label string) (*ecdsa.PrivateKey, error) {
+	curve := elliptic.P256()
+	if seed == "" {
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	}
+	n := curve.Params().N
+	for counter := uint64(0); ; counter++ {
+		d := new(big.Int).SetBytes(seededBytes(seed, label, counter))
+		if d.Sign() == 0 || d.Cmp(n) >= 0 {
+			continue
+		}
+		key := &ecdsa.PrivateKey{D: d}
+		key.Curve = curve
+		key.X, key.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, 32)))
+		return key, nil
+	}
+}
+
+// newSerial returns a random serial number, or one derived from the seed and label if there is a seed
+func newSerial(seed string, label string) (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), 62)
+	if seed == "" {
+		return rand.Int(rand.Reader, limit)
+	}
+	return new(big.Int).Mod(new(big.Int).SetBytes(seededBytes(seed, "serial:"+label, 0)), limit), nil
+}
+
+// validity returns the period a certificate is valid for - the lifetime from now, or the fixed period of
+// certificates derived from a seed
+func validity(seed string, lifetime func(time.Time) time.Time) (notBefore time.Time, notAfter time.Time) {
+	if seed != "" {
+		return seededNotBefore, seededNotAfter
+	}
+	now := time.Now()
+	return now.Add(-time.Hour), lifetime(now)
+}
+
+// signer signs with the key, using nonces derived from the key and the message if there is a seed, in the
+// manner of RFC 6979, so that the same certificate is signed the same way every time
+func signer(key *ecdsa.PrivateKey, seed string) crypto.Signer {
+	if seed == "" {
+		return key
+	}
+	return &deterministicSigner{key: key}
+}
+
+type deterministicSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s *deterministicSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+func (s *deterministicSigner) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	params := s.key.Curve.Params()
+	n := params.N
+	e := new(big.Int).SetBytes(digest)
+	if excess := len(digest)*8 - n.BitLen(); excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+	keyBytes := s.key.D.FillBytes(make([]byte, (n.BitLen()+7)/8))
+	for counter := uint64(0); ; counter++ {
+		mac := hmac.New(sha256.New, keyBytes)
+		mac.Write(digest)
+		counterBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(counterBytes, counter)
+		mac.Write(counterBytes)
+		k := new(big.Int).Mod(new(big.Int).SetBytes(mac.Sum(nil)), n)
+		if k.Sign() == 0 {
+			continue
+		}
+		x, _ := s.key.Curve.ScalarBaseMult(k.FillBytes(make([]byte, len(keyBytes))))
+		r := new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+		sig := new(big.Int).Mul(r, s.key.D)
+		sig.Add(sig, e)
+		sig.Mul(sig, new(big.Int).ModInverse(k, n))
+		sig.Mod(sig, n)
+		if sig.Sign() == 0 {
+			continue
+		}
+		return asn1.Marshal(struct{ R, S *big.Int }{r, sig})
+	}
+}
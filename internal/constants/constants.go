@@ -23,3 +23,9 @@ import (
 
 var homeDir, _ = os.UserHomeDir()
 var StacksDir = filepath.Join(homeDir, ".firefly", "stacks")
+var PortRegistryFile = filepath.Join(homeDir, ".firefly", "ports.json")
+var StateFile = filepath.Join(homeDir, ".firefly", "state.json")
+var GoldenDir = filepath.Join(homeDir, ".firefly", "golden")
+var ManifestCacheDir = filepath.Join(homeDir, ".firefly", "cache", "manifests")
+var AliasesFile = filepath.Join(homeDir, ".firefly", "aliases.yaml")
+var DaemonTokenFile = filepath.Join(homeDir, ".firefly", "daemon-token")
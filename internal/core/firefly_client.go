@@ -0,0 +1,264 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+type Event struct {
+	ID          string `json:"id"`
+	Sequence    int64  `json:"sequence"`
+	Type        string `json:"type"`
+	Namespace   string `json:"namespace,omitempty"`
+	Reference   string `json:"reference"`
+	Correlator  string `json:"correlator,omitempty"`
+	Transaction string `json:"tx,omitempty"`
+	Topic       string `json:"topic,omitempty"`
+	Created     string `json:"created,omitempty"`
+}
+
+type MessageHeader struct {
+	ID     string   `json:"id"`
+	Type   string   `json:"type"`
+	Author string   `json:"author"`
+	Group  string   `json:"group,omitempty"`
+	Tag    string   `json:"tag,omitempty"`
+	Topics []string `json:"topics,omitempty"`
+	CID    string   `json:"cid,omitempty"`
+}
+
+type Message struct {
+	Header MessageHeader `json:"header"`
+}
+
+type TokenTransfer struct {
+	LocalID    string `json:"localId"`
+	Type       string `json:"type"`
+	Pool       string `json:"pool"`
+	TokenIndex string `json:"tokenIndex,omitempty"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to,omitempty"`
+	Amount     string `json:"amount"`
+}
+
+type Operation struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Namespace struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`
+}
+
+type TokenConnectorStatus struct {
+	Name string `json:"name"`
+}
+
+type ContractAPI struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Interface struct {
+		ID string `json:"id,omitempty"`
+	} `json:"interface"`
+}
+
+// FireflyURL returns the URL of a path in the default namespace of the member's FireFly API
+func FireflyURL(member *types.Member, path string) string {
+	return fmt.Sprintf("%s/api/v1/namespaces/default%s", member.FireflyClientURL(), path)
+}
+
+func GetEvents(member *types.Member, query string) ([]*Event, error) {
+	var events []*Event
+	if err := RequestWithRetry(http.MethodGet, FireflyURL(member, "/events?"+query), nil, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetLatestEventSequence returns the sequence of the most recent event on the member, or zero if there are no events
+func GetLatestEventSequence(member *types.Member) (int64, error) {
+	events, err := GetEvents(member, "sort=sequence&descending&limit=1")
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	return events[0].Sequence, nil
+}
+
+// WaitForEvent polls the member for the first event after the given sequence with the given type, or of any
+// type if empty, and if a tag is given, for a message with that tag. A zero timeout waits forever.
+func WaitForEvent(member *types.Member, after int64, eventType string, tag string, timeout time.Duration) (*Event, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		events, err := GetEvents(member, fmt.Sprintf("sequence=>%d&sort=sequence", after))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			after = e.Sequence
+			if eventType != "" && e.Type != eventType {
+				continue
+			}
+			if tag != "" {
+				if !strings.HasPrefix(e.Type, "message_") {
+					continue
+				}
+				msg, err := GetMessage(member, e.Reference)
+				if err != nil {
+					return nil, err
+				}
+				if msg.Header.Tag != tag {
+					continue
+				}
+			}
+			return e, nil
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			description := "event"
+			if eventType != "" {
+				description = eventType + " event"
+			}
+			if tag != "" {
+				return nil, fmt.Errorf("no %s with tag '%s' received by member %s within %s", description, tag, member.ID, timeout)
+			}
+			return nil, fmt.Errorf("no %s received by member %s within %s", description, member.ID, timeout)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+func GetMessage(member *types.Member, id string) (*Message, error) {
+	var msg *Message
+	if err := RequestWithRetry(http.MethodGet, FireflyURL(member, "/messages/"+id), nil, &msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func GetTokenTransfer(member *types.Member, id string) (*TokenTransfer, error) {
+	var transfer *TokenTransfer
+	if err := RequestWithRetry(http.MethodGet, FireflyURL(member, "/tokens/transfers/"+id), nil, &transfer); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+func GetOperations(member *types.Member, query string) ([]*Operation, error) {
+	var operations []*Operation
+	if err := RequestWithRetry(http.MethodGet, FireflyURL(member, "/operations?"+query), nil, &operations); err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+func GetNamespaces(member *types.Member) ([]*Namespace, error) {
+	var namespaces []*Namespace
+	url := member.FireflyClientURL() + "/api/v1/namespaces"
+	if err := RequestWithRetry(http.MethodGet, url, nil, &namespaces); err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// CreateNamespace broadcasts a new namespace to the network, waiting for it to be confirmed
+func CreateNamespace(member *types.Member, name, description string) (*Namespace, error) {
+	var namespace *Namespace
+	url := member.FireflyClientURL() + "/api/v1/namespaces?confirm=true"
+	body := &Namespace{Name: name, Description: description}
+	if err := RequestWithRetry(http.MethodPost, url, body, &namespace); err != nil {
+		return nil, err
+	}
+	return namespace, nil
+}
+
+// GetTokenConnectors returns the token connectors the member's FireFly node is configured with
+func GetTokenConnectors(member *types.Member) ([]*TokenConnectorStatus, error) {
+	var connectors []*TokenConnectorStatus
+	if err := request(http.MethodGet, FireflyURL(member, "/tokens/connectors"), nil, &connectors); err != nil {
+		return nil, err
+	}
+	return connectors, nil
+}
+
+// GetContractAPIs returns the contract APIs defined in the default namespace of the member
+func GetContractAPIs(member *types.Member) ([]*ContractAPI, error) {
+	var apis []*ContractAPI
+	if err := request(http.MethodGet, FireflyURL(member, "/apis"), nil, &apis); err != nil {
+		return nil, err
+	}
+	return apis, nil
+}
+
+// GetContractAPIInterface fetches the interface of a contract API, which fails if the API can no longer
+// be resolved to a contract the node can call
+func GetContractAPIInterface(member *types.Member, name string) error {
+	var result json.RawMessage
+	return request(http.MethodGet, FireflyURL(member, fmt.Sprintf("/apis/%s/interface", name)), nil, &result)
+}
+
+// GetDiagnostics fetches a URL of a member's FireFly API once, without retrying, so an unresponsive member
+// does not hold up collecting diagnostics from the others
+// Status is the status of a FireFly node, which is ready to use once its org and node are registered
+type Status struct {
+	Node struct {
+		Name       string `json:"name"`
+		Registered bool   `json:"registered"`
+	} `json:"node"`
+	Org struct {
+		Name       string `json:"name"`
+		Registered bool   `json:"registered"`
+	} `json:"org"`
+}
+
+// GetStatus returns the status of the member's FireFly node, without retrying if it cannot be reached
+func GetStatus(member *types.Member) (*Status, error) {
+	var status *Status
+	if err := request(http.MethodGet, member.FireflyClientURL()+"/api/v1/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func GetDiagnostics(url string) (json.RawMessage, error) {
+	var result json.RawMessage
+	if err := request(http.MethodGet, url, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetCount returns the number of rows in a collection of the default namespace of the member, such as messages
+func GetCount(member *types.Member, collection string) (int64, error) {
+	var result struct {
+		Total int64 `json:"total"`
+	}
+	if err := request(http.MethodGet, FireflyURL(member, "/"+collection+"?limit=1&count"), nil, &result); err != nil {
+		return 0, err
+	}
+	return result.Total, nil
+}
@@ -23,9 +23,42 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"sync"
 	"time"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+var (
+	stackClientsMux sync.Mutex
+	// stackClients holds the client for the FireFly servers of each stack with its own CA, by host and port
+	stackClients = make(map[string]*http.Client)
 )
 
+// SetStackClient makes requests to the FireFly API and admin servers of the members of the stack use the
+// given client, which trusts the CA of the stack
+func SetStackClient(stack *types.Stack, client *http.Client) {
+	stackClientsMux.Lock()
+	defer stackClientsMux.Unlock()
+	for _, member := range stack.Members {
+		for _, baseURL := range []string{member.FireflyURL(), member.FireflyAdminURL()} {
+			if u, err := url.Parse(baseURL); err == nil {
+				stackClients[u.Host] = client
+			}
+		}
+	}
+}
+
+func clientFor(u *url.URL) *http.Client {
+	stackClientsMux.Lock()
+	defer stackClientsMux.Unlock()
+	if client, ok := stackClients[u.Host]; ok {
+		return client
+	}
+	return &http.Client{}
+}
+
 func RequestWithRetry(method, url string, body, result interface{}) (err error) {
 	retries := 30
 	for {
@@ -64,8 +97,7 @@ func request(method, url string, body, result interface{}) (err error) {
 	if err != nil {
 		return err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := clientFor(req.URL).Do(req)
 	if err != nil {
 		return err
 	}
@@ -75,7 +107,8 @@ func request(method, url string, body, result interface{}) (err error) {
 		if resp.StatusCode != 204 {
 			responseBytes, _ = ioutil.ReadAll(resp.Body)
 		}
-		return fmt.Errorf("%s returned %d: %s", url, resp.StatusCode, responseBytes)
+		// The URL may include the member's API credentials
+		return fmt.Errorf("%s returned %d: %s", req.URL.Redacted(), resp.StatusCode, responseBytes)
 	}
 
 	if resp.StatusCode == 204 {
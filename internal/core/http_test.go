@@ -0,0 +1,56 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+func TestSetStackClientOnlyTrustsTheStackServers(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	var result map[string]interface{}
+	if err := request(http.MethodGet, server.URL+"/status", nil, &result); err == nil {
+		t.Fatal("expected the server to be untrusted before the stack client is set")
+	}
+
+	stack := &types.Stack{Name: "tls", Members: []*types.Member{{ID: "0", TLS: true, ExposedFireflyPort: port}}}
+	SetStackClient(stack, server.Client())
+	defer func() {
+		stackClientsMux.Lock()
+		delete(stackClients, u.Host)
+		stackClientsMux.Unlock()
+	}()
+	if err := request(http.MethodGet, server.URL+"/status", nil, &result); err != nil {
+		t.Fatalf("expected the stack client to trust the server: %s", err)
+	}
+	if _, err := http.Get(server.URL); err == nil {
+		t.Error("expected the default client to still not trust the server")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+const (
+	fireflyImage       = "ghcr.io/hyperledger/firefly"
+	releasesURL        = "https://api.github.com/repos/hyperledger/firefly/releases/latest"
+	manifestURLPattern = "https://raw.githubusercontent.com/hyperledger/firefly/%s/manifest.json"
+)
+
+var releaseVersionRegex = regexp.MustCompile(`^v\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+
+// ValidateRelease checks that a release is "stable", "head", or a version in the form vX.Y.Z
+func ValidateRelease(release string) error {
+	if release == "stable" || release == "head" || releaseVersionRegex.MatchString(release) {
+		return nil
+	}
+	return fmt.Errorf("\"%s\" is not a valid release. valid options are: stable, head, or a version in the form vX.Y.Z", release)
+}
+
+// GetReleaseManifest resolves the version of each FireFly component that makes up a release. The
+// component versions are read from the manifest published in the FireFly repository for the release.
+func GetReleaseManifest(release string) (*types.VersionManifest, error) {
+	gitRef := release
+	coreTag := release
+	switch release {
+	case "head":
+		gitRef = "main"
+	case "stable":
+		latest, err := getLatestReleaseTag()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find the latest FireFly release: %s", err)
+		}
+		gitRef = latest
+		coreTag = latest
+	}
+
+	var manifest *types.VersionManifest
+	if err := getJSON(fmt.Sprintf(manifestURLPattern, gitRef), &manifest); err != nil {
+		return nil, fmt.Errorf("failed to read the version manifest for release %s: %s", release, err)
+	}
+	manifest.Release = release
+	manifest.FireFly = &types.ManifestEntry{
+		Image: fireflyImage,
+		Tag:   coreTag,
+	}
+	// The cache is only needed by offline mode, so a failure to write it does not fail the lookup
+	_ = CacheReleaseManifest(manifest)
+	return manifest, nil
+}
+
+// CacheReleaseManifest saves a version manifest under the manifest cache, so the release can be
+// resolved by GetCachedReleaseManifest without network access.
+func CacheReleaseManifest(manifest *types.VersionManifest) error {
+	if manifest == nil || manifest.Release == "" {
+		return nil
+	}
+	if err := os.MkdirAll(constants.ManifestCacheDir, 0755); err != nil {
+		return err
+	}
+	manifestBytes, err := json.MarshalIndent(manifest, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cachedManifestPath(manifest.Release), manifestBytes, 0644)
+}
+
+// GetCachedReleaseManifest reads the version manifest of a release from the manifest cache, which
+// holds every manifest previously fetched by GetReleaseManifest.
+func GetCachedReleaseManifest(release string) (*types.VersionManifest, error) {
+	manifestBytes, err := ioutil.ReadFile(cachedManifestPath(release))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("the version manifest for release %s is not cached - resolve the release once while online, or run 'ff offline prepare' for a stack that uses it", release)
+	} else if err != nil {
+		return nil, err
+	}
+	var manifest *types.VersionManifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to read the cached version manifest for release %s: %s", release, err)
+	}
+	return manifest, nil
+}
+
+func cachedManifestPath(release string) string {
+	return filepath.Join(constants.ManifestCacheDir, fmt.Sprintf("%s.json", release))
+}
+
+func getLatestReleaseTag() (string, error) {
+	var latest struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := getJSON(releasesURL, &latest); err != nil {
+		return "", err
+	}
+	return latest.TagName, nil
+}
+
+func getJSON(url string, result interface{}) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%s returned %d: %s", url, resp.StatusCode, body)
+	}
+	return json.Unmarshal(body, result)
+}
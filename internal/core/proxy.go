@@ -0,0 +1,86 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// SetProxy routes every HTTP request made by the CLI through the proxy, except those to hosts it excludes.
+// Without a proxy, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used.
+func SetProxy(proxy *types.ProxyConfig) error {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil
+	}
+	if proxy == nil || (proxy.HTTP == "" && proxy.HTTPS == "") {
+		transport.Proxy = http.ProxyFromEnvironment
+		return nil
+	}
+	var httpProxy, httpsProxy *url.URL
+	var err error
+	if proxy.HTTP != "" {
+		if httpProxy, err = url.Parse(proxy.HTTP); err != nil {
+			return err
+		}
+	}
+	if proxy.HTTPS != "" {
+		if httpsProxy, err = url.Parse(proxy.HTTPS); err != nil {
+			return err
+		}
+	}
+	noProxy := proxy.NoProxyHosts()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Hostname(), noProxy) {
+			return nil, nil
+		}
+		if req.URL.Scheme == "https" {
+			return httpsProxy, nil
+		}
+		return httpProxy, nil
+	}
+	return nil
+}
+
+// bypassProxy matches a host against NO_PROXY entries, which are "*", a host, a domain that also matches
+// its subdomains, or a CIDR range of IP addresses
+func bypassProxy(host string, noProxy []string) bool {
+	ip := net.ParseIP(host)
+	for _, entry := range noProxy {
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		switch {
+		case entry == "*":
+			return true
+		case ip != nil && strings.Contains(entry, "/"):
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && cidr.Contains(ip) {
+				return true
+			}
+		case strings.EqualFold(host, entry):
+			return true
+		case strings.HasSuffix(strings.ToLower(host), "."+strings.TrimPrefix(strings.ToLower(entry), ".")):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+const releasesURLPattern = "https://api.github.com/repos/hyperledger/%s/releases?per_page=100"
+
+// componentRepos are the GitHub repositories in which the release notes of each component are published
+var componentRepos = map[string]string{
+	types.FireFlyComponent:           "firefly",
+	types.EthconnectComponent:        "firefly-ethconnect",
+	types.DataExchangeComponent:      "firefly-dataexchange-https",
+	types.TokensERC1155Component:     "firefly-tokens-erc1155",
+	types.TokensERC20ERC721Component: "firefly-tokens-erc20-erc721",
+}
+
+// ReleaseNotes are the notes of one GitHub release of a component, along with the lines of the notes
+// which mention breaking changes
+type ReleaseNotes struct {
+	Tag             string   `json:"tag" yaml:"tag"`
+	Name            string   `json:"name" yaml:"name"`
+	URL             string   `json:"url" yaml:"url"`
+	Body            string   `json:"body,omitempty" yaml:"body,omitempty"`
+	BreakingChanges []string `json:"breakingChanges,omitempty" yaml:"breakingChanges,omitempty"`
+}
+
+// GetReleaseNotes returns the notes of every release of a component after the from tag, up to and
+// including the to tag, oldest first. If either tag is not a version, only the notes of the to tag
+// are returned.
+func GetReleaseNotes(component string, fromTag string, toTag string) ([]*ReleaseNotes, error) {
+	repo, ok := componentRepos[component]
+	if !ok {
+		return nil, fmt.Errorf("release notes are not published for %s", component)
+	}
+	var releases []struct {
+		TagName string `json:"tag_name"`
+		Name    string `json:"name"`
+		HTMLURL string `json:"html_url"`
+		Body    string `json:"body"`
+		Draft   bool   `json:"draft"`
+	}
+	if err := getJSON(fmt.Sprintf(releasesURLPattern, repo), &releases); err != nil {
+		return nil, err
+	}
+
+	from, fromOK := parseVersion(fromTag)
+	to, toOK := parseVersion(toTag)
+	notes := make([]*ReleaseNotes, 0)
+	// GitHub lists the newest releases first
+	for i := len(releases) - 1; i >= 0; i-- {
+		release := releases[i]
+		if release.Draft {
+			continue
+		}
+		if fromOK && toOK {
+			version, ok := parseVersion(release.TagName)
+			if !ok || compareVersions(version, from) <= 0 || compareVersions(version, to) > 0 {
+				continue
+			}
+		} else if release.TagName != toTag {
+			continue
+		}
+		notes = append(notes, &ReleaseNotes{
+			Tag:             release.TagName,
+			Name:            release.Name,
+			URL:             release.HTMLURL,
+			Body:            strings.TrimSpace(strings.ReplaceAll(release.Body, "\r\n", "\n")),
+			BreakingChanges: findBreakingChanges(release.Body),
+		})
+	}
+	return notes, nil
+}
+
+// findBreakingChanges returns the lines of release notes which mention a breaking change, along with
+// the items listed under a heading that does
+func findBreakingChanges(body string) []string {
+	changes := make([]string, 0)
+	underHeading := false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		breaking := strings.Contains(strings.ToLower(line), "breaking")
+		switch {
+		case strings.HasPrefix(line, "#"):
+			underHeading = breaking
+		case line == "":
+		case breaking || underHeading:
+			changes = append(changes, strings.TrimSpace(strings.TrimLeft(line, "-*")))
+		}
+	}
+	return changes
+}
+
+// parseVersion parses a tag in the form vX.Y.Z, ignoring any pre-release suffix
+func parseVersion(tag string) ([3]int, bool) {
+	var version [3]int
+	if !releaseVersionRegex.MatchString(tag) {
+		return version, false
+	}
+	core := strings.SplitN(strings.TrimPrefix(tag, "v"), "-", 2)[0]
+	for i, part := range strings.Split(core, ".") {
+		version[i], _ = strconv.Atoi(part)
+	}
+	return version, true
+}
+
+// IsVersionBefore checks whether a tag in the form vX.Y.Z is a version earlier than the minimum. Tags that are
+// not versions, such as "head", are never earlier.
+func IsVersionBefore(tag string, minimum string) bool {
+	version, ok := parseVersion(tag)
+	minimumVersion, minimumOK := parseVersion(minimum)
+	return ok && minimumOK && compareVersions(version, minimumVersion) < 0
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return 0
+}
@@ -0,0 +1,149 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/log"
+)
+
+type OperationStatus string
+
+const (
+	OperationRunning   OperationStatus = "running"
+	OperationSucceeded OperationStatus = "succeeded"
+	OperationFailed    OperationStatus = "failed"
+)
+
+// Event is a progress message logged by the stack manager while running an operation
+type Event struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+}
+
+type OperationInfo struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	Stack    string          `json:"stack"`
+	Status   OperationStatus `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Created  time.Time       `json:"created"`
+	Finished *time.Time      `json:"finished,omitempty"`
+}
+
+// Operation is a long running action on a stack, such as creating or starting it. It is the logger
+// given to the stack manager, so everything the stack manager logs becomes an event of the operation.
+type Operation struct {
+	info    OperationInfo
+	level   log.LogLevel
+	events  []*Event
+	changed chan struct{}
+	mux     sync.Mutex
+}
+
+func newOperation(id, opType, stackName string, verbose bool) *Operation {
+	op := &Operation{
+		info: OperationInfo{
+			ID:      id,
+			Type:    opType,
+			Stack:   stackName,
+			Status:  OperationRunning,
+			Created: time.Now(),
+		},
+		level:   log.Info,
+		changed: make(chan struct{}),
+	}
+	if verbose {
+		op.level = log.Debug
+	}
+	return op
+}
+
+func (op *Operation) Status() OperationInfo {
+	op.mux.Lock()
+	defer op.mux.Unlock()
+	return op.info
+}
+
+// EventsSince returns the events after the first n, whether the operation has finished, and a channel
+// that is closed the next time the operation changes
+func (op *Operation) EventsSince(n int) ([]*Event, bool, <-chan struct{}) {
+	op.mux.Lock()
+	defer op.mux.Unlock()
+	var events []*Event
+	if n < len(op.events) {
+		events = append(events, op.events[n:]...)
+	}
+	return events, op.info.Status != OperationRunning, op.changed
+}
+
+func (op *Operation) finish(err error) {
+	op.mux.Lock()
+	defer op.mux.Unlock()
+	now := time.Now()
+	op.info.Finished = &now
+	if err != nil {
+		op.info.Status = OperationFailed
+		op.info.Error = err.Error()
+	} else {
+		op.info.Status = OperationSucceeded
+	}
+	op.notify()
+}
+
+func (op *Operation) record(level log.LogLevel, name, message string) {
+	if level < op.level {
+		return
+	}
+	op.mux.Lock()
+	defer op.mux.Unlock()
+	op.events = append(op.events, &Event{Time: time.Now(), Level: name, Message: message})
+	op.notify()
+}
+
+func (op *Operation) notify() {
+	close(op.changed)
+	op.changed = make(chan struct{})
+}
+
+func (op *Operation) SetLogLevel(l log.LogLevel) {
+	op.level = l
+}
+
+func (op *Operation) Trace(s string) {
+	op.record(log.Trace, "trace", s)
+}
+
+func (op *Operation) Debug(s string) {
+	op.record(log.Debug, "debug", s)
+}
+
+func (op *Operation) Info(s string) {
+	op.record(log.Info, "info", s)
+}
+
+func (op *Operation) Warn(s string) {
+	op.record(log.Warn, "warn", s)
+}
+
+func (op *Operation) Error(e error) {
+	op.record(log.Error, "error", fmt.Sprint(e))
+}
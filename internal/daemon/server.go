@@ -0,0 +1,473 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+)
+
+const apiPrefix = "/api/v1/"
+
+//go:embed ui/index.html
+var dashboard []byte
+
+type UpgradeOptions struct {
+	Release       string `json:"release"`
+	StatelessOnly bool   `json:"statelessOnly"`
+	// Upgrade even if the release notes of a component mention breaking changes
+	Force bool `json:"force"`
+}
+
+type StackSummary struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+	Error   string `json:"error,omitempty"`
+}
+
+type StackDetails struct {
+	StackSummary
+	Endpoints *stacks.StackEndpoints `json:"endpoints,omitempty"`
+}
+
+// Server exposes the stack manager over a local REST API, and serves a dashboard that uses it. Changes
+// to stacks are run as operations in the background, and their progress can be streamed while they run.
+type Server struct {
+	Log     log.Logger
+	Token   string
+	Verbose bool
+	// Only accept requests addressed to localhost, so web pages cannot reach the API by DNS rebinding
+	LocalOnly bool
+
+	operations map[string]*Operation
+	lastID     int
+	opsMux     sync.Mutex
+	// The stack manager configures the docker package globally for the stack it loads, so only one
+	// stack is worked on at a time
+	stackMux sync.Mutex
+}
+
+func NewServer(logger log.Logger, token string, verbose bool) *Server {
+	return &Server{
+		Log:        logger,
+		Token:      token,
+		Verbose:    verbose,
+		LocalOnly:  true,
+		operations: make(map[string]*Operation),
+	}
+}
+
+// GenerateToken returns a random bearer token for a daemon started without one
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// IsLoopback reports whether the host of an address, such as a listen address or a Host header,
+// can only be reached from this machine
+func IsLoopback(address string) bool {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// checkSource rejects requests addressed to another host name, which reach a local daemon through
+// DNS rebinding, and requests made by web pages other than the dashboard
+func (s *Server) checkSource(r *http.Request) error {
+	if s.LocalOnly && !IsLoopback(r.Host) {
+		return fmt.Errorf("requests must be addressed to localhost")
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return fmt.Errorf("cross-origin requests are not allowed")
+		}
+	}
+	return nil
+}
+
+// Serve handles requests on the listener until the stop channel is closed
+func (s *Server) Serve(listener net.Listener, stop <-chan struct{}) error {
+	server := &http.Server{
+		Handler: s,
+	}
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			server.Close()
+		}
+	}()
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkSource(r); err != nil {
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+	// The dashboard itself holds no data, so is served without the token, which it asks for instead
+	if r.Method == http.MethodGet && r.URL.Path == "/" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(dashboard)
+		return
+	}
+	expected := "Bearer " + s.Token
+	if s.Token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+		writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid bearer token"))
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, apiPrefix) {
+		writeError(w, http.StatusNotFound, fmt.Errorf("not found"))
+		return
+	}
+	path := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, apiPrefix), "/"), "/")
+	route := fmt.Sprintf("%s %s", r.Method, path[0])
+	if len(path) > 1 {
+		route += " {}" + strings.Join(append([]string{""}, path[2:]...), " ")
+	}
+	switch route {
+	case "GET stacks":
+		s.listStacks(w)
+	case "POST stacks":
+		s.createStack(w, r)
+	case "GET stacks {}":
+		s.getStack(w, path[1])
+	case "POST stacks {} start":
+		s.startStack(w, r, path[1])
+	case "POST stacks {} stop":
+		s.stopStack(w, path[1])
+	case "POST stacks {} reset":
+		s.resetStack(w, path[1])
+	case "GET stacks {} logs":
+		s.getLogs(w, r, path[1])
+	case "GET stacks {} upgrade":
+		s.getUpgrade(w, r, path[1])
+	case "POST stacks {} upgrade":
+		s.upgradeStack(w, r, path[1])
+	case "GET templates":
+		writeJSON(w, http.StatusOK, Templates)
+	case "GET operations":
+		s.listOperations(w)
+	case "GET operations {}":
+		s.getOperation(w, path[1])
+	case "GET operations {} events":
+		s.streamEvents(w, r, path[1])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route for %s %s", r.Method, r.URL.Path))
+	}
+}
+
+func (s *Server) listStacks(w http.ResponseWriter) {
+	names, err := stacks.ListStacks()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	summaries := make([]*StackSummary, 0, len(names))
+	for _, name := range names {
+		summaries = append(summaries, &s.inspectStack(name, false).StackSummary)
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
+
+func (s *Server) getStack(w http.ResponseWriter, stackName string) {
+	if exists, err := stacks.CheckExists(stackName); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	} else if !exists {
+		writeError(w, http.StatusNotFound, fmt.Errorf("stack '%s' does not exist", stackName))
+		return
+	}
+	writeJSON(w, http.StatusOK, s.inspectStack(stackName, true))
+}
+
+func (s *Server) inspectStack(stackName string, withEndpoints bool) *StackDetails {
+	s.stackMux.Lock()
+	defer s.stackMux.Unlock()
+	details := &StackDetails{StackSummary: StackSummary{Name: stackName}}
+	stackManager := stacks.NewStackManager(&log.StdoutLogger{LogLevel: log.Error})
+	if err := stackManager.LoadStack(stackName); err != nil {
+		details.Error = err.Error()
+		return details
+	}
+	running, err := stackManager.IsRunning(s.Verbose)
+	if err != nil {
+		details.Error = err.Error()
+	}
+	details.Running = running
+	if withEndpoints {
+		details.Endpoints = stackManager.GetEndpoints()
+	}
+	return details
+}
+
+func (s *Server) createStack(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	spec, err := stacks.ParseStackSpec(body, "request body")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.runOperation(w, "create", spec.Name, func(stackManager *stacks.StackManager) error {
+		return stackManager.InitStackFromSpec(spec, s.Verbose)
+	})
+}
+
+func (s *Server) startStack(w http.ResponseWriter, r *http.Request, stackName string) {
+	options := &stacks.StartOptions{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(options); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid start options: %s", err))
+			return
+		}
+	}
+	s.runOperation(w, "start", stackName, func(stackManager *stacks.StackManager) error {
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+		return stackManager.StartStack(false, s.Verbose, options)
+	})
+}
+
+func (s *Server) stopStack(w http.ResponseWriter, stackName string) {
+	s.runOperation(w, "stop", stackName, func(stackManager *stacks.StackManager) error {
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+		return stackManager.StopStack(s.Verbose)
+	})
+}
+
+func (s *Server) resetStack(w http.ResponseWriter, stackName string) {
+	s.runOperation(w, "reset", stackName, func(stackManager *stacks.StackManager) error {
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+		return stackManager.ResetStack(s.Verbose)
+	})
+}
+
+func (s *Server) getLogs(w http.ResponseWriter, r *http.Request, stackName string) {
+	tail := 200
+	if value := r.URL.Query().Get("tail"); value != "" {
+		var err error
+		if tail, err = strconv.Atoi(value); err != nil || tail <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid tail '%s'", value))
+			return
+		}
+	}
+	s.withStack(w, stackName, func(stackManager *stacks.StackManager) {
+		logs, err := stackManager.GetLogs(r.URL.Query().Get("service"), tail, s.Verbose)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte(logs))
+	})
+}
+
+// getUpgrade previews an upgrade, returning the version change and release notes of each component
+func (s *Server) getUpgrade(w http.ResponseWriter, r *http.Request, stackName string) {
+	s.withStack(w, stackName, func(stackManager *stacks.StackManager) {
+		manifest, err := stackManager.GetUpgradeManifest(r.URL.Query().Get("release"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, stackManager.GetVersionChanges(manifest))
+	})
+}
+
+func (s *Server) upgradeStack(w http.ResponseWriter, r *http.Request, stackName string) {
+	options := &UpgradeOptions{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(options); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid upgrade options: %s", err))
+			return
+		}
+	}
+	s.runOperation(w, "upgrade", stackName, func(stackManager *stacks.StackManager) error {
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return err
+		}
+		manifest, err := stackManager.GetUpgradeManifest(options.Release)
+		if err != nil {
+			return err
+		}
+		changes := stackManager.GetVersionChanges(manifest)
+		for _, change := range changes {
+			stackManager.Log.Info(fmt.Sprintf("%s: %s -> %s", change.Component, change.From, change.To))
+		}
+		if stacks.HasBreakingChanges(changes) && !options.Force {
+			return fmt.Errorf("the release notes mention breaking changes - set force to upgrade anyway")
+		}
+		return stackManager.UpgradeStack(manifest, options.StatelessOnly, s.Verbose)
+	})
+}
+
+// withStack loads a stack for a request that is answered straight away, rather than by an operation
+func (s *Server) withStack(w http.ResponseWriter, stackName string, handle func(stackManager *stacks.StackManager)) {
+	if exists, err := stacks.CheckExists(stackName); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	} else if !exists {
+		writeError(w, http.StatusNotFound, fmt.Errorf("stack '%s' does not exist", stackName))
+		return
+	}
+	s.stackMux.Lock()
+	defer s.stackMux.Unlock()
+	stackManager := stacks.NewStackManager(&log.StdoutLogger{LogLevel: log.Error})
+	if err := stackManager.LoadStack(stackName); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	handle(stackManager)
+}
+
+func (s *Server) runOperation(w http.ResponseWriter, opType, stackName string, run func(stackManager *stacks.StackManager) error) {
+	s.opsMux.Lock()
+	s.lastID++
+	op := newOperation(strconv.Itoa(s.lastID), opType, stackName, s.Verbose)
+	s.operations[op.info.ID] = op
+	s.opsMux.Unlock()
+
+	s.Log.Info(fmt.Sprintf("operation %s: %s stack '%s'", op.info.ID, opType, stackName))
+	go func() {
+		s.stackMux.Lock()
+		defer s.stackMux.Unlock()
+		err := run(stacks.NewStackManager(op))
+		op.finish(err)
+		if err != nil {
+			s.Log.Warn(fmt.Sprintf("operation %s failed: %s", op.info.ID, err))
+		} else {
+			s.Log.Info(fmt.Sprintf("operation %s succeeded", op.info.ID))
+		}
+	}()
+	writeJSON(w, http.StatusAccepted, op.Status())
+}
+
+func (s *Server) getOperationByID(id string) *Operation {
+	s.opsMux.Lock()
+	defer s.opsMux.Unlock()
+	return s.operations[id]
+}
+
+func (s *Server) listOperations(w http.ResponseWriter) {
+	s.opsMux.Lock()
+	ops := make([]OperationInfo, 0, len(s.operations))
+	for _, op := range s.operations {
+		ops = append(ops, op.Status())
+	}
+	s.opsMux.Unlock()
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].Created.Before(ops[j].Created)
+	})
+	writeJSON(w, http.StatusOK, ops)
+}
+
+func (s *Server) getOperation(w http.ResponseWriter, id string) {
+	op := s.getOperationByID(id)
+	if op == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("operation '%s' does not exist", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, op.Status())
+}
+
+// streamEvents writes the events of an operation as newline delimited JSON, from the start of the
+// operation until it finishes or the client goes away
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, id string) {
+	op := s.getOperationByID(id)
+	if op == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("operation '%s' does not exist", id))
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	sent := 0
+	for {
+		events, finished, changed := op.EventsSince(sent)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+		}
+		sent += len(events)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if finished {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
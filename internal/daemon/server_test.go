@@ -0,0 +1,113 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/firefly-cli/internal/log"
+)
+
+func TestServeHTTPRejectsUnauthorizedRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		localOnly bool
+		host      string
+		origin    string
+		auth      string
+		status    int
+	}{
+		{name: "valid token", token: "secret", localOnly: true, host: "127.0.0.1:5999", auth: "Bearer secret", status: http.StatusOK},
+		{name: "localhost host", token: "secret", localOnly: true, host: "localhost:5999", auth: "Bearer secret", status: http.StatusOK},
+		{name: "ipv6 loopback host", token: "secret", localOnly: true, host: "[::1]:5999", auth: "Bearer secret", status: http.StatusOK},
+		{name: "missing token", token: "secret", localOnly: true, host: "127.0.0.1:5999", status: http.StatusUnauthorized},
+		{name: "wrong token", token: "secret", localOnly: true, host: "127.0.0.1:5999", auth: "Bearer guess", status: http.StatusUnauthorized},
+		{name: "no token configured", localOnly: true, host: "127.0.0.1:5999", auth: "Bearer ", status: http.StatusUnauthorized},
+		{name: "rebound host", token: "secret", localOnly: true, host: "attacker.example.com:5999", auth: "Bearer secret", status: http.StatusForbidden},
+		{name: "cross-origin", token: "secret", localOnly: true, host: "127.0.0.1:5999", origin: "http://attacker.example.com", auth: "Bearer secret", status: http.StatusForbidden},
+		{name: "null origin", token: "secret", localOnly: true, host: "127.0.0.1:5999", origin: "null", auth: "Bearer secret", status: http.StatusForbidden},
+		{name: "same origin", token: "secret", localOnly: true, host: "127.0.0.1:5999", origin: "http://127.0.0.1:5999", auth: "Bearer secret", status: http.StatusOK},
+		{name: "other host when not local only", token: "secret", host: "devbox.example.com:5999", auth: "Bearer secret", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := NewServer(&log.StdoutLogger{LogLevel: log.Error}, test.token, false)
+			server.LocalOnly = test.localOnly
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/templates", nil)
+			req.Host = test.host
+			if test.origin != "" {
+				req.Header.Set("Origin", test.origin)
+			}
+			if test.auth != "" {
+				req.Header.Set("Authorization", test.auth)
+			}
+			res := httptest.NewRecorder()
+			server.ServeHTTP(res, req)
+			if res.Code != test.status {
+				t.Errorf("expected status %d, got %d: %s", test.status, res.Code, res.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeHTTPDashboardRequiresLocalHost(t *testing.T) {
+	server := NewServer(&log.StdoutLogger{LogLevel: log.Error}, "secret", false)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "attacker.example.com"
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d", http.StatusForbidden, res.Code)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:5999":  true,
+		"127.0.0.1":       true,
+		"localhost:5999":  true,
+		"LOCALHOST":       true,
+		"[::1]:5999":      true,
+		"0.0.0.0:5999":    false,
+		":5999":           false,
+		"10.0.0.5:5999":   false,
+		"example.com:80":  false,
+		"localhost.a.com": false,
+	}
+	for address, expected := range tests {
+		if actual := IsLoopback(address); actual != expected {
+			t.Errorf("IsLoopback(%q) = %t, expected %t", address, actual, expected)
+		}
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	a, err := GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GenerateToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != 64 || a == b {
+		t.Errorf("expected distinct 64 character tokens, got %q and %q", a, b)
+	}
+}
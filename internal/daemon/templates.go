@@ -0,0 +1,56 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import "github.com/hyperledger/firefly-cli/internal/stacks"
+
+// Template is a starting point for a new stack. Any keys left out of the spec take the same defaults as
+// the init command.
+type Template struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Spec        *stacks.StackSpec `json:"spec"`
+}
+
+var Templates = []*Template{
+	{
+		Name:        "minimal",
+		Description: "One member with SQLite and no tokens connector - the quickest stack to start",
+		Spec: &stacks.StackSpec{
+			Members:         1,
+			TokensProviders: []string{stacks.NilTokens.String()},
+		},
+	},
+	{
+		Name:        "default",
+		Description: "Two members with SQLite and the ERC1155 tokens connector, as created by ff init",
+		Spec: &stacks.StackSpec{
+			Members: 2,
+		},
+	},
+	{
+		Name:        "full",
+		Description: "Three members with PostgreSQL, both tokens connectors, a Kafka event bridge and monitoring",
+		Spec: &stacks.StackSpec{
+			Members:         3,
+			Database:        stacks.PostgreSQL.String(),
+			TokensProviders: []string{stacks.ERC1155.String(), stacks.ERC20ERC721.String()},
+			EventBridge:     stacks.Kafka.String(),
+			Monitoring:      true,
+		},
+	},
+}
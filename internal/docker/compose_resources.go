@@ -0,0 +1,89 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	ContainerResource = "container"
+	VolumeResource    = "volume"
+	NetworkResource   = "network"
+)
+
+// ComposeResource is a container, volume or network that belongs to a docker compose project
+type ComposeResource struct {
+	Type    string `json:"type" yaml:"type"`
+	Name    string `json:"name" yaml:"name"`
+	Project string `json:"project" yaml:"project"`
+	// Directory of the compose file of the project, which is only recorded on containers
+	WorkingDir string `json:"-" yaml:"-"`
+	// Whether a container is running
+	Running bool `json:"-" yaml:"-"`
+}
+
+// ListComposeResources returns the containers and networks created by docker compose, and every volume - volumes
+// copied or imported with the docker CLI have no compose labels, so their project is left empty
+func ListComposeResources(verbose bool) ([]*ComposeResource, error) {
+	projectFormat := engine.LabelFormat("com.docker.compose.project")
+	workingDirFormat := engine.LabelFormat("com.docker.compose.project.working_dir")
+	resources := make([]*ComposeResource, 0)
+	for _, resourceType := range []string{ContainerResource, VolumeResource, NetworkResource} {
+		args := []string{resourceType, "ls"}
+		switch resourceType {
+		case ContainerResource:
+			args = append(args, "-a", "--filter", "label=com.docker.compose.project", "--format", fmt.Sprintf("{{.Names}}\t%s\t%s\t{{.State}}", projectFormat, workingDirFormat))
+		case VolumeResource:
+			args = append(args, "--format", fmt.Sprintf("{{.Name}}\t%s", projectFormat))
+		case NetworkResource:
+			args = append(args, "--filter", "label=com.docker.compose.project", "--format", fmt.Sprintf("{{.Name}}\t%s", projectFormat))
+		}
+		output, err := RunDockerCommandBuffered(".", verbose, args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(output, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			fields := strings.Split(line, "\t")
+			for len(fields) < 4 {
+				fields = append(fields, "")
+			}
+			project := strings.TrimSpace(fields[1])
+			if project == "<no value>" {
+				project = ""
+			}
+			resources = append(resources, &ComposeResource{
+				Type:       resourceType,
+				Name:       strings.TrimSpace(fields[0]),
+				Project:    project,
+				WorkingDir: strings.TrimSpace(fields[2]),
+				Running:    resourceType == ContainerResource && strings.TrimSpace(fields[3]) == "running",
+			})
+		}
+	}
+	return resources, nil
+}
+
+// RemoveComposeResource removes a container, volume or network. Containers are not forced, so a container that
+// is running is never removed.
+func RemoveComposeResource(resource *ComposeResource, verbose bool) error {
+	return RunDockerCommand(".", verbose, verbose, resource.Type, "rm", resource.Name)
+}
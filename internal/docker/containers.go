@@ -0,0 +1,131 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ContainerStatus struct {
+	ID      string `json:"id" yaml:"id"`
+	Name    string `json:"name" yaml:"name"`
+	Service string `json:"service" yaml:"service"`
+	Image   string `json:"image" yaml:"image"`
+	State   string `json:"state" yaml:"state"`
+	Status  string `json:"status" yaml:"status"`
+	Ports   string `json:"ports,omitempty" yaml:"ports,omitempty"`
+}
+
+// ListProjectContainers returns every container, running or not, that docker compose
+// created for the given project
+func ListProjectContainers(projectName string, verbose bool) ([]*ContainerStatus, error) {
+	output, err := RunDockerCommandBuffered(".", verbose, "ps", "-a", "--filter", fmt.Sprintf("label=com.docker.compose.project=%s", projectName), "--format", engine.ContainerStatusFormat())
+	if err != nil {
+		return nil, err
+	}
+	containers := make([]*ContainerStatus, 0)
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		for len(fields) < 7 {
+			fields = append(fields, "")
+		}
+		containers = append(containers, &ContainerStatus{
+			ID:      fields[0],
+			Name:    fields[1],
+			Service: fields[2],
+			Image:   fields[3],
+			State:   fields[4],
+			Status:  fields[5],
+			Ports:   fields[6],
+		})
+	}
+	return containers, nil
+}
+
+type ContainerState struct {
+	Name         string
+	Service      string
+	Status       string
+	OOMKilled    bool
+	ExitCode     int
+	RestartCount int
+	// When the container was last started, which is zero if it has never started
+	StartedAt time.Time
+	// Memory limit of the container in bytes, or zero if the container is not limited
+	MemoryLimit int64
+}
+
+const containerStateFormat = `{{.Name}}	{{index .Config.Labels "com.docker.compose.service"}}	{{.State.Status}}	{{.State.OOMKilled}}	{{.State.ExitCode}}	{{.RestartCount}}	{{.HostConfig.Memory}}	{{.State.StartedAt}}`
+
+// InspectContainers returns the state of each of the given containers
+func InspectContainers(ids []string, verbose bool) ([]*ContainerState, error) {
+	if len(ids) == 0 {
+		return []*ContainerState{}, nil
+	}
+	args := append([]string{"inspect", "--format", containerStateFormat}, ids...)
+	output, err := RunDockerCommandBuffered(".", verbose, args...)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*ContainerState, 0, len(ids))
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 8 {
+			continue
+		}
+		state := &ContainerState{
+			Name:      strings.TrimPrefix(fields[0], "/"),
+			Service:   fields[1],
+			Status:    fields[2],
+			OOMKilled: fields[3] == "true",
+		}
+		state.ExitCode, _ = strconv.Atoi(fields[4])
+		state.RestartCount, _ = strconv.Atoi(fields[5])
+		state.MemoryLimit, _ = strconv.ParseInt(fields[6], 10, 64)
+		state.StartedAt, _ = time.Parse(time.RFC3339Nano, fields[7])
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// WaitForHealthy waits for the container's health check to pass. Containers without a health check
+// only need to be running.
+func WaitForHealthy(containerName string, timeout time.Duration, verbose bool) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		output, err := RunDockerCommandBuffered(".", verbose, "inspect", "--format", "{{if .State.Health}}{{.State.Health.Status}}{{else}}{{.State.Status}}{{end}}", containerName)
+		if err != nil {
+			return err
+		}
+		switch strings.TrimSpace(output) {
+		case "healthy", "running":
+			return nil
+		case "exited", "dead":
+			return fmt.Errorf("container %s stopped while waiting for it to become healthy", containerName)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for container %s to become healthy", containerName)
+		}
+		time.Sleep(time.Second)
+	}
+}
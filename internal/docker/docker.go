@@ -18,42 +18,246 @@ package docker
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"path"
 	"strings"
 )
 
+// UtilityImage is the image used to run short-lived containers that copy files into volumes
+var UtilityImage = "alpine"
+
 func CreateVolume(volumeName string, verbose bool) error {
 	return RunDockerCommand(".", verbose, verbose, "volume", "create", volumeName)
 }
 
+// CopyFileToVolume copies the file in through a stopped container rather than a bind mount, so that it
+// also works when the docker daemon is on a remote host
 func CopyFileToVolume(volumeName string, sourcePath string, destPath string, verbose bool) error {
-	fileName := path.Base(sourcePath)
-	return RunDockerCommand(".", verbose, verbose, "run", "--rm", "-v", fmt.Sprintf("%s:/source/%s", sourcePath, fileName), "-v", fmt.Sprintf("%s:/dest", volumeName), "alpine", "cp", path.Join("/", "source", fileName), path.Join("/", "dest", destPath))
+	output, err := RunDockerCommandBuffered(".", verbose, "create", "-v", fmt.Sprintf("%s:/dest", volumeName), UtilityImage)
+	if err != nil {
+		return err
+	}
+	containerID := strings.TrimSpace(output)
+	defer RunDockerCommand(".", verbose, verbose, "rm", containerID)
+	return RunDockerCommand(".", verbose, verbose, "cp", sourcePath, fmt.Sprintf("%s:%s", containerID, path.Join("/", "dest", destPath)))
+}
+
+// ExportVolume writes the contents of the volume to w as a tar stream, through a stopped container so that it
+// also works when the docker daemon is on a remote host
+func ExportVolume(volumeName string, w io.Writer, verbose bool) error {
+	output, err := RunDockerCommandBuffered(".", verbose, "create", "-v", fmt.Sprintf("%s:/src", volumeName), UtilityImage)
+	if err != nil {
+		return err
+	}
+	containerID := strings.TrimSpace(output)
+	defer RunDockerCommand(".", verbose, verbose, "rm", containerID)
+	cpCmd := newCommand(engine.Name(), "cp", containerID+":/src/.", "-")
+	cpCmd.Stdout = w
+	var stderr bytes.Buffer
+	cpCmd.Stderr = &stderr
+	if verbose {
+		fmt.Println(cpCmd.String())
+	}
+	if err := cpCmd.Run(); err != nil {
+		return fmt.Errorf("failed to export volume %s: %s", volumeName, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// ImportVolume creates the volume and extracts a tar stream written by ExportVolume into it, keeping the
+// owners of the files
+func ImportVolume(volumeName string, r io.Reader, verbose bool) error {
+	if err := CreateVolume(volumeName, verbose); err != nil {
+		return err
+	}
+	output, err := RunDockerCommandBuffered(".", verbose, "create", "-v", fmt.Sprintf("%s:/dest", volumeName), UtilityImage)
+	if err != nil {
+		return err
+	}
+	containerID := strings.TrimSpace(output)
+	defer RunDockerCommand(".", verbose, verbose, "rm", containerID)
+	cpCmd := newCommand(engine.Name(), "cp", "-a", "-", containerID+":/dest")
+	cpCmd.Stdin = r
+	var stderr bytes.Buffer
+	cpCmd.Stderr = &stderr
+	if verbose {
+		fmt.Println(cpCmd.String())
+	}
+	if err := cpCmd.Run(); err != nil {
+		return fmt.Errorf("failed to import volume %s: %s", volumeName, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// CopyVolume creates a volume with a copy of the contents of another, keeping the owners of the files
+func CopyVolume(fromVolumeName string, toVolumeName string, verbose bool) error {
+	if err := CreateVolume(toVolumeName, verbose); err != nil {
+		return err
+	}
+	return RunDockerCommand(".", verbose, verbose, "run", "--rm", "-v", fmt.Sprintf("%s:/src", fromVolumeName), "-v", fmt.Sprintf("%s:/dest", toVolumeName), UtilityImage, "cp", "-a", "/src/.", "/dest/")
 }
 
 func MkdirInVolume(volumeName string, directory string, verbose bool) error {
-	return RunDockerCommand(".", verbose, verbose, "run", "--rm", "-v", fmt.Sprintf("%s:/dest", volumeName), "alpine", "mkdir", "-p", path.Join("/", "dest", directory))
+	return RunDockerCommand(".", verbose, verbose, "run", "--rm", "-v", fmt.Sprintf("%s:/dest", volumeName), UtilityImage, "mkdir", "-p", path.Join("/", "dest", directory))
+}
+
+func RemoveFromVolume(volumeName string, file string, verbose bool) error {
+	return RunDockerCommand(".", verbose, verbose, "run", "--rm", "-v", fmt.Sprintf("%s:/dest", volumeName), UtilityImage, "rm", "-rf", path.Join("/", "dest", file))
+}
+
+// ListVolumes returns the names of all volumes whose name starts with the prefix
+func ListVolumes(prefix string, verbose bool) ([]string, error) {
+	output, err := RunDockerCommandBuffered(".", verbose, "volume", "ls", "--filter", "name="+prefix, "--format", "{{.Name}}")
+	if err != nil {
+		return nil, err
+	}
+	volumes := make([]string, 0)
+	for _, name := range strings.Split(output, "\n") {
+		// The name filter matches anywhere in the name
+		if name = strings.TrimSpace(name); strings.HasPrefix(name, prefix) {
+			volumes = append(volumes, name)
+		}
+	}
+	return volumes, nil
+}
+
+// ImageExists returns whether the image has already been pulled
+func ImageExists(image string, verbose bool) (bool, error) {
+	output, err := RunDockerCommandBuffered(".", verbose, "images", "-q", image)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) != "", nil
+}
+
+// GetImageDigest returns the image as a reference pinned by the digest it was pulled with, or an empty string
+// if the image was built locally and has no digest
+func GetImageDigest(image string, verbose bool) (string, error) {
+	output, err := RunDockerCommandBuffered(".", verbose, "image", "inspect", "--format", "{{range .RepoDigests}}{{println .}}{{end}}", image)
+	if err != nil {
+		return "", err
+	}
+	// The repository of the image, without its tag or digest
+	repository := strings.SplitN(image, "@", 2)[0]
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository = repository[:i]
+	}
+	digests := strings.Fields(output)
+	for _, digest := range digests {
+		if strings.HasPrefix(digest, repository+"@") {
+			return digest, nil
+		}
+	}
+	// An image tagged from another repository is pinned to the repository it was pulled from
+	if len(digests) > 0 {
+		return digests[0], nil
+	}
+	return "", nil
 }
 
 func RemoveVolume(volumeName string, verbose bool) error {
 	return RunDockerCommand(".", verbose, verbose, "volume", "remove", volumeName)
 }
 
+// Login authenticates docker with a registry, passing the password on stdin so that it is not visible in the process list
+func Login(registry string, username string, password string, verbose bool) error {
+	dockerCmd := newCommand(engine.Name(), "login", registry, "--username", username, "--password-stdin")
+	dockerCmd.Stdin = strings.NewReader(password)
+	if verbose {
+		fmt.Println(dockerCmd.String())
+	}
+	if output, err := dockerCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to log in to %s: %s", registry, output)
+	}
+	return nil
+}
+
 func RunDockerCommand(workingDir string, showCommand bool, pipeStdout bool, command ...string) error {
-	dockerCmd := exec.Command("docker", command...)
+	dockerCmd := newCommand(engine.Name(), command...)
+	dockerCmd.Dir = workingDir
+	showCommand, pipeStdout = outputOptions(showCommand, pipeStdout)
+	return runCommand(dockerCmd, showCommand, pipeStdout, command...)
+}
+
+// RunDockerCommandWithEnv runs a docker command with extra environment variables, which a container can be given
+// with "-e NAME" so that secret values are not visible in the process list or in the command shown
+func RunDockerCommandWithEnv(workingDir string, env []string, showCommand bool, pipeStdout bool, command ...string) error {
+	dockerCmd := newCommand(engine.Name(), command...)
+	if dockerCmd.Env == nil {
+		dockerCmd.Env = os.Environ()
+	}
+	dockerCmd.Env = append(dockerCmd.Env, env...)
 	dockerCmd.Dir = workingDir
+	showCommand, pipeStdout = outputOptions(showCommand, pipeStdout)
 	return runCommand(dockerCmd, showCommand, pipeStdout, command...)
 }
 
+// RunDockerCommandInteractive runs a docker command attached to the terminal, for commands such as a shell in
+// a container that the user works in directly
+func RunDockerCommandInteractive(showCommand bool, command ...string) error {
+	dockerCmd := newCommand(engine.Name(), command...)
+	dockerCmd.Stdin = os.Stdin
+	dockerCmd.Stdout = os.Stdout
+	dockerCmd.Stderr = os.Stderr
+	if showCommand, _ = outputOptions(showCommand, false); showCommand {
+		fmt.Println(dockerCmd.String())
+	}
+	return dockerCmd.Run()
+}
+
+func RunDockerCommandBuffered(workingDir string, showCommand bool, command ...string) (string, error) {
+	dockerCmd := newCommand(engine.Name(), command...)
+	dockerCmd.Dir = workingDir
+	if showCommand, _ = outputOptions(showCommand, false); showCommand {
+		fmt.Println(dockerCmd.String())
+	}
+	output, err := dockerCmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return "", fmt.Errorf("%s\nFailed [%d] %s", strings.Join(dockerCmd.Args, " "), exitErr.ExitCode(), failureOutput(string(exitErr.Stderr)))
+	} else if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
 func RunDockerComposeCommand(workingDir string, showCommand bool, pipeStdout bool, command ...string) error {
-	dockerCmd := exec.Command("docker-compose", command...)
+	composeCommand := engine.ComposeCommand()
+	dockerCmd := newCommand(composeCommand[0], append(composeCommand[1:], command...)...)
 	dockerCmd.Dir = workingDir
+	showCommand, pipeStdout = outputOptions(showCommand, pipeStdout)
 	return runCommand(dockerCmd, showCommand, pipeStdout, command...)
 }
 
+// RunDockerComposeCommandAttached runs a docker compose command whose output is the result the user asked
+// for, such as logs, so it is always shown whatever the component output setting
+func RunDockerComposeCommandAttached(workingDir string, showCommand bool, command ...string) error {
+	composeCommand := engine.ComposeCommand()
+	dockerCmd := newCommand(composeCommand[0], append(composeCommand[1:], command...)...)
+	dockerCmd.Dir = workingDir
+	showCommand, _ = outputOptions(showCommand, false)
+	return runCommand(dockerCmd, showCommand, true, command...)
+}
+
+func RunDockerComposeCommandBuffered(workingDir string, showCommand bool, command ...string) (string, error) {
+	composeCommand := engine.ComposeCommand()
+	dockerCmd := newCommand(composeCommand[0], append(composeCommand[1:], command...)...)
+	dockerCmd.Dir = workingDir
+	if showCommand, _ = outputOptions(showCommand, false); showCommand {
+		fmt.Println(dockerCmd.String())
+	}
+	output, err := dockerCmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return "", fmt.Errorf("%s\nFailed [%d] %s", strings.Join(dockerCmd.Args, " "), exitErr.ExitCode(), failureOutput(string(exitErr.Stderr)))
+	} else if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
 func runCommand(cmd *exec.Cmd, showCommand bool, pipeStdout bool, command ...string) error {
 	if showCommand {
 		fmt.Println(cmd.String())
@@ -92,7 +296,7 @@ outputCapture:
 	cmd.Wait()
 	statusCode := cmd.ProcessState.ExitCode()
 	if statusCode != 0 {
-		return fmt.Errorf("%s\nFailed [%d] %s", strings.Join(cmd.Args, " "), statusCode, outputBuff.String())
+		return fmt.Errorf("%s\nFailed [%d] %s", strings.Join(cmd.Args, " "), statusCode, failureOutput(outputBuff.String()))
 	}
 	return nil
 }
@@ -18,6 +18,8 @@ package docker
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/hyperledger/firefly-cli/pkg/types"
 )
@@ -45,6 +47,7 @@ type ServiceDefinition struct {
 type Service struct {
 	Image       string                       `yaml:"image,omitempty"`
 	Build       string                       `yaml:"build,omitempty"`
+	Entrypoint  []string                     `yaml:"entrypoint,omitempty"`
 	Command     string                       `yaml:"command,omitempty"`
 	Environment map[string]string            `yaml:"environment,omitempty"`
 	Volumes     []string                     `yaml:"volumes,omitempty"`
@@ -52,6 +55,14 @@ type Service struct {
 	DependsOn   map[string]map[string]string `yaml:"depends_on,omitempty"`
 	HealthCheck *HealthCheck                 `yaml:"healthcheck,omitempty"`
 	Logging     *LoggingConfig               `yaml:"logging,omitempty"`
+	ExtraHosts  []string                     `yaml:"extra_hosts,omitempty"`
+	Networks    map[string]*ServiceNetwork   `yaml:"networks,omitempty"`
+	MemLimit    string                       `yaml:"mem_limit,omitempty"`
+	CPUs        float64                      `yaml:"cpus,omitempty"`
+}
+
+type ServiceNetwork struct {
+	Aliases []string `yaml:"aliases,omitempty"`
 }
 
 type DockerComposeConfig struct {
@@ -78,25 +89,43 @@ func CreateDockerCompose(stack *types.Stack) *DockerComposeConfig {
 	for _, member := range stack.Members {
 
 		if !member.External {
+			// With a custom domain, other machines reach members through the edge proxy, which checks the
+			// credentials of tenants, so the FireFly ports are only published for the CLI and local tools
+			hostIP := ""
+			if stack.Domain != "" {
+				hostIP = "127.0.0.1:"
+			}
 			compose.Services["firefly_core_"+member.ID] = &Service{
-				Image: "ghcr.io/hyperledger/firefly:latest",
+				Image: stack.GetImage(types.FireFlyComponent),
 				Ports: []string{
-					fmt.Sprintf("%d:%d", member.ExposedFireflyPort, member.ExposedFireflyPort),
-					fmt.Sprintf("%d:%d", member.ExposedFireflyAdminPort, member.ExposedFireflyAdminPort),
-				},
-				Volumes: []string{fmt.Sprintf("firefly_core_%s:/etc/firefly", member.ID)},
-				DependsOn: map[string]map[string]string{
-					"dataexchange_" + member.ID: {"condition": "service_started"},
+					fmt.Sprintf("%s%d:%d", hostIP, member.ExposedFireflyPort, member.ExposedFireflyPort),
+					fmt.Sprintf("%s%d:%d", hostIP, member.ExposedFireflyAdminPort, member.ExposedFireflyAdminPort),
 				},
-				Logging: StandardLogOptions,
+				Volumes:   []string{fmt.Sprintf("firefly_core_%s:/etc/firefly", member.ID)},
+				DependsOn: map[string]map[string]string{},
+				Logging:   StandardLogOptions,
+			}
+			if !stack.Gateway {
+				compose.Services["firefly_core_"+member.ID].DependsOn["dataexchange_"+member.ID] = map[string]string{"condition": "service_started"}
+			}
+
+			// An external database on localhost is on the docker host, which the URL in the config points at
+			if member.PostgresOnDockerHost() {
+				service := compose.Services["firefly_core_"+member.ID]
+				service.ExtraHosts = append(service.ExtraHosts, types.DockerHostMapping)
+			}
+
+			if stack.UIPath != "" {
+				service := compose.Services["firefly_core_"+member.ID]
+				service.Volumes = append(service.Volumes, fmt.Sprintf("%s:%s:ro", stack.UIPath, types.CustomUIMountPath))
 			}
 
 			compose.Volumes["firefly_core_"+member.ID] = struct{}{}
 		}
 
-		if stack.Database == "postgres" {
+		if stack.Database == "postgres" && member.PostgresURL == "" {
 			compose.Services["postgres_"+member.ID] = &Service{
-				Image: "postgres",
+				Image: stack.GetImage(types.PostgresComponent),
 				Ports: []string{fmt.Sprintf("%d:5432", member.ExposedPostgresPort)},
 				Environment: map[string]string{
 					"POSTGRES_PASSWORD": "f1refly",
@@ -117,38 +146,105 @@ func CreateDockerCompose(stack *types.Stack) *DockerComposeConfig {
 			if service, ok := compose.Services[fmt.Sprintf("firefly_core_%v", *member.Index)]; ok {
 				service.DependsOn["postgres_"+member.ID] = map[string]string{"condition": "service_healthy"}
 			}
-		}
 
-		compose.Services["ipfs_"+member.ID] = &Service{
-			Image: "ipfs/go-ipfs",
-			Ports: []string{
-				fmt.Sprintf("%d:5001", member.ExposedIPFSApiPort),
-				fmt.Sprintf("%d:8080", member.ExposedIPFSGWPort),
-			},
-			Environment: map[string]string{
-				"IPFS_SWARM_KEY":    stack.SwarmKey,
-				"LIBP2P_FORCE_PNET": "1",
-			},
-			Volumes: []string{
-				fmt.Sprintf("ipfs_staging_%s:/export", member.ID),
-				fmt.Sprintf("ipfs_data_%s:/data/ipfs", member.ID),
-			},
-			Logging: StandardLogOptions,
+			if stack.PostgresReplicas {
+				addPostgresReplica(compose, stack, member)
+			}
 		}
 
-		compose.Volumes["ipfs_staging_"+member.ID] = struct{}{}
-		compose.Volumes["ipfs_data_"+member.ID] = struct{}{}
+		// A shared IPFS node is defined along with the first member
+		if ipfsName, owner := stack.IPFSNode(member); owner == member {
+			volumeSuffix := strings.TrimPrefix(ipfsName, "ipfs")
+			compose.Services[ipfsName] = &Service{
+				Image: stack.GetImage(types.IPFSComponent),
+				Ports: []string{
+					fmt.Sprintf("%d:5001", member.ExposedIPFSApiPort),
+					fmt.Sprintf("%d:8080", member.ExposedIPFSGWPort),
+				},
+				Environment: map[string]string{
+					"IPFS_SWARM_KEY":    stack.SwarmKey,
+					"LIBP2P_FORCE_PNET": "1",
+				},
+				Volumes: []string{
+					fmt.Sprintf("ipfs_staging%s:/export", volumeSuffix),
+					fmt.Sprintf("ipfs_data%s:/data/ipfs", volumeSuffix),
+				},
+				Logging: StandardLogOptions,
+			}
+
+			compose.Volumes["ipfs_staging"+volumeSuffix] = struct{}{}
+			compose.Volumes["ipfs_data"+volumeSuffix] = struct{}{}
 
-		compose.Services["dataexchange_"+member.ID] = &Service{
-			Image:   "ghcr.io/hyperledger/firefly-dataexchange-https:latest",
-			Ports:   []string{fmt.Sprintf("%d:3000", member.ExposedDataexchangePort)},
-			Volumes: []string{fmt.Sprintf("dataexchange_%s:/data", member.ID)},
-			Logging: StandardLogOptions,
+			// The node runs the scripts in its init directory every time it starts, before the daemon
+			if stack.IPFS != nil {
+				service := compose.Services[ipfsName]
+				service.Volumes = append(service.Volumes, fmt.Sprintf("ipfs_init%s:/container-init.d", volumeSuffix))
+				compose.Volumes["ipfs_init"+volumeSuffix] = struct{}{}
+			}
 		}
 
-		compose.Volumes["dataexchange_"+member.ID] = struct{}{}
+		// A gateway has no peers to exchange data with
+		if !stack.Gateway {
+			compose.Services["dataexchange_"+member.ID] = &Service{
+				Image:       stack.GetImage(types.DataExchangeComponent),
+				Ports:       []string{fmt.Sprintf("%d:3000", member.ExposedDataexchangePort)},
+				Environment: stack.GetNodeEnvironment(nil),
+				Volumes:     []string{fmt.Sprintf("dataexchange_%s:/data", member.ID)},
+				Logging:     StandardLogOptions,
+			}
+
+			compose.Volumes["dataexchange_"+member.ID] = struct{}{}
+		}
 
 	}
 
 	return compose
 }
+
+// postgresPrimaryScript lets replicas connect for streaming replication, with an init script run by the
+// image entrypoint when the database is first created
+const postgresPrimaryScript = `echo 'echo "host replication all all md5" >> "$$PGDATA/pg_hba.conf"' > /docker-entrypoint-initdb.d/replication.sh
+exec docker-entrypoint.sh postgres`
+
+// postgresReplicaScript clones the primary with pg_basebackup the first time the replica starts, retrying
+// until the primary accepts connections, and then runs it as a hot standby
+const postgresReplicaScript = `if [ ! -s "$$PGDATA/PG_VERSION" ]; then
+  until pg_basebackup -h %s -U postgres -D "$$PGDATA" -R -X stream; do sleep 2; done
+  chmod 0700 "$$PGDATA"
+fi
+exec docker-entrypoint.sh postgres -c recovery_min_apply_delay=%dms`
+
+// addPostgresReplica adds a read replica streaming from the member's database container. The replica
+// applies changes after the stack's replica lag, to expose queries that expect to read their own writes.
+func addPostgresReplica(compose *DockerComposeConfig, stack *types.Stack, member *types.Member) {
+	primaryName := "postgres_" + member.ID
+	replicaName := "postgres_replica_" + member.ID
+	compose.Services[primaryName].Entrypoint = []string{"bash", "-c", postgresPrimaryScript}
+
+	var lag time.Duration
+	if stack.PostgresReplicaLag != "" {
+		lag, _ = time.ParseDuration(stack.PostgresReplicaLag)
+	}
+	compose.Services[replicaName] = &Service{
+		Image:      stack.GetImage(types.PostgresComponent),
+		Entrypoint: []string{"bash", "-c", fmt.Sprintf(postgresReplicaScript, stack.ServiceHost(primaryName), lag.Milliseconds())},
+		Ports:      []string{fmt.Sprintf("%d:5432", member.ExposedPostgresReplicaPort)},
+		Environment: map[string]string{
+			"POSTGRES_PASSWORD": "f1refly",
+			"PGPASSWORD":        "f1refly",
+			"PGDATA":            "/var/lib/postgresql/data/pgdata",
+		},
+		Volumes: []string{fmt.Sprintf("%s:/var/lib/postgresql/data", replicaName)},
+		DependsOn: map[string]map[string]string{
+			primaryName: {"condition": "service_healthy"},
+		},
+		HealthCheck: &HealthCheck{
+			Test:     []string{"CMD-SHELL", "pg_isready -U postgres"},
+			Interval: "5s",
+			Timeout:  "3s",
+			Retries:  24,
+		},
+		Logging: StandardLogOptions,
+	}
+	compose.Volumes[replicaName] = struct{}{}
+}
@@ -0,0 +1,205 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// IContainerEngine abstracts the differences between the CLIs of the container engines that can run a stack.
+// Both engines accept the same commands for managing containers, volumes and compose projects, but differ
+// in their binaries, output formats and events.
+type IContainerEngine interface {
+	// Name is the name of the CLI binary of the engine
+	Name() string
+	// ComposeCommand is the binary and leading arguments of the CLI used to manage compose projects
+	ComposeCommand() []string
+	// ContainerStatusFormat is a template for the engine's ps command, matching containerStatusFormat fields
+	ContainerStatusFormat() string
+	// EventsArgs are the arguments to stream the stop and OOM events of a compose project's containers
+	EventsArgs(projectName string) []string
+	ParseEvent(line []byte) (*ContainerEvent, error)
+	// ServerVersionArgs are the arguments to print the version of the engine's daemon or machine, which fail if it is unreachable
+	ServerVersionArgs() []string
+	// LabelFormat is a template for the value of a label in the output of the engine's ls commands
+	LabelFormat(label string) string
+	// ContainerName is the name the compose command gives to the container of a service
+	ContainerName(projectName, serviceName string) string
+}
+
+var ContainerEngineStrings = []string{"auto", "docker", "podman"}
+
+var engine IContainerEngine = &DockerEngine{}
+
+// SetContainerEngine selects the engine used by every command in this package. With "auto", podman is only
+// used if it is installed and docker is not.
+func SetContainerEngine(name string) error {
+	switch name {
+	case "docker":
+		engine = &DockerEngine{}
+	case "podman":
+		engine = &PodmanEngine{}
+	case "auto":
+		engine = &DockerEngine{}
+		if _, err := exec.LookPath("docker"); err != nil {
+			if _, err := exec.LookPath("podman"); err == nil {
+				engine = &PodmanEngine{}
+			}
+		}
+	default:
+		return fmt.Errorf("\"%s\" is not a valid container engine. valid options are: %v", name, ContainerEngineStrings)
+	}
+	return nil
+}
+
+func GetContainerEngine() IContainerEngine {
+	return engine
+}
+
+// ContainerName is the name of the container of a service in a compose project, with the compose command of
+// the selected engine
+func ContainerName(projectName, serviceName string) string {
+	return engine.ContainerName(projectName, serviceName)
+}
+
+type DockerEngine struct{}
+
+var (
+	dockerComposeCommand    []string
+	detectDockerComposeOnce sync.Once
+)
+
+func (e *DockerEngine) Name() string {
+	return "docker"
+}
+
+// ComposeCommand uses the standalone docker-compose binary if it is installed, otherwise the
+// compose v2 plugin of docker, which is all that is installed by newer versions of Docker Desktop
+func (e *DockerEngine) ComposeCommand() []string {
+	detectDockerComposeOnce.Do(func() {
+		dockerComposeCommand = []string{"docker-compose"}
+		if _, err := exec.LookPath("docker-compose"); err != nil {
+			if err := exec.Command("docker", "compose", "version").Run(); err == nil {
+				dockerComposeCommand = []string{"docker", "compose"}
+			}
+		}
+	})
+	return dockerComposeCommand
+}
+
+// ContainerName follows the compose v2 plugin, which joins the names with hyphens rather than underscores
+func (e *DockerEngine) ContainerName(projectName, serviceName string) string {
+	if len(e.ComposeCommand()) > 1 {
+		return fmt.Sprintf("%s-%s-1", projectName, serviceName)
+	}
+	return fmt.Sprintf("%s_%s_1", projectName, serviceName)
+}
+
+func (e *DockerEngine) ContainerStatusFormat() string {
+	return `{{.ID}}	{{.Names}}	{{.Label "com.docker.compose.service"}}	{{.Image}}	{{.State}}	{{.Status}}	{{.Ports}}`
+}
+
+func (e *DockerEngine) LabelFormat(label string) string {
+	return fmt.Sprintf(`{{.Label "%s"}}`, label)
+}
+
+func (e *DockerEngine) ServerVersionArgs() []string {
+	return []string{"info", "--format", "{{.ServerVersion}}"}
+}
+
+func (e *DockerEngine) EventsArgs(projectName string) []string {
+	return []string{"events",
+		"--filter", "type=container",
+		"--filter", fmt.Sprintf("label=com.docker.compose.project=%s", projectName),
+		"--filter", "event=oom",
+		"--filter", "event=die",
+		"--format", "{{json .}}",
+	}
+}
+
+func (e *DockerEngine) ParseEvent(line []byte) (*ContainerEvent, error) {
+	var event *ContainerEvent
+	err := json.Unmarshal(line, &event)
+	return event, err
+}
+
+type PodmanEngine struct{}
+
+func (e *PodmanEngine) Name() string {
+	return "podman"
+}
+
+func (e *PodmanEngine) ComposeCommand() []string {
+	return []string{"podman-compose"}
+}
+
+func (e *PodmanEngine) ContainerName(projectName, serviceName string) string {
+	return fmt.Sprintf("%s_%s_1", projectName, serviceName)
+}
+
+func (e *PodmanEngine) ContainerStatusFormat() string {
+	return `{{.ID}}	{{.Names}}	{{index .Labels "com.docker.compose.service"}}	{{.Image}}	{{.State}}	{{.Status}}	{{.Ports}}`
+}
+
+func (e *PodmanEngine) LabelFormat(label string) string {
+	return fmt.Sprintf(`{{index .Labels "%s"}}`, label)
+}
+
+func (e *PodmanEngine) ServerVersionArgs() []string {
+	return []string{"info", "--format", "{{.Version.Version}}"}
+}
+
+func (e *PodmanEngine) EventsArgs(projectName string) []string {
+	return []string{"events",
+		"--filter", "type=container",
+		"--filter", fmt.Sprintf("label=com.docker.compose.project=%s", projectName),
+		"--filter", "event=oom",
+		"--filter", "event=died",
+		"--format", "json",
+	}
+}
+
+func (e *PodmanEngine) ParseEvent(line []byte) (*ContainerEvent, error) {
+	var podmanEvent struct {
+		ID         string            `json:"ID"`
+		Name       string            `json:"Name"`
+		Status     string            `json:"Status"`
+		Time       time.Time         `json:"Time"`
+		Attributes map[string]string `json:"Attributes"`
+	}
+	if err := json.Unmarshal(line, &podmanEvent); err != nil {
+		return nil, err
+	}
+	event := &ContainerEvent{
+		Status: podmanEvent.Status,
+		ID:     podmanEvent.ID,
+		Time:   podmanEvent.Time.Unix(),
+	}
+	if event.Status == "died" {
+		event.Status = "die"
+	}
+	event.Actor.Attributes = podmanEvent.Attributes
+	if event.Actor.Attributes == nil {
+		event.Actor.Attributes = make(map[string]string)
+	}
+	event.Actor.Attributes["name"] = podmanEvent.Name
+	return event, nil
+}
@@ -0,0 +1,76 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"bufio"
+	"fmt"
+)
+
+type ContainerEvent struct {
+	Status string `json:"status"`
+	ID     string `json:"id"`
+	Time   int64  `json:"time"`
+	Actor  struct {
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+func (e *ContainerEvent) Service() string {
+	return e.Actor.Attributes["com.docker.compose.service"]
+}
+
+func (e *ContainerEvent) ContainerName() string {
+	return e.Actor.Attributes["name"]
+}
+
+// WatchProjectEvents streams the container events of a docker compose project to the handler until the
+// stop channel is closed. Only the events which indicate a container has stopped are included.
+func WatchProjectEvents(projectName string, verbose bool, stop <-chan struct{}, handler func(e *ContainerEvent)) error {
+	dockerCmd := newCommand(engine.Name(), engine.EventsArgs(projectName)...)
+	if verbose {
+		fmt.Println(dockerCmd.String())
+	}
+	stdout, err := dockerCmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := dockerCmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		<-stop
+		dockerCmd.Process.Kill()
+	}()
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		e, err := engine.ParseEvent(scanner.Bytes())
+		if err != nil || e == nil {
+			continue
+		}
+		handler(e)
+	}
+	dockerCmd.Wait()
+	select {
+	case <-stop:
+		return nil
+	default:
+		return fmt.Errorf("%s events exited unexpectedly: %v", engine.Name(), scanner.Err())
+	}
+}
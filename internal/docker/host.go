@@ -0,0 +1,79 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DockerHost and DockerContext select the daemon that every docker command is sent to. They are set
+// from the stack, so a stack created on a remote daemon keeps using it whatever the current context.
+var (
+	DockerHost    string
+	DockerContext string
+)
+
+// GetRemoteDaemon returns the DOCKER_HOST, or the name of the current docker context, if either selects
+// a daemon other than the local one
+func GetRemoteDaemon() (host string, context string) {
+	if engine.Name() != "docker" {
+		return "", ""
+	}
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		if GetDaemonHostname(host) == "" {
+			return "", ""
+		}
+		return host, ""
+	}
+	output, err := exec.Command("docker", "context", "inspect", "--format", "{{.Name}}\t{{.Endpoints.docker.Host}}").Output()
+	if err != nil {
+		return "", ""
+	}
+	parts := strings.SplitN(strings.TrimSpace(string(output)), "\t", 2)
+	if len(parts) != 2 || parts[0] == "default" || GetDaemonHostname(parts[1]) == "" {
+		return "", ""
+	}
+	return parts[1], parts[0]
+}
+
+// GetDaemonHostname returns the hostname of a tcp:// or ssh:// daemon address, or an empty
+// string for a local socket
+func GetDaemonHostname(host string) string {
+	u, err := url.Parse(host)
+	if err != nil || (u.Scheme != "tcp" && u.Scheme != "ssh") {
+		return ""
+	}
+	hostname := u.Hostname()
+	if ip := net.ParseIP(hostname); hostname == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return ""
+	}
+	return hostname
+}
+
+func newCommand(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	if DockerContext != "" {
+		cmd.Env = append(os.Environ(), "DOCKER_CONTEXT="+DockerContext)
+	} else if DockerHost != "" {
+		cmd.Env = append(os.Environ(), "DOCKER_HOST="+DockerHost)
+	}
+	return cmd
+}
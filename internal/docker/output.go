@@ -0,0 +1,58 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import "fmt"
+
+var ComponentOutputStrings = []string{"auto", "none", "errors", "full"}
+
+// componentOutput is how much of the output of docker, docker compose, and the tools run in containers
+// is passed through. With "auto", it is shown along with each command when the command is verbose.
+var componentOutput = "auto"
+
+// SetComponentOutput selects how much third-party output every command in this package shows, separately
+// from the CLI's own verbosity. With "errors", output is only kept to report a failed command, and with
+// "none", failures are reported by their exit code alone.
+func SetComponentOutput(name string) error {
+	for _, valid := range ComponentOutputStrings {
+		if name == valid {
+			componentOutput = name
+			return nil
+		}
+	}
+	return fmt.Errorf("\"%s\" is not a valid component output. valid options are: %v", name, ComponentOutputStrings)
+}
+
+// outputOptions applies the component output setting to whether a command is printed and its output piped
+func outputOptions(showCommand bool, pipeStdout bool) (bool, bool) {
+	switch componentOutput {
+	case "full":
+		return true, true
+	case "errors", "none":
+		return false, false
+	default:
+		return showCommand, pipeStdout
+	}
+}
+
+// failureOutput is the output of a failed command to include in its error
+func failureOutput(output string) string {
+	if componentOutput == "none" {
+		return ""
+	}
+	return output
+}
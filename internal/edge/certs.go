@@ -0,0 +1,102 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// writeCertificates creates a CA for the stack, and a wildcard certificate for its domain signed by
+// the CA. Existing certificates are kept, so the CA only has to be trusted once.
+func writeCertificates(certsDir string, domain string) error {
+	if _, err := os.Stat(filepath.Join(certsDir, "cert.pem")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(certsDir, 0755); err != nil {
+		return err
+	}
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "FireFly CLI CA for " + domain, Organization: []string{"FireFly CLI"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		PermittedDNSDomains:   []string{domain},
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "*." + domain},
+		DNSNames:     []string{domain, "*." + domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		// Browsers reject server certificates valid for more than 398 days
+		NotAfter:    time.Now().AddDate(0, 0, 397),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writePEM(filepath.Join(certsDir, "ca.pem"), "CERTIFICATE", caDER, 0644); err != nil {
+		return err
+	}
+	// The CA key is not needed again, so it is discarded rather than left on disk
+	if err := writePEM(filepath.Join(certsDir, "cert.pem"), "CERTIFICATE", certDER, 0644); err != nil {
+		return err
+	}
+	return writePEM(filepath.Join(certsDir, "key.pem"), "EC PRIVATE KEY", keyDER, 0600)
+}
+
+func writePEM(filename string, blockType string, der []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(filename, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), perm)
+}
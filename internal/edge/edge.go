@@ -0,0 +1,222 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/certs"
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// ValidateDomain checks a custom domain, which may be given with or without a leading wildcard, and returns it without
+func ValidateDomain(domain string) (string, error) {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "*."))
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "", fmt.Errorf("\"%s\" is not a valid domain - use a domain with at least two labels, such as ff.test", domain)
+	}
+	for _, label := range labels {
+		if label == "" || strings.Trim(label, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return "", fmt.Errorf("\"%s\" is not a valid domain", domain)
+		}
+	}
+	return domain, nil
+}
+
+// GetMemberHostname returns the friendly hostname of a member's FireFly API and UI
+func GetMemberHostname(stack *types.Stack, member *types.Member) string {
+	return fmt.Sprintf("member%s.%s", member.ID, stack.Domain)
+}
+
+func GetMemberURL(stack *types.Stack, member *types.Member) string {
+	if stack.ExposedEdgePort == 443 {
+		return fmt.Sprintf("https://%s", GetMemberHostname(stack, member))
+	}
+	return fmt.Sprintf("https://%s:%d", GetMemberHostname(stack, member), stack.ExposedEdgePort)
+}
+
+// GetHostsEntries returns the lines to add to the hosts file, so the friendly hostnames resolve to the stack
+func GetHostsEntries(stack *types.Stack) []string {
+	entries := make([]string, 0, len(stack.Members))
+	for _, member := range stack.Members {
+		if !member.External {
+			entries = append(entries, fmt.Sprintf("%s %s", stack.Host(), GetMemberHostname(stack, member)))
+		}
+	}
+	return entries
+}
+
+// GetDnsmasqConfig returns a dnsmasq rule resolving every hostname under the domain to the stack, as an
+// alternative to hosts file entries
+func GetDnsmasqConfig(stack *types.Stack) string {
+	return fmt.Sprintf("address=/%s/%s", stack.Domain, stack.Host())
+}
+
+func getEdgeDir(stack *types.Stack) string {
+	return filepath.Join(constants.StacksDir, stack.Name, "edge")
+}
+
+func GetCACertPath(stack *types.Stack) string {
+	return filepath.Join(getEdgeDir(stack), "certs", "ca.pem")
+}
+
+// WriteConfig writes the NGINX config, with a TLS server for each member's FireFly core, and the
+// certificates and tenant password files it serves. The hosts file entries are also written, for convenience.
+func WriteConfig(stack *types.Stack) error {
+	if stack.Domain == "" {
+		return nil
+	}
+	edgeDir := getEdgeDir(stack)
+	certsDir := filepath.Join(edgeDir, "certs")
+	if stack.TLS {
+		// The wildcard certificate is issued by the same CA as the certificates of FireFly core, so a browser
+		// or client that trusts the stack's CA can reach each member both directly and through the proxy
+		if err := certs.WriteWildcardCertificate(stack, certsDir, stack.Domain); err != nil {
+			return err
+		}
+	} else if err := writeCertificates(certsDir, stack.Domain); err != nil {
+		return err
+	}
+
+	if err := writePasswordFiles(filepath.Join(edgeDir, "htpasswd"), stack.Tenants); err != nil {
+		return err
+	}
+
+	// Members are routed to by the server name the client asks for with SNI, and a handshake for any other
+	// name is rejected, rather than answered by whichever member happens to be first
+	var config strings.Builder
+	config.WriteString(`server {
+    listen 443 ssl default_server;
+    ssl_reject_handshake on;
+}
+
+`)
+	for _, member := range stack.Members {
+		if member.External {
+			continue
+		}
+		scheme := "http"
+		if member.TLS {
+			scheme = "https"
+		}
+		proxy := fmt.Sprintf(`proxy_pass %s://%s:%d;
+        proxy_http_version 1.1;
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-Proto https;
+        proxy_set_header Upgrade $http_upgrade;
+        proxy_set_header Connection "upgrade";
+        proxy_read_timeout 1h;`, scheme, stack.ServiceHost("firefly_core_"+member.ID), member.ExposedFireflyPort)
+		if member.TLS {
+			proxy += fmt.Sprintf(`
+        proxy_ssl_verify on;
+        proxy_ssl_trusted_certificate /etc/nginx/certs/ca.pem;
+        proxy_ssl_name %s;
+        proxy_ssl_server_name on;`, types.ServiceAlias("firefly_core_"+member.ID))
+		}
+		fmt.Fprintf(&config, `server {
+    listen 443 ssl;
+    server_name %s;
+
+    ssl_certificate /etc/nginx/certs/cert.pem;
+    ssl_certificate_key /etc/nginx/certs/key.pem;
+`, GetMemberHostname(stack, member))
+		// Each tenant with credentials has their namespace locked to them. Their credentials are checked
+		// by the proxy, so it authenticates to FireFly core as the member on their behalf.
+		tenantProxy := proxy
+		if member.APIUsername != "" {
+			credentials := base64.StdEncoding.EncodeToString([]byte(member.APIUsername + ":" + member.APIPassword))
+			tenantProxy += fmt.Sprintf(`
+        proxy_set_header Authorization "Basic %s";`, credentials)
+		}
+		hasCredentials := false
+		for _, tenant := range stack.Tenants {
+			if tenant.Username == "" {
+				continue
+			}
+			hasCredentials = true
+			fmt.Fprintf(&config, `
+    location ~ ^/api/v1/namespaces/%s(/|$) {
+        auth_basic "%s";
+        auth_basic_user_file /etc/nginx/htpasswd/%s;
+        %s
+    }
+`, tenant.Namespace, tenant.Namespace, tenant.Namespace, tenantProxy)
+		}
+		// The namespace of a websocket subscription is only known from the messages sent over it, so the proxy
+		// can only check that the client is one of the tenants, not that it stays in its own namespace
+		if hasCredentials {
+			fmt.Fprintf(&config, `
+    location /ws {
+        auth_basic "websocket";
+        auth_basic_user_file /etc/nginx/htpasswd/%s;
+        %s
+    }
+`, websocketPasswordFile, tenantProxy)
+		}
+		fmt.Fprintf(&config, `
+    location / {
+        %s
+    }
+}
+
+`, proxy)
+	}
+	if err := os.MkdirAll(filepath.Join(edgeDir, "conf.d"), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(edgeDir, "conf.d", "default.conf"), []byte(config.String()), 0755); err != nil {
+		return err
+	}
+	hosts := strings.Join(GetHostsEntries(stack), "\n") + "\n"
+	return ioutil.WriteFile(filepath.Join(edgeDir, "hosts"), []byte(hosts), 0755)
+}
+
+func GetDockerServiceDefinitions(stack *types.Stack) []*docker.ServiceDefinition {
+	if stack.Domain == "" {
+		return nil
+	}
+	dependsOn := make(map[string]map[string]string)
+	for _, member := range stack.Members {
+		if !member.External {
+			dependsOn["firefly_core_"+member.ID] = map[string]string{"condition": "service_started"}
+		}
+	}
+	return []*docker.ServiceDefinition{
+		{
+			ServiceName: "edge",
+			Service: &docker.Service{
+				Image: stack.MirrorImage("nginx:alpine"),
+				Ports: []string{fmt.Sprintf("%d:443", stack.ExposedEdgePort)},
+				Volumes: []string{
+					"./edge/conf.d:/etc/nginx/conf.d",
+					"./edge/certs:/etc/nginx/certs",
+					"./edge/htpasswd:/etc/nginx/htpasswd",
+				},
+				DependsOn: dependsOn,
+				Logging:   docker.StandardLogOptions,
+			},
+		},
+	}
+}
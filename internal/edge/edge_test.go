@@ -0,0 +1,125 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+func TestWriteConfigRequiresTenantCredentials(t *testing.T) {
+	stacksDir := constants.StacksDir
+	constants.StacksDir = t.TempDir()
+	defer func() { constants.StacksDir = stacksDir }()
+
+	tests := []struct {
+		name     string
+		tenants  []*types.Tenant
+		contains []string
+		excludes []string
+	}{
+		{
+			name: "tenant with credentials",
+			tenants: []*types.Tenant{
+				{Name: "alice", Namespace: "alice", Username: "alice", Password: "pw"},
+			},
+			contains: []string{
+				"location ~ ^/api/v1/namespaces/alice(/|$) {",
+				"auth_basic_user_file /etc/nginx/htpasswd/alice;",
+				"location /ws {",
+				"auth_basic_user_file /etc/nginx/htpasswd/" + websocketPasswordFile + ";",
+			},
+		},
+		{
+			name:     "tenant without credentials",
+			tenants:  []*types.Tenant{{Name: "bob", Namespace: "bob"}},
+			excludes: []string{"auth_basic", "location /ws"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stack := &types.Stack{
+				Name:            "dev",
+				Domain:          "ff.test",
+				ExposedEdgePort: 443,
+				Members:         []*types.Member{{ID: "0", ExposedFireflyPort: 5000}},
+				Tenants:         test.tenants,
+			}
+			if err := WriteConfig(stack); err != nil {
+				t.Fatal(err)
+			}
+			d, err := ioutil.ReadFile(filepath.Join(getEdgeDir(stack), "conf.d", "default.conf"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := string(d)
+			for _, s := range test.contains {
+				if !strings.Contains(config, s) {
+					t.Errorf("expected the config to contain %q:\n%s", s, config)
+				}
+			}
+			for _, s := range test.excludes {
+				if strings.Contains(config, s) {
+					t.Errorf("expected the config not to contain %q:\n%s", s, config)
+				}
+			}
+		})
+	}
+}
+
+func TestWritePasswordFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "htpasswd")
+	tenants := []*types.Tenant{
+		{Name: "alice", Namespace: "alice", Username: "alice", Password: "alice-pw"},
+		{Name: "bob", Namespace: "bob"},
+		{Name: "carol", Namespace: "carol", Username: "carol", Password: "carol-pw"},
+	}
+	if err := writePasswordFiles(dir, tenants); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tenant := range tenants {
+		d, err := ioutil.ReadFile(filepath.Join(dir, tenant.Namespace))
+		if tenant.Username == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("expected no password file for %s, who has no credentials", tenant.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !checkHtpasswdEntry(t, string(d), tenant.Username, tenant.Password) {
+			t.Errorf("the password file of %s does not match their password", tenant.Name)
+		}
+	}
+
+	d, err := ioutil.ReadFile(filepath.Join(dir, websocketPasswordFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(d)), "\n")
+	if len(lines) != 2 || !checkHtpasswdEntry(t, lines[0], "alice", "alice-pw") || !checkHtpasswdEntry(t, lines[1], "carol", "carol-pw") {
+		t.Errorf("expected the websocket password file to hold the entries of alice and carol, got %q", d)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// websocketPasswordFile holds the credentials of every tenant, any of whom can connect to the websocket. It
+// cannot clash with the file of a tenant, as namespaces start with a letter or digit.
+const websocketPasswordFile = "_websocket"
+
+// writePasswordFiles writes an htpasswd file for each tenant with credentials, and one with all of their
+// credentials for the websocket. The directory is kept, rather than recreated, as it is bind mounted into
+// the running proxy.
+func writePasswordFiles(passwordDir string, tenants []*types.Tenant) error {
+	if err := os.MkdirAll(passwordDir, 0755); err != nil {
+		return err
+	}
+	var all strings.Builder
+	for _, tenant := range tenants {
+		if tenant.Username == "" {
+			continue
+		}
+		entry, err := HtpasswdEntry(tenant.Username, tenant.Password)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(passwordDir, tenant.Namespace), []byte(entry), 0644); err != nil {
+			return err
+		}
+		all.WriteString(entry)
+	}
+	return ioutil.WriteFile(filepath.Join(passwordDir, websocketPasswordFile), []byte(all.String()), 0644)
+}
+
+// HtpasswdEntry returns an htpasswd line for the credentials, using the salted SHA-1 scheme which both
+// NGINX and FireFly core understand without any extra modules
+func HtpasswdEntry(username, password string) (string, error) {
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	hash := sha1.Sum(append([]byte(password), salt...))
+	return fmt.Sprintf("%s:{SSHA}%s\n", username, base64.StdEncoding.EncodeToString(append(hash[:], salt...))), nil
+}
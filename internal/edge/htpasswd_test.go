@@ -0,0 +1,71 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edge
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// checkHtpasswdEntry verifies a password against an entry the way NGINX does for the {SSHA} scheme
+func checkHtpasswdEntry(t *testing.T, entry, username, password string) bool {
+	parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+	if len(parts) != 2 || parts[0] != username || !strings.HasPrefix(parts[1], "{SSHA}") {
+		t.Fatalf("malformed htpasswd entry %q", entry)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(parts[1], "{SSHA}"))
+	if err != nil || len(decoded) <= sha1.Size {
+		t.Fatalf("malformed hash in htpasswd entry %q", entry)
+	}
+	hash, salt := decoded[:sha1.Size], decoded[sha1.Size:]
+	expected := sha1.Sum(append([]byte(password), salt...))
+	return string(hash) == string(expected[:])
+}
+
+func TestHtpasswdEntry(t *testing.T) {
+	tests := []struct {
+		username string
+		password string
+	}{
+		{username: "alice", password: "s3cret"},
+		{username: "bob", password: ""},
+		{username: "carol", password: "with:colon and spaces"},
+	}
+	for _, test := range tests {
+		entry, err := HtpasswdEntry(test.username, test.password)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(entry, "\n") {
+			t.Errorf("expected entry for %s to end with a newline", test.username)
+		}
+		if !checkHtpasswdEntry(t, entry, test.username, test.password) {
+			t.Errorf("the entry for %s does not match its password", test.username)
+		}
+		if checkHtpasswdEntry(t, entry, test.username, test.password+"x") {
+			t.Errorf("the entry for %s matches the wrong password", test.username)
+		}
+	}
+
+	a, _ := HtpasswdEntry("alice", "s3cret")
+	b, _ := HtpasswdEntry("alice", "s3cret")
+	if a == b {
+		t.Error("expected each entry to have its own salt")
+	}
+}
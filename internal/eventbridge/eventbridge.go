@@ -0,0 +1,207 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventbridge
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+	"gopkg.in/yaml.v2"
+)
+
+// The bridge for each member is a Benthos container which holds a websocket
+// subscription open to FireFly core, and republishes every event it receives
+// to the stack's broker
+type BridgeConfig struct {
+	Input  *BridgeInput  `yaml:"input"`
+	Output *BridgeOutput `yaml:"output"`
+}
+
+type BridgeInput struct {
+	Websocket *WebsocketInput `yaml:"websocket"`
+}
+
+type WebsocketInput struct {
+	URL         string     `yaml:"url"`
+	OpenMessage string     `yaml:"open_message"`
+	TLS         *TLSConfig `yaml:"tls,omitempty"`
+	BasicAuth   *BasicAuth `yaml:"basic_auth,omitempty"`
+}
+
+type BasicAuth struct {
+	Enabled  bool   `yaml:"enabled"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+type TLSConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	RootCAsFile string `yaml:"root_cas_file,omitempty"`
+}
+
+type BridgeOutput struct {
+	Kafka *KafkaOutput `yaml:"kafka,omitempty"`
+	NATS  *NATSOutput  `yaml:"nats,omitempty"`
+}
+
+type KafkaOutput struct {
+	Addresses []string `yaml:"addresses"`
+	Topic     string   `yaml:"topic"`
+	Key       string   `yaml:"key,omitempty"`
+}
+
+type NATSOutput struct {
+	URLs    []string `yaml:"urls"`
+	Subject string   `yaml:"subject"`
+}
+
+func GetTopic(member *types.Member) string {
+	return fmt.Sprintf("firefly.events.%s", member.ID)
+}
+
+func GetBrokerURL(stack *types.Stack) string {
+	switch stack.EventBridge {
+	case "kafka":
+		return fmt.Sprintf("%s:%d", stack.Host(), stack.ExposedEventBrokerPort)
+	case "nats":
+		return fmt.Sprintf("nats://%s:%d", stack.Host(), stack.ExposedEventBrokerPort)
+	default:
+		return ""
+	}
+}
+
+func getConfigFilename(member *types.Member) string {
+	return fmt.Sprintf("event_bridge_%s.yaml", member.ID)
+}
+
+func WriteConfig(stack *types.Stack) error {
+	if stack.EventBridge == "" || stack.EventBridge == "none" {
+		return nil
+	}
+	for _, member := range stack.Members {
+		if member.External {
+			continue
+		}
+		scheme := "ws"
+		var tlsConfig *TLSConfig
+		if member.TLS {
+			scheme = "wss"
+			tlsConfig = &TLSConfig{Enabled: true, RootCAsFile: "/tls/ca.pem"}
+		}
+		websocket := &WebsocketInput{
+			URL:         fmt.Sprintf("%s://%s:%d/ws", scheme, stack.ServiceHost("firefly_core_"+member.ID), member.ExposedFireflyPort),
+			OpenMessage: `{"type":"start","namespace":"default","name":"event_bridge","ephemeral":true,"autoack":true}`,
+			TLS:         tlsConfig,
+		}
+		if member.APIUsername != "" {
+			websocket.BasicAuth = &BasicAuth{Enabled: true, Username: member.APIUsername, Password: member.APIPassword}
+		}
+		config := &BridgeConfig{
+			Input:  &BridgeInput{Websocket: websocket},
+			Output: &BridgeOutput{},
+		}
+		switch stack.EventBridge {
+		case "kafka":
+			config.Output.Kafka = &KafkaOutput{
+				Addresses: []string{stack.ServiceHost("kafka") + ":9092"},
+				Topic:     GetTopic(member),
+				Key:       `${! json("id") }`,
+			}
+		case "nats":
+			config.Output.NATS = &NATSOutput{
+				URLs:    []string{fmt.Sprintf("nats://%s:4222", stack.ServiceHost("nats"))},
+				Subject: GetTopic(member),
+			}
+		}
+		bytes, err := yaml.Marshal(config)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(constants.StacksDir, stack.Name, "configs", getConfigFilename(member)), bytes, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetDockerServiceDefinitions(stack *types.Stack) []*docker.ServiceDefinition {
+	var brokerName string
+	serviceDefinitions := make([]*docker.ServiceDefinition, 0)
+	switch stack.EventBridge {
+	case "kafka":
+		brokerName = "kafka"
+		serviceDefinitions = append(serviceDefinitions, &docker.ServiceDefinition{
+			ServiceName: brokerName,
+			Service: &docker.Service{
+				Image: stack.MirrorImage("bitnami/kafka:3"),
+				Ports: []string{fmt.Sprintf("%d:9094", stack.ExposedEventBrokerPort)},
+				Environment: map[string]string{
+					"KAFKA_CFG_NODE_ID":                        "0",
+					"KAFKA_CFG_PROCESS_ROLES":                  "controller,broker",
+					"KAFKA_CFG_CONTROLLER_QUORUM_VOTERS":       "0@kafka:9093",
+					"KAFKA_CFG_CONTROLLER_LISTENER_NAMES":      "CONTROLLER",
+					"KAFKA_CFG_LISTENERS":                      "PLAINTEXT://:9092,CONTROLLER://:9093,EXTERNAL://:9094",
+					"KAFKA_CFG_ADVERTISED_LISTENERS":           fmt.Sprintf("PLAINTEXT://%s:9092,EXTERNAL://%s:%d", types.ServiceAlias("kafka"), stack.Host(), stack.ExposedEventBrokerPort),
+					"KAFKA_CFG_LISTENER_SECURITY_PROTOCOL_MAP": "CONTROLLER:PLAINTEXT,EXTERNAL:PLAINTEXT,PLAINTEXT:PLAINTEXT",
+					"KAFKA_CFG_AUTO_CREATE_TOPICS_ENABLE":      "true",
+				},
+				Volumes: []string{"kafka:/bitnami/kafka"},
+				Logging: docker.StandardLogOptions,
+			},
+			VolumeNames: []string{"kafka"},
+		})
+	case "nats":
+		brokerName = "nats"
+		serviceDefinitions = append(serviceDefinitions, &docker.ServiceDefinition{
+			ServiceName: brokerName,
+			Service: &docker.Service{
+				Image:   stack.MirrorImage("nats:2"),
+				Ports:   []string{fmt.Sprintf("%d:4222", stack.ExposedEventBrokerPort)},
+				Logging: docker.StandardLogOptions,
+			},
+		})
+	default:
+		return nil
+	}
+
+	for _, member := range stack.Members {
+		if member.External {
+			continue
+		}
+		volumes := []string{fmt.Sprintf("./configs/%s:/benthos.yaml", getConfigFilename(member))}
+		if member.TLS {
+			volumes = append(volumes, "./tls/ca.pem:/tls/ca.pem")
+		}
+		serviceDefinitions = append(serviceDefinitions, &docker.ServiceDefinition{
+			ServiceName: "event_bridge_" + member.ID,
+			Service: &docker.Service{
+				Image:   stack.MirrorImage("jeffail/benthos:latest"),
+				Volumes: volumes,
+				DependsOn: map[string]map[string]string{
+					brokerName:                  {"condition": "service_started"},
+					"firefly_core_" + member.ID: {"condition": "service_started"},
+				},
+				Logging: docker.StandardLogOptions,
+			},
+		})
+	}
+	return serviceDefinitions
+}
@@ -0,0 +1,192 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extensions
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ExecutablePrefix is the prefix of the name of executables on the PATH which are run as subcommands
+const ExecutablePrefix = "firefly-"
+
+// ShellPrefix marks a step of an alias which is run by the shell instead of the CLI
+const ShellPrefix = "!"
+
+// Alias is a user-defined subcommand, which runs one or more commands of the CLI or of the shell in turn
+type Alias struct {
+	Name        string   `yaml:"-"`
+	Description string   `yaml:"description,omitempty"`
+	Steps       []string `yaml:"steps"`
+}
+
+// UnmarshalYAML accepts an alias written as a single command, as a list of commands, or in full with a
+// description and steps
+func (a *Alias) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var step string
+	if err := unmarshal(&step); err == nil {
+		a.Steps = []string{step}
+		return nil
+	}
+	var steps []string
+	if err := unmarshal(&steps); err == nil {
+		a.Steps = steps
+		return nil
+	}
+	type plain Alias
+	return unmarshal((*plain)(a))
+}
+
+type aliasesFile struct {
+	Aliases map[string]*Alias `yaml:"aliases"`
+}
+
+var placeholderRegexp = regexp.MustCompile(`\$(@|[1-9])`)
+
+// LoadAliases reads the aliases defined in a file, sorted by name. There are no aliases if the file does
+// not exist.
+func LoadAliases(filename string) ([]*Alias, error) {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var file aliasesFile
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid aliases in %s: %s", filename, err)
+	}
+	aliases := make([]*Alias, 0, len(file.Aliases))
+	for name, alias := range file.Aliases {
+		if alias == nil || len(alias.Steps) == 0 {
+			return nil, fmt.Errorf("alias '%s' in %s has no steps", name, filename)
+		}
+		if strings.ContainsAny(name, " \t") || strings.HasPrefix(name, "-") {
+			return nil, fmt.Errorf("'%s' in %s is not a valid alias name", name, filename)
+		}
+		for _, step := range alias.Steps {
+			if strings.TrimSpace(strings.TrimPrefix(step, ShellPrefix)) == "" {
+				return nil, fmt.Errorf("alias '%s' in %s has an empty step", name, filename)
+			}
+		}
+		alias.Name = name
+		aliases = append(aliases, alias)
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Name < aliases[j].Name })
+	return aliases, nil
+}
+
+// Step is one command of an alias, with the arguments of the alias filled in
+type Step struct {
+	// If set, Args holds a single script, which is run by the shell with the arguments of the alias as
+	// its positional parameters
+	Shell bool
+	Args  []string
+}
+
+// Expand fills in the arguments the alias is run with. In CLI commands, $1 to $9 are replaced by the
+// arguments in those positions and $@ by all of them. If no step refers to the arguments, they are
+// appended to each CLI command, as with a single command alias such as "up: start --no-rollback".
+// Commands are split into arguments on whitespace.
+func (a *Alias) Expand(args []string) ([]*Step, error) {
+	placeholders := false
+	required := 0
+	for _, step := range a.Steps {
+		if strings.HasPrefix(step, ShellPrefix) {
+			continue
+		}
+		for _, match := range placeholderRegexp.FindAllStringSubmatch(step, -1) {
+			placeholders = true
+			if n, err := strconv.Atoi(match[1]); err == nil && n > required {
+				required = n
+			}
+		}
+	}
+	if len(args) < required {
+		return nil, fmt.Errorf("alias '%s' needs at least %d argument(s)", a.Name, required)
+	}
+
+	steps := make([]*Step, len(a.Steps))
+	for i, step := range a.Steps {
+		if strings.HasPrefix(step, ShellPrefix) {
+			steps[i] = &Step{Shell: true, Args: []string{strings.TrimPrefix(step, ShellPrefix)}}
+			continue
+		}
+		var stepArgs []string
+		for _, field := range strings.Fields(step) {
+			if field == "$@" {
+				stepArgs = append(stepArgs, args...)
+				continue
+			}
+			stepArgs = append(stepArgs, placeholderRegexp.ReplaceAllStringFunc(field, func(placeholder string) string {
+				if placeholder == "$@" {
+					return strings.Join(args, " ")
+				}
+				n, _ := strconv.Atoi(placeholder[1:])
+				return args[n-1]
+			}))
+		}
+		if !placeholders {
+			stepArgs = append(stepArgs, args...)
+		}
+		steps[i] = &Step{Args: stepArgs}
+	}
+	return steps, nil
+}
+
+// FindExecutables finds the executables on the PATH named firefly-<name>, by name. An executable found in
+// an earlier directory of the PATH hides those of the same name in later directories.
+func FindExecutables() map[string]string {
+	executables := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if !strings.HasPrefix(name, ExecutablePrefix) || entry.IsDir() {
+				continue
+			}
+			if runtime.GOOS == "windows" {
+				if !strings.EqualFold(filepath.Ext(name), ".exe") {
+					continue
+				}
+				name = strings.TrimSuffix(name, filepath.Ext(name))
+			} else if entry.Mode()&0111 == 0 {
+				continue
+			}
+			name = strings.TrimPrefix(name, ExecutablePrefix)
+			if _, ok := executables[name]; name != "" && !ok {
+				executables[name] = filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+	return executables
+}
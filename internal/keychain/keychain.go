@@ -0,0 +1,82 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keychain
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Secrets are stored in the OS keychain using its CLI - the security command on macOS, and
+// secret-tool (libsecret) on Linux - so no native libraries are needed to build the CLI.
+const service = "firefly-cli"
+
+func Set(account string, secret string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", service, "-a", account, "-w", secret)
+	case "linux":
+		cmd = exec.Command("secret-tool", "store", "--label", fmt.Sprintf("FireFly CLI %s", account), "service", service, "account", account)
+		cmd.Stdin = strings.NewReader(secret)
+	default:
+		return unsupported()
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to store secret in the OS keychain: %s %s", err, output)
+	}
+	return nil
+}
+
+func Get(account string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux":
+		cmd = exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	default:
+		return "", unsupported()
+	}
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret for '%s' from the OS keychain: %s", account, err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+func Delete(account string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "delete-generic-password", "-s", service, "-a", account)
+	case "linux":
+		cmd = exec.Command("secret-tool", "clear", "service", service, "account", account)
+	default:
+		return unsupported()
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to delete secret for '%s' from the OS keychain: %s %s", account, err, output)
+	}
+	return nil
+}
+
+func unsupported() error {
+	return fmt.Errorf("the OS keychain is not supported on %s", runtime.GOOS)
+}
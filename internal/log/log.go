@@ -16,6 +16,11 @@
 
 package log
 
+import (
+	"fmt"
+	"strings"
+)
+
 type LogLevel int
 
 const (
@@ -34,3 +39,21 @@ type Logger interface {
 	Warn(s string)
 	Error(e error)
 }
+
+var LogLevelStrings = []string{"trace", "debug", "info", "warn", "error"}
+
+func (l LogLevel) String() string {
+	if l < Trace || l > Error {
+		return fmt.Sprintf("%d", int(l))
+	}
+	return LogLevelStrings[l]
+}
+
+func LogLevelFromString(s string) (LogLevel, error) {
+	for i, name := range LogLevelStrings {
+		if strings.EqualFold(s, name) {
+			return LogLevel(i), nil
+		}
+	}
+	return Info, fmt.Errorf("\"%s\" is not a valid log level. valid options are: %v", s, LogLevelStrings)
+}
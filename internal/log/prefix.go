@@ -0,0 +1,46 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import "fmt"
+
+// PrefixLogger passes everything through to another logger with a prefix, so that the output of several
+// operations running at once can be told apart
+type PrefixLogger struct {
+	Logger
+	Prefix string
+}
+
+func (l *PrefixLogger) Trace(s string) {
+	l.Logger.Trace(l.Prefix + s)
+}
+
+func (l *PrefixLogger) Debug(s string) {
+	l.Logger.Debug(l.Prefix + s)
+}
+
+func (l *PrefixLogger) Info(s string) {
+	l.Logger.Info(l.Prefix + s)
+}
+
+func (l *PrefixLogger) Warn(s string) {
+	l.Logger.Warn(l.Prefix + s)
+}
+
+func (l *PrefixLogger) Error(e error) {
+	l.Logger.Error(fmt.Errorf("%s%s", l.Prefix, e))
+}
@@ -0,0 +1,78 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Phase is a step of a command. A new phase starts each time the command logs an info message.
+type Phase struct {
+	Name       string    `json:"name"`
+	StartTime  time.Time `json:"startTime"`
+	DurationMS int64     `json:"durationMs"`
+}
+
+// RecordingLogger passes everything through to another logger, while recording the phases of the
+// command and the warnings and errors it logs
+type RecordingLogger struct {
+	Logger
+	Phases   []*Phase
+	Warnings []string
+
+	current *Phase
+	mux     sync.Mutex
+}
+
+func (l *RecordingLogger) Info(s string) {
+	l.mux.Lock()
+	l.endPhase()
+	l.current = &Phase{Name: s, StartTime: time.Now()}
+	l.Phases = append(l.Phases, l.current)
+	l.mux.Unlock()
+	l.Logger.Info(s)
+}
+
+func (l *RecordingLogger) Warn(s string) {
+	l.mux.Lock()
+	l.Warnings = append(l.Warnings, s)
+	l.mux.Unlock()
+	l.Logger.Warn(s)
+}
+
+func (l *RecordingLogger) Error(e error) {
+	l.mux.Lock()
+	l.Warnings = append(l.Warnings, fmt.Sprintf("error: %s", e))
+	l.mux.Unlock()
+	l.Logger.Error(e)
+}
+
+// Finish ends the current phase
+func (l *RecordingLogger) Finish() {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.endPhase()
+}
+
+func (l *RecordingLogger) endPhase() {
+	if l.current != nil {
+		l.current.DurationMS = time.Since(l.current.StartTime).Milliseconds()
+		l.current = nil
+	}
+}
@@ -49,14 +49,26 @@ func (l *SpinnerLogger) Info(s string) {
 	}
 }
 
+// Warnings and errors replace the message of the spinner while it is running, and are printed once it has stopped
+
 func (l *SpinnerLogger) Warn(s string) {
-	if l.logLevel <= Warn && l.Spinner != nil {
-		l.Spinner.Suffix = fmt.Sprintf(" %s...", s)
+	if l.logLevel > Warn {
+		return
+	}
+	if l.Spinner != nil && l.Spinner.Active() {
+		l.Spinner.Suffix = fmt.Sprintf(" Warning: %s...", s)
+	} else {
+		printWarning(s)
 	}
 }
 
 func (l *SpinnerLogger) Error(e error) {
-	if l.logLevel <= Trace && l.Spinner != nil {
+	if l.logLevel > Error {
+		return
+	}
+	if l.Spinner != nil && l.Spinner.Active() {
 		l.Spinner.Suffix = fmt.Sprintf(" Error: %s...", e.Error())
+	} else {
+		printError(e)
 	}
 }
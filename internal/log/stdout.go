@@ -16,7 +16,10 @@
 
 package log
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+)
 
 type StdoutLogger struct {
 	LogLevel LogLevel
@@ -46,12 +49,21 @@ func (l *StdoutLogger) Info(s string) {
 
 func (l *StdoutLogger) Warn(s string) {
 	if l.LogLevel <= Warn {
-		fmt.Println(s)
+		printWarning(s)
 	}
 }
 
 func (l *StdoutLogger) Error(e error) {
-	if l.LogLevel <= Trace {
-		fmt.Println(e.Error())
+	if l.LogLevel <= Error {
+		printError(e)
 	}
 }
+
+// Warnings and errors go to stderr, so that they are seen even when the output of a command is piped
+func printWarning(s string) {
+	fmt.Fprintf(os.Stderr, "WARNING: %s\n", s)
+}
+
+func printError(e error) {
+	fmt.Fprintf(os.Stderr, "ERROR: %s\n", e)
+}
@@ -0,0 +1,152 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package matrix
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	defaultName    = "matrix"
+	defaultTimeout = 5 * time.Minute
+	latestRelease  = "latest"
+)
+
+// Matrix lists the values of each dimension to test. Every combination of the values is run, apart from
+// those matching an exclusion. A dimension which is not given takes the default of the init command.
+type Matrix struct {
+	// Prefix of the names of the stacks created for each combination
+	Name                string   `yaml:"name"`
+	Members             int      `yaml:"members"`
+	BlockchainProviders []string `yaml:"blockchain-provider"`
+	Databases           []string `yaml:"database"`
+	TokensProviders     []string `yaml:"tokens-provider"`
+	Releases            []string `yaml:"release"`
+	// Scenario run against each stack, instead of the built-in smoke test
+	Scenario string `yaml:"scenario"`
+	// How long each stack has to become ready once started
+	Timeout time.Duration  `yaml:"timeout"`
+	Exclude []*Combination `yaml:"exclude"`
+}
+
+// Combination is one set of values from the matrix. In an exclusion, empty fields match any value.
+type Combination struct {
+	BlockchainProvider string `yaml:"blockchain-provider" json:"blockchainProvider"`
+	Database           string `yaml:"database" json:"database"`
+	TokensProvider     string `yaml:"tokens-provider" json:"tokensProvider"`
+	Release            string `yaml:"release" json:"release"`
+}
+
+func (c *Combination) String() string {
+	return fmt.Sprintf("%s / %s / %s / %s", c.BlockchainProvider, c.Database, c.TokensProvider, c.Release)
+}
+
+func (c *Combination) matches(exclusion *Combination) bool {
+	return (exclusion.BlockchainProvider == "" || exclusion.BlockchainProvider == c.BlockchainProvider) &&
+		(exclusion.Database == "" || exclusion.Database == c.Database) &&
+		(exclusion.TokensProvider == "" || exclusion.TokensProvider == c.TokensProvider) &&
+		(exclusion.Release == "" || exclusion.Release == c.Release)
+}
+
+// LoadMatrix reads a matrix file. The scenario file, if any, is relative to the matrix file.
+func LoadMatrix(filename string) (*Matrix, error) {
+	d, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	matrix := &Matrix{
+		Name:    defaultName,
+		Members: 2,
+		Timeout: defaultTimeout,
+	}
+	if err := yaml.UnmarshalStrict(d, matrix); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", filename, err)
+	}
+	if matrix.Members <= 0 {
+		return nil, fmt.Errorf("%s: number of members must be greater than zero", filename)
+	}
+	for _, provider := range matrix.BlockchainProviders {
+		if _, err := stacks.BlockchainProviderFromString(provider); err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+	for _, database := range matrix.Databases {
+		if _, err := stacks.DatabaseSelectionFromString(database); err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+	for _, provider := range matrix.TokensProviders {
+		if _, err := stacks.TokensProviderFromString(provider); err != nil {
+			return nil, fmt.Errorf("%s: %s", filename, err)
+		}
+	}
+	for _, release := range matrix.Releases {
+		if release != latestRelease {
+			if err := core.ValidateRelease(release); err != nil {
+				return nil, fmt.Errorf("%s: %s", filename, err)
+			}
+		}
+	}
+	if matrix.Scenario != "" && !filepath.IsAbs(matrix.Scenario) {
+		matrix.Scenario = filepath.Join(filepath.Dir(filename), matrix.Scenario)
+	}
+	return matrix, nil
+}
+
+// Combinations returns every combination of the values in the matrix which is not excluded
+func (m *Matrix) Combinations() []*Combination {
+	orDefault := func(values []string, defaultValue string) []string {
+		if len(values) == 0 {
+			return []string{defaultValue}
+		}
+		return values
+	}
+	defaults := stacks.NewStackSpec("")
+	combinations := make([]*Combination, 0)
+	for _, blockchainProvider := range orDefault(m.BlockchainProviders, defaults.BlockchainProvider) {
+		for _, database := range orDefault(m.Databases, defaults.Database) {
+			for _, tokensProvider := range orDefault(m.TokensProviders, defaults.TokensProviders[0]) {
+				for _, release := range orDefault(m.Releases, latestRelease) {
+					c := &Combination{
+						BlockchainProvider: blockchainProvider,
+						Database:           database,
+						TokensProvider:     tokensProvider,
+						Release:            release,
+					}
+					excluded := false
+					for _, exclusion := range m.Exclude {
+						if c.matches(exclusion) {
+							excluded = true
+							break
+						}
+					}
+					if !excluded {
+						combinations = append(combinations, c)
+					}
+				}
+			}
+		}
+	}
+	return combinations
+}
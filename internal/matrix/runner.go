@@ -0,0 +1,151 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package matrix
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/internal/scenario"
+	"github.com/hyperledger/firefly-cli/internal/stacks"
+)
+
+// The stages of a run, which a failed combination reports as the stage it failed in
+const (
+	StageCreate   = "create"
+	StageStart    = "start"
+	StageScenario = "scenario"
+)
+
+// Result is the outcome of running the scenario against the stack of one combination
+type Result struct {
+	Combination `yaml:",inline"`
+	Stack       string        `json:"stack" yaml:"stack"`
+	Passed      bool          `json:"passed" yaml:"passed"`
+	Stage       string        `json:"stage,omitempty" yaml:"stage,omitempty"`
+	Duration    time.Duration `json:"duration" yaml:"duration"`
+	Error       string        `json:"error,omitempty" yaml:"error,omitempty"`
+	// Whether the stack was kept for debugging, rather than removed
+	Kept bool `json:"kept,omitempty" yaml:"kept,omitempty"`
+}
+
+// Runner creates a stack for each combination of the matrix in turn, starts it, runs the scenario against
+// it, and removes it again
+type Runner struct {
+	Log     log.Logger
+	Matrix  *Matrix
+	Verbose bool
+	// Keep the stacks of failed combinations, to investigate the failure
+	KeepFailed bool
+}
+
+// StackName returns the name of the stack created for the combination with the given index
+func (r *Runner) StackName(i int) string {
+	return fmt.Sprintf("%s-%d", r.Matrix.Name, i+1)
+}
+
+// Run runs every combination, and returns the result of each. An error is only returned if the matrix
+// cannot be run at all.
+func (r *Runner) Run() ([]*Result, error) {
+	var customScenario *scenario.Scenario
+	if r.Matrix.Scenario != "" {
+		var err error
+		if customScenario, err = scenario.LoadScenario(r.Matrix.Scenario); err != nil {
+			return nil, err
+		}
+	}
+	combinations := r.Matrix.Combinations()
+	for i := range combinations {
+		if exists, err := stacks.CheckExists(r.StackName(i)); err != nil {
+			return nil, err
+		} else if exists {
+			return nil, fmt.Errorf("stack '%s' already exists - remove it, or give the matrix another name", r.StackName(i))
+		}
+	}
+
+	results := make([]*Result, len(combinations))
+	for i, c := range combinations {
+		r.Log.Info(fmt.Sprintf("combination %d of %d: %s", i+1, len(combinations), c))
+		results[i] = r.runCombination(r.StackName(i), c, customScenario)
+	}
+	return results, nil
+}
+
+func (r *Runner) runCombination(stackName string, c *Combination, customScenario *scenario.Scenario) *Result {
+	result := &Result{Combination: *c, Stack: stackName}
+	startTime := time.Now()
+	fail := func(stage string, err error) *Result {
+		result.Stage = stage
+		result.Error = err.Error()
+		result.Duration = time.Since(startTime).Round(time.Second)
+		return result
+	}
+
+	spec := stacks.NewStackSpec(stackName)
+	spec.Members = r.Matrix.Members
+	spec.BlockchainProvider = c.BlockchainProvider
+	spec.Database = c.Database
+	spec.TokensProviders = []string{c.TokensProvider}
+	spec.AutoPorts = true
+	if c.Release != latestRelease {
+		spec.Release = c.Release
+	}
+	stackManager := stacks.NewStackManager(r.Log)
+	if err := stackManager.InitStackFromSpec(spec, r.Verbose); err != nil {
+		return fail(StageCreate, err)
+	}
+	defer func() {
+		if !result.Passed && r.KeepFailed {
+			result.Kept = true
+			return
+		}
+		r.Log.Info(fmt.Sprintf("removing stack '%s'", stackName))
+		if err := stackManager.RemoveStack(r.Verbose); err != nil {
+			r.Log.Warn(fmt.Sprintf("failed to remove stack '%s': %s", stackName, err))
+		}
+	}()
+
+	options := &stacks.StartOptions{
+		NoRollback:  r.KeepFailed,
+		Wait:        true,
+		WaitTimeout: r.Matrix.Timeout,
+	}
+	if err := stackManager.StartStack(false, r.Verbose, options); err != nil {
+		return fail(StageStart, err)
+	}
+
+	s := customScenario
+	if s == nil {
+		memberIDs := make([]string, len(stackManager.Stack.Members))
+		for i, member := range stackManager.Stack.Members {
+			memberIDs[i] = member.ID
+		}
+		s = scenario.SmokeTest(memberIDs)
+	}
+	runner := &scenario.Runner{
+		Log:      r.Log,
+		Stack:    stackManager.Stack,
+		Scenario: s,
+	}
+	if _, err := runner.Run(); err != nil {
+		return fail(StageScenario, err)
+	}
+	result.Passed = true
+	result.Duration = time.Since(startTime).Round(time.Second)
+	return result
+}
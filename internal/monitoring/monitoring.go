@@ -0,0 +1,202 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitoring
+
+import (
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+	"gopkg.in/yaml.v2"
+)
+
+// MetricsPort is the port FireFly core serves Prometheus metrics on inside the stack
+const MetricsPort = 6000
+
+//go:embed dashboards/firefly.json
+var fireflyDashboard []byte
+
+type PrometheusConfig struct {
+	Global        *PrometheusGlobalConfig   `yaml:"global"`
+	ScrapeConfigs []*PrometheusScrapeConfig `yaml:"scrape_configs"`
+}
+
+type PrometheusGlobalConfig struct {
+	ScrapeInterval string `yaml:"scrape_interval"`
+}
+
+type PrometheusScrapeConfig struct {
+	JobName       string                     `yaml:"job_name"`
+	MetricsPath   string                     `yaml:"metrics_path"`
+	StaticConfigs []*PrometheusStaticConfigs `yaml:"static_configs"`
+}
+
+type PrometheusStaticConfigs struct {
+	Targets []string          `yaml:"targets"`
+	Labels  map[string]string `yaml:"labels"`
+}
+
+type GrafanaDatasources struct {
+	APIVersion  int                  `yaml:"apiVersion"`
+	Datasources []*GrafanaDatasource `yaml:"datasources"`
+}
+
+type GrafanaDatasource struct {
+	Name      string `yaml:"name"`
+	Type      string `yaml:"type"`
+	Access    string `yaml:"access"`
+	URL       string `yaml:"url"`
+	IsDefault bool   `yaml:"isDefault"`
+}
+
+type GrafanaDashboardProviders struct {
+	APIVersion int                         `yaml:"apiVersion"`
+	Providers  []*GrafanaDashboardProvider `yaml:"providers"`
+}
+
+type GrafanaDashboardProvider struct {
+	Name    string            `yaml:"name"`
+	Type    string            `yaml:"type"`
+	Options map[string]string `yaml:"options"`
+}
+
+func GetFireflyConfig(stack *types.Stack) *core.MetricsServerConfig {
+	if stack.ExposedPrometheusPort == 0 {
+		return nil
+	}
+	return &core.MetricsServerConfig{
+		Enabled: true,
+		Address: "0.0.0.0",
+		Port:    MetricsPort,
+		Path:    "/metrics",
+	}
+}
+
+func GetPrometheusURL(stack *types.Stack) string {
+	return fmt.Sprintf("http://%s:%d", stack.Host(), stack.ExposedPrometheusPort)
+}
+
+func GetGrafanaURL(stack *types.Stack) string {
+	return fmt.Sprintf("http://%s:%d", stack.Host(), stack.ExposedGrafanaPort)
+}
+
+// WriteConfig writes the Prometheus config, which scrapes the FireFly core of every member run in docker,
+// and provisions Grafana with Prometheus as its data source and the built in dashboards
+func WriteConfig(stack *types.Stack) error {
+	if stack.ExposedPrometheusPort == 0 {
+		return nil
+	}
+	stackDir := filepath.Join(constants.StacksDir, stack.Name)
+	scrapeConfig := &PrometheusScrapeConfig{
+		JobName:       "firefly",
+		MetricsPath:   "/metrics",
+		StaticConfigs: []*PrometheusStaticConfigs{},
+	}
+	for _, member := range stack.Members {
+		if member.External {
+			continue
+		}
+		scrapeConfig.StaticConfigs = append(scrapeConfig.StaticConfigs, &PrometheusStaticConfigs{
+			Targets: []string{fmt.Sprintf("%s:%d", stack.ServiceHost("firefly_core_"+member.ID), MetricsPort)},
+			Labels:  map[string]string{"member": member.ID},
+		})
+	}
+	if err := writeYAML(filepath.Join(stackDir, "configs", "prometheus.yml"), &PrometheusConfig{
+		Global:        &PrometheusGlobalConfig{ScrapeInterval: "5s"},
+		ScrapeConfigs: []*PrometheusScrapeConfig{scrapeConfig},
+	}); err != nil {
+		return err
+	}
+
+	grafanaDir := filepath.Join(stackDir, "grafana")
+	if err := writeYAML(filepath.Join(grafanaDir, "provisioning", "datasources", "prometheus.yml"), &GrafanaDatasources{
+		APIVersion: 1,
+		Datasources: []*GrafanaDatasource{
+			{Name: "Prometheus", Type: "prometheus", Access: "proxy", URL: fmt.Sprintf("http://%s:9090", stack.ServiceHost("prometheus")), IsDefault: true},
+		},
+	}); err != nil {
+		return err
+	}
+	if err := writeYAML(filepath.Join(grafanaDir, "provisioning", "dashboards", "firefly.yml"), &GrafanaDashboardProviders{
+		APIVersion: 1,
+		Providers: []*GrafanaDashboardProvider{
+			{Name: "FireFly", Type: "file", Options: map[string]string{"path": "/var/lib/grafana/dashboards"}},
+		},
+	}); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(grafanaDir, "dashboards"), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(grafanaDir, "dashboards", "firefly.json"), fireflyDashboard, 0755)
+}
+
+func writeYAML(filename string, value interface{}) error {
+	bytes, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, bytes, 0755)
+}
+
+func GetDockerServiceDefinitions(stack *types.Stack) []*docker.ServiceDefinition {
+	if stack.ExposedPrometheusPort == 0 {
+		return nil
+	}
+	return []*docker.ServiceDefinition{
+		{
+			ServiceName: "prometheus",
+			Service: &docker.Service{
+				Image:   stack.MirrorImage("prom/prometheus"),
+				Ports:   []string{fmt.Sprintf("%d:9090", stack.ExposedPrometheusPort)},
+				Volumes: []string{"./configs/prometheus.yml:/etc/prometheus/prometheus.yml", "prometheus:/prometheus"},
+				Logging: docker.StandardLogOptions,
+			},
+			VolumeNames: []string{"prometheus"},
+		},
+		{
+			ServiceName: "grafana",
+			Service: &docker.Service{
+				Image: stack.MirrorImage("grafana/grafana"),
+				Ports: []string{fmt.Sprintf("%d:3000", stack.ExposedGrafanaPort)},
+				Environment: map[string]string{
+					// Local dev stacks are not secured, so skip the Grafana login
+					"GF_AUTH_ANONYMOUS_ENABLED":  "true",
+					"GF_AUTH_ANONYMOUS_ORG_ROLE": "Admin",
+					"GF_AUTH_DISABLE_LOGIN_FORM": "true",
+				},
+				Volumes: []string{
+					"./grafana/provisioning:/etc/grafana/provisioning",
+					"./grafana/dashboards:/var/lib/grafana/dashboards",
+					"grafana:/var/lib/grafana",
+				},
+				DependsOn: map[string]map[string]string{"prometheus": {"condition": "service_started"}},
+				Logging:   docker.StandardLogOptions,
+			},
+			VolumeNames: []string{"grafana"},
+		},
+	}
+}
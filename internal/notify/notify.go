@@ -0,0 +1,146 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// Types of notification target
+const (
+	Slack   = "slack"
+	Webhook = "webhook"
+)
+
+var TargetTypes = []string{Slack, Webhook}
+
+// Lifecycle events of a stack that can be notified
+const (
+	Started       = "started"
+	StartFailed   = "start-failed"
+	Stopped       = "stopped"
+	Reset         = "reset"
+	Upgraded      = "upgraded"
+	UpgradeFailed = "upgrade-failed"
+	Recovered     = "recovered"
+	RecoverFailed = "recover-failed"
+	TestEvent     = "test"
+)
+
+const requestTimeout = 10 * time.Second
+
+var EventTypes = []string{Started, StartFailed, Stopped, Reset, Upgraded, UpgradeFailed, Recovered, RecoverFailed}
+
+// Event is the body posted to webhook targets
+type Event struct {
+	Stack   string    `json:"stack"`
+	Event   string    `json:"event"`
+	Message string    `json:"message"`
+	Error   string    `json:"error,omitempty"`
+	Host    string    `json:"host,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+func NewEvent(stackName, eventType, message string, err error) *Event {
+	event := &Event{
+		Stack:   stackName,
+		Event:   eventType,
+		Message: message,
+		Time:    time.Now().UTC(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	// The host tells a team which machine a shared stack's event came from
+	event.Host, _ = os.Hostname()
+	return event
+}
+
+// ValidateTarget checks the type of the target and the events it wants
+func ValidateTarget(target *types.NotificationTarget) error {
+	if target.Type != Slack && target.Type != Webhook {
+		return fmt.Errorf("\"%s\" is not a valid notification type. valid options are: %v", target.Type, TargetTypes)
+	}
+	if !strings.HasPrefix(target.URL, "http://") && !strings.HasPrefix(target.URL, "https://") {
+		return fmt.Errorf("notification URL '%s' must be an http or https URL", target.URL)
+	}
+	for _, e := range target.Events {
+		found := false
+		for _, eventType := range EventTypes {
+			if e == eventType {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("\"%s\" is not a valid event. valid options are: %v", e, EventTypes)
+		}
+	}
+	return nil
+}
+
+// Wants returns true if the target should be sent the event - targets with no list of events get all of them
+func Wants(target *types.NotificationTarget, eventType string) bool {
+	if len(target.Events) == 0 || eventType == TestEvent {
+		return true
+	}
+	for _, e := range target.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Send posts the event to the target - a Slack incoming webhook gets a message, and a generic webhook gets the event as JSON
+func Send(target *types.NotificationTarget, event *Event) error {
+	var body interface{} = event
+	if target.Type == Slack {
+		text := fmt.Sprintf("*FireFly stack %s*: %s", event.Stack, event.Message)
+		if event.Error != "" {
+			text = fmt.Sprintf("%s\n```%s```", text, event.Error)
+		}
+		if event.Host != "" {
+			text = fmt.Sprintf("%s\n_on %s_", text, event.Host)
+		}
+		body = map[string]string{"text": text}
+	}
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Post(target.URL, "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		responseBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%d %s", resp.StatusCode, responseBody)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// GetSandboxURL returns the URL of the member's FireFly Sandbox, or an empty string if it does not have one
+func GetSandboxURL(member *types.Member) string {
+	if member.ExposedSandboxPort == 0 {
+		return ""
+	}
+	return fmt.Sprintf("http://%s:%d", member.Host(), member.ExposedSandboxPort)
+}
+
+// GetDockerServiceDefinitions returns a FireFly Sandbox service for each member that has one, pointed at
+// the member's FireFly API
+func GetDockerServiceDefinitions(stack *types.Stack) []*docker.ServiceDefinition {
+	serviceDefinitions := []*docker.ServiceDefinition{}
+	for _, member := range stack.Members {
+		serviceName := stack.SandboxService(member)
+		if serviceName == "" {
+			continue
+		}
+		scheme := "http"
+		if member.TLS {
+			scheme = "https"
+		}
+		service := &docker.Service{
+			Image: stack.GetImage(types.SandboxComponent),
+			Ports: []string{fmt.Sprintf("%d:3001", member.ExposedSandboxPort)},
+			Environment: map[string]string{
+				"FF_ENDPOINT": fmt.Sprintf("%s://%s:%d", scheme, stack.ServiceHost("firefly_core_"+member.ID), member.ExposedFireflyPort),
+			},
+			DependsOn: map[string]map[string]string{
+				"firefly_core_" + member.ID: {"condition": "service_started"},
+			},
+			Logging: docker.StandardLogOptions,
+		}
+		if member.TLS {
+			// The sandbox is a Node.js app, which trusts the CA of the stack in addition to the public CAs
+			service.Volumes = []string{"./tls/ca.pem:/etc/firefly-sandbox/ca.pem:ro"}
+			service.Environment["NODE_EXTRA_CA_CERTS"] = "/etc/firefly-sandbox/ca.pem"
+		}
+		serviceDefinitions = append(serviceDefinitions, &docker.ServiceDefinition{
+			ServiceName: serviceName,
+			Service:     service,
+		})
+	}
+	return serviceDefinitions
+}
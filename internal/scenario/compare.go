@@ -0,0 +1,58 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenario
+
+import "time"
+
+// StepComparison is the result of one step of a scenario run against two stacks. A or B is nil if the
+// step was not reached on that stack, because an earlier step failed.
+type StepComparison struct {
+	Name   string      `json:"name" yaml:"name"`
+	Member string      `json:"member" yaml:"member"`
+	A      *StepResult `json:"a,omitempty" yaml:"a,omitempty"`
+	B      *StepResult `json:"b,omitempty" yaml:"b,omitempty"`
+	// How much longer the step took on B than on A, if it passed on both
+	Delta time.Duration `json:"delta,omitempty" yaml:"delta,omitempty"`
+	// Whether the step had a different outcome on the two stacks
+	Differs bool `json:"differs" yaml:"differs"`
+}
+
+// CompareResults lines up the results of the same scenario run against two stacks, step by step
+func CompareResults(s *Scenario, a, b []*StepResult) []*StepComparison {
+	comparisons := make([]*StepComparison, 0, len(s.Steps))
+	for i, step := range s.Steps {
+		c := &StepComparison{
+			Name:   step.Name,
+			Member: step.Member,
+		}
+		if i < len(a) {
+			c.A = a[i]
+		}
+		if i < len(b) {
+			c.B = b[i]
+		}
+		if c.A == nil && c.B == nil {
+			break
+		}
+		c.Differs = c.A == nil || c.B == nil || c.A.Passed != c.B.Passed
+		if !c.Differs && c.A.Passed {
+			c.Delta = c.B.Duration - c.A.Duration
+		}
+		comparisons = append(comparisons, c)
+	}
+	return comparisons
+}
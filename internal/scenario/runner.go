@@ -0,0 +1,162 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenario
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum/ethconnect"
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+type StepResult struct {
+	Name     string        `json:"name" yaml:"name"`
+	Member   string        `json:"member" yaml:"member"`
+	Passed   bool          `json:"passed" yaml:"passed"`
+	Duration time.Duration `json:"duration" yaml:"duration"`
+	Error    string        `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type Runner struct {
+	Log      log.Logger
+	Stack    *types.Stack
+	Scenario *Scenario
+
+	// The sequence of the last event consumed by an expect step, for each member
+	cursors map[string]int64
+}
+
+// Run executes each step of the scenario in order, stopping at the first step which fails
+func (r *Runner) Run() ([]*StepResult, error) {
+	r.cursors = make(map[string]int64)
+	for _, step := range r.Scenario.Steps {
+		member, err := r.getMember(step.Member)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", step.Name, err)
+		}
+		if _, ok := r.cursors[member.ID]; step.Expect != nil && !ok {
+			// Only events which happen after the scenario starts can satisfy an expect step
+			if r.cursors[member.ID], err = core.GetLatestEventSequence(member); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	results := make([]*StepResult, 0, len(r.Scenario.Steps))
+	for _, step := range r.Scenario.Steps {
+		r.Log.Info(fmt.Sprintf("running %s", step.Name))
+		start := time.Now()
+		err := r.runStep(step)
+		result := &StepResult{
+			Name:     step.Name,
+			Member:   step.Member,
+			Passed:   err == nil,
+			Duration: time.Since(start).Round(time.Millisecond),
+		}
+		results = append(results, result)
+		if err != nil {
+			result.Error = err.Error()
+			return results, fmt.Errorf("%s failed: %s", step.Name, err)
+		}
+	}
+	return results, nil
+}
+
+func (r *Runner) runStep(step *Step) error {
+	member, _ := r.getMember(step.Member)
+	switch {
+	case step.Broadcast != nil:
+		return r.sendMessage(member, "/messages/broadcast", step.Broadcast, nil)
+	case step.Private != nil:
+		identities := []map[string]string{{"identity": "org_" + member.ID}}
+		for _, id := range step.Private.To {
+			if _, err := r.getMember(id); err != nil {
+				return err
+			}
+			identities = append(identities, map[string]string{"identity": "org_" + id})
+		}
+		return r.sendMessage(member, "/messages/private", step.Private, map[string]interface{}{"members": identities})
+	case step.Transfer != nil:
+		return r.transfer(member, step.Transfer)
+	case step.Invoke != nil:
+		ethconnectUrl := fmt.Sprintf("http://%s:%v", member.Host(), member.ExposedEthconnectPort)
+		params := make(map[string]interface{}, len(step.Invoke.Params))
+		for k, v := range step.Invoke.Params {
+			params[k] = toJSONValue(v)
+		}
+		_, err := ethconnect.InvokeContract(ethconnectUrl, step.Invoke.Contract, step.Invoke.Method, member.Address, params)
+		return err
+	default:
+		return r.expect(member, step.Expect)
+	}
+}
+
+func (r *Runner) sendMessage(member *types.Member, path string, action *MessageAction, group map[string]interface{}) error {
+	body := map[string]interface{}{
+		"header": map[string]interface{}{
+			"tag":    action.Tag,
+			"topics": action.Topics,
+		},
+		"data": []map[string]interface{}{
+			{"value": toJSONValue(action.Value)},
+		},
+	}
+	if group != nil {
+		body["group"] = group
+	}
+	return core.RequestWithRetry(http.MethodPost, core.FireflyURL(member, path), body, nil)
+}
+
+func (r *Runner) transfer(member *types.Member, action *TransferAction) error {
+	recipient, err := r.getMember(action.To)
+	if err != nil {
+		return err
+	}
+	connector := action.Connector
+	if connector == "" && len(r.Stack.TokensProviders) > 0 {
+		connector = r.Stack.TokensProviders[0]
+	}
+	body := map[string]interface{}{
+		"tokenIndex": action.TokenIndex,
+		"to":         recipient.Address,
+		"amount":     action.Amount,
+	}
+	path := fmt.Sprintf("/tokens/%s/pools/%s/transfers", connector, action.Pool)
+	return core.RequestWithRetry(http.MethodPost, core.FireflyURL(member, path), body, nil)
+}
+
+func (r *Runner) expect(member *types.Member, assertion *ExpectAssertion) error {
+	e, err := core.WaitForEvent(member, r.cursors[member.ID], assertion.Event, assertion.Tag, assertion.Timeout)
+	if err != nil {
+		return err
+	}
+	r.cursors[member.ID] = e.Sequence
+	return nil
+}
+
+func (r *Runner) getMember(memberID string) (*types.Member, error) {
+	for _, member := range r.Stack.Members {
+		if member.ID == memberID {
+			return member, nil
+		}
+	}
+	return nil, fmt.Errorf("member '%s' does not exist in stack '%s'", memberID, r.Stack.Name)
+}
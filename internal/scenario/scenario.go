@@ -0,0 +1,139 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenario
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const defaultTimeout = 30 * time.Second
+
+type Scenario struct {
+	Name    string        `yaml:"name,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	Steps   []*Step       `yaml:"steps"`
+}
+
+// Step is a single action taken by a member, or an assertion about events received by a member.
+// Exactly one of the action fields must be set.
+type Step struct {
+	Name      string           `yaml:"name,omitempty"`
+	Member    string           `yaml:"member"`
+	Broadcast *MessageAction   `yaml:"broadcast,omitempty"`
+	Private   *MessageAction   `yaml:"private,omitempty"`
+	Transfer  *TransferAction  `yaml:"transfer,omitempty"`
+	Invoke    *InvokeAction    `yaml:"invoke,omitempty"`
+	Expect    *ExpectAssertion `yaml:"expect,omitempty"`
+}
+
+type MessageAction struct {
+	Value  interface{} `yaml:"value"`
+	Tag    string      `yaml:"tag,omitempty"`
+	Topics []string    `yaml:"topics,omitempty"`
+	// IDs of the members a private message is sent to, in addition to the sender
+	To []string `yaml:"to,omitempty"`
+}
+
+type TransferAction struct {
+	Connector  string `yaml:"connector,omitempty"`
+	Pool       string `yaml:"pool"`
+	TokenIndex string `yaml:"tokenIndex,omitempty"`
+	To         string `yaml:"to"`
+	Amount     string `yaml:"amount"`
+}
+
+type InvokeAction struct {
+	Contract string                 `yaml:"contract"`
+	Method   string                 `yaml:"method"`
+	Params   map[string]interface{} `yaml:"params,omitempty"`
+}
+
+type ExpectAssertion struct {
+	Event   string        `yaml:"event"`
+	Tag     string        `yaml:"tag,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+func LoadScenario(filename string) (*Scenario, error) {
+	d, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var scenario *Scenario
+	if err := yaml.UnmarshalStrict(d, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %s", filename, err)
+	}
+	if scenario == nil || len(scenario.Steps) == 0 {
+		return nil, fmt.Errorf("%s does not contain any steps", filename)
+	}
+	if err := scenario.prepare(); err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+// prepare checks each step of the scenario, and fills in the default names and timeouts
+func (scenario *Scenario) prepare() error {
+	if scenario.Timeout == 0 {
+		scenario.Timeout = defaultTimeout
+	}
+	for i, step := range scenario.Steps {
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step %d", i+1)
+		}
+		if step.Member == "" {
+			return fmt.Errorf("%s: no member specified", step.Name)
+		}
+		actions := 0
+		for _, set := range []bool{step.Broadcast != nil, step.Private != nil, step.Transfer != nil, step.Invoke != nil, step.Expect != nil} {
+			if set {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return fmt.Errorf("%s: each step must have exactly one of broadcast, private, transfer, invoke or expect", step.Name)
+		}
+		if step.Expect != nil && step.Expect.Timeout == 0 {
+			step.Expect.Timeout = scenario.Timeout
+		}
+	}
+	return nil
+}
+
+// toJSONValue converts the map[interface{}]interface{} values produced by the YAML parser into
+// map[string]interface{}, so that they can be marshalled as JSON
+func toJSONValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = toJSONValue(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range t {
+			t[i] = toJSONValue(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenario
+
+// SmokeTest returns a scenario which checks that the members of a stack can reach each other: the first
+// member broadcasts a message, which every member must receive and confirm.
+func SmokeTest(memberIDs []string) *Scenario {
+	scenario := &Scenario{Name: "smoke test"}
+	if len(memberIDs) == 0 {
+		return scenario
+	}
+	scenario.Steps = append(scenario.Steps, &Step{
+		Name:   "broadcast",
+		Member: memberIDs[0],
+		Broadcast: &MessageAction{
+			Tag:   "smoke",
+			Value: "hello from member " + memberIDs[0],
+		},
+	})
+	for _, id := range memberIDs {
+		scenario.Steps = append(scenario.Steps, &Step{
+			Name:   "confirm on member " + id,
+			Member: id,
+			Expect: &ExpectAssertion{
+				Event: "message_confirmed",
+				Tag:   "smoke",
+			},
+		})
+	}
+	scenario.prepare()
+	return scenario
+}
@@ -0,0 +1,120 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/keychain"
+)
+
+// A value of the form secret://keychain/<name> or secret://env/<NAME> is a reference to a secret kept on the
+// host, which is stored as is and only resolved when the value is used
+const referencePrefix = "secret://"
+
+var referenceRegexp = regexp.MustCompile(`secret://(keychain|env)/[A-Za-z0-9_.\-]+`)
+
+// keychainAccount is the OS keychain account a named secret is kept under, apart from the passphrases of
+// encrypted stacks, which are kept under the names of the stacks
+func keychainAccount(name string) string {
+	return "secret:" + name
+}
+
+// IsReference returns whether the value is a reference to a secret
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, referencePrefix)
+}
+
+// Resolve returns the secret a value refers to, or the value itself if it is not a reference
+func Resolve(value string) (string, error) {
+	if !IsReference(value) {
+		return value, nil
+	}
+	if referenceRegexp.FindString(value) != value {
+		return "", fmt.Errorf("\"%s\" is not a valid secret reference - use secret://keychain/<name> or secret://env/<NAME>", value)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, referencePrefix), "/", 2)
+	switch parts[0] {
+	case "env":
+		secret, ok := os.LookupEnv(parts[1])
+		if !ok {
+			return "", fmt.Errorf("environment variable %s of secret reference %s is not set", parts[1], value)
+		}
+		return secret, nil
+	default:
+		secret, err := keychain.Get(keychainAccount(parts[1]))
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve secret reference %s: %s", value, err)
+		}
+		return secret, nil
+	}
+}
+
+// ResolveAll replaces every secret reference in the text with the secret it refers to
+func ResolveAll(text string) (string, error) {
+	var resolveErr error
+	resolved := referenceRegexp.ReplaceAllStringFunc(text, func(reference string) string {
+		secret, err := Resolve(reference)
+		if err != nil && resolveErr == nil {
+			resolveErr = err
+		}
+		return secret
+	})
+	return resolved, resolveErr
+}
+
+// Set stores a named secret in the OS keychain, for secret://keychain/<name> references
+func Set(name string, secret string) error {
+	return keychain.Set(keychainAccount(name), secret)
+}
+
+// Delete removes a named secret from the OS keychain
+func Delete(name string) error {
+	return keychain.Delete(keychainAccount(name))
+}
+
+// CopyFileToVolume copies a config file into a volume with the secrets it refers to resolved. The resolved
+// file is only written to a temporary directory outside the stack's directory while it is copied.
+func CopyFileToVolume(volumeName string, sourcePath string, destPath string, verbose bool) error {
+	d, err := ioutil.ReadFile(sourcePath)
+	if err != nil {
+		return err
+	}
+	if !referenceRegexp.Match(d) {
+		return docker.CopyFileToVolume(volumeName, sourcePath, destPath, verbose)
+	}
+	resolved, err := ResolveAll(string(d))
+	if err != nil {
+		return err
+	}
+	tempDir, err := ioutil.TempDir("", "firefly-secrets")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+	tempFile := filepath.Join(tempDir, filepath.Base(sourcePath))
+	if err := ioutil.WriteFile(tempFile, []byte(resolved), 0600); err != nil {
+		return err
+	}
+	return docker.CopyFileToVolume(volumeName, tempFile, destPath, verbose)
+}
@@ -0,0 +1,123 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulator
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// ReplyTag is set on every message sent by the simulator, so it never replies to its own messages
+const ReplyTag = "simulated_reply"
+
+type Simulator struct {
+	Log          log.Logger
+	Member       *types.Member
+	PollInterval time.Duration
+	ReplyText    string
+
+	lastSequence int64
+}
+
+// Run polls the member's FireFly events until the stop channel is closed, replying to private
+// messages and accepting token transfers on behalf of the member. Only events which occur after
+// the simulator starts are handled. A failed poll is logged and retried on the next tick.
+func (s *Simulator) Run(stop <-chan struct{}) error {
+	if s.PollInterval <= 0 {
+		return fmt.Errorf("the poll interval must be greater than zero")
+	}
+	latest, err := core.GetLatestEventSequence(s.Member)
+	if err != nil {
+		return err
+	}
+	s.lastSequence = latest
+	s.Log.Info(fmt.Sprintf("simulating member %s from event %d", s.Member.ID, s.lastSequence))
+
+	ticker := time.NewTicker(s.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			if err := s.poll(); err != nil {
+				s.Log.Error(fmt.Errorf("failed to poll member %s: %s", s.Member.ID, err))
+			}
+		}
+	}
+}
+
+func (s *Simulator) poll() error {
+	events, err := core.GetEvents(s.Member, fmt.Sprintf("sequence=>%d&sort=sequence&limit=25", s.lastSequence))
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		switch e.Type {
+		case "message_confirmed":
+			err = s.handleMessage(e.Reference)
+		case "token_transfer_confirmed":
+			err = s.handleTokenTransfer(e.Reference)
+		}
+		if err != nil {
+			return err
+		}
+		s.lastSequence = e.Sequence
+	}
+	return nil
+}
+
+func (s *Simulator) handleMessage(id string) error {
+	msg, err := core.GetMessage(s.Member, id)
+	if err != nil {
+		return err
+	}
+	if msg.Header.Type != "private" || msg.Header.Tag == ReplyTag || msg.Header.Author == s.Member.Address {
+		return nil
+	}
+	fmt.Printf("received private message %s from %s - replying\n", msg.Header.ID, msg.Header.Author)
+	reply := map[string]interface{}{
+		"header": map[string]interface{}{
+			"group": msg.Header.Group,
+			"tag":   ReplyTag,
+			"cid":   msg.Header.ID,
+		},
+		"data": []map[string]interface{}{
+			{"value": s.ReplyText},
+		},
+	}
+	return core.RequestWithRetry(http.MethodPost, core.FireflyURL(s.Member, "/messages/private"), reply, nil)
+}
+
+// handleTokenTransfer acknowledges transfers received by the member. FireFly credits the recipient
+// as soon as a transfer is confirmed, so there is nothing further to do to accept it.
+func (s *Simulator) handleTokenTransfer(id string) error {
+	transfer, err := core.GetTokenTransfer(s.Member, id)
+	if err != nil {
+		return err
+	}
+	if transfer.To != s.Member.Address {
+		return nil
+	}
+	fmt.Printf("accepted token %s of %s from pool %s (from %s)\n", transfer.Type, transfer.Amount, transfer.Pool, transfer.From)
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// ABPairStackNames returns the names of the blue and green stacks of a pair
+func ABPairStackNames(pair string) []string {
+	return []string{pair + "-blue", pair + "-green"}
+}
+
+// CreateABPair creates a blue and a green stack from the spec, which differ only in the release each is
+// pinned to. Both stacks derive their keys from the same seed, so members have the same identities and
+// addresses on both, and the green stack is moved to free ports if the blue stack's ports are taken.
+func CreateABPair(logger log.Logger, spec *StackSpec, releases []string, verbose bool) ([]*StackManager, error) {
+	if len(releases) != 2 {
+		return nil, fmt.Errorf("exactly two versions are needed to create a pair, but %d were given", len(releases))
+	}
+	if releases[0] == releases[1] {
+		return nil, fmt.Errorf("the two versions of a pair must be different")
+	}
+	for _, release := range releases {
+		if err := core.ValidateRelease(release); err != nil {
+			return nil, err
+		}
+	}
+	names := ABPairStackNames(spec.Name)
+	for _, name := range names {
+		if exists, err := CheckExists(name); err != nil {
+			return nil, err
+		} else if exists {
+			return nil, fmt.Errorf("stack '%s' already exists", name)
+		}
+	}
+	seed := spec.Seed
+	if seed == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		seed = hex.EncodeToString(b)
+	}
+
+	managers := make([]*StackManager, 0, len(names))
+	for i, name := range names {
+		options, err := spec.initOptions()
+		if err == nil {
+			options.Verbose = verbose
+			options.Release = releases[i]
+			options.Seed = seed
+			options.AutoAdjustPorts = true
+			stackManager := NewStackManager(logger)
+			if err = stackManager.InitStack(name, spec.Members, options); err == nil {
+				stackManager.Stack.ABPair = spec.Name
+				err = stackManager.writeStackConfig()
+				managers = append(managers, stackManager)
+			}
+		}
+		if err != nil {
+			// Never leave half a pair behind
+			for _, name := range names[:i+1] {
+				deleteStackFiles(name)
+				if releaseErr := releaseStackPorts(name); releaseErr != nil {
+					logger.Info(releaseErr.Error())
+				}
+			}
+			return nil, fmt.Errorf("failed to create stack '%s': %s", name, err)
+		}
+	}
+	return managers, nil
+}
+
+// ListABPairs returns the names of the blue/green pairs which exist
+func ListABPairs() ([]string, error) {
+	stackNames, err := ListStacks()
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool)
+	for _, name := range stackNames {
+		d, err := stateStore.Read(name)
+		if err != nil {
+			continue
+		}
+		var stack *types.Stack
+		if parseEncryptedFile(d) != nil || json.Unmarshal(d, &stack) != nil {
+			continue
+		}
+		if stack.ABPair != "" {
+			found[stack.ABPair] = true
+		}
+	}
+	pairs := make([]string, 0, len(found))
+	for pair := range found {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	return pairs, nil
+}
+
+// LoadABPair loads the blue and green stacks of a pair
+func LoadABPair(logger log.Logger, pair string) ([]*StackManager, error) {
+	managers := make([]*StackManager, 0, 2)
+	for _, name := range ABPairStackNames(pair) {
+		stackManager := NewStackManager(logger)
+		if err := stackManager.LoadStack(name); err != nil {
+			return nil, err
+		}
+		if stackManager.Stack.ABPair != pair {
+			return nil, fmt.Errorf("stack '%s' is not part of the pair '%s'", name, pair)
+		}
+		managers = append(managers, stackManager)
+	}
+	return managers, nil
+}
+
+// Release returns the release the stack is pinned to, or "latest" if it tracks the latest images
+func (s *StackManager) Release() string {
+	if s.Stack.VersionManifest != nil && s.Stack.VersionManifest.Release != "" {
+		return s.Stack.VersionManifest.Release
+	}
+	return "latest"
+}
@@ -0,0 +1,84 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+type AccountInfo struct {
+	Address    string `json:"address" yaml:"address"`
+	Member     string `json:"member,omitempty" yaml:"member,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty" yaml:"privateKey,omitempty"`
+}
+
+// GetAccounts returns the signing account of each member, followed by any additional accounts
+func (s *StackManager) GetAccounts(includePrivateKeys bool) []*AccountInfo {
+	accounts := make([]*AccountInfo, 0, len(s.Stack.Members)+len(s.Stack.Accounts))
+	for _, member := range s.Stack.Members {
+		account := &AccountInfo{
+			Address: member.Address,
+			Member:  member.ID,
+		}
+		if includePrivateKeys {
+			account.PrivateKey = member.PrivateKey
+		}
+		accounts = append(accounts, account)
+	}
+	for _, a := range s.Stack.Accounts {
+		account := &AccountInfo{
+			Address: a.Address,
+		}
+		if includePrivateKeys {
+			account.PrivateKey = a.PrivateKey
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+// CreateAccount generates a new funded account. If the stack has never been run, the account is funded
+// in the genesis block, otherwise it is imported into the running blockchain node and funded there.
+func (s *StackManager) CreateAccount() (*types.Account, error) {
+	if err := s.checkGenerated(); err != nil {
+		return nil, err
+	}
+	account := ethereum.GenerateAccount()
+
+	runBefore, err := s.StackHasRunBefore()
+	if err != nil {
+		return nil, err
+	}
+	if runBefore {
+		if err := s.blockchainProvider.ImportAccount(account); err != nil {
+			return nil, fmt.Errorf("failed to import account - please make sure the stack is running: %s", err)
+		}
+	}
+
+	s.Stack.Accounts = append(s.Stack.Accounts, account)
+	if err := s.writeStackConfig(); err != nil {
+		return nil, err
+	}
+	// Regenerate the blockchain config so the account survives a reset
+	if err := s.blockchainProvider.WriteConfig(); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
@@ -0,0 +1,434 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+	"gopkg.in/yaml.v2"
+)
+
+// AdoptReport describes how an existing docker compose deployment was modelled as a stack
+type AdoptReport struct {
+	Stack              string   `json:"stack" yaml:"stack"`
+	Directory          string   `json:"directory" yaml:"directory"`
+	Members            []string `json:"members" yaml:"members"`
+	BlockchainProvider string   `json:"blockchainProvider,omitempty" yaml:"blockchainProvider,omitempty"`
+	Database           string   `json:"database" yaml:"database"`
+	TokensProviders    []string `json:"tokensProviders,omitempty" yaml:"tokensProviders,omitempty"`
+	// Parts of the deployment the CLI does not model, or cannot manage
+	Unsupported []string `json:"unsupported,omitempty" yaml:"unsupported,omitempty"`
+}
+
+type adoptedService struct {
+	name        string
+	component   string
+	Image       string        `yaml:"image"`
+	Build       interface{}   `yaml:"build"`
+	Ports       []interface{} `yaml:"ports"`
+	Volumes     []interface{} `yaml:"volumes"`
+	NetworkMode string        `yaml:"network_mode"`
+	Extends     interface{}   `yaml:"extends"`
+}
+
+// adoptedComponents identifies the services of a deployment by the repository of their image
+var adoptedComponents = map[string]string{
+	"firefly":                     types.FireFlyComponent,
+	"firefly-ethconnect":          types.EthconnectComponent,
+	"firefly-dataexchange-https":  types.DataExchangeComponent,
+	"firefly-tokens-erc1155":      types.TokensERC1155Component,
+	"firefly-tokens-erc20-erc721": types.TokensERC20ERC721Component,
+	"client-go":                   types.GethComponent,
+	"geth":                        types.GethComponent,
+	"besu":                        HyperledgerBesu.String(),
+	"go-ipfs":                     types.IPFSComponent,
+	"kubo":                        types.IPFSComponent,
+	"ipfs":                        types.IPFSComponent,
+	"postgres":                    types.PostgresComponent,
+}
+
+var memberSuffix = regexp.MustCompile(`[_-](\d+)$`)
+
+// ComposeProjectName returns the project name docker compose gives the deployment in a directory, which
+// the adopted stack must share to manage the same containers and volumes
+func ComposeProjectName(dir string) string {
+	return regexp.MustCompile(`[^-_a-z0-9]`).ReplaceAllString(strings.ToLower(filepath.Base(dir)), "")
+}
+
+// AdoptStack builds a stack from the docker compose file of an existing FireFly deployment. The
+// deployment's own compose file is kept, with relative paths pointed back at its directory, so the CLI
+// starts, stops and inspects the same containers and volumes without regenerating anything.
+func (s *StackManager) AdoptStack(dir string, verbose bool) (*AdoptReport, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	composeFile := ""
+	for _, name := range []string{"docker-compose.yml", "docker-compose.yaml", "compose.yml", "compose.yaml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			composeFile = filepath.Join(dir, name)
+			break
+		}
+	}
+	if composeFile == "" {
+		return nil, fmt.Errorf("no docker compose file found in %s", dir)
+	}
+	d, err := ioutil.ReadFile(composeFile)
+	if err != nil {
+		return nil, err
+	}
+
+	stackName := ComposeProjectName(dir)
+	if stackName == "" {
+		return nil, fmt.Errorf("cannot derive a docker compose project name from %s", dir)
+	}
+	if exists, err := CheckExists(stackName); err != nil {
+		return nil, err
+	} else if exists {
+		return nil, fmt.Errorf("stack '%s' already exists", stackName)
+	}
+
+	var parsed struct {
+		Services map[string]*adoptedService `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(d, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid docker compose file %s: %s", composeFile, err)
+	}
+	report := &AdoptReport{Stack: stackName, Directory: dir}
+	s.Stack = &types.Stack{
+		Name:        stackName,
+		Version:     currentStackVersion,
+		AdoptedFrom: dir,
+		Database:    SQLite3.String(),
+	}
+	services := s.classifyServices(parsed.Services, report)
+	if err := s.buildAdoptedMembers(dir, services, report); err != nil {
+		return nil, err
+	}
+
+	compose, err := rewriteComposePaths(d, dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := reserveStackPorts(s.Stack); err != nil {
+		return nil, err
+	}
+	if err := s.writeAdoptedStack(dir, compose); err != nil {
+		deleteStackFiles(stackName)
+		if releaseErr := releaseStackPorts(stackName); releaseErr != nil {
+			s.Log.Error(releaseErr)
+		}
+		return nil, err
+	}
+	s.blockchainProvider = s.getBlockchainProvider(verbose)
+	s.tokensProviders = s.getTokensProviders(verbose)
+	return report, nil
+}
+
+// checkGenerated returns an error for changes that need the CLI to generate the stack's files and keys,
+// which an adopted stack does not
+func (s *StackManager) checkGenerated() error {
+	if s.Stack.AdoptedFrom != "" {
+		return fmt.Errorf("stack '%s' was adopted from %s, whose docker compose file is not generated by the CLI - make this change there instead", s.Stack.Name, s.Stack.AdoptedFrom)
+	}
+	return nil
+}
+
+func (s *StackManager) writeAdoptedStack(dir string, compose []byte) error {
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	if err := os.MkdirAll(filepath.Join(stackDir, "data"), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(stackDir, "docker-compose.yml"), compose, 0755); err != nil {
+		return err
+	}
+	// Variables in the compose file are substituted from the .env file next to it
+	if env, err := ioutil.ReadFile(filepath.Join(dir, ".env")); err == nil {
+		if err := ioutil.WriteFile(filepath.Join(stackDir, ".env"), env, 0644); err != nil {
+			return err
+		}
+	}
+	return s.writeStackConfig()
+}
+
+func (s *StackManager) classifyServices(composeServices map[string]*adoptedService, report *AdoptReport) []*adoptedService {
+	names := make([]string, 0, len(composeServices))
+	for name := range composeServices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	services := make([]*adoptedService, 0, len(names))
+	for _, name := range names {
+		service := composeServices[name]
+		if service == nil {
+			continue
+		}
+		service.name = name
+		repository := service.Image
+		if i := strings.LastIndex(repository, "@"); i >= 0 {
+			repository = repository[:i]
+		}
+		if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+			repository = repository[:i]
+		}
+		service.component = adoptedComponents[repository[strings.LastIndex(repository, "/")+1:]]
+		switch {
+		case service.Image == "":
+			report.Unsupported = append(report.Unsupported, fmt.Sprintf("service '%s' is built rather than run from an image, so it is not modelled", name))
+		case service.component == "":
+			report.Unsupported = append(report.Unsupported, fmt.Sprintf("service '%s' runs %s, which the CLI does not know, so it is not modelled", name, service.Image))
+		}
+		if service.NetworkMode != "" {
+			report.Unsupported = append(report.Unsupported, fmt.Sprintf("service '%s' uses network_mode %s", name, service.NetworkMode))
+		}
+		if service.Extends != nil {
+			report.Unsupported = append(report.Unsupported, fmt.Sprintf("service '%s' extends another service, whose settings are not modelled", name))
+		}
+		if service.component != "" {
+			services = append(services, service)
+			if image := service.Image; s.Stack.ImageOverrides[service.component] == "" {
+				if s.Stack.ImageOverrides == nil {
+					s.Stack.ImageOverrides = make(map[string]string)
+				}
+				s.Stack.ImageOverrides[service.component] = image
+			}
+		}
+	}
+	return services
+}
+
+func (s *StackManager) buildAdoptedMembers(dir string, services []*adoptedService, report *AdoptReport) error {
+	// Members are the FireFly core services, and every other per-member service is matched to a member by
+	// the number its name ends with
+	members := make(map[string]*types.Member)
+	for _, service := range services {
+		if service.component != types.FireFlyComponent {
+			continue
+		}
+		index := len(s.Stack.Members)
+		id := fmt.Sprint(index)
+		if match := memberSuffix.FindStringSubmatch(service.name); match != nil {
+			id = match[1]
+		}
+		member := &types.Member{ID: id, Index: &index, ExposedTokensPorts: make(map[string]int)}
+		apiPort, adminPort := readAdoptedCorePorts(dir, service)
+		member.ExposedFireflyPort = publishedPort(service, apiPort)
+		member.ExposedFireflyAdminPort = publishedPort(service, adminPort)
+		members[id] = member
+		s.Stack.Members = append(s.Stack.Members, member)
+		report.Members = append(report.Members, id)
+	}
+	if len(s.Stack.Members) == 0 {
+		return fmt.Errorf("no FireFly core services found in the docker compose file")
+	}
+
+	memberOf := func(service *adoptedService) *types.Member {
+		if match := memberSuffix.FindStringSubmatch(service.name); match != nil {
+			return members[match[1]]
+		}
+		if len(s.Stack.Members) == 1 {
+			return s.Stack.Members[0]
+		}
+		return nil
+	}
+	for _, service := range services {
+		switch service.component {
+		case types.FireFlyComponent:
+			continue
+		case types.GethComponent, HyperledgerBesu.String():
+			if service.component == types.GethComponent {
+				s.Stack.BlockchainProvider = GoEthereum.String()
+			} else {
+				s.Stack.BlockchainProvider = HyperledgerBesu.String()
+			}
+			s.Stack.ExposedBlockchainPort = publishedPort(service, 8545)
+			continue
+		}
+		member := memberOf(service)
+		if member == nil {
+			report.Unsupported = append(report.Unsupported, fmt.Sprintf("service '%s' could not be matched to a member by its name", service.name))
+			continue
+		}
+		switch service.component {
+		case types.EthconnectComponent:
+			member.ExposedEthconnectPort = publishedPort(service, 8080)
+		case types.DataExchangeComponent:
+			member.ExposedDataexchangePort = publishedPort(service, 3000)
+		case types.IPFSComponent:
+			member.ExposedIPFSApiPort = publishedPort(service, 5001)
+			member.ExposedIPFSGWPort = publishedPort(service, 8080)
+		case types.PostgresComponent:
+			s.Stack.Database = PostgreSQL.String()
+			member.ExposedPostgresPort = publishedPort(service, 5432)
+		case types.TokensERC1155Component, types.TokensERC20ERC721Component:
+			provider := ERC1155.String()
+			if service.component == types.TokensERC20ERC721Component {
+				provider = ERC20ERC721.String()
+			}
+			member.ExposedTokensPorts[provider] = publishedPort(service, 3000)
+			if !containsString(s.Stack.TokensProviders, provider) {
+				s.Stack.TokensProviders = append(s.Stack.TokensProviders, provider)
+			}
+		}
+	}
+	if s.Stack.BlockchainProvider == "" {
+		report.Unsupported = append(report.Unsupported, "no geth or besu blockchain node was found, so blockchain commands are not available")
+	}
+	report.BlockchainProvider = s.Stack.BlockchainProvider
+	report.Database = s.Stack.Database
+	report.TokensProviders = s.Stack.TokensProviders
+	report.Unsupported = append(report.Unsupported, "the keys of the deployment's blockchain accounts are not known to the CLI, so accounts cannot be managed and contracts are not deployed by it")
+	return nil
+}
+
+// readAdoptedCorePorts returns the ports FireFly core listens on inside its container, from its config
+// file if it is bind mounted from the deployment's directory, or the CLI's defaults otherwise
+func readAdoptedCorePorts(dir string, service *adoptedService) (apiPort, adminPort int) {
+	apiPort, adminPort = 5000, 5101
+	for _, volume := range service.Volumes {
+		spec, ok := volume.(string)
+		if !ok {
+			continue
+		}
+		parts := strings.Split(spec, ":")
+		if len(parts) < 2 || !strings.HasPrefix(parts[0], ".") || filepath.Ext(parts[0]) != ".yml" && filepath.Ext(parts[0]) != ".yaml" {
+			continue
+		}
+		config, err := core.ReadFireflyConfig(filepath.Join(dir, parts[0]))
+		if err != nil || config == nil {
+			continue
+		}
+		if config.HTTP != nil && config.HTTP.Port != 0 {
+			apiPort = config.HTTP.Port
+		}
+		if config.Admin != nil && config.Admin.Port != 0 {
+			adminPort = config.Admin.Port
+		}
+	}
+	return apiPort, adminPort
+}
+
+// publishedPort returns the host port a container port of the service is published on, or zero if
+// it is not published
+func publishedPort(service *adoptedService, containerPort int) int {
+	for _, port := range service.Ports {
+		switch p := port.(type) {
+		case string:
+			parts := strings.Split(strings.TrimSuffix(p, "/tcp"), ":")
+			if len(parts) < 2 {
+				continue
+			}
+			target, err := strconv.Atoi(parts[len(parts)-1])
+			if err != nil || target != containerPort {
+				continue
+			}
+			if published, err := strconv.Atoi(parts[len(parts)-2]); err == nil {
+				return published
+			}
+		case map[interface{}]interface{}:
+			if fmt.Sprint(p["target"]) != fmt.Sprint(containerPort) {
+				continue
+			}
+			if published, err := strconv.Atoi(fmt.Sprint(p["published"])); err == nil {
+				return published
+			}
+		}
+	}
+	return 0
+}
+
+// rewriteComposePaths points the relative bind mounts, build contexts and env files of a compose file
+// at the directory it was read from, keeping everything else as it was
+func rewriteComposePaths(d []byte, dir string) ([]byte, error) {
+	var compose yaml.MapSlice
+	if err := yaml.Unmarshal(d, &compose); err != nil {
+		return nil, err
+	}
+	absolute := func(p string) string {
+		if strings.HasPrefix(p, ".") {
+			return filepath.Join(dir, p)
+		}
+		return p
+	}
+	for _, item := range compose {
+		if item.Key != "services" {
+			continue
+		}
+		services, _ := item.Value.(yaml.MapSlice)
+		for _, serviceItem := range services {
+			service, _ := serviceItem.Value.(yaml.MapSlice)
+			for i, field := range service {
+				switch field.Key {
+				case "volumes":
+					volumes, _ := field.Value.([]interface{})
+					for j, volume := range volumes {
+						switch v := volume.(type) {
+						case string:
+							parts := strings.SplitN(v, ":", 2)
+							parts[0] = absolute(parts[0])
+							volumes[j] = strings.Join(parts, ":")
+						case yaml.MapSlice:
+							for k, attr := range v {
+								if attr.Key == "source" {
+									v[k].Value = absolute(fmt.Sprint(attr.Value))
+								}
+							}
+						}
+					}
+				case "build":
+					if context, ok := field.Value.(string); ok {
+						service[i].Value = absolute(context)
+					} else if build, ok := field.Value.(yaml.MapSlice); ok {
+						for k, attr := range build {
+							if attr.Key == "context" {
+								build[k].Value = absolute(fmt.Sprint(attr.Value))
+							}
+						}
+					}
+				case "env_file":
+					if envFile, ok := field.Value.(string); ok {
+						service[i].Value = absolute(envFile)
+					} else if envFiles, ok := field.Value.([]interface{}); ok {
+						for k, envFile := range envFiles {
+							envFiles[k] = absolute(fmt.Sprint(envFile))
+						}
+					}
+				}
+			}
+		}
+	}
+	return yaml.Marshal(compose)
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// HostAlias is a service alias, and the host the generated configs use to reach that service
+type HostAlias struct {
+	Alias   string `json:"alias"`
+	Service string `json:"service"`
+	Host    string `json:"host"`
+}
+
+// GetHostAliases returns the alias of every service in the stack, sorted by alias
+func (s *StackManager) GetHostAliases() []*HostAlias {
+	aliases := make([]*HostAlias, 0)
+	for name := range s.buildDockerCompose().Services {
+		aliases = append(aliases, &HostAlias{
+			Alias:   types.ServiceAlias(name),
+			Service: name,
+			Host:    s.Stack.ServiceHost(name),
+		})
+	}
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Alias < aliases[j].Alias })
+	return aliases
+}
+
+// SetHostAlias maps a service alias to an external host, so every generated config reaches that
+// service at the host instead. An empty host maps the alias back to the service's own container.
+// If the stack has been run before, its configs are copied into place and its containers recreated.
+func (s *StackManager) SetHostAlias(alias string, host string, verbose bool) error {
+	if err := s.checkGenerated(); err != nil {
+		return err
+	}
+	found := false
+	for _, a := range s.GetHostAliases() {
+		if a.Alias == alias {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("'%s' is not the alias of a service in stack '%s'", alias, s.Stack.Name)
+	}
+
+	if host == "" {
+		delete(s.Stack.HostAliases, alias)
+	} else {
+		if s.Stack.HostAliases == nil {
+			s.Stack.HostAliases = make(map[string]string)
+		}
+		s.Stack.HostAliases[alias] = host
+	}
+	if err := s.writeStackFiles(verbose); err != nil {
+		return err
+	}
+
+	runBefore, err := s.StackHasRunBefore()
+	if err != nil || !runBefore {
+		return err
+	}
+	for _, member := range s.Stack.Members {
+		if member.External {
+			continue
+		}
+		if err := s.copyFireflyConfigToVolume(member, verbose); err != nil {
+			return err
+		}
+	}
+	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	s.Log.Info("recreating containers")
+	return docker.RunDockerComposeCommand(workingDir, verbose, verbose, "up", "-d", "--force-recreate")
+}
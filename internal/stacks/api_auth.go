@@ -0,0 +1,55 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/edge"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// generateAPICredentials gives the member the credentials its FireFly API and admin API will require
+func generateAPICredentials(member *types.Member, random io.Reader) error {
+	password := make([]byte, 16)
+	if _, err := io.ReadFull(random, password); err != nil {
+		return err
+	}
+	member.APIUsername = "member" + member.ID
+	member.APIPassword = hex.EncodeToString(password)
+	return nil
+}
+
+// writeAPIPasswordFiles writes the htpasswd file FireFly core checks the credentials of each member against
+func (s *StackManager) writeAPIPasswordFiles() error {
+	for _, member := range s.Stack.Members {
+		if member.APIUsername == "" {
+			continue
+		}
+		entry, err := edge.HtpasswdEntry(member.APIUsername, member.APIPassword)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(core.GetPasswordFilePath(s.Stack, member), []byte(entry), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
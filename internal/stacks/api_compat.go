@@ -0,0 +1,139 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// APIIncompatibility is something a member's FireFly API offered before an upgrade that no longer works
+type APIIncompatibility struct {
+	Member  string `json:"member"`
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Problem string `json:"problem"`
+}
+
+// RecordAPIExpectations saves the namespaces, token connectors and contract APIs offered by the FireFly API
+// of each member, so they can be checked by CheckAPICompatibility once the stack is upgraded. The stack
+// must be running.
+func (s *StackManager) RecordAPIExpectations() error {
+	expectations := make(map[string]*types.APIExpectations, len(s.Stack.Members))
+	for _, member := range s.Stack.Members {
+		expected, err := getAPIExpectations(member)
+		if err != nil {
+			return fmt.Errorf("failed to read the FireFly API of member %s: %s", member.ID, err)
+		}
+		expectations[member.ID] = expected
+	}
+	s.Stack.APIExpectations = expectations
+	return s.writeStackConfig()
+}
+
+func getAPIExpectations(member *types.Member) (*types.APIExpectations, error) {
+	expected := &types.APIExpectations{}
+	namespaces, err := core.GetNamespaces(member)
+	if err != nil {
+		return nil, err
+	}
+	for _, namespace := range namespaces {
+		expected.Namespaces = append(expected.Namespaces, namespace.Name)
+	}
+	connectors, err := core.GetTokenConnectors(member)
+	if err != nil {
+		return nil, err
+	}
+	for _, connector := range connectors {
+		expected.TokenConnectors = append(expected.TokenConnectors, connector.Name)
+	}
+	apis, err := core.GetContractAPIs(member)
+	if err != nil {
+		return nil, err
+	}
+	for _, api := range apis {
+		expected.ContractAPIs = append(expected.ContractAPIs, api.Name)
+	}
+	sort.Strings(expected.Namespaces)
+	sort.Strings(expected.TokenConnectors)
+	sort.Strings(expected.ContractAPIs)
+	return expected, nil
+}
+
+// CheckAPICompatibility waits for the FireFly node of each member to be ready, then checks that everything
+// recorded by RecordAPIExpectations is still offered by its API, and that each contract API can still be
+// resolved to its interface.
+func (s *StackManager) CheckAPICompatibility(timeout time.Duration) ([]*APIIncompatibility, error) {
+	if len(s.Stack.APIExpectations) == 0 {
+		return nil, fmt.Errorf("no API expectations are recorded for stack '%s'", s.Stack.Name)
+	}
+	if err := s.WaitForReady(timeout); err != nil {
+		return nil, err
+	}
+	incompatibilities := make([]*APIIncompatibility, 0)
+	for _, member := range s.Stack.Members {
+		expected, ok := s.Stack.APIExpectations[member.ID]
+		if !ok {
+			continue
+		}
+		actual, err := getAPIExpectations(member)
+		if err != nil {
+			incompatibilities = append(incompatibilities, &APIIncompatibility{
+				Member:  member.ID,
+				Kind:    "api",
+				Problem: err.Error(),
+			})
+			continue
+		}
+		incompatibilities = append(incompatibilities, missingFromAPI(member.ID, "namespace", expected.Namespaces, actual.Namespaces)...)
+		incompatibilities = append(incompatibilities, missingFromAPI(member.ID, "token connector", expected.TokenConnectors, actual.TokenConnectors)...)
+		incompatibilities = append(incompatibilities, missingFromAPI(member.ID, "contract API", expected.ContractAPIs, actual.ContractAPIs)...)
+		for _, name := range expected.ContractAPIs {
+			if !containsString(actual.ContractAPIs, name) {
+				continue
+			}
+			if err := core.GetContractAPIInterface(member, name); err != nil {
+				incompatibilities = append(incompatibilities, &APIIncompatibility{
+					Member:  member.ID,
+					Kind:    "contract API",
+					Name:    name,
+					Problem: fmt.Sprintf("its interface cannot be resolved: %s", err),
+				})
+			}
+		}
+	}
+	return incompatibilities, nil
+}
+
+func missingFromAPI(memberID string, kind string, expected []string, actual []string) []*APIIncompatibility {
+	missing := make([]*APIIncompatibility, 0)
+	for _, name := range expected {
+		if !containsString(actual, name) {
+			missing = append(missing, &APIIncompatibility{
+				Member:  memberID,
+				Kind:    kind,
+				Name:    name,
+				Problem: "no longer listed by the API",
+			})
+		}
+	}
+	return missing
+}
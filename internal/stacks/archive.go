@@ -0,0 +1,386 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+)
+
+// stackArchive describes the contents of a stack archive. It is always the first file in the archive,
+// followed by stack.json, the files of the stack under files/, and the contents of each volume under volumes/.
+type stackArchive struct {
+	Name string `json:"name"`
+	// Volumes whose contents are in the archive, without the prefix of the stack name
+	Volumes []string `json:"volumes,omitempty"`
+	// Set in the archives of frozen stacks, along with the files under repro/
+	Frozen *FrozenStack `json:"frozen,omitempty"`
+}
+
+const stackArchiveInfo = "export.json"
+
+// ExportStack writes the stack to w as a gzipped tar archive. The data directory of the stack is only included
+// with its volumes, as together they are the state of a stack which has been run - without them, the stack
+// runs its first time setup again when it is imported and started.
+func (s *StackManager) ExportStack(w io.Writer, includeVolumes bool, verbose bool) error {
+	return s.exportStack(w, &stackArchive{Name: s.Stack.Name}, includeVolumes, nil, verbose)
+}
+
+// exportStack writes the archive described by info, with the extra files under repro/ by name
+func (s *StackManager) exportStack(w io.Writer, info *stackArchive, includeVolumes bool, reproFiles map[string][]byte, verbose bool) error {
+	if s.Stack.AdoptedFrom != "" {
+		return fmt.Errorf("stack '%s' was adopted from %s, so archive that directory instead", s.Stack.Name, s.Stack.AdoptedFrom)
+	}
+	if s.useKeychain {
+		return fmt.Errorf("stack '%s' is encrypted with a passphrase in the OS keychain, which cannot be exported", s.Stack.Name)
+	}
+	if includeVolumes {
+		if err := s.checkNotArchived(); err != nil {
+			return err
+		}
+		if running, err := s.IsRunning(verbose); err != nil {
+			return err
+		} else if running {
+			return fmt.Errorf("stack '%s' is running - stop it before exporting its volumes", s.Stack.Name)
+		}
+		var err error
+		if info.Volumes, err = s.getExistingVolumes(verbose); err != nil {
+			return err
+		}
+	}
+	stackState, err := stateStore.Read(s.Stack.Name)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	infoBytes, _ := json.MarshalIndent(info, "", " ")
+	if err := writeArchiveFile(tw, stackArchiveInfo, infoBytes, 0644); err != nil {
+		return err
+	}
+	if err := writeArchiveFile(tw, "stack.json", stackState, 0600); err != nil {
+		return err
+	}
+
+	exclude := map[string]bool{"stack.json": true, "snapshots": true, defaultColdArchive: true}
+	if !includeVolumes {
+		exclude["data"] = true
+	}
+	if err := writeArchiveDir(tw, filepath.Join(constants.StacksDir, s.Stack.Name), ".", exclude); err != nil {
+		return err
+	}
+
+	reproNames := make([]string, 0, len(reproFiles))
+	for name := range reproFiles {
+		reproNames = append(reproNames, name)
+	}
+	sort.Strings(reproNames)
+	for _, name := range reproNames {
+		if err := writeArchiveFile(tw, "repro/"+name, reproFiles[name], 0644); err != nil {
+			return err
+		}
+	}
+
+	for _, volume := range info.Volumes {
+		s.Log.Info(fmt.Sprintf("exporting volume %s", volume))
+		if err := s.exportVolume(tw, volume, verbose); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// getExistingVolumes returns the volumes of the stack which exist, without the prefix of the stack name
+func (s *StackManager) getExistingVolumes(verbose bool) ([]string, error) {
+	prefix := s.Stack.Name + "_"
+	names, err := docker.ListVolumes(prefix, verbose)
+	if err != nil {
+		return nil, err
+	}
+	stackVolumes := s.buildDockerCompose().Volumes
+	volumes := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, ok := stackVolumes[strings.TrimPrefix(name, prefix)]; ok {
+			volumes = append(volumes, strings.TrimPrefix(name, prefix))
+		}
+	}
+	sort.Strings(volumes)
+	return volumes, nil
+}
+
+// exportVolume adds the contents of the volume to the archive as a nested tar file. The contents are
+// buffered in a temporary file, as the size of each file must be known before it is added to the archive.
+func (s *StackManager) exportVolume(tw *tar.Writer, volume string, verbose bool) error {
+	tmp, err := ioutil.TempFile("", "ff-volume-*.tar")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := docker.ExportVolume(s.Stack.Name+"_"+volume, tmp, verbose); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    "volumes/" + volume + ".tar",
+		Mode:    0600,
+		Size:    size,
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, tmp)
+	return err
+}
+
+// writeArchiveDir adds the files in dir, a directory under root, to the archive under files/, other than the
+// files and directories in exclude. The files are named by their path relative to root.
+func writeArchiveDir(tw *tar.Writer, root, dir string, exclude map[string]bool) error {
+	return filepath.Walk(filepath.Join(root, dir), func(p string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		switch {
+		case exclude[rel] && fileInfo.IsDir():
+			return filepath.SkipDir
+		case rel == "." || exclude[rel]:
+			return nil
+		case fileInfo.IsDir():
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     "files/" + filepath.ToSlash(rel) + "/",
+				Mode:     0755,
+				ModTime:  fileInfo.ModTime(),
+			})
+		case !fileInfo.Mode().IsRegular():
+			return nil
+		}
+		d, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		// Files keep their modification time, so the same files always give the same archive
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    "files/" + filepath.ToSlash(rel),
+			Mode:    int64(fileInfo.Mode().Perm()),
+			Size:    int64(len(d)),
+			ModTime: fileInfo.ModTime(),
+		}); err != nil {
+			return err
+		}
+		_, err = tw.Write(d)
+		return err
+	})
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, data []byte, mode int64) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    mode,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// ImportStack creates a stack from an archive written by ExportStack, named stackName or, if empty, the name
+// of the exported stack. If the ports of the stack are taken on this machine, it is moved to free ports,
+// and its files are regenerated for the ports it is given.
+func (s *StackManager) ImportStack(r io.Reader, stackName string, verbose bool) error {
+	_, err := s.importStack(r, stackName, false, verbose)
+	return err
+}
+
+// importStack imports the archive, and returns its description. The images of a frozen stack are pinned to
+// the digests they had when it was frozen, and the files under repro/ are extracted to the same directory of
+// the stack. With frozenOnly, an archive of a stack that was not frozen is rejected.
+func (s *StackManager) importStack(r io.Reader, stackName string, frozenOnly bool, verbose bool) (info *stackArchive, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a stack archive: %s", err)
+	}
+	tr := tar.NewReader(gz)
+	header, err := tr.Next()
+	if err != nil || header.Name != stackArchiveInfo {
+		return nil, errors.New("not a stack archive: the archive does not start with " + stackArchiveInfo)
+	}
+	if err := json.NewDecoder(tr).Decode(&info); err != nil {
+		return nil, fmt.Errorf("not a stack archive: %s", err)
+	}
+	if frozenOnly && info.Frozen == nil {
+		return nil, fmt.Errorf("the archive is of a stack that was exported rather than frozen - use import instead")
+	}
+	if stackName == "" {
+		stackName = info.Name
+	}
+	if stackName == "" || stackName != filepath.Base(stackName) || strings.HasPrefix(stackName, ".") {
+		return nil, fmt.Errorf("invalid stack name in archive: '%s'", stackName)
+	}
+	stackDir := filepath.Join(constants.StacksDir, stackName)
+	if exists, err := CheckExists(stackName); err != nil {
+		return nil, err
+	} else if _, statErr := os.Stat(stackDir); exists || statErr == nil {
+		return nil, fmt.Errorf("stack '%s' already exists - import it under another name", stackName)
+	}
+	if len(info.Volumes) > 0 {
+		if existing, err := docker.ListVolumes(stackName+"_", verbose); err != nil {
+			return nil, err
+		} else if len(existing) > 0 {
+			return nil, fmt.Errorf("volumes of an earlier stack named '%s' still exist (%s) - remove them, or import the stack under another name", stackName, strings.Join(existing, ", "))
+		}
+	}
+
+	var importedVolumes []string
+	defer func() {
+		if err != nil {
+			deleteStackFiles(stackName)
+			for _, volume := range importedVolumes {
+				docker.RemoveVolume(volume, verbose)
+			}
+		}
+	}()
+	for {
+		header, err = tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch {
+		case header.Name == "stack.json":
+			d, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if err := stateStore.Write(stackName, d); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(header.Name, "files/"):
+			if err := extractArchiveFile(tr, header, strings.TrimPrefix(header.Name, "files/"), stackDir); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(header.Name, "repro/"):
+			if err := extractArchiveFile(tr, header, header.Name, stackDir); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(header.Name, "volumes/"):
+			volume := stackName + "_" + strings.TrimSuffix(strings.TrimPrefix(header.Name, "volumes/"), ".tar")
+			s.Log.Info(fmt.Sprintf("importing volume %s", volume))
+			importedVolumes = append(importedVolumes, volume)
+			if err := docker.ImportVolume(volume, tr, verbose); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.LoadStack(stackName); err != nil {
+		return nil, err
+	}
+	s.Stack.Name = stackName
+	// The archive of an archived stack is not exported with it
+	s.Stack.ArchivedTo = ""
+	s.Stack.State, s.Stack.StateError = StateCreated, ""
+	if len(importedVolumes) > 0 {
+		s.Stack.State = StateStopped
+	}
+	if info.Frozen != nil {
+		for component, image := range info.Frozen.Images {
+			if s.Stack.ImageOverrides == nil {
+				s.Stack.ImageOverrides = make(map[string]string)
+			}
+			s.Stack.ImageOverrides[component] = image
+		}
+	}
+	unlock, err := lockPortRegistry()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	registry, err := readPortRegistry()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.allocatePorts(registry, true); err != nil {
+		return nil, err
+	}
+	if err := s.writeStackFiles(verbose); err != nil {
+		return nil, err
+	}
+	if len(importedVolumes) > 0 {
+		// The FireFly core configs in the imported volumes have the ports of the exported stack
+		for _, member := range s.Stack.Members {
+			if member.External {
+				continue
+			}
+			if err := s.copyFireflyConfigToVolume(member, verbose); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return info, registry.write()
+}
+
+// extractArchiveFile writes a file from the archive to its path under stackDir
+func extractArchiveFile(tr *tar.Reader, header *tar.Header, name string, stackDir string) error {
+	target := filepath.Join(stackDir, filepath.FromSlash(name))
+	if !strings.HasPrefix(target, stackDir+string(os.PathSeparator)) {
+		return fmt.Errorf("invalid path in stack archive: %s", header.Name)
+	}
+	if header.Typeflag == tar.TypeDir {
+		return os.MkdirAll(target, 0755)
+	}
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode).Perm())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, tr)
+	return err
+}
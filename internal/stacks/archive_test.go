@@ -0,0 +1,88 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractArchiveFileRejectsPathTraversal(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		target string
+		valid  bool
+	}{
+		{name: "file in stack", path: "configs/firefly_core_0.yml", target: "configs/firefly_core_0.yml", valid: true},
+		{name: "absolute path stays in stack", path: "/etc/passwd", target: "etc/passwd", valid: true},
+		{name: "parent directory", path: "../escaped", valid: false},
+		{name: "nested parent directories", path: "configs/../../escaped", valid: false},
+		{name: "deep traversal", path: "../../../../tmp/escaped", valid: false},
+		{name: "stack directory itself", path: ".", valid: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := t.TempDir()
+			stackDir := filepath.Join(root, "stack")
+			if err := os.MkdirAll(stackDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			content := []byte("content")
+			if err := tw.WriteHeader(&tar.Header{Name: test.path, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write(content); err != nil {
+				t.Fatal(err)
+			}
+			tw.Close()
+
+			tr := tar.NewReader(&buf)
+			header, err := tr.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = extractArchiveFile(tr, header, header.Name, stackDir)
+			if !test.valid {
+				if err == nil {
+					t.Fatalf("expected %s to be rejected", test.path)
+				}
+				if _, statErr := os.Stat(filepath.Join(root, "escaped")); statErr == nil {
+					t.Fatal("a file was written outside the stack directory")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			written, err := ioutil.ReadFile(filepath.Join(stackDir, filepath.FromSlash(test.target)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(written, content) {
+				t.Errorf("wrote %q, expected %q", written, content)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/core"
+)
+
+// AwaitEvent blocks until the member receives an event of the given type, or of any type if empty, and with the
+// given message tag if set. Only events after the since sequence match, or if it is negative, only events which
+// arrive after the call starts.
+func (s *StackManager) AwaitEvent(memberID string, eventType string, tag string, since int64, timeout time.Duration) (*core.Event, error) {
+	member, err := s.getMember(memberID)
+	if err != nil {
+		return nil, err
+	}
+	if since < 0 {
+		if since, err = core.GetLatestEventSequence(member); err != nil {
+			return nil, err
+		}
+	}
+	return core.WaitForEvent(member, since, eventType, tag, timeout)
+}
@@ -0,0 +1,202 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+)
+
+// stackBackup describes the contents of a backup archive, which holds the data directory of the stack under
+// files/ and the contents of each volume under volumes/, in the same layout as a stack archive
+type stackBackup struct {
+	Stack   string    `json:"stack"`
+	Created time.Time `json:"created"`
+	// The address of each member, which identify the chain the volumes belong to
+	Members map[string]string `json:"members"`
+	Volumes []string          `json:"volumes"`
+}
+
+const stackBackupInfo = "backup.json"
+
+// BackupStack writes the data of the stopped stack - the contents of its volumes and its data directory -
+// to w as a gzipped tar archive
+func (s *StackManager) BackupStack(w io.Writer, verbose bool) error {
+	info, err := s.newStackBackup(verbose)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	infoBytes, _ := json.MarshalIndent(info, "", " ")
+	if err := writeArchiveFile(tw, stackBackupInfo, infoBytes, 0644); err != nil {
+		return err
+	}
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	if _, err := os.Stat(filepath.Join(stackDir, "data")); err == nil {
+		if err := writeArchiveDir(tw, stackDir, "data", nil); err != nil {
+			return err
+		}
+	}
+	for _, volume := range info.Volumes {
+		s.Log.Info(fmt.Sprintf("backing up volume %s", volume))
+		if err := s.exportVolume(tw, volume, verbose); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// newStackBackup describes a backup of the stack, which must be stopped and have been started before
+func (s *StackManager) newStackBackup(verbose bool) (*stackBackup, error) {
+	if running, err := s.IsRunning(verbose); err != nil {
+		return nil, err
+	} else if running {
+		return nil, fmt.Errorf("stack '%s' is running - stop it before backing it up", s.Stack.Name)
+	}
+	volumes, err := s.getExistingVolumes(verbose)
+	if err != nil {
+		return nil, err
+	}
+	if len(volumes) == 0 {
+		return nil, fmt.Errorf("stack '%s' has no data to back up, as it has not been started", s.Stack.Name)
+	}
+	info := &stackBackup{
+		Stack:   s.Stack.Name,
+		Created: time.Now().UTC(),
+		Members: make(map[string]string, len(s.Stack.Members)),
+		Volumes: volumes,
+	}
+	for _, member := range s.Stack.Members {
+		info.Members[member.ID] = member.Address
+	}
+	return info, nil
+}
+
+// RestoreStack replaces the data of the stopped stack with the data in a backup written by BackupStack. The
+// backup can be of another stack, such as the one the stack was imported from, as long as it has the same
+// members with the same addresses.
+func (s *StackManager) RestoreStack(r io.Reader, verbose bool) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("not a stack backup: %s", err)
+	}
+	tr := tar.NewReader(gz)
+	header, err := tr.Next()
+	if err != nil || header.Name != stackBackupInfo {
+		return errors.New("not a stack backup: the archive does not start with " + stackBackupInfo)
+	}
+	var info *stackBackup
+	if err := json.NewDecoder(tr).Decode(&info); err != nil {
+		return fmt.Errorf("not a stack backup: %s", err)
+	}
+	if err := s.checkBackupMatches(info, verbose); err != nil {
+		return err
+	}
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	if err := s.removeDataForRestore(verbose); err != nil {
+		return err
+	}
+	if err := s.restoreBackupFiles(tr, stackDir, verbose); err != nil {
+		return fmt.Errorf("the stack was only partly restored, so reset it or restore the backup again: %s", err)
+	}
+	return s.finishRestore(verbose)
+}
+
+// checkBackupMatches checks the backup is of the same chain as the stack, which must be stopped
+func (s *StackManager) checkBackupMatches(info *stackBackup, verbose bool) error {
+	if len(info.Members) != len(s.Stack.Members) {
+		return fmt.Errorf("the backup of stack '%s' has %d members, but stack '%s' has %d", info.Stack, len(info.Members), s.Stack.Name, len(s.Stack.Members))
+	}
+	for _, member := range s.Stack.Members {
+		if address, ok := info.Members[member.ID]; !ok || address != member.Address {
+			return fmt.Errorf("the backup of stack '%s' is of a different chain - member %s does not match", info.Stack, member.ID)
+		}
+	}
+	if running, err := s.IsRunning(verbose); err != nil {
+		return err
+	} else if running {
+		return fmt.Errorf("stack '%s' is running - stop it before restoring a backup", s.Stack.Name)
+	}
+	return nil
+}
+
+func (s *StackManager) removeDataForRestore(verbose bool) error {
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	s.Log.Info("removing the current data of the stack")
+	if err := docker.RunDockerComposeCommand(stackDir, verbose, verbose, "down", "--volumes"); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(stackDir, "data"))
+}
+
+func (s *StackManager) finishRestore(verbose bool) error {
+	if s.Stack.AdoptedFrom != "" {
+		return nil
+	}
+	if err := s.ensureDirectories(); err != nil {
+		return err
+	}
+	// The FireFly core configs in the volumes are those of the stack that was backed up, whose ports may differ
+	for _, member := range s.Stack.Members {
+		if member.External {
+			continue
+		}
+		if err := s.copyFireflyConfigToVolume(member, verbose); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StackManager) restoreBackupFiles(tr *tar.Reader, stackDir string, verbose bool) error {
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(header.Name, "files/data/"):
+			if err := extractArchiveFile(tr, header, strings.TrimPrefix(header.Name, "files/"), stackDir); err != nil {
+				return err
+			}
+		case strings.HasPrefix(header.Name, "volumes/"):
+			volume := s.Stack.Name + "_" + strings.TrimSuffix(strings.TrimPrefix(header.Name, "volumes/"), ".tar")
+			s.Log.Info(fmt.Sprintf("restoring volume %s", volume))
+			if err := docker.ImportVolume(volume, tr, verbose); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+)
+
+// A chunked backup is a directory holding the data of a stack as a series of chunk files, listed with their
+// checksums in a manifest that is updated as each chunk is written. The data directory of the stack and
+// each volume are separate gzipped streams, so a backup that is interrupted can carry on from the last
+// chunk written rather than starting over.
+const chunkedBackupManifest = "manifest.json"
+
+type chunkedBackup struct {
+	stackBackup
+	ChunkSize int64          `json:"chunkSize"`
+	Entries   []*backupEntry `json:"entries"`
+	Complete  bool           `json:"complete"`
+}
+
+// backupEntry is the data directory of the stack, as a tar stream, or the contents of one of its volumes
+type backupEntry struct {
+	Name     string         `json:"name"`
+	Chunks   []*backupChunk `json:"chunks,omitempty"`
+	Complete bool           `json:"complete"`
+}
+
+type backupChunk struct {
+	File   string `json:"file"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+const backupDataEntry = "data"
+
+var errBackupChanged = errors.New("the data has changed since the backup was interrupted")
+
+// IsChunkedBackup returns whether the path is a directory holding a chunked backup
+func IsChunkedBackup(path string) bool {
+	_, err := os.Stat(filepath.Join(path, chunkedBackupManifest))
+	return err == nil
+}
+
+func readChunkedBackup(dir string) (*chunkedBackup, error) {
+	manifestBytes, err := ioutil.ReadFile(filepath.Join(dir, chunkedBackupManifest))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var backup *chunkedBackup
+	if err := json.Unmarshal(manifestBytes, &backup); err != nil || backup.Stack == "" {
+		return nil, fmt.Errorf("%s does not hold a stack backup", dir)
+	}
+	// The chunk files are opened, written and removed in the backup directory, so a manifest must not be able
+	// to name a file anywhere else
+	for _, entry := range backup.Entries {
+		for _, chunk := range entry.Chunks {
+			if !isValidChunkFile(chunk.File) {
+				return nil, fmt.Errorf("invalid chunk file in backup manifest: %s", chunk.File)
+			}
+		}
+	}
+	return backup, nil
+}
+
+// isValidChunkFile checks that the name of a chunk file is a plain file name in the backup directory
+func isValidChunkFile(name string) bool {
+	if name == "" || name == "." || name == ".." || filepath.IsAbs(name) || strings.Contains(name, "..") {
+		return false
+	}
+	return !strings.ContainsAny(name, `/\`) && filepath.Base(name) == name
+}
+
+func writeChunkedBackup(dir string, backup *chunkedBackup) error {
+	manifestBytes, err := json.MarshalIndent(backup, "", " ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, chunkedBackupManifest+".tmp")
+	if err := ioutil.WriteFile(tmp, manifestBytes, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, chunkedBackupManifest))
+}
+
+// BackupStackToChunks writes the data of the stopped stack to the directory as chunks of the given size,
+// at no more than bytesPerSecond if it is not 0. If the directory holds a backup of the stack that was
+// interrupted, it is carried on: the chunks already written are checked against the data of the stack,
+// and only those after them are written.
+func (s *StackManager) BackupStackToChunks(dir string, chunkSize int64, bytesPerSecond int64, verbose bool) error {
+	info, err := s.newStackBackup(verbose)
+	if err != nil {
+		return err
+	}
+	backup, err := readChunkedBackup(dir)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		backup = &chunkedBackup{stackBackup: *info, ChunkSize: chunkSize}
+		stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+		if _, err := os.Stat(filepath.Join(stackDir, "data")); err == nil {
+			backup.Entries = append(backup.Entries, &backupEntry{Name: backupDataEntry})
+		}
+		for _, volume := range info.Volumes {
+			backup.Entries = append(backup.Entries, &backupEntry{Name: "volumes/" + volume})
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := writeChunkedBackup(dir, backup); err != nil {
+			return err
+		}
+	} else {
+		if backup.Complete {
+			return fmt.Errorf("%s already holds a complete backup of stack '%s'", dir, backup.Stack)
+		}
+		if backup.Stack != s.Stack.Name || strings.Join(backup.Volumes, ",") != strings.Join(info.Volumes, ",") {
+			return fmt.Errorf("%s holds an unfinished backup of other data than stack '%s' has now - use another directory", dir, s.Stack.Name)
+		}
+		s.Log.Info(fmt.Sprintf("carrying on with the backup in %s, started %s", dir, backup.Created.Local().Format("2006-01-02 15:04:05")))
+	}
+
+	for _, entry := range backup.Entries {
+		if entry.Complete {
+			s.Log.Debug(fmt.Sprintf("%s was already backed up", entry.Name))
+			continue
+		}
+		s.Log.Info(fmt.Sprintf("backing up %s", entry.Name))
+		err := s.backupEntryToChunks(dir, backup, entry, bytesPerSecond, verbose)
+		if err == errBackupChanged {
+			s.Log.Warn(fmt.Sprintf("%s has changed since the backup was interrupted, so it is backed up again from the start", entry.Name))
+			for _, chunk := range entry.Chunks {
+				os.Remove(filepath.Join(dir, chunk.File))
+			}
+			entry.Chunks = nil
+			err = s.backupEntryToChunks(dir, backup, entry, bytesPerSecond, verbose)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	backup.Complete = true
+	return writeChunkedBackup(dir, backup)
+}
+
+func (s *StackManager) backupEntryToChunks(dir string, backup *chunkedBackup, entry *backupEntry, bytesPerSecond int64, verbose bool) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(s.writeBackupEntry(pw, entry.Name, verbose))
+	}()
+	// Closing the reader stops the export if the chunks are not all read
+	defer pr.Close()
+
+	for i := 0; ; i++ {
+		if i < len(entry.Chunks) {
+			chunk := entry.Chunks[i]
+			hash := sha256.New()
+			n, err := io.CopyN(hash, pr, chunk.Size)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if n != chunk.Size || hex.EncodeToString(hash.Sum(nil)) != chunk.SHA256 {
+				return errBackupChanged
+			}
+			continue
+		}
+
+		file := fmt.Sprintf("%s-%05d.chunk", strings.ReplaceAll(entry.Name, "/", "-"), i)
+		part := filepath.Join(dir, file+".part")
+		f, err := os.Create(part)
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		w := io.MultiWriter(hash, NewTransferWriter(f, bytesPerSecond, s.Log, fmt.Sprintf("%s chunk %d", entry.Name, i+1)))
+		n, err := io.CopyN(w, pr, backup.ChunkSize)
+		if err == nil {
+			err = f.Sync()
+		}
+		f.Close()
+		if (err != nil && err != io.EOF) || n == 0 {
+			os.Remove(part)
+			if n == 0 && (err == nil || err == io.EOF) {
+				break
+			}
+			return err
+		}
+		if err := os.Rename(part, filepath.Join(dir, file)); err != nil {
+			return err
+		}
+		entry.Chunks = append(entry.Chunks, &backupChunk{
+			File:   file,
+			Size:   n,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		})
+		if err := writeChunkedBackup(dir, backup); err != nil {
+			return err
+		}
+		s.Log.Info(fmt.Sprintf("%s: chunk %d written (%s)", entry.Name, i+1, describeBytes(n)))
+		if n < backup.ChunkSize {
+			break
+		}
+	}
+	entry.Complete = true
+	return writeChunkedBackup(dir, backup)
+}
+
+// writeBackupEntry writes the data of an entry as a gzipped stream. The gzip header has no timestamp, so the
+// same data always gives the same stream, which is what lets an interrupted backup be checked and resumed.
+func (s *StackManager) writeBackupEntry(w io.Writer, name string, verbose bool) error {
+	gz := gzip.NewWriter(w)
+	if name == backupDataEntry {
+		tw := tar.NewWriter(gz)
+		if err := writeArchiveDir(tw, filepath.Join(constants.StacksDir, s.Stack.Name), "data", nil); err != nil {
+			return err
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+	} else if err := docker.ExportVolume(s.Stack.Name+"_"+strings.TrimPrefix(name, "volumes/"), gz, verbose); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// RestoreStackFromChunks replaces the data of the stopped stack with a complete chunked backup. Every chunk
+// is checked against its checksum before any data of the stack is removed.
+func (s *StackManager) RestoreStackFromChunks(dir string, verbose bool) error {
+	backup, err := readChunkedBackup(dir)
+	if err != nil {
+		return err
+	} else if backup == nil {
+		return fmt.Errorf("%s does not hold a stack backup", dir)
+	}
+	if !backup.Complete {
+		return fmt.Errorf("the backup in %s is incomplete - run the backup again into the same directory to finish it", dir)
+	}
+	if err := s.checkBackupMatches(&backup.stackBackup, verbose); err != nil {
+		return err
+	}
+	s.Log.Info("checking the chunks of the backup")
+	for _, entry := range backup.Entries {
+		for _, chunk := range entry.Chunks {
+			if err := checkBackupChunk(dir, chunk); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := s.removeDataForRestore(verbose); err != nil {
+		return err
+	}
+	for _, entry := range backup.Entries {
+		if err := s.restoreBackupEntry(dir, entry, verbose); err != nil {
+			return fmt.Errorf("the stack was only partly restored, so reset it or restore the backup again: %s", err)
+		}
+	}
+	return s.finishRestore(verbose)
+}
+
+func checkBackupChunk(dir string, chunk *backupChunk) error {
+	f, err := os.Open(filepath.Join(dir, chunk.File))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return err
+	}
+	if n != chunk.Size || hex.EncodeToString(hash.Sum(nil)) != chunk.SHA256 {
+		return fmt.Errorf("chunk %s of the backup is corrupt", chunk.File)
+	}
+	return nil
+}
+
+func (s *StackManager) restoreBackupEntry(dir string, entry *backupEntry, verbose bool) error {
+	readers := make([]io.Reader, len(entry.Chunks))
+	for i, chunk := range entry.Chunks {
+		f, err := os.Open(filepath.Join(dir, chunk.File))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		readers[i] = f
+	}
+	gz, err := gzip.NewReader(io.MultiReader(readers...))
+	if err != nil {
+		return err
+	}
+	if entry.Name != backupDataEntry {
+		volume := s.Stack.Name + "_" + strings.TrimPrefix(entry.Name, "volumes/")
+		s.Log.Info(fmt.Sprintf("restoring volume %s", volume))
+		return docker.ImportVolume(volume, gz, verbose)
+	}
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if strings.HasPrefix(header.Name, "files/data/") {
+			if err := extractArchiveFile(tr, header, strings.TrimPrefix(header.Name, "files/"), stackDir); err != nil {
+				return err
+			}
+		}
+	}
+}
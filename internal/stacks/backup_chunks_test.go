@@ -0,0 +1,74 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsValidChunkFile(t *testing.T) {
+	tests := map[string]bool{
+		"data-00000.chunk":           true,
+		"dev_postgres_0-00012.chunk": true,
+		"":                           false,
+		".":                          false,
+		"..":                         false,
+		"../outside.chunk":           false,
+		"nested/data-00000.chunk":    false,
+		`nested\data-00000.chunk`:    false,
+		"/etc/passwd":                false,
+		"data..chunk":                false,
+	}
+	for name, expected := range tests {
+		if actual := isValidChunkFile(name); actual != expected {
+			t.Errorf("isValidChunkFile(%q) = %t, expected %t", name, actual, expected)
+		}
+	}
+}
+
+func TestReadChunkedBackupRejectsPathsOutsideTheBackup(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		valid bool
+	}{
+		{name: "chunk in backup", file: "data-00000.chunk", valid: true},
+		{name: "parent directory", file: "../../.bashrc", valid: false},
+		{name: "absolute path", file: "/tmp/evil", valid: false},
+		{name: "subdirectory", file: "sub/data-00000.chunk", valid: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			manifest := `{"stack": "dev", "entries": [{"name": "data", "chunks": [{"file": "` + test.file + `", "size": 1, "sha256": ""}]}]}`
+			if err := ioutil.WriteFile(filepath.Join(dir, chunkedBackupManifest), []byte(manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+			backup, err := readChunkedBackup(dir)
+			if test.valid {
+				if err != nil || backup == nil {
+					t.Fatalf("expected the manifest to be read, got %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), "invalid chunk file") {
+				t.Fatalf("expected the manifest to be rejected, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/keychain"
+)
+
+// CloneStack creates a copy of the stack under a new name, on the next free ports, with its own certificates.
+// Without its volumes, the members of the clone get new keys, so the clone starts its own network from
+// scratch. With them, the members keep their keys, as the chain and FireFly data in the volumes belong to them.
+// If the stack has a custom domain, the clone is served under a subdomain of it named after the clone.
+func (s *StackManager) CloneStack(cloneName string, includeVolumes bool, edgePort int, verbose bool) (_ *StackManager, err error) {
+	if err := s.checkGenerated(); err != nil {
+		return nil, err
+	}
+	if exists, err := CheckExists(cloneName); err != nil {
+		return nil, err
+	} else if _, statErr := os.Stat(filepath.Join(constants.StacksDir, cloneName)); exists || statErr == nil {
+		return nil, fmt.Errorf("stack '%s' already exists", cloneName)
+	}
+	for _, member := range s.Stack.Members {
+		if member.PostgresURL != "" {
+			return nil, fmt.Errorf("member %s of stack '%s' uses an external PostgreSQL server, which a clone cannot share", member.ID, s.Stack.Name)
+		}
+	}
+	var volumes []string
+	if includeVolumes {
+		if err := s.checkNotArchived(); err != nil {
+			return nil, err
+		}
+		if running, err := s.IsRunning(verbose); err != nil {
+			return nil, err
+		} else if running {
+			return nil, fmt.Errorf("stack '%s' is running - stop it before cloning its volumes", s.Stack.Name)
+		}
+		if volumes, err = s.getExistingVolumes(verbose); err != nil {
+			return nil, err
+		}
+		if existing, err := docker.ListVolumes(cloneName+"_", verbose); err != nil {
+			return nil, err
+		} else if len(existing) > 0 {
+			return nil, fmt.Errorf("volumes of an earlier stack named '%s' still exist - remove them, or choose another name", cloneName)
+		}
+	}
+
+	clone := NewStackManager(s.Log)
+	stackBytes, _ := json.Marshal(s.Stack)
+	if err := json.Unmarshal(stackBytes, &clone.Stack); err != nil {
+		return nil, err
+	}
+	clone.Stack.Name = cloneName
+	clone.Stack.ABPair = ""
+	clone.Stack.ArchivedTo = ""
+	clone.Stack.State, clone.Stack.StateError = StateCreated, ""
+	if len(volumes) > 0 {
+		clone.Stack.State = StateStopped
+	}
+	clone.passphrase = s.passphrase
+	clone.useKeychain = s.useKeychain
+	if clone.Stack.Domain != "" {
+		clone.Stack.Domain = cloneName + "." + s.Stack.Domain
+		if edgePort != 0 {
+			clone.Stack.ExposedEdgePort = edgePort
+		}
+	}
+	// The node RPC ports of the original are well-known ports that the clone cannot share
+	if len(getNodeRPCPorts(clone.Stack)) > 0 {
+		clone.Stack.ExposedNodeRPCPort, clone.Stack.ExposedNodeWSPort = 0, 0
+		for _, member := range clone.Stack.Members {
+			member.ExposedNodeRPCPort, member.ExposedNodeWSPort = 0, 0
+		}
+		s.Log.Info(fmt.Sprintf("the blockchain node RPC of '%s' is not exposed in the clone", s.Stack.Name))
+	}
+	if len(volumes) == 0 {
+		if err := clone.regenerateIdentities(); err != nil {
+			return nil, err
+		}
+	}
+	clone.blockchainProvider = clone.getBlockchainProvider(false)
+	clone.tokensProviders = clone.getTokensProviders(false)
+
+	var copiedVolumes []string
+	defer func() {
+		if err != nil {
+			deleteStackFiles(cloneName)
+			for _, volume := range copiedVolumes {
+				docker.RemoveVolume(volume, verbose)
+			}
+			if clone.useKeychain {
+				keychain.Delete(cloneName)
+			}
+		}
+	}()
+
+	unlock, err := lockPortRegistry()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	registry, err := readPortRegistry()
+	if err != nil {
+		return nil, err
+	}
+	if err := clone.allocatePorts(registry, true); err != nil {
+		return nil, err
+	}
+	if clone.useKeychain {
+		if err := keychain.Set(cloneName, clone.passphrase); err != nil {
+			return nil, err
+		}
+	}
+	if err := clone.writeStackFiles(verbose); err != nil {
+		return nil, err
+	}
+
+	if len(volumes) > 0 {
+		// The data directory holds the data exchange certificates, which belong with the data in the volumes
+		if err := copyDir(filepath.Join(constants.StacksDir, s.Stack.Name, "data"), filepath.Join(constants.StacksDir, cloneName, "data")); err != nil {
+			return nil, err
+		}
+		for _, volume := range volumes {
+			s.Log.Info(fmt.Sprintf("copying volume %s", volume))
+			copiedVolumes = append(copiedVolumes, cloneName+"_"+volume)
+			if err := docker.CopyVolume(s.Stack.Name+"_"+volume, cloneName+"_"+volume, verbose); err != nil {
+				return nil, err
+			}
+		}
+		for _, member := range clone.Stack.Members {
+			if member.External {
+				continue
+			}
+			if err := clone.copyFireflyConfigToVolume(member, verbose); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return clone, registry.write()
+}
+
+// regenerateIdentities gives each member of the stack new keys and API credentials, and the stack a new IPFS
+// swarm key and randomly generated certificates, so it shares no secrets with the stack it was copied from
+func (s *StackManager) regenerateIdentities() error {
+	s.Stack.SwarmKey = GenerateSwarmKey(rand.Reader)
+	s.Stack.CertificateSeed = ""
+	if s.Stack.VaultToken != "" {
+		token, err := generateVaultToken(rand.Reader)
+		if err != nil {
+			return err
+		}
+		s.Stack.VaultToken = token
+	}
+	for _, member := range s.Stack.Members {
+		account := ethereum.GenerateAccount()
+		member.Address = account.Address
+		member.PrivateKey = account.PrivateKey
+		if member.NodeKey != "" {
+			member.NodeKey = ethereum.GenerateAccount().PrivateKey
+		}
+		if member.SealerAddress != "" {
+			sealer := ethereum.GenerateAccount()
+			member.SealerAddress = sealer.Address
+			member.SealerPrivateKey = sealer.PrivateKey
+		}
+		if member.APIUsername != "" {
+			if err := generateAPICredentials(member, rand.Reader); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func copyDir(src, dest string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, p)
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		d, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(target, d, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,132 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+)
+
+const defaultColdArchive = "archived" + snapshotExtension
+
+// ArchiveStack moves the data of the stack out of docker into an archive, so that many stacks can be kept
+// without their volumes taking up space in docker. The stack is stopped, the contents of its volumes are
+// written to the archive - in the stack directory unless a file or directory is given - and its containers,
+// networks and volumes are removed. The stack keeps its ports until it is unarchived. Returns the archive.
+func (s *StackManager) ArchiveStack(path string, verbose bool) (string, error) {
+	if s.Stack.ArchivedTo != "" {
+		return "", fmt.Errorf("stack '%s' is already archived in %s", s.Stack.Name, s.Stack.ArchivedTo)
+	}
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	filename := filepath.Join(stackDir, defaultColdArchive)
+	if path != "" {
+		filename = path
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			filename = filepath.Join(path, s.Stack.Name+snapshotExtension)
+		}
+	}
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filename); err == nil {
+		return "", fmt.Errorf("%s already exists", filename)
+	}
+	if volumes, err := s.getExistingVolumes(verbose); err != nil {
+		return "", err
+	} else if len(volumes) == 0 {
+		return "", fmt.Errorf("stack '%s' has no data to archive, as it has not been started", s.Stack.Name)
+	}
+	if running, err := s.IsRunning(verbose); err != nil {
+		return "", err
+	} else if running {
+		s.Log.Info(fmt.Sprintf("stopping stack '%s'", s.Stack.Name))
+		if err := s.StopStack(verbose); err != nil {
+			return "", err
+		}
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".archive-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := s.BackupStack(tmp, verbose); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return "", err
+	}
+
+	// Record the archive before the volumes are removed, so the stack is never left without its data and
+	// without knowing where it is
+	s.Stack.ArchivedTo = filename
+	if err := s.writeStackConfig(); err != nil {
+		return "", err
+	}
+	s.Log.Info(fmt.Sprintf("removing the containers and volumes of stack '%s'", s.Stack.Name))
+	if err := docker.RunDockerComposeCommand(stackDir, verbose, verbose, "down", "--volumes", "--remove-orphans"); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// UnarchiveStack restores the volumes of an archived stack from its archive, so it can be started again.
+// The archive is removed once restored, unless keep is set.
+func (s *StackManager) UnarchiveStack(keep bool, verbose bool) error {
+	if s.Stack.ArchivedTo == "" {
+		return fmt.Errorf("stack '%s' is not archived", s.Stack.Name)
+	}
+	f, err := os.Open(s.Stack.ArchivedTo)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("the archive of stack '%s' is missing from %s - move it back to unarchive the stack", s.Stack.Name, s.Stack.ArchivedTo)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := s.RestoreStack(f, verbose); err != nil {
+		return err
+	}
+	f.Close()
+
+	filename := s.Stack.ArchivedTo
+	s.Stack.ArchivedTo = ""
+	if err := s.writeStackConfig(); err != nil {
+		return err
+	}
+	if !keep {
+		return os.Remove(filename)
+	}
+	return nil
+}
+
+// checkNotArchived rejects changes to the data of a stack whose data is in an archive
+func (s *StackManager) checkNotArchived() error {
+	if s.Stack.ArchivedTo != "" {
+		return fmt.Errorf("stack '%s' is archived in %s - unarchive it first", s.Stack.Name, s.Stack.ArchivedTo)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"gopkg.in/yaml.v2"
+)
+
+type StackDifference struct {
+	Field string `json:"field" yaml:"field"`
+	A     string `json:"a" yaml:"a"`
+	B     string `json:"b" yaml:"b"`
+}
+
+// Fields which are generated randomly for every stack, so are always different and not worth reporting
+var ignoredCompareFields = []string{"name", "swarmKey", "accounts", "*.address", "*.privateKey", "*.org.identity"}
+
+// Compare returns every setting which differs between this stack and another, including the
+// providers, component versions, ports and the FireFly core config of each member
+func (s *StackManager) Compare(other *StackManager) ([]*StackDifference, error) {
+	a, err := s.flattenForCompare()
+	if err != nil {
+		return nil, err
+	}
+	b, err := other.flattenForCompare()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]bool)
+	for k := range a {
+		fields[k] = true
+	}
+	for k := range b {
+		fields[k] = true
+	}
+	differences := make([]*StackDifference, 0)
+	for field := range fields {
+		if a[field] != b[field] {
+			differences = append(differences, &StackDifference{Field: field, A: a[field], B: b[field]})
+		}
+	}
+	sort.Slice(differences, func(i, j int) bool { return differences[i].Field < differences[j].Field })
+	return differences, nil
+}
+
+func (s *StackManager) flattenForCompare() (map[string]string, error) {
+	values := make(map[string]string)
+
+	// Round trip through JSON so the stack is flattened using the same field names as stack.json
+	stackBytes, err := json.Marshal(s.Stack)
+	if err != nil {
+		return nil, err
+	}
+	var stack map[string]interface{}
+	if err := json.Unmarshal(stackBytes, &stack); err != nil {
+		return nil, err
+	}
+	flatten("", stack, values)
+
+	for _, member := range s.Stack.Members {
+		configBytes, err := ioutil.ReadFile(filepath.Join(constants.StacksDir, s.Stack.Name, "configs", fmt.Sprintf("firefly_core_%s.yml", member.ID)))
+		if err != nil {
+			return nil, err
+		}
+		var config interface{}
+		if err := yaml.Unmarshal(configBytes, &config); err != nil {
+			return nil, err
+		}
+		flatten(fmt.Sprintf("config.%s", member.ID), config, values)
+	}
+
+	for field := range values {
+		for _, pattern := range ignoredCompareFields {
+			if matchesField(pattern, field) {
+				delete(values, field)
+				break
+			}
+		}
+	}
+	return values, nil
+}
+
+func flatten(prefix string, value interface{}, values map[string]string) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			flatten(join(k), child, values)
+		}
+	case map[interface{}]interface{}:
+		for k, child := range v {
+			flatten(join(fmt.Sprint(k)), child, values)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flatten(join(fmt.Sprint(i)), child, values)
+		}
+	default:
+		values[prefix] = fmt.Sprint(v)
+	}
+}
+
+// matchesField matches a field against a pattern, where the pattern either names a top level
+// field (matching it and everything under it) or starts with "*." to match a suffix at any depth
+func matchesField(pattern string, field string) bool {
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(field, pattern[1:])
+	}
+	return field == pattern || strings.HasPrefix(field, pattern+".")
+}
@@ -0,0 +1,146 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum/ethconnect"
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/monitoring"
+	"github.com/hyperledger/firefly-cli/internal/secrets"
+)
+
+// coreConfigOverridesFile is the file in the stack directory whose FireFly core config is merged into the
+// generated config of each member, every time it is written
+const coreConfigOverridesFile = "core-config-overrides.yml"
+
+func (s *StackManager) coreConfigOverridesPath() string {
+	return filepath.Join(constants.StacksDir, s.Stack.Name, coreConfigOverridesFile)
+}
+
+// readCoreConfigOverrides returns the stack's FireFly core config overrides, or nil if it has none
+func (s *StackManager) readCoreConfigOverrides() (map[interface{}]interface{}, error) {
+	overrides, err := core.ReadFireflyConfigOverrides(s.coreConfigOverridesPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return overrides, err
+}
+
+// copyConfigOverrides saves an overrides file given at init in the stack directory
+func (s *StackManager) copyConfigOverrides(path string, filename string) error {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// Secret references are kept in the copy as they are, and resolved when the generated configs are copied
+	// into the volumes of the services
+	if _, err := secrets.ResolveAll(string(bytes)); err != nil {
+		return fmt.Errorf("failed to resolve the secrets in %s: %s", path, err)
+	}
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	if err := os.MkdirAll(stackDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(stackDir, filename), bytes, 0644)
+}
+
+// buildFireflyCoreConfigs generates the FireFly core config of each member, with the overrides merged in, by
+// the name of the member's FireFly core service
+func (s *StackManager) buildFireflyCoreConfigs() (map[string][]byte, error) {
+	overrides, err := s.readCoreConfigOverrides()
+	if err != nil {
+		return nil, err
+	}
+	configs := make(map[string][]byte, len(s.Stack.Members))
+	for _, member := range s.Stack.Members {
+		config := core.NewFireflyConfig(s.Stack, member)
+		config.Blockchain = s.blockchainProvider.GetFireflyConfig(member)
+		config.Tokens = s.getTokensConfig(member)
+		config.Metrics = monitoring.GetFireflyConfig(s.Stack)
+		if level := s.Stack.LogLevels["firefly_core_"+member.ID]; level != "" {
+			config.Log.Level = level
+		}
+		if configs["firefly_core_"+member.ID], err = core.MarshalFireflyConfigWithOverrides(config, overrides); err != nil {
+			return nil, err
+		}
+	}
+	return configs, nil
+}
+
+func (s *StackManager) writeFireflyCoreConfigs() error {
+	configs, err := s.buildFireflyCoreConfigs()
+	if err != nil {
+		return err
+	}
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	for _, member := range s.Stack.Members {
+		if err := ioutil.WriteFile(filepath.Join(stackDir, "configs", fmt.Sprintf("firefly_core_%s.yml", member.ID)), configs["firefly_core_"+member.ID], 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyCoreConfigOverrides regenerates the FireFly core config of each member before the stack is started,
+// so changes to the overrides file take effect. Once the stack has run, the configs are copied into the
+// volumes its FireFly nodes read them from.
+func (s *StackManager) applyCoreConfigOverrides(verbose bool) error {
+	if s.Stack.AdoptedFrom != "" {
+		return nil
+	}
+	if _, err := os.Stat(s.coreConfigOverridesPath()); os.IsNotExist(err) {
+		return nil
+	}
+	s.Log.Info("applying FireFly core config overrides")
+	if err := s.writeFireflyCoreConfigs(); err != nil {
+		return fmt.Errorf("failed to apply FireFly core config overrides: %s", err)
+	}
+	runBefore, err := s.StackHasRunBefore()
+	if err != nil || !runBefore {
+		return err
+	}
+	for _, member := range s.Stack.Members {
+		if !member.External {
+			if err := s.copyFireflyConfigToVolume(member, verbose); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyEthconnectConfig regenerates the config of each member's ethconnect before the stack is started, so
+// changes to its overrides file take effect, and copies it into their volumes once the stack has run
+func (s *StackManager) applyEthconnectConfig(verbose bool) error {
+	if s.Stack.AdoptedFrom != "" || s.Stack.BlockchainProvider != GoEthereum.String() || !ethconnect.HasConfig(s.Stack) {
+		return nil
+	}
+	if err := ethconnect.WriteConfig(s.Stack); err != nil {
+		return fmt.Errorf("failed to write ethconnect config: %s", err)
+	}
+	runBefore, err := s.StackHasRunBefore()
+	if err != nil || !runBefore {
+		return err
+	}
+	return ethconnect.CopyConfigToVolumes(s.Stack, verbose)
+}
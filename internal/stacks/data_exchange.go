@@ -46,7 +46,7 @@ func (s *StackManager) GenerateDataExchangeHTTPSConfig(memberId string) *DataExc
 		P2P: &DataExchangeListenerConfig{
 			Hostname: "0.0.0.0",
 			Port:     3001,
-			Endpoint: fmt.Sprintf("https://dataexchange_%s:3001", memberId),
+			Endpoint: fmt.Sprintf("https://%s:3001", s.Stack.ServiceHost("dataexchange_"+memberId)),
 		},
 		Peers: []*PeerConfig{},
 	}
@@ -0,0 +1,51 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+func (s *StackManager) getMember(memberID string) (*types.Member, error) {
+	for _, member := range s.Stack.Members {
+		if member.ID == memberID {
+			return member, nil
+		}
+	}
+	return nil, fmt.Errorf("member '%s' does not exist in stack '%s'", memberID, s.Stack.Name)
+}
+
+// DeployContract deploys a compiled contract artifact (containing the ABI and bytecode) using
+// the ethconnect instance of the given member, and returns the address of the new contract
+func (s *StackManager) DeployContract(filename string, memberID string, registeredName string, params map[string]string) (string, error) {
+	if err := s.checkGenerated(); err != nil {
+		return "", err
+	}
+	member, err := s.getMember(memberID)
+	if err != nil {
+		return "", err
+	}
+	contract, err := ethereum.ReadCompiledContract(filename)
+	if err != nil {
+		return "", err
+	}
+	s.Log.Info(fmt.Sprintf("deploying %s on '%s'", contract.ContractName, member.ID))
+	return ethereum.DeployContract(member, contract, registeredName, params)
+}
@@ -0,0 +1,167 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/docker"
+)
+
+const (
+	direnvBlockStart = "# >>> firefly stack >>>"
+	direnvBlockEnd   = "# <<< firefly stack <<<"
+)
+
+type EnvVar struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value" yaml:"value"`
+}
+
+// GetDirenvVariables returns the allowlisted environment variables describing the
+// endpoints of one member of the stack. The first member is used if memberID is empty.
+// API credentials are only included when asked for, as .envrc files are easy to commit.
+func (s *StackManager) GetDirenvVariables(memberID string, includeCredentials bool) ([]*EnvVar, error) {
+	if len(s.Stack.Members) == 0 {
+		return nil, fmt.Errorf("stack '%s' has no members", s.Stack.Name)
+	}
+	if memberID == "" {
+		memberID = s.Stack.Members[0].ID
+	}
+	if _, err := s.getMember(memberID); err != nil {
+		return nil, err
+	}
+	endpoints := s.GetEndpoints()
+	var m *MemberEndpoints
+	for _, e := range endpoints.Members {
+		if e.ID == memberID {
+			m = e
+		}
+	}
+
+	vars := []*EnvVar{}
+	add := func(name, value string) {
+		if value != "" {
+			vars = append(vars, &EnvVar{Name: name, Value: value})
+		}
+	}
+	add("FIREFLY_STACK", s.Stack.Name)
+	add("FIREFLY_MEMBER", m.ID)
+	add("FIREFLY_API_URL", m.FireflyAPI)
+	add("FIREFLY_UI_URL", m.FireflyUI)
+	add("FIREFLY_ADMIN_URL", m.AdminAPI)
+	if includeCredentials {
+		add("FIREFLY_API_USERNAME", m.APIUsername)
+		add("FIREFLY_API_PASSWORD", m.APIPassword)
+	}
+	blockchain := m.Blockchain
+	if blockchain == "" {
+		blockchain = endpoints.Blockchain
+	}
+	add("FIREFLY_BLOCKCHAIN_RPC", blockchain)
+	nodeRPC, nodeWS := m.NodeRPC, m.NodeWS
+	if nodeRPC == "" {
+		nodeRPC, nodeWS = endpoints.NodeRPC, endpoints.NodeWS
+	}
+	add("FIREFLY_NODE_RPC_URL", nodeRPC)
+	add("FIREFLY_NODE_WS_URL", nodeWS)
+	add("FIREFLY_ETHCONNECT_URL", m.Ethconnect)
+	add("FIREFLY_IPFS_API_URL", m.IPFSAPI)
+	add("FIREFLY_IPFS_GATEWAY_URL", m.IPFSGateway)
+	add("FIREFLY_DATAEXCHANGE_URL", m.DataExchange)
+	providers := make([]string, 0, len(m.Tokens))
+	for provider := range m.Tokens {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		add("FIREFLY_TOKENS_URL_"+envName(provider), m.Tokens[provider])
+	}
+	add("FIREFLY_POSTGRES_URL", m.Postgres)
+	return vars, nil
+}
+
+// DirenvBlock renders the managed .envrc block. Unless the guard is disabled, the block
+// logs an error from direnv when none of the stack's containers are running.
+func (s *StackManager) DirenvBlock(vars []*EnvVar, ffCommand string, guard bool) string {
+	var b strings.Builder
+	fmt.Fprintln(&b, direnvBlockStart)
+	fmt.Fprintf(&b, "# Generated by '%s direnv %s' - changes inside this block are overwritten\n", ffCommand, s.Stack.Name)
+	for _, v := range vars {
+		fmt.Fprintf(&b, "export %s=%s\n", v.Name, shellQuote(v.Value))
+	}
+	if guard {
+		engine := docker.GetContainerEngine().Name()
+		fmt.Fprintf(&b, "if [ -z \"$(%s ps -q --filter label=com.docker.compose.project=%s --filter status=running 2>/dev/null)\" ]; then\n", engine, s.Stack.Name)
+		fmt.Fprintf(&b, "  log_error \"FireFly stack '%s' is not running - start it with: %s start %s\"\n", s.Stack.Name, ffCommand, s.Stack.Name)
+		fmt.Fprintln(&b, "fi")
+	}
+	fmt.Fprintln(&b, direnvBlockEnd)
+	return b.String()
+}
+
+// WriteDirenvFile replaces the managed block in the given file, or appends it if the file
+// has none, leaving everything else in the file as it was. It returns true if an existing
+// block was replaced.
+func WriteDirenvFile(filename string, block string) (bool, error) {
+	existing, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	content := string(existing)
+	replaced := false
+	start := strings.Index(content, direnvBlockStart)
+	if start >= 0 {
+		end := strings.Index(content[start:], direnvBlockEnd)
+		if end < 0 {
+			return false, fmt.Errorf("%s has a '%s' line without a matching '%s' line", filename, direnvBlockStart, direnvBlockEnd)
+		}
+		end += start + len(direnvBlockEnd)
+		if end < len(content) && content[end] == '\n' {
+			end++
+		}
+		content = content[:start] + block + content[end:]
+		replaced = true
+	} else {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += block
+	}
+	return replaced, ioutil.WriteFile(filename, []byte(content), 0644)
+}
+
+func envName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
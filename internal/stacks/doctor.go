@@ -0,0 +1,386 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/certs"
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+	"gopkg.in/yaml.v2"
+)
+
+// Statuses of the checks run by the doctor
+const (
+	DoctorOK      = "ok"
+	DoctorWarning = "warning"
+	DoctorFailed  = "failed"
+	// Skipped checks depend on another check which failed
+	DoctorSkipped = "skipped"
+)
+
+// Free space for images and volumes below which the doctor warns, or fails, in MB
+const (
+	doctorDiskWarningMB = 10 * 1024
+	doctorDiskFailedMB  = 2 * 1024
+)
+
+type DoctorCheck struct {
+	Name        string `json:"name" yaml:"name"`
+	Status      string `json:"status" yaml:"status"`
+	Message     string `json:"message" yaml:"message"`
+	Remediation string `json:"remediation,omitempty" yaml:"remediation,omitempty"`
+}
+
+// Doctor checks that the environment can run stacks and, if a stack is given, that the stack is consistent and
+// can be started. Every check runs even if an earlier one fails, unless it depends on the one that failed.
+func Doctor(logger log.Logger, stackName string, verbose bool) []*DoctorCheck {
+	// The stack is loaded first, as it selects the docker host and the registry images are pulled from
+	var stack *types.Stack
+	var stackCheck *DoctorCheck
+	if stackName != "" {
+		stackManager := NewStackManager(logger)
+		stackCheck = stackManager.checkStackConfig(stackName)
+		stack = stackManager.Stack
+	}
+
+	engineCheck := checkContainerEngine(verbose)
+	engineOK := engineCheck.Status == DoctorOK
+	checks := []*DoctorCheck{
+		engineCheck,
+		checkCompose(verbose),
+		checkDiskSpace(engineOK, verbose),
+		checkImageAccess(stack, engineOK, verbose),
+	}
+	if stackName != "" {
+		checks = append(checks, stackCheck, checkStackPorts(stack, engineOK, verbose))
+	}
+	return checks
+}
+
+func checkContainerEngine(verbose bool) *DoctorCheck {
+	engine := docker.GetContainerEngine()
+	check := &DoctorCheck{Name: "container engine"}
+	if _, err := exec.LookPath(engine.Name()); err != nil {
+		check.Status = DoctorFailed
+		check.Message = fmt.Sprintf("%s is not installed", engine.Name())
+		check.Remediation = "install Docker (https://docs.docker.com/get-docker/) or Podman (https://podman.io), and make sure it is on your PATH"
+		return check
+	}
+	version, err := docker.RunDockerCommandBuffered("", verbose, engine.ServerVersionArgs()...)
+	if err != nil {
+		check.Status = DoctorFailed
+		if dockerHost, _ := docker.GetRemoteDaemon(); dockerHost != "" {
+			check.Message = fmt.Sprintf("%s is installed, but the daemon at %s cannot be reached", engine.Name(), dockerHost)
+			check.Remediation = "check the remote host is up and reachable, or unset DOCKER_HOST to use the local daemon"
+		} else if engine.Name() == "podman" {
+			check.Message = "podman is installed, but cannot connect to its machine"
+			check.Remediation = "start the podman machine with 'podman machine start'"
+		} else {
+			check.Message = "docker is installed, but the daemon is not running or cannot be reached"
+			check.Remediation = "start Docker Desktop, or the docker service with 'sudo systemctl start docker', and check your user can run 'docker ps'"
+		}
+		return check
+	}
+	check.Status = DoctorOK
+	check.Message = fmt.Sprintf("%s %s", engine.Name(), strings.TrimSpace(version))
+	return check
+}
+
+func checkCompose(verbose bool) *DoctorCheck {
+	composeCommand := strings.Join(docker.GetContainerEngine().ComposeCommand(), " ")
+	check := &DoctorCheck{Name: "compose"}
+	output, err := docker.RunDockerComposeCommandBuffered("", verbose, "version")
+	if err != nil {
+		check.Status = DoctorFailed
+		check.Message = fmt.Sprintf("%s is not available", composeCommand)
+		if docker.GetContainerEngine().Name() == "podman" {
+			check.Remediation = "install podman-compose with 'pip3 install podman-compose'"
+		} else {
+			check.Remediation = "install the Docker Compose plugin (https://docs.docker.com/compose/install/)"
+		}
+		return check
+	}
+	check.Status = DoctorOK
+	check.Message = strings.TrimSpace(strings.SplitN(strings.TrimSpace(output), "\n", 2)[0])
+	return check
+}
+
+// checkDiskSpace measures the free space from inside a container, as that is where images and volumes are
+// stored - the virtual machine's disk with Docker Desktop, rather than the host's
+func checkDiskSpace(engineOK bool, verbose bool) *DoctorCheck {
+	check := &DoctorCheck{Name: "disk space"}
+	if !engineOK {
+		check.Status = DoctorSkipped
+		check.Message = "the container engine is not available"
+		return check
+	}
+	output, err := docker.RunDockerCommandBuffered("", verbose, "run", "--rm", docker.UtilityImage, "df", "-Pk", "/")
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	var availableKB int
+	if err == nil && len(fields) >= 4 {
+		availableKB, err = strconv.Atoi(fields[3])
+	} else if err == nil {
+		err = fmt.Errorf("unexpected output from df: %s", output)
+	}
+	if err != nil {
+		check.Status = DoctorWarning
+		check.Message = fmt.Sprintf("could not measure the free disk space: %s", err)
+		return check
+	}
+	freeMB := availableKB / 1024
+	check.Message = fmt.Sprintf("%.1f GB free for images and volumes", float64(freeMB)/1024)
+	switch {
+	case freeMB < doctorDiskFailedMB:
+		check.Status = DoctorFailed
+	case freeMB < doctorDiskWarningMB:
+		check.Status = DoctorWarning
+	default:
+		check.Status = DoctorOK
+		return check
+	}
+	check.Remediation = "remove stacks you no longer need with 'ff remove', and unused images and volumes with 'docker system prune' - with Docker Desktop, you can also increase its disk size in the settings"
+	return check
+}
+
+// checkImageAccess checks that every image of the stack, or just the utility image if no stack is given,
+// is available locally or can be pulled
+func checkImageAccess(stack *types.Stack, engineOK bool, verbose bool) *DoctorCheck {
+	check := &DoctorCheck{Name: "image pull access"}
+	if !engineOK {
+		check.Status = DoctorSkipped
+		check.Message = "the container engine is not available"
+		return check
+	}
+	images := []string{docker.UtilityImage}
+	if stack != nil {
+		images = getComposeImages(stack)
+	}
+	var unavailable []string
+	for _, image := range images {
+		if _, err := docker.RunDockerCommandBuffered("", verbose, "image", "inspect", image); err == nil {
+			continue
+		}
+		if _, err := docker.RunDockerCommandBuffered("", verbose, "manifest", "inspect", image); err != nil {
+			unavailable = append(unavailable, image)
+		}
+	}
+	if len(unavailable) == 0 {
+		check.Status = DoctorOK
+		check.Message = fmt.Sprintf("%d images are available locally or can be pulled", len(images))
+		return check
+	}
+	check.Status = DoctorFailed
+	check.Message = fmt.Sprintf("cannot pull %s", strings.Join(unavailable, ", "))
+	check.Remediation = "check your network connection and proxy settings, log in to private registries with 'docker login <registry>', and check the names of any images overridden at init"
+	if stack != nil && stack.Registry != nil {
+		check.Remediation = fmt.Sprintf("images are pulled through %s - check it is reachable and the credentials given at init are still valid", stack.Registry.URL)
+	}
+	return check
+}
+
+// getComposeServices returns the image of each service in the stack's compose file, by service name. The
+// compose file of an adopted stack is not generated, so it is read rather than built from the stack.
+func getComposeServices(stack *types.Stack) map[string]string {
+	var compose struct {
+		Services map[string]struct {
+			Image string `yaml:"image"`
+		} `yaml:"services"`
+	}
+	d, err := ioutil.ReadFile(filepath.Join(constants.StacksDir, stack.Name, "docker-compose.yml"))
+	if err != nil || yaml.Unmarshal(d, &compose) != nil {
+		return nil
+	}
+	services := make(map[string]string, len(compose.Services))
+	for name, service := range compose.Services {
+		services[name] = service.Image
+	}
+	return services
+}
+
+// getComposeImages returns the distinct images in the stack's compose file
+func getComposeImages(stack *types.Stack) []string {
+	services := getComposeServices(stack)
+	found := make(map[string]bool)
+	images := make([]string, 0, len(services))
+	for _, image := range services {
+		if image != "" && !found[image] {
+			found[image] = true
+			images = append(images, image)
+		}
+	}
+	sort.Strings(images)
+	return images
+}
+
+func checkStackPorts(stack *types.Stack, engineOK bool, verbose bool) *DoctorCheck {
+	check := &DoctorCheck{Name: "ports"}
+	if stack == nil {
+		check.Status = DoctorSkipped
+		check.Message = "the stack config could not be read"
+		return check
+	}
+	if engineOK {
+		containers, _ := docker.ListProjectContainers(stack.Name, verbose)
+		for _, c := range containers {
+			if c.State == "running" {
+				check.Status = DoctorOK
+				check.Message = "the stack is running, so its ports are in use by the stack itself"
+				return check
+			}
+		}
+	}
+	registry, err := readPortRegistry()
+	if err != nil {
+		check.Status = DoctorWarning
+		check.Message = fmt.Sprintf("could not read the port registry: %s", err)
+		return check
+	}
+	reservedBy := make(map[int]string)
+	for name, reservation := range registry.Stacks {
+		if name == stack.Name || reservation.Host != stack.Host() {
+			continue
+		}
+		for _, port := range reservation.Ports {
+			reservedBy[port] = name
+		}
+	}
+
+	var inUse, shared []string
+	otherStacks := make(map[string]bool)
+	ports := getStackPorts(stack)
+	for _, port := range ports {
+		if available, err := checkPortAvailable(stack.Host(), port); err != nil || !available {
+			inUse = append(inUse, fmt.Sprint(port))
+		}
+		if name, ok := reservedBy[port]; ok {
+			shared = append(shared, fmt.Sprint(port))
+			otherStacks[name] = true
+		}
+	}
+	names := make([]string, 0, len(otherStacks))
+	for name := range otherStacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch {
+	case len(inUse) > 0:
+		check.Status = DoctorFailed
+		check.Message = fmt.Sprintf("ports %s are already in use", strings.Join(inUse, ", "))
+		if len(names) > 0 {
+			check.Remediation = fmt.Sprintf("stop %s, which use the same ports, with 'ff stop', or stop any other process listening on them", strings.Join(names, ", "))
+		} else {
+			check.Remediation = "stop the processes listening on these ports, or create the stack again on other ports with --firefly-base-port and --services-base-port"
+		}
+	case len(shared) > 0:
+		check.Status = DoctorWarning
+		check.Message = fmt.Sprintf("ports %s are also used by %s, so only one of them can run at a time", strings.Join(shared, ", "), strings.Join(names, ", "))
+	default:
+		check.Status = DoctorOK
+		check.Message = fmt.Sprintf("all %d ports of the stack are free", len(ports))
+	}
+	return check
+}
+
+// checkStackConfig loads the stack, and checks that its stack.json is consistent with itself and with the
+// files generated for the stack. The stack is left unset if it cannot be loaded.
+func (s *StackManager) checkStackConfig(stackName string) *DoctorCheck {
+	check := &DoctorCheck{Name: "stack config"}
+	if exists, err := CheckExists(stackName); err == nil && !exists {
+		check.Status = DoctorFailed
+		check.Message = fmt.Sprintf("stack '%s' does not exist", stackName)
+		check.Remediation = "list the stacks with 'ff ls'"
+		return check
+	}
+	if err := s.LoadStack(stackName); err != nil {
+		s.Stack = nil
+		check.Status = DoctorFailed
+		check.Message = fmt.Sprintf("stack.json could not be read: %s", err)
+		check.Remediation = fmt.Sprintf("if the stack is encrypted, check the passphrase - otherwise restore stack.json from a backup, or create the stack again with 'ff remove %s' and 'ff init'", stackName)
+		return check
+	}
+
+	stackDir := filepath.Join(constants.StacksDir, stackName)
+	var problems []string
+	if s.Stack.Version > currentStackVersion {
+		check.Status = DoctorFailed
+		check.Message = fmt.Sprintf("the stack was created by a newer version of the CLI (stack version %d)", s.Stack.Version)
+		check.Remediation = "upgrade ff to the latest release"
+		return check
+	}
+	if s.Stack.Name != stackName {
+		problems = append(problems, fmt.Sprintf("stack.json is for stack '%s'", s.Stack.Name))
+	}
+	if len(s.Stack.Members) == 0 {
+		problems = append(problems, "the stack has no members")
+	}
+	memberIDs := make(map[string]bool)
+	for _, member := range s.Stack.Members {
+		if memberIDs[member.ID] {
+			problems = append(problems, fmt.Sprintf("member %s appears more than once", member.ID))
+		}
+		memberIDs[member.ID] = true
+		if s.Stack.AdoptedFrom == "" {
+			configFile := filepath.Join(stackDir, "configs", fmt.Sprintf("firefly_core_%s.yml", member.ID))
+			if _, err := os.Stat(configFile); err != nil {
+				problems = append(problems, fmt.Sprintf("the FireFly core config of member %s is missing", member.ID))
+			}
+		}
+	}
+	seenPorts := make(map[int]bool)
+	for _, port := range getStackPorts(s.Stack) {
+		if seenPorts[port] {
+			problems = append(problems, fmt.Sprintf("port %d is used by more than one service", port))
+		}
+		seenPorts[port] = true
+	}
+	if _, err := os.Stat(filepath.Join(stackDir, "docker-compose.yml")); err != nil {
+		problems = append(problems, "docker-compose.yml is missing")
+	}
+	if s.Stack.TLS {
+		if _, err := os.Stat(certs.GetCACertPath(s.Stack)); err != nil {
+			problems = append(problems, "the TLS CA certificate is missing")
+		}
+	}
+	if s.Stack.AdoptedFrom != "" {
+		if _, err := os.Stat(s.Stack.AdoptedFrom); err != nil {
+			problems = append(problems, fmt.Sprintf("the directory the stack was adopted from, %s, no longer exists", s.Stack.AdoptedFrom))
+		}
+	}
+
+	if len(problems) > 0 {
+		check.Status = DoctorFailed
+		check.Message = strings.Join(problems, "; ")
+		check.Remediation = fmt.Sprintf("the stack's files have been changed or deleted by hand - create the stack again with 'ff remove %s' and 'ff init'", stackName)
+		return check
+	}
+	check.Status = DoctorOK
+	check.Message = fmt.Sprintf("stack.json is consistent, with %d members", len(s.Stack.Members))
+	return check
+}
@@ -0,0 +1,191 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/hyperledger/firefly-cli/internal/keychain"
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnvVar can be set to the passphrase of an encrypted stack, to run commands without being prompted for it
+const PassphraseEnvVar = "FF_STACK_PASSPHRASE"
+
+// PromptPassphrase is called to ask the user for the passphrase of an encrypted stack, when it is not
+// set in the environment or stored in the OS keychain
+var PromptPassphrase func(prompt string) (string, error)
+
+// encryptedFile is written in place of the contents of a sensitive file. The key is derived from the
+// passphrase with scrypt, and the contents are encrypted with AES-256-GCM. The lifecycle state of an
+// encrypted stack is kept in the clear alongside its contents, so stacks can be listed without passphrases.
+type encryptedFile struct {
+	Encrypted *encryptedData `json:"encrypted"`
+	State     string         `json:"state,omitempty"`
+}
+
+type encryptedData struct {
+	KDF        string `json:"kdf"`
+	Keychain   bool   `json:"keychain,omitempty"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 32768, 8, 1, 32)
+}
+
+func encrypt(plaintext []byte, passphrase string, useKeychain bool) ([]byte, error) {
+	data, err := encryptData(plaintext, passphrase, useKeychain)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(&encryptedFile{Encrypted: data}, "", " ")
+}
+
+func encryptData(plaintext []byte, passphrase string, useKeychain bool) (*encryptedData, error) {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return &encryptedData{
+		KDF:        "scrypt",
+		Keychain:   useKeychain,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	}, nil
+}
+
+func decrypt(data *encryptedData, passphrase string) ([]byte, error) {
+	if data.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation function '%s'", data.KDF)
+	}
+	key, err := deriveKey(passphrase, data.Salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, data.Nonce, data.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("incorrect passphrase")
+	}
+	return plaintext, nil
+}
+
+// parseEncryptedFile returns the encrypted contents of a file, or nil if the file is not encrypted
+func parseEncryptedFile(data []byte) *encryptedData {
+	var file *encryptedFile
+	if err := json.Unmarshal(data, &file); err != nil || file == nil {
+		return nil
+	}
+	return file.Encrypted
+}
+
+// unlockStack finds the passphrase of an encrypted stack and decrypts its contents
+func (s *StackManager) unlockStack(stackName string, data *encryptedData) ([]byte, error) {
+	passphrase := os.Getenv(PassphraseEnvVar)
+	if passphrase == "" && data.Keychain {
+		var err error
+		if passphrase, err = keychain.Get(stackName); err != nil {
+			return nil, err
+		}
+	}
+	if passphrase == "" {
+		if PromptPassphrase == nil {
+			return nil, fmt.Errorf("stack '%s' is encrypted - set %s to its passphrase", stackName, PassphraseEnvVar)
+		}
+		var err error
+		if passphrase, err = PromptPassphrase(fmt.Sprintf("passphrase for stack '%s': ", stackName)); err != nil {
+			return nil, err
+		}
+	}
+	plaintext, err := decrypt(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt stack '%s': %s", stackName, err)
+	}
+	s.passphrase = passphrase
+	s.useKeychain = data.Keychain
+	return plaintext, nil
+}
+
+// setupEncryption sets the passphrase used to encrypt a new stack, generating one and storing it in
+// the OS keychain if requested
+func (s *StackManager) setupEncryption(options *InitOptions) error {
+	s.Stack.Encrypted = true
+	s.passphrase = options.Passphrase
+	if options.UseKeychain {
+		key := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, key); err != nil {
+			return err
+		}
+		s.passphrase = hex.EncodeToString(key)
+		s.useKeychain = true
+		if err := keychain.Set(s.Stack.Name, s.passphrase); err != nil {
+			return err
+		}
+	}
+	if s.passphrase == "" {
+		return fmt.Errorf("a passphrase is required to encrypt the stack")
+	}
+	return nil
+}
+
+// writeSensitiveFile writes the file encrypted with the stack's passphrase if the stack is encrypted,
+// adding a .enc extension to its name
+func (s *StackManager) writeSensitiveFile(filename string, data []byte) error {
+	if !s.Stack.Encrypted {
+		return ioutil.WriteFile(filename, data, 0600)
+	}
+	encrypted, err := encrypt(data, s.passphrase, s.useKeychain)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename+".enc", encrypted, 0600)
+}
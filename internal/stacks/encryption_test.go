@@ -0,0 +1,103 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		plaintext  []byte
+		passphrase string
+	}{
+		{name: "stack definition", plaintext: []byte(`{"name": "dev", "members": []}`), passphrase: "correct horse"},
+		{name: "empty file", plaintext: []byte{}, passphrase: "correct horse"},
+		{name: "binary key", plaintext: bytes.Repeat([]byte{0x00, 0xff, 0x10}, 1000), passphrase: "correct horse"},
+		{name: "unicode passphrase", plaintext: []byte("secret"), passphrase: "pässwörd ✓"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encrypted, err := encrypt(test.plaintext, test.passphrase, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(test.plaintext) > 0 && bytes.Contains(encrypted, test.plaintext) {
+				t.Fatal("the encrypted file contains the plaintext")
+			}
+			data := parseEncryptedFile(encrypted)
+			if data == nil {
+				t.Fatal("the encrypted file was not recognised as encrypted")
+			}
+			decrypted, err := decrypt(data, test.passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted, test.plaintext) {
+				t.Errorf("decrypted %q, expected %q", decrypted, test.plaintext)
+			}
+		})
+	}
+}
+
+func TestDecryptRejectsWrongPassphraseOrTampering(t *testing.T) {
+	data, err := encryptData([]byte("private key"), "correct horse", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decrypt(data, "wrong horse"); err == nil || !strings.Contains(err.Error(), "incorrect passphrase") {
+		t.Errorf("expected an incorrect passphrase error, got %v", err)
+	}
+
+	tampered := *data
+	tampered.Ciphertext = append([]byte{}, data.Ciphertext...)
+	tampered.Ciphertext[0] ^= 0xff
+	if _, err := decrypt(&tampered, "correct horse"); err == nil {
+		t.Error("expected tampered ciphertext to be rejected")
+	}
+
+	unsupported := *data
+	unsupported.KDF = "pbkdf2"
+	if _, err := decrypt(&unsupported, "correct horse"); err == nil {
+		t.Error("expected an unsupported key derivation function to be rejected")
+	}
+}
+
+func TestEncryptUsesFreshSaltAndNonce(t *testing.T) {
+	a, err := encryptData([]byte("same"), "same", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := encryptData([]byte("same"), "same", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(a.Salt, b.Salt) || bytes.Equal(a.Nonce, b.Nonce) || bytes.Equal(a.Ciphertext, b.Ciphertext) {
+		t.Error("expected each encryption to use a new salt and nonce")
+	}
+}
+
+func TestParseEncryptedFileIgnoresPlainFiles(t *testing.T) {
+	for _, plain := range []string{`{"name": "dev"}`, `not json`, `null`} {
+		if parseEncryptedFile([]byte(plain)) != nil {
+			t.Errorf("expected %q not to be treated as encrypted", plain)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
+	"github.com/hyperledger/firefly-cli/internal/edge"
+	"github.com/hyperledger/firefly-cli/internal/eventbridge"
+	"github.com/hyperledger/firefly-cli/internal/monitoring"
+	"github.com/hyperledger/firefly-cli/internal/sandbox"
+	"github.com/hyperledger/firefly-cli/internal/webhookrelay"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+type MemberEndpoints struct {
+	ID         string `json:"id" yaml:"id"`
+	External   bool   `json:"external,omitempty" yaml:"external,omitempty"`
+	URL        string `json:"url,omitempty" yaml:"url,omitempty"`
+	FireflyAPI string `json:"fireflyApi" yaml:"fireflyApi"`
+	FireflyUI  string `json:"fireflyUi,omitempty" yaml:"fireflyUi,omitempty"`
+	AdminAPI   string `json:"adminApi" yaml:"adminApi"`
+	// FireFly Sandbox pointed at the member's FireFly API, if the member has one
+	Sandbox string `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+	// Credentials required by the FireFly API and admin API, if the stack has API auth enabled
+	APIUsername  string `json:"apiUsername,omitempty" yaml:"apiUsername,omitempty"`
+	APIPassword  string `json:"apiPassword,omitempty" yaml:"apiPassword,omitempty"`
+	Blockchain   string `json:"blockchain,omitempty" yaml:"blockchain,omitempty"`
+	Ethconnect   string `json:"ethconnect,omitempty" yaml:"ethconnect,omitempty"`
+	IPFSAPI      string `json:"ipfsApi" yaml:"ipfsApi"`
+	IPFSGateway  string `json:"ipfsGateway" yaml:"ipfsGateway"`
+	DataExchange string `json:"dataExchange,omitempty" yaml:"dataExchange,omitempty"`
+	// URL of each tokens connector, by tokens provider
+	Tokens   map[string]string `json:"tokens,omitempty" yaml:"tokens,omitempty"`
+	Postgres string            `json:"postgres,omitempty" yaml:"postgres,omitempty"`
+	// Read replica of the member's database, if the stack has replicas
+	PostgresReplica string `json:"postgresReplica,omitempty" yaml:"postgresReplica,omitempty"`
+	// JSON-RPC and WebSocket endpoints of the member's own blockchain node, if exposed for wallets and tools
+	NodeRPC string `json:"nodeRpc,omitempty" yaml:"nodeRpc,omitempty"`
+	NodeWS  string `json:"nodeWs,omitempty" yaml:"nodeWs,omitempty"`
+}
+
+type StackEndpoints struct {
+	Name        string `json:"name" yaml:"name"`
+	Blockchain  string `json:"blockchain" yaml:"blockchain"`
+	EventBroker string `json:"eventBroker,omitempty" yaml:"eventBroker,omitempty"`
+	// JSON-RPC and WebSocket endpoints of the shared blockchain node, if exposed for wallets and tools
+	NodeRPC string `json:"nodeRpc,omitempty" yaml:"nodeRpc,omitempty"`
+	NodeWS  string `json:"nodeWs,omitempty" yaml:"nodeWs,omitempty"`
+	// URL to use for FireFly webhook subscriptions, from inside the stack
+	WebhookRelay   string             `json:"webhookRelay,omitempty" yaml:"webhookRelay,omitempty"`
+	WebhookRelayUI string             `json:"webhookRelayUi,omitempty" yaml:"webhookRelayUi,omitempty"`
+	Prometheus     string             `json:"prometheus,omitempty" yaml:"prometheus,omitempty"`
+	Grafana        string             `json:"grafana,omitempty" yaml:"grafana,omitempty"`
+	Members        []*MemberEndpoints `json:"members" yaml:"members"`
+}
+
+func (s *StackManager) GetEndpoints() *StackEndpoints {
+	endpoints := &StackEndpoints{
+		Name:        s.Stack.Name,
+		Blockchain:  fmt.Sprintf("http://%s:%d", s.Stack.Host(), s.Stack.ExposedBlockchainPort),
+		Members:     make([]*MemberEndpoints, len(s.Stack.Members)),
+		EventBroker: eventbridge.GetBrokerURL(s.Stack),
+	}
+	if s.Stack.ExposedNodeRPCPort != 0 {
+		endpoints.NodeRPC = fmt.Sprintf("http://%s:%d", s.Stack.Host(), s.Stack.ExposedNodeRPCPort)
+		endpoints.NodeWS = fmt.Sprintf("ws://%s:%d", s.Stack.Host(), s.Stack.ExposedNodeWSPort)
+	}
+	if s.Stack.WebhookRelayTargetPort != 0 {
+		endpoints.WebhookRelay = webhookrelay.GetWebhookURL(s.Stack)
+		endpoints.WebhookRelayUI = webhookrelay.GetReplayUIURL(s.Stack)
+	}
+	if s.Stack.ExposedPrometheusPort != 0 {
+		endpoints.Prometheus = monitoring.GetPrometheusURL(s.Stack)
+		endpoints.Grafana = monitoring.GetGrafanaURL(s.Stack)
+	}
+	for i, member := range s.Stack.Members {
+		_, ipfsOwner := s.Stack.IPFSNode(member)
+		m := &MemberEndpoints{
+			ID:          member.ID,
+			External:    member.External,
+			FireflyAPI:  member.FireflyURL() + "/api/v1",
+			AdminAPI:    member.FireflyAdminURL() + "/admin/api/v1",
+			APIUsername: member.APIUsername,
+			APIPassword: member.APIPassword,
+			Ethconnect:  fmt.Sprintf("http://%s:%d", member.Host(), member.ExposedEthconnectPort),
+			IPFSAPI:     fmt.Sprintf("http://%s:%d", ipfsOwner.Host(), ipfsOwner.ExposedIPFSApiPort),
+			IPFSGateway: fmt.Sprintf("http://%s:%d", ipfsOwner.Host(), ipfsOwner.ExposedIPFSGWPort),
+			Sandbox:     sandbox.GetSandboxURL(member),
+		}
+		if !s.Stack.NoUI {
+			m.FireflyUI = member.FireflyURL() + "/ui"
+		}
+		if member.ExposedDataexchangePort != 0 {
+			m.DataExchange = fmt.Sprintf("http://%s:%d", member.Host(), member.ExposedDataexchangePort)
+		}
+		if s.Stack.Domain != "" && !member.External {
+			m.URL = edge.GetMemberURL(s.Stack, member)
+		}
+		if s.Stack.BlockchainNodes == types.BlockchainNodePerMember {
+			m.Blockchain = fmt.Sprintf("http://%s:%d", member.Host(), member.ExposedBlockchainPort)
+		}
+		if member.ExposedNodeRPCPort != 0 {
+			m.NodeRPC = fmt.Sprintf("http://%s:%d", member.Host(), member.ExposedNodeRPCPort)
+			m.NodeWS = fmt.Sprintf("ws://%s:%d", member.Host(), member.ExposedNodeWSPort)
+		}
+		for provider, port := range member.ExposedTokensPorts {
+			if m.Tokens == nil {
+				m.Tokens = make(map[string]string)
+			}
+			m.Tokens[provider] = fmt.Sprintf("http://%s:%d", member.Host(), port)
+		}
+		if member.PostgresURL != "" {
+			m.Postgres = member.GetHostPostgresURL()
+		} else if s.Stack.Database == PostgreSQL.String() {
+			m.Postgres = fmt.Sprintf("postgres://postgres:f1refly@%s:%d?sslmode=disable", member.Host(), member.ExposedPostgresPort)
+		}
+		if member.ExposedPostgresReplicaPort != 0 {
+			m.PostgresReplica = fmt.Sprintf("postgres://postgres:f1refly@%s:%d?sslmode=disable", member.Host(), member.ExposedPostgresReplicaPort)
+		}
+		endpoints.Members[i] = m
+	}
+	return endpoints
+}
+
+func (s *StackManager) PrintEndpoints() {
+	endpoints := s.GetEndpoints()
+	fmt.Printf("Blockchain RPC: %s\n", endpoints.Blockchain)
+	if endpoints.NodeRPC != "" {
+		fmt.Printf("Node JSON-RPC: %s (chain ID %d)\n", endpoints.NodeRPC, ethereum.GetChainID(s.Stack))
+		fmt.Printf("Node WebSocket: %s\n", endpoints.NodeWS)
+	}
+	if endpoints.EventBroker != "" {
+		fmt.Printf("Event broker (%s): %s\n", s.Stack.EventBridge, endpoints.EventBroker)
+	}
+	if endpoints.WebhookRelay != "" {
+		fmt.Printf("Webhook relay: %s (forwards to host port %d)\n", endpoints.WebhookRelay, s.Stack.WebhookRelayTargetPort)
+		fmt.Printf("Webhook relay UI: %s\n", endpoints.WebhookRelayUI)
+	}
+	if endpoints.Grafana != "" {
+		fmt.Printf("Prometheus: %s\n", endpoints.Prometheus)
+		fmt.Printf("Grafana dashboards: %s\n", endpoints.Grafana)
+	}
+	for _, m := range endpoints.Members {
+		fmt.Printf("\nMember '%s':\n", m.ID)
+		if m.URL != "" {
+			fmt.Printf("  URL:           %s\n", m.URL)
+		}
+		fmt.Printf("  FireFly API:   %s\n", m.FireflyAPI)
+		if m.FireflyUI != "" {
+			fmt.Printf("  FireFly UI:    %s\n", m.FireflyUI)
+		}
+		fmt.Printf("  Admin API:     %s\n", m.AdminAPI)
+		if m.Sandbox != "" {
+			fmt.Printf("  Sandbox:       %s\n", m.Sandbox)
+		}
+		if m.APIUsername != "" {
+			fmt.Printf("  API username:  %s\n", m.APIUsername)
+			fmt.Printf("  API password:  %s\n", m.APIPassword)
+		}
+		if m.Blockchain != "" {
+			fmt.Printf("  Blockchain:    %s\n", m.Blockchain)
+		}
+		if m.NodeRPC != "" {
+			fmt.Printf("  Node JSON-RPC: %s (chain ID %d)\n", m.NodeRPC, ethereum.GetChainID(s.Stack))
+			fmt.Printf("  Node WS:       %s\n", m.NodeWS)
+		}
+		fmt.Printf("  Ethconnect:    %s\n", m.Ethconnect)
+		fmt.Printf("  IPFS API:      %s\n", m.IPFSAPI)
+		fmt.Printf("  IPFS Gateway:  %s\n", m.IPFSGateway)
+		if m.DataExchange != "" {
+			fmt.Printf("  Data Exchange: %s\n", m.DataExchange)
+		}
+		for _, provider := range s.Stack.TokensProviders {
+			if url, ok := m.Tokens[provider]; ok {
+				fmt.Printf("  Tokens:        %s (%s)\n", url, provider)
+			}
+		}
+		if m.Postgres != "" {
+			fmt.Printf("  Postgres:      %s\n", m.Postgres)
+		}
+		if m.PostgresReplica != "" {
+			fmt.Printf("  Replica:       %s\n", m.PostgresReplica)
+		}
+	}
+	fmt.Print("\n")
+}
@@ -0,0 +1,150 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// FIPS statuses of the components of a stack
+const (
+	// Restricted components only use FIPS-approved TLS cipher suites, but the crypto module in their image is not validated
+	FIPSRestricted = "restricted"
+	// Non-compliant components cannot be configured to use only FIPS-approved algorithms
+	FIPSNonCompliant = "non-compliant"
+	// User-supplied components run an image overridden at init, so compliance depends on that image
+	FIPSUserSupplied = "user-supplied"
+)
+
+type FIPSStatus struct {
+	Component string `json:"component" yaml:"component"`
+	Image     string `json:"image,omitempty" yaml:"image,omitempty"`
+	Status    string `json:"status" yaml:"status"`
+	Notes     string `json:"notes" yaml:"notes"`
+}
+
+// None of the FireFly components publish FIPS validated images yet, so in FIPS mode the stack still runs the
+// default images, with TLS restricted wherever the component supports it. Validated builds can be run by
+// overriding the image of a component at init.
+var fipsStatuses = map[string]*FIPSStatus{
+	types.FireFlyComponent: {
+		Status: FIPSNonCompliant,
+		Notes:  "Go standard library crypto is not FIPS validated - supply a BoringCrypto build with --core-image",
+	},
+	types.EthconnectComponent: {
+		Status: FIPSNonCompliant,
+		Notes:  "Go standard library crypto is not FIPS validated - supply a BoringCrypto build with --ethconnect-image",
+	},
+	types.GethComponent: {
+		Status: FIPSNonCompliant,
+		Notes:  "Ethereum transactions are signed with secp256k1 and hashed with Keccak-256, which are not FIPS-approved - cannot comply",
+	},
+	types.IPFSComponent: {
+		Status: FIPSNonCompliant,
+		Notes:  "libp2p transport security uses Go standard library crypto, which is not FIPS validated",
+	},
+	types.DataExchangeComponent: {
+		Status: FIPSRestricted,
+		Notes:  "TLS between peers is limited to FIPS-approved AES-GCM cipher suites, but the image's OpenSSL is not FIPS validated",
+	},
+	types.TokensERC1155Component: {
+		Status: FIPSRestricted,
+		Notes:  "TLS is limited to FIPS-approved AES-GCM cipher suites, but the image's OpenSSL is not FIPS validated",
+	},
+	types.TokensERC20ERC721Component: {
+		Status: FIPSRestricted,
+		Notes:  "TLS is limited to FIPS-approved AES-GCM cipher suites, but the image's OpenSSL is not FIPS validated",
+	},
+	types.PostgresComponent: {
+		Status: FIPSNonCompliant,
+		Notes:  "the official image's OpenSSL is not FIPS validated - supply a validated build with --postgres-image",
+	},
+	"event-bridge": {
+		Status: FIPSNonCompliant,
+		Notes:  "the message broker and bridge images do not use FIPS validated crypto",
+	},
+	"webhook-relay": {
+		Status: FIPSNonCompliant,
+		Notes:  "the relay runs on the Go standard library, whose crypto is not FIPS validated",
+	},
+	"monitoring": {
+		Status: FIPSNonCompliant,
+		Notes:  "the Prometheus and Grafana images run on the Go standard library, whose crypto is not FIPS validated",
+	},
+}
+
+// GetFIPSReport describes whether each component of the stack can comply with FIPS
+func (s *StackManager) GetFIPSReport() []*FIPSStatus {
+	components := []string{}
+	for _, member := range s.Stack.Members {
+		if !member.External {
+			components = append(components, types.FireFlyComponent)
+			break
+		}
+	}
+	if s.Stack.BlockchainProvider == GoEthereum.String() {
+		components = append(components, types.GethComponent, types.EthconnectComponent)
+	}
+	components = append(components, types.IPFSComponent, types.DataExchangeComponent)
+	for _, tokensProvider := range s.Stack.TokensProviders {
+		switch tokensProvider {
+		case ERC1155.String():
+			components = append(components, types.TokensERC1155Component)
+		case ERC20ERC721.String():
+			components = append(components, types.TokensERC20ERC721Component)
+		}
+	}
+	if s.Stack.Database == PostgreSQL.String() {
+		for _, member := range s.Stack.Members {
+			if member.PostgresURL == "" {
+				components = append(components, types.PostgresComponent)
+				break
+			}
+		}
+	}
+
+	report := make([]*FIPSStatus, 0, len(components)+2)
+	for _, component := range components {
+		status := &FIPSStatus{
+			Component: component,
+			Image:     s.Stack.GetImage(component),
+			Status:    fipsStatuses[component].Status,
+			Notes:     fipsStatuses[component].Notes,
+		}
+		if _, ok := s.Stack.ImageOverrides[component]; ok {
+			status.Status = FIPSUserSupplied
+			status.Notes = "image overridden at init - compliance depends on the supplied image"
+		}
+		report = append(report, status)
+	}
+	if s.Stack.EventBridge != "" {
+		status := *fipsStatuses["event-bridge"]
+		status.Component = "event-bridge"
+		report = append(report, &status)
+	}
+	if s.Stack.WebhookRelayTargetPort != 0 {
+		status := *fipsStatuses["webhook-relay"]
+		status.Component = "webhook-relay"
+		report = append(report, &status)
+	}
+	if s.Stack.ExposedPrometheusPort != 0 {
+		status := *fipsStatuses["monitoring"]
+		status.Component = "monitoring"
+		report = append(report, &status)
+	}
+	return report
+}
@@ -22,52 +22,66 @@ import (
 	"time"
 
 	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/tracing"
+	"github.com/hyperledger/firefly-cli/pkg/types"
 )
 
-func (s *StackManager) registerFireflyIdentities(verbose bool) error {
-	emptyObject := make(map[string]interface{})
-
+func (s *StackManager) registerFireflyIdentities(verbose bool) (err error) {
+	span := tracing.StartSpan("register")
+	defer func() { span.End(err) }()
 	for _, member := range s.Stack.Members {
-		orgName := fmt.Sprintf("org_%s", member.ID)
-		nodeName := fmt.Sprintf("node_%s", member.ID)
-		ffURL := fmt.Sprintf("http://127.0.0.1:%d/api/v1", member.ExposedFireflyPort)
-		s.Log.Info(fmt.Sprintf("registering %s and %s", orgName, nodeName))
-
-		registerOrgURL := fmt.Sprintf("%s/network/register/node/organization", ffURL)
-		err := core.RequestWithRetry(http.MethodPost, registerOrgURL, emptyObject, nil)
+		memberSpan := span.StartChild(fmt.Sprintf("register member %s", member.ID))
+		err := s.registerFireflyIdentity(member, verbose)
+		memberSpan.End(err)
 		if err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		foundOrg := false
-		retries := 60
-		for !foundOrg {
-			type establishedOrg struct {
-				ID   string `json:"id"`
-				Name string `json:"name"`
-			}
-			orgURL := fmt.Sprintf("%s/network/organizations", ffURL)
-			var orgs []establishedOrg
-			err := core.RequestWithRetry(http.MethodGet, orgURL, nil, &orgs)
-			if err != nil {
-				return nil
-			}
-			for _, o := range orgs {
-				foundOrg = foundOrg || o.Name == orgName
-			}
-			if !foundOrg && retries > 0 {
-				time.Sleep(1 * time.Second)
-				retries--
-			} else if !foundOrg && retries == 0 {
-				return fmt.Errorf("timeout error waiting to register %s and %s", orgName, nodeName)
-			}
-		}
+func (s *StackManager) registerFireflyIdentity(member *types.Member, verbose bool) error {
+	emptyObject := make(map[string]interface{})
 
-		registerNodeURL := fmt.Sprintf("%s/network/register/node", ffURL)
-		err = core.RequestWithRetry(http.MethodPost, registerNodeURL, emptyObject, nil)
+	orgName := fmt.Sprintf("org_%s", member.ID)
+	nodeName := fmt.Sprintf("node_%s", member.ID)
+	ffURL := member.FireflyClientURL() + "/api/v1"
+	s.Log.Info(fmt.Sprintf("registering %s and %s", orgName, nodeName))
+
+	registerOrgURL := fmt.Sprintf("%s/network/register/node/organization", ffURL)
+	err := core.RequestWithRetry(http.MethodPost, registerOrgURL, emptyObject, nil)
+	if err != nil {
+		return err
+	}
+
+	foundOrg := false
+	retries := 60
+	for !foundOrg {
+		type establishedOrg struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		}
+		orgURL := fmt.Sprintf("%s/network/organizations", ffURL)
+		var orgs []establishedOrg
+		err := core.RequestWithRetry(http.MethodGet, orgURL, nil, &orgs)
 		if err != nil {
 			return nil
 		}
+		for _, o := range orgs {
+			foundOrg = foundOrg || o.Name == orgName
+		}
+		if !foundOrg && retries > 0 {
+			time.Sleep(1 * time.Second)
+			retries--
+		} else if !foundOrg && retries == 0 {
+			return fmt.Errorf("timeout error waiting to register %s and %s", orgName, nodeName)
+		}
+	}
+
+	registerNodeURL := fmt.Sprintf("%s/network/register/node", ffURL)
+	err = core.RequestWithRetry(http.MethodPost, registerNodeURL, emptyObject, nil)
+	if err != nil {
+		return nil
 	}
 	return nil
 }
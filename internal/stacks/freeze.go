@@ -0,0 +1,143 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// FrozenStack describes a stack frozen into a bundle, to recreate exactly elsewhere as the reproduction of a bug
+type FrozenStack struct {
+	FrozenAt time.Time `json:"frozenAt" yaml:"frozenAt"`
+	// Image of each component, pinned by digest
+	Images map[string]string `json:"images,omitempty" yaml:"images,omitempty"`
+	// Images that could not be pinned, such as images built locally, which must be available wherever the
+	// stack is thawed
+	Unpinned []string `json:"unpinned,omitempty" yaml:"unpinned,omitempty"`
+	// Files of the recent history of each member under repro/ - empty if the stack was not running
+	History []string `json:"history,omitempty" yaml:"history,omitempty"`
+	Errors  []string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// The components whose images are pinned in a frozen stack
+var pinnedComponents = []string{
+	types.FireFlyComponent,
+	types.EthconnectComponent,
+	types.DataExchangeComponent,
+	types.TokensERC1155Component,
+	types.TokensERC20ERC721Component,
+	types.GethComponent,
+	types.IPFSComponent,
+	types.PostgresComponent,
+	types.EthsignerComponent,
+	types.VaultComponent,
+	types.SandboxComponent,
+}
+
+// The recent history of each member collected from its FireFly core, by file name
+var frozenHistoryEndpoints = map[string]string{
+	"operations": "/operations?sort=created&descending&limit=100",
+	"events":     "/events?sort=sequence&descending&limit=100",
+}
+
+// FreezeStack writes a bundle of the stack to w, which thaw recreates exactly: the stack, its data volumes,
+// the digest of each of its images, and the recent operations and events of each member. A running stack is
+// stopped while its volumes are written, and started again afterwards.
+func (s *StackManager) FreezeStack(w io.Writer, verbose bool) (*FrozenStack, error) {
+	frozen := &FrozenStack{
+		FrozenAt: time.Now().UTC(),
+		Images:   make(map[string]string),
+	}
+	s.pinImages(frozen, verbose)
+
+	reproFiles := make(map[string][]byte)
+	running, err := s.IsRunning(verbose)
+	if err != nil {
+		return nil, err
+	}
+	if running {
+		for _, member := range s.Stack.Members {
+			for name, endpoint := range frozenHistoryEndpoints {
+				result, err := core.GetDiagnostics(core.FireflyURL(member, endpoint))
+				if err != nil {
+					frozen.Errors = append(frozen.Errors, fmt.Sprintf("%s of member %s: %s", name, member.ID, err))
+					continue
+				}
+				var v interface{}
+				if err := json.Unmarshal(result, &v); err != nil {
+					frozen.Errors = append(frozen.Errors, fmt.Sprintf("%s of member %s: %s", name, member.ID, err))
+					continue
+				}
+				d, _ := json.MarshalIndent(v, "", "  ")
+				file := fmt.Sprintf("members/%s/%s.json", member.ID, name)
+				reproFiles[file] = d
+				frozen.History = append(frozen.History, "repro/"+file)
+			}
+		}
+		sort.Strings(frozen.History)
+	} else {
+		s.Log.Info(fmt.Sprintf("stack '%s' is not running, so its recent history is not included", s.Stack.Name))
+	}
+
+	info := &stackArchive{Name: s.Stack.Name, Frozen: frozen}
+	err = s.whileStopped(verbose, func() error {
+		return s.exportStack(w, info, true, reproFiles, verbose)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return frozen, nil
+}
+
+// pinImages records the digest of the image of each component the stack uses
+func (s *StackManager) pinImages(frozen *FrozenStack, verbose bool) {
+	used := make(map[string]bool)
+	for _, image := range s.GetServiceImages() {
+		used[image] = true
+	}
+	for _, component := range pinnedComponents {
+		image := s.Stack.GetImage(component)
+		if !used[image] {
+			continue
+		}
+		digest, err := docker.GetImageDigest(image, verbose)
+		if err != nil || digest == "" {
+			s.Log.Warn(fmt.Sprintf("image %s has no digest to pin it by - it must be available wherever the stack is thawed", image))
+			frozen.Unpinned = append(frozen.Unpinned, image)
+			continue
+		}
+		frozen.Images[component] = digest
+	}
+}
+
+// ThawStack recreates a stack from a bundle written by FreezeStack, named stackName or, if empty, the name of
+// the frozen stack. Its images are pinned to the digests they had when it was frozen.
+func (s *StackManager) ThawStack(r io.Reader, stackName string, verbose bool) (*FrozenStack, error) {
+	info, err := s.importStack(r, stackName, true, verbose)
+	if err != nil {
+		return nil, err
+	}
+	return info.Frozen, nil
+}
@@ -0,0 +1,230 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// minGasLimit is the lowest gas limit geth accepts for a block
+const minGasLimit = 5000
+
+// setChainParams validates the chain parameters and the genesis fragment, and stores them on the stack. The chain
+// ID, block period and gas limit are taken from the fragment if they are only set there, so that the node and the
+// endpoints agree with the genesis file.
+func (s *StackManager) setChainParams(options *InitOptions) error {
+	chainID, period, gasLimit := options.ChainID, options.BlockPeriod, options.GasLimit
+	if options.GenesisFragment != "" {
+		fragment, err := readGenesisFragment(options.GenesisFragment)
+		if err != nil {
+			return err
+		}
+		config, _ := fragment["config"].(map[string]interface{})
+		if value, ok := config["chainId"]; ok {
+			if chainID, err = adoptChainParam("config.chainId", value, chainID, "--chain-id"); err != nil {
+				return err
+			}
+			delete(config, "chainId")
+		}
+		clique, _ := config["clique"].(map[string]interface{})
+		if value, ok := clique["period"]; ok {
+			if period, err = adoptChainParam("config.clique.period", value, period, "--block-period"); err != nil {
+				return err
+			}
+			delete(clique, "period")
+		}
+		if value, ok := fragment["gasLimit"]; ok {
+			limit, err := adoptChainParam("gasLimit", value, int(gasLimit), "--gas-limit")
+			if err != nil {
+				return err
+			}
+			gasLimit = uint64(limit)
+			delete(fragment, "gasLimit")
+		}
+		s.Stack.GenesisOverrides = fragment
+	}
+	if chainID < 0 {
+		return fmt.Errorf("invalid chain ID %d", chainID)
+	}
+	if period < 0 {
+		return fmt.Errorf("invalid block period %d - give a number of seconds, or 0 to seal a block for each transaction", period)
+	}
+	if gasLimit != 0 && gasLimit < minGasLimit {
+		return fmt.Errorf("invalid gas limit %d - it must be at least %d", gasLimit, minGasLimit)
+	}
+	s.Stack.ChainID, s.Stack.BlockPeriod, s.Stack.GasLimit = chainID, period, gasLimit
+	return nil
+}
+
+// readGenesisFragment reads a JSON object of genesis parameters. Fields the stack generates itself to make its
+// members the signers of the chain cannot be overridden.
+func readGenesisFragment(path string) (map[string]interface{}, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fragment map[string]interface{}
+	if err := json.Unmarshal(bytes, &fragment); err != nil {
+		return nil, fmt.Errorf("%s is not a JSON object of genesis parameters: %s", path, err)
+	}
+	if _, ok := fragment["extraData"]; ok {
+		return nil, fmt.Errorf("the genesis fragment cannot set extraData, which holds the clique signers of the stack")
+	}
+	if config, ok := fragment["config"]; ok {
+		if _, isMap := config.(map[string]interface{}); !isMap {
+			return nil, fmt.Errorf("the config of the genesis fragment must be a JSON object")
+		}
+	}
+	return fragment, nil
+}
+
+// adoptChainParam returns the value of a chain parameter set in the genesis fragment, which must be a number -
+// or a hex string, as in genesis files - that agrees with the flag if the flag is set too
+func adoptChainParam(field string, value interface{}, flagValue int, flag string) (int, error) {
+	var parsed int
+	switch v := value.(type) {
+	case float64:
+		parsed = int(v)
+		if float64(parsed) != v {
+			return 0, fmt.Errorf("invalid %s %v in the genesis fragment", field, v)
+		}
+	case string:
+		n, ok := new(big.Int).SetString(v, 0)
+		if !ok || !n.IsInt64() {
+			return 0, fmt.Errorf("invalid %s '%s' in the genesis fragment", field, v)
+		}
+		parsed = int(n.Int64())
+	default:
+		return 0, fmt.Errorf("invalid %s %v in the genesis fragment", field, v)
+	}
+	if flagValue != 0 && flagValue != parsed {
+		return 0, fmt.Errorf("%s is %d in the genesis fragment, but %d with %s", field, parsed, flagValue, flag)
+	}
+	return parsed, nil
+}
+
+// setGenesisAccounts validates the accounts to allocate in the genesis block, and stores them on the stack in
+// the canonical form of the genesis file - lower case hex with a 0x prefix, and storage slots padded to 32 bytes
+func (s *StackManager) setGenesisAccounts(accounts []*types.GenesisAccount) error {
+	taken := make(map[string]string)
+	for _, member := range s.Stack.Members {
+		taken[strings.ToLower(member.Address)] = fmt.Sprintf("member %s", member.ID)
+		if member.SealerAddress != "" {
+			taken[strings.ToLower(member.SealerAddress)] = fmt.Sprintf("the blockchain node of member %s", member.ID)
+		}
+	}
+	for _, account := range s.Stack.Accounts {
+		taken[strings.ToLower(account.Address)] = "an account of the stack"
+	}
+	for _, account := range accounts {
+		address, err := normalizeHex(account.Address, 20)
+		if err != nil {
+			return fmt.Errorf("invalid genesis account address '%s': %s", account.Address, err)
+		}
+		if owner, ok := taken[address]; ok {
+			return fmt.Errorf("genesis account %s is already allocated to %s", address, owner)
+		}
+		taken[address] = "another genesis account"
+
+		allocated := &types.GenesisAccount{Address: address}
+		if account.Balance != "" {
+			if allocated.Balance, err = parseWei(account.Balance); err != nil {
+				return fmt.Errorf("invalid balance '%s' of genesis account %s - give an amount of wei in decimal or 0x hex", account.Balance, address)
+			}
+		}
+		if account.Code != "" {
+			if allocated.Code, err = normalizeHex(account.Code, 0); err != nil {
+				return fmt.Errorf("invalid code of genesis account %s: %s", address, err)
+			}
+		}
+		if len(account.Storage) > 0 {
+			if allocated.Code == "" {
+				return fmt.Errorf("genesis account %s has storage but no contract code", address)
+			}
+			allocated.Storage = make(map[string]string, len(account.Storage))
+			for slot, value := range account.Storage {
+				normalizedSlot, err := normalizeHex(slot, -32)
+				if err != nil {
+					return fmt.Errorf("invalid storage slot '%s' of genesis account %s: %s", slot, address, err)
+				}
+				normalizedValue, err := normalizeHex(value, -32)
+				if err != nil {
+					return fmt.Errorf("invalid value of storage slot '%s' of genesis account %s: %s", slot, address, err)
+				}
+				allocated.Storage[normalizedSlot] = normalizedValue
+			}
+		}
+		s.Stack.GenesisAccounts = append(s.Stack.GenesisAccounts, allocated)
+	}
+	return nil
+}
+
+// createPrefundedAccounts generates accounts funded in the genesis block, in addition to the members' own accounts,
+// with the given balance or the default balance if it is empty
+func (s *StackManager) createPrefundedAccounts(count int, balance string, random io.Reader) error {
+	if balance != "" {
+		wei, err := parseWei(balance)
+		if err != nil {
+			return fmt.Errorf("invalid initial balance '%s' - give an amount of wei in decimal or 0x hex", balance)
+		}
+		balance = wei
+	}
+	for i := 0; i < count; i++ {
+		account := ethereum.GenerateAccountFromSource(random)
+		account.Balance = balance
+		s.Stack.Accounts = append(s.Stack.Accounts, account)
+	}
+	return nil
+}
+
+// parseWei parses an amount of wei in decimal or 0x hex, and returns it in the 0x hex form of the genesis file
+func parseWei(value string) (string, error) {
+	wei, ok := new(big.Int).SetString(value, 0)
+	if !ok || wei.Sign() < 0 {
+		return "", fmt.Errorf("invalid amount of wei '%s'", value)
+	}
+	return "0x" + wei.Text(16), nil
+}
+
+// normalizeHex checks a hex string, with or without a 0x prefix, and returns it in lower case with the prefix.
+// A positive size is the exact number of bytes required, and a negative size the maximum, to which the value
+// is padded with leading zeros.
+func normalizeHex(value string, size int) (string, error) {
+	digits := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(value), "0x"), "0X"))
+	if size < 0 {
+		if len(digits) > -size*2 {
+			return "", fmt.Errorf("must be at most %d bytes", -size)
+		}
+		digits = strings.Repeat("0", -size*2-len(digits)) + digits
+	}
+	if _, err := hex.DecodeString(digits); err != nil || digits == "" {
+		return "", fmt.Errorf("must be hex encoded bytes")
+	}
+	if size > 0 && len(digits) != size*2 {
+		return "", fmt.Errorf("must be %d bytes", size)
+	}
+	return "0x" + digits, nil
+}
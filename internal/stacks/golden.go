@@ -0,0 +1,169 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+)
+
+// GoldenImage is an archive of a stack that has been started, with its chain, databases and registered
+// members, from which new stacks are created that are ready without first time setup
+type GoldenImage struct {
+	Name    string    `json:"name" yaml:"name"`
+	Stack   string    `json:"stack" yaml:"stack"`
+	Created time.Time `json:"created" yaml:"created"`
+	Size    int64     `json:"size" yaml:"size"`
+}
+
+func goldenImageFile(name string) (string, error) {
+	if !snapshotNameRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid golden image name '%s' - use letters, numbers, '.', '_' and '-'", name)
+	}
+	return filepath.Join(constants.GoldenDir, name+snapshotExtension), nil
+}
+
+// CreateGoldenImage archives the stack with its volumes as a golden image. The stack must have been started,
+// so that its contracts are deployed and its members registered. A running stack is stopped while the image
+// is created, and started again afterwards.
+func (s *StackManager) CreateGoldenImage(name string, verbose bool) error {
+	filename, err := goldenImageFile(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("golden image '%s' already exists", name)
+	}
+	if runBefore, err := s.StackHasRunBefore(); err != nil {
+		return err
+	} else if !runBefore {
+		return fmt.Errorf("stack '%s' has never been started - start it first, so that the golden image has its contracts deployed and members registered", s.Stack.Name)
+	}
+	for _, member := range s.Stack.Members {
+		if member.PostgresURL != "" {
+			return fmt.Errorf("stack '%s' uses an external PostgreSQL server, whose data cannot be included in a golden image", s.Stack.Name)
+		}
+	}
+	if err := os.MkdirAll(constants.GoldenDir, 0755); err != nil {
+		return err
+	}
+	return s.whileStopped(verbose, func() error {
+		tmp, err := ioutil.TempFile(constants.GoldenDir, ".golden-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if err := s.ExportStack(tmp, true, verbose); err != nil {
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), filename)
+	})
+}
+
+// InitStackFromGoldenImage creates a stack from a golden image, on the next free block of ports. Its members
+// have the keys and identities of the stack the image was created from, on a chain of its own.
+func (s *StackManager) InitStackFromGoldenImage(stackName string, name string, verbose bool) error {
+	filename, err := goldenImageFile(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("golden image '%s' does not exist", name)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.ImportStack(f, stackName, verbose)
+}
+
+// ListGoldenImages returns the golden images, oldest first
+func ListGoldenImages() ([]*GoldenImage, error) {
+	files, err := ioutil.ReadDir(constants.GoldenDir)
+	if os.IsNotExist(err) {
+		return []*GoldenImage{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	images := make([]*GoldenImage, 0, len(files))
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), snapshotExtension)
+		if f.IsDir() || name == f.Name() || !snapshotNameRegex.MatchString(name) {
+			continue
+		}
+		info, _ := readArchiveInfo(filepath.Join(constants.GoldenDir, f.Name()))
+		image := &GoldenImage{
+			Name:    name,
+			Created: f.ModTime(),
+			Size:    f.Size(),
+		}
+		if info != nil {
+			image.Stack = info.Name
+		}
+		images = append(images, image)
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Created.Before(images[j].Created)
+	})
+	return images, nil
+}
+
+func DeleteGoldenImage(name string) error {
+	filename, err := goldenImageFile(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filename)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("golden image '%s' does not exist", name)
+	}
+	return err
+}
+
+// readArchiveInfo reads the description at the start of a stack archive
+func readArchiveInfo(filename string) (*stackArchive, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	if header, err := tr.Next(); err != nil || header.Name != stackArchiveInfo {
+		return nil, fmt.Errorf("%s is not a stack archive", filename)
+	}
+	var info *stackArchive
+	err = json.NewDecoder(tr).Decode(&info)
+	return info, err
+}
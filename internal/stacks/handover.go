@@ -0,0 +1,131 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/sandbox"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+const handoverTimeout = 2 * time.Minute
+
+// statelessServices returns the services which keep no state of their own, so that a standby container
+// can serve requests while the service's container is replaced. These are the tokens connectors and the
+// sandboxes. Each is returned as it is in the compose file, with everything added to it there.
+func (s *StackManager) statelessServices() []*docker.ServiceDefinition {
+	definitions := make([]*docker.ServiceDefinition, 0)
+	for _, tokensProvider := range s.tokensProviders {
+		definitions = append(definitions, tokensProvider.GetDockerServiceDefinitions()...)
+	}
+	definitions = append(definitions, sandbox.GetDockerServiceDefinitions(s.Stack)...)
+	compose := s.buildDockerCompose()
+	services := make([]*docker.ServiceDefinition, 0, len(definitions))
+	for _, definition := range definitions {
+		if service, ok := compose.Services[definition.ServiceName]; ok {
+			services = append(services, &docker.ServiceDefinition{ServiceName: definition.ServiceName, Service: service})
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].ServiceName < services[j].ServiceName })
+	return services
+}
+
+// handoverService replaces the container of a stateless service without downtime. A standby container is
+// started from the service's current image and given the service's alias, so that other services reach it
+// while the service's own container is recreated, and it is removed once the new container is healthy.
+// It mounts the same volumes and files as the service. Ports published on the host are not covered by the
+// standby container.
+func (s *StackManager) handoverService(serviceDefinition *docker.ServiceDefinition, verbose bool) error {
+	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	name := serviceDefinition.ServiceName
+	service := serviceDefinition.Service
+	standbyName := fmt.Sprintf("%s_%s_standby", s.Stack.Name, name)
+
+	args := []string{
+		"run", "-d", "--name", standbyName,
+		"--network", s.Stack.Name + "_default",
+		"--network-alias", types.ServiceAlias(name),
+	}
+	keys := make([]string, 0, len(service.Environment))
+	for key := range service.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--env", fmt.Sprintf("%s=%s", key, service.Environment[key]))
+	}
+	for _, volume := range service.Volumes {
+		args = append(args, "--volume", s.standbyVolume(workingDir, volume))
+	}
+	if check := service.HealthCheck; check != nil && len(check.Test) > 1 {
+		args = append(args, "--health-cmd", strings.Join(check.Test[1:], " "), "--health-interval", "2s")
+	}
+	if len(service.Entrypoint) > 0 {
+		args = append(args, "--entrypoint", service.Entrypoint[0])
+	}
+	args = append(args, service.Image)
+	if len(service.Entrypoint) > 1 {
+		args = append(args, service.Entrypoint[1:]...)
+	}
+	if service.Command != "" {
+		args = append(args, strings.Fields(service.Command)...)
+	}
+
+	s.Log.Info(fmt.Sprintf("starting standby container for %s", name))
+	if err := docker.RunDockerCommand(workingDir, verbose, verbose, args...); err != nil {
+		return err
+	}
+	defer func() {
+		if err := docker.RunDockerCommand(workingDir, verbose, verbose, "rm", "-f", standbyName); err != nil {
+			s.Log.Error(fmt.Errorf("failed to remove standby container %s: %s", standbyName, err))
+		}
+	}()
+	if err := docker.WaitForHealthy(standbyName, handoverTimeout, verbose); err != nil {
+		return err
+	}
+
+	s.Log.Info(fmt.Sprintf("recreating %s", name))
+	if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, "up", "-d", "--no-deps", name); err != nil {
+		return err
+	}
+	return docker.WaitForHealthy(docker.ContainerName(s.Stack.Name, name), handoverTimeout, verbose)
+}
+
+// standbyVolume turns a volume of a service in the compose file into a volume of a standby container. Files
+// are given relative to the stack directory in the compose file, and named volumes are prefixed with the
+// name of the stack by compose.
+func (s *StackManager) standbyVolume(workingDir string, volume string) string {
+	parts := strings.SplitN(volume, ":", 2)
+	source := parts[0]
+	switch {
+	case strings.HasPrefix(source, "."):
+		source = filepath.Join(workingDir, source)
+	case !filepath.IsAbs(source):
+		source = s.Stack.Name + "_" + source
+	}
+	if len(parts) == 1 {
+		return source
+	}
+	return source + ":" + parts[1]
+}
@@ -0,0 +1,41 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"testing"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+func TestStandbyVolume(t *testing.T) {
+	s := &StackManager{Stack: &types.Stack{Name: "dev"}}
+	tests := []struct {
+		volume   string
+		expected string
+	}{
+		{volume: "./tls/ca.pem:/etc/firefly-sandbox/ca.pem:ro", expected: "/stacks/dev/tls/ca.pem:/etc/firefly-sandbox/ca.pem:ro"},
+		{volume: "tokens_0:/data", expected: "dev_tokens_0:/data"},
+		{volume: "/var/run/docker.sock:/var/run/docker.sock", expected: "/var/run/docker.sock:/var/run/docker.sock"},
+		{volume: "/data", expected: "/data"},
+	}
+	for _, test := range tests {
+		if volume := s.standbyVolume("/stacks/dev", test.volume); volume != test.expected {
+			t.Errorf("%s: got %s, expected %s", test.volume, volume, test.expected)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// Scripts run in the inspection container before the command. Services that need a writable data directory
+// to start, even only to read, get a copy of their read-only volume inside the container, which is thrown away
+// with it.
+const (
+	postgresInspectScript = `cp -a /snapshot/. "$PGDATA" && rm -f "$PGDATA/postmaster.pid" && chmod 700 "$PGDATA" && ` +
+		`gosu postgres pg_ctl -D "$PGDATA" -o "-c listen_addresses=''" -w start >/dev/null && exec gosu postgres "$@"`
+	sqliteInspectScript = `apk add --no-cache sqlite >/dev/null && exec "$@"`
+	ipfsInspectScript   = `mkdir -p "$IPFS_PATH" && cp -a /snapshot/. "$IPFS_PATH" && exec "$@"`
+)
+
+// sqliteInspectURI opens the SQLite database without locking or writing to it, as its volume is read-only
+const sqliteInspectURI = "file:/data/db?immutable=1"
+
+// InspectData opens a shell, or runs a query or command, in a throwaway container with the volume holding the
+// component's data for the member mounted read-only. The stack must be stopped, so that the data is not
+// changing underneath.
+func (s *StackManager) InspectData(memberID string, component DataComponent, query string, command []string, verbose bool) error {
+	if s.Stack.AdoptedFrom != "" {
+		return fmt.Errorf("stack '%s' was adopted from a hand-written deployment, whose volumes are not known", s.Stack.Name)
+	}
+	member := s.Stack.Members[0]
+	if memberID != "" {
+		var err error
+		if member, err = s.getMember(memberID); err != nil {
+			return err
+		}
+	}
+	if query != "" && component != DatabaseData {
+		return fmt.Errorf("a query can only be run against the database - give a command to run instead")
+	}
+	if query != "" && len(command) > 0 {
+		return fmt.Errorf("give either a query or a command to run, not both")
+	}
+	if hasRunBefore, err := s.StackHasRunBefore(); err != nil {
+		return err
+	} else if !hasRunBefore {
+		return fmt.Errorf("stack '%s' has not been started yet, so it has no data to inspect", s.Stack.Name)
+	}
+	if running, err := s.IsRunning(verbose); err != nil {
+		return err
+	} else if running {
+		return fmt.Errorf("stack '%s' is running - stop it first, so that its data does not change while it is inspected", s.Stack.Name)
+	}
+
+	args, err := s.inspectDataArgs(member, component, query, command)
+	if err != nil {
+		return err
+	}
+	return docker.RunDockerCommandInteractive(verbose, args...)
+}
+
+// inspectDataArgs returns the docker run arguments of the inspection container
+func (s *StackManager) inspectDataArgs(member *types.Member, component DataComponent, query string, command []string) ([]string, error) {
+	args := []string{"run", "--rm", "-i"}
+	if len(command) == 0 && query == "" {
+		args = append(args, "-t")
+	}
+	volume := func(name string, target string) []string {
+		return []string{"-v", fmt.Sprintf("%s_%s:%s:ro", s.Stack.Name, name, target)}
+	}
+
+	switch component {
+	case DatabaseData:
+		if member.PostgresURL != "" {
+			return nil, fmt.Errorf("the database of member %s is on an external PostgreSQL server - connect to it directly at %s", member.ID, member.PostgresURL)
+		}
+		if s.Stack.Database == PostgreSQL.String() {
+			args = append(args, volume("postgres_"+member.ID, "/snapshot")...)
+			args = append(args, "-e", "PGDATA=/tmp/pgdata", "--entrypoint", "sh", s.Stack.GetImage(types.PostgresComponent), "-c", postgresInspectScript, "sh")
+			if query != "" {
+				return append(args, "psql", "-U", "postgres", "-c", query), nil
+			}
+			if len(command) == 0 {
+				command = []string{"psql", "-U", "postgres"}
+			}
+			return append(args, command...), nil
+		}
+		if member.External {
+			return nil, fmt.Errorf("the database of member %s is in a file on this host - open %s directly", member.ID, filepath.Join(constants.StacksDir, s.Stack.Name, "data", member.ID+".db"))
+		}
+		args = append(args, volume("firefly_core_"+member.ID, "/data")...)
+		args = append(args, docker.UtilityImage, "sh", "-c", sqliteInspectScript, "sh")
+		if query != "" {
+			return append(args, "sqlite3", sqliteInspectURI, query), nil
+		}
+		if len(command) == 0 {
+			command = []string{"sqlite3", sqliteInspectURI}
+		}
+		return append(args, command...), nil
+
+	case IPFSData:
+		ipfsName, _ := s.Stack.IPFSNode(member)
+		args = append(args, volume("ipfs_data"+strings.TrimPrefix(ipfsName, "ipfs"), "/snapshot")...)
+		args = append(args, "-e", "IPFS_PATH=/tmp/ipfs", "--entrypoint", "sh", s.Stack.GetImage(types.IPFSComponent), "-c", ipfsInspectScript, "sh")
+
+	case DataExchangeData:
+		args = append(args, volume("dataexchange_"+member.ID, "/data")...)
+		args = append(args, "-w", "/data", docker.UtilityImage)
+	}
+	if len(command) == 0 {
+		command = []string{"sh"}
+	}
+	return append(args, command...), nil
+}
@@ -0,0 +1,86 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+var storageSizeRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?\s*([kKMGT]i?B|B)?$`)
+
+func validateIPFSConfig(config *types.IPFSConfig) error {
+	if config.StorageMax != "" && !storageSizeRegexp.MatchString(config.StorageMax) {
+		return fmt.Errorf("\"%s\" is not a valid IPFS storage size, such as 500MB or 2GB", config.StorageMax)
+	}
+	if config.LowWater < 0 || config.HighWater < 0 {
+		return fmt.Errorf("the IPFS connection limits cannot be negative")
+	}
+	if config.LowWater != 0 && config.HighWater != 0 && config.LowWater > config.HighWater {
+		return fmt.Errorf("the IPFS connection low water mark (%d) cannot be above the high water mark (%d)", config.LowWater, config.HighWater)
+	}
+	return nil
+}
+
+// ipfsInitScript applies the stack's IPFS settings to the config of a node, each time the node starts
+func ipfsInitScript(config *types.IPFSConfig) string {
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n# Applies the IPFS settings of the FireFly stack\nset -e\n")
+	if config.StorageMax != "" {
+		fmt.Fprintf(&script, "ipfs config Datastore.StorageMax '%s'\n", strings.ReplaceAll(config.StorageMax, " ", ""))
+	}
+	if config.LowWater != 0 {
+		fmt.Fprintf(&script, "ipfs config --json Swarm.ConnMgr.LowWater %d\n", config.LowWater)
+	}
+	if config.HighWater != 0 {
+		fmt.Fprintf(&script, "ipfs config --json Swarm.ConnMgr.HighWater %d\n", config.HighWater)
+	}
+	if config.DisableMDNS {
+		script.WriteString("ipfs config --json Discovery.MDNS.Enabled false\n")
+	}
+	return script.String()
+}
+
+// copyIPFSConfigToVolumes writes the script applying the stack's IPFS settings to the stack directory, and
+// copies it into the init volume of each IPFS node
+func (s *StackManager) copyIPFSConfigToVolumes(verbose bool) error {
+	if s.Stack.IPFS == nil {
+		return nil
+	}
+	scriptPath := filepath.Join(constants.StacksDir, s.Stack.Name, "configs", "ipfs_init.sh")
+	if err := ioutil.WriteFile(scriptPath, []byte(ipfsInitScript(s.Stack.IPFS)), 0755); err != nil {
+		return err
+	}
+	for _, member := range s.Stack.Members {
+		ipfsName, owner := s.Stack.IPFSNode(member)
+		if owner != member {
+			continue
+		}
+		volumeName := fmt.Sprintf("%s_ipfs_init%s", s.Stack.Name, strings.TrimPrefix(ipfsName, "ipfs"))
+		if err := docker.CopyFileToVolume(volumeName, scriptPath, "/001-firefly.sh", verbose); err != nil {
+			return err
+		}
+	}
+	return nil
+}
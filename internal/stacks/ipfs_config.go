@@ -17,17 +17,17 @@
 package stacks
 
 import (
-	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"io"
 
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
 )
 
-func GenerateSwarmKey() string {
+func GenerateSwarmKey(random io.Reader) string {
 	key := make([]byte, 32)
-	rand.Read(key)
+	io.ReadFull(random, key)
 	hexKey := hex.EncodeToString(key)
 	return "/key/swarm/psk/1.0.0/\n/base16/\n" + hexKey
 }
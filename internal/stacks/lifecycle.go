@@ -0,0 +1,145 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// The lifecycle states of a stack, which are kept in its state so that every command agrees on them
+const (
+	StateCreated  = "created"
+	StateStarting = "starting"
+	StateRunning  = "running"
+	StateStopped  = "stopped"
+	StateError    = "error"
+)
+
+// operationStates lists the states each operation on a stack can be run in. A stack can be stopped in any
+// state, which is how a stack left starting by an interrupted command is recovered.
+var operationStates = map[string][]string{
+	"start":   {StateCreated, StateStopped, StateError},
+	"reset":   {StateCreated, StateRunning, StateStopped, StateError},
+	"remove":  {StateCreated, StateRunning, StateStopped, StateError},
+	"upgrade": {StateCreated, StateRunning, StateStopped, StateError},
+	"change":  {StateCreated, StateRunning, StateStopped, StateError},
+}
+
+type StackState struct {
+	Name  string `json:"name" yaml:"name"`
+	State string `json:"state,omitempty" yaml:"state,omitempty"`
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// ListStackStates returns the recorded state of every stack, without querying docker. Stacks created before
+// states were recorded have no state until a command changes it.
+func ListStackStates() ([]*StackState, error) {
+	names, err := ListStacks()
+	if err != nil {
+		return nil, err
+	}
+	states := make([]*StackState, len(names))
+	for i, name := range names {
+		states[i] = &StackState{Name: name}
+		d, err := stateStore.Read(name)
+		if err != nil {
+			continue
+		}
+		var encrypted *encryptedFile
+		if json.Unmarshal(d, &encrypted) == nil && encrypted != nil && encrypted.Encrypted != nil {
+			states[i].State = encrypted.State
+			continue
+		}
+		var stack *types.Stack
+		if json.Unmarshal(d, &stack) == nil && stack != nil {
+			states[i].State = stack.State
+			states[i].Error = stack.StateError
+		}
+	}
+	return states, nil
+}
+
+// State returns the state of the stack, correcting the recorded state if docker shows it is out of date -
+// the containers of a running stack may have been stopped outside the CLI, or by docker restarting. The
+// state of a stack created before states were recorded is worked out the same way.
+func (s *StackManager) State(verbose bool) (string, error) {
+	state := s.Stack.State
+	if state == StateStarting || state == StateError {
+		return state, nil
+	}
+	running, err := s.IsRunning(verbose)
+	if err != nil {
+		if state != "" {
+			return state, nil
+		}
+		return "", err
+	}
+	switch {
+	case running:
+		state = StateRunning
+	case state == StateRunning:
+		state = StateStopped
+	case state == "":
+		if runBefore, err := s.StackHasRunBefore(); err != nil {
+			return "", err
+		} else if runBefore {
+			state = StateStopped
+		} else {
+			state = StateCreated
+		}
+	}
+	if state != s.Stack.State {
+		if err := s.setState(state, nil); err != nil {
+			return "", err
+		}
+	}
+	return state, nil
+}
+
+// checkOperation returns an error if the operation cannot be run on the stack in its current state
+func (s *StackManager) checkOperation(operation string, verbose bool) error {
+	state, err := s.State(verbose)
+	if err != nil {
+		return err
+	}
+	for _, allowed := range operationStates[operation] {
+		if state == allowed {
+			return nil
+		}
+	}
+	switch state {
+	case StateStarting:
+		return fmt.Errorf("stack '%s' is starting - wait for it to start, or stop it if it is no longer starting, before trying to %s it", s.Stack.Name, operation)
+	case StateRunning:
+		return fmt.Errorf("stack '%s' is already running", s.Stack.Name)
+	default:
+		return fmt.Errorf("cannot %s stack '%s' while it is %s", operation, s.Stack.Name, state)
+	}
+}
+
+// setState records the stack's new state, and the error that caused it if it is the error state
+func (s *StackManager) setState(state string, cause error) error {
+	s.Stack.State = state
+	s.Stack.StateError = ""
+	if cause != nil {
+		s.Stack.StateError = cause.Error()
+	}
+	return s.writeStackConfig()
+}
@@ -0,0 +1,180 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+)
+
+var LogLevelStrings = []string{"trace", "debug", "info", "warn", "error"}
+
+// logLevelEnvVars are the environment variables that set the log level of the services configured by their
+// environment, by the prefix of their service name. FireFly core is configured by its config file instead.
+var logLevelEnvVars = map[string]string{
+	"dataexchange": "LOG_LEVEL",
+	"ipfs":         "GOLOG_LOG_LEVEL",
+}
+
+// getLogLevelEnv returns the environment variable that sets the log level of a service, and the value to set it
+// to for the level, or an empty string if the level is not set or the service has no such variable
+func getLogLevelEnv(serviceName, level string) (string, string) {
+	if level == "" {
+		return "", ""
+	}
+	for prefix, envVar := range logLevelEnvVars {
+		if serviceName == prefix || strings.HasPrefix(serviceName, prefix+"_") {
+			// IPFS has no trace level
+			if prefix == "ipfs" && level == "trace" {
+				level = "debug"
+			}
+			return envVar, level
+		}
+	}
+	return "", ""
+}
+
+// getLogLevelServices returns the services of the stack whose log level can be set, sorted by name
+func (s *StackManager) getLogLevelServices() []string {
+	services := make([]string, 0)
+	for name := range s.buildDockerCompose().Services {
+		if strings.HasPrefix(name, "firefly_core_") {
+			services = append(services, name)
+		} else if envVar, _ := getLogLevelEnv(name, "debug"); envVar != "" {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+// resolveLogLevelTarget returns the service a target refers to: either a service name, or a member ID for
+// the FireFly core of that member
+func (s *StackManager) resolveLogLevelTarget(target string) (string, error) {
+	if member, err := s.getMember(target); err == nil {
+		if member.External {
+			return "", fmt.Errorf("FireFly core of member %s runs outside docker - set its log level in %s", member.ID, filepath.Join(constants.StacksDir, s.Stack.Name, "configs", fmt.Sprintf("firefly_core_%s.yml", member.ID)))
+		}
+		target = "firefly_core_" + member.ID
+	}
+	services := s.getLogLevelServices()
+	for _, service := range services {
+		if service == target {
+			return service, nil
+		}
+	}
+	return "", fmt.Errorf("cannot set the log level of '%s' - use a member ID or one of: %s", target, strings.Join(services, ", "))
+}
+
+// SetLogLevel sets the log level of a service, or of the FireFly core of a member. If the service is running,
+// it is restarted with the new level.
+func (s *StackManager) SetLogLevel(target string, level string, verbose bool) (string, error) {
+	if err := s.checkGenerated(); err != nil {
+		return "", err
+	}
+	level = strings.ToLower(level)
+	if !containsString(LogLevelStrings, level) {
+		return "", fmt.Errorf("\"%s\" is not a valid log level. Options are: %v", level, LogLevelStrings)
+	}
+	service, err := s.resolveLogLevelTarget(target)
+	if err != nil {
+		return "", err
+	}
+	if s.Stack.LogLevels == nil {
+		s.Stack.LogLevels = make(map[string]string)
+	}
+	s.Stack.LogLevels[service] = level
+	return service, s.applyLogLevels([]string{service}, verbose)
+}
+
+// ResetLogLevel returns a service, or the FireFly core of a member, to its default log level. With no target,
+// every service with a log level set is reset.
+func (s *StackManager) ResetLogLevel(target string, verbose bool) ([]string, error) {
+	if err := s.checkGenerated(); err != nil {
+		return nil, err
+	}
+	services := make([]string, 0, len(s.Stack.LogLevels))
+	if target == "" {
+		for service := range s.Stack.LogLevels {
+			services = append(services, service)
+		}
+		sort.Strings(services)
+	} else {
+		service, err := s.resolveLogLevelTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := s.Stack.LogLevels[service]; ok {
+			services = append(services, service)
+		}
+	}
+	for _, service := range services {
+		delete(s.Stack.LogLevels, service)
+	}
+	if len(services) == 0 {
+		return services, nil
+	}
+	return services, s.applyLogLevels(services, verbose)
+}
+
+// applyLogLevels regenerates the stack files, and restarts any of the services that are running so they
+// pick up their new log level
+func (s *StackManager) applyLogLevels(services []string, verbose bool) error {
+	if err := s.writeStackFiles(verbose); err != nil {
+		return err
+	}
+	runBefore, err := s.StackHasRunBefore()
+	if err != nil || !runBefore {
+		return err
+	}
+	running, err := s.IsRunning(verbose)
+	if err != nil {
+		return err
+	}
+	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	for _, service := range services {
+		if !strings.HasPrefix(service, "firefly_core_") {
+			// Any service whose environment has changed is recreated
+			if running {
+				s.Log.Info(fmt.Sprintf("recreating %s", service))
+				if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, "up", "-d", "--no-deps", service); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		member, err := s.getMember(strings.TrimPrefix(service, "firefly_core_"))
+		if err != nil {
+			return err
+		}
+		if err := s.copyFireflyConfigToVolume(member, verbose); err != nil {
+			return err
+		}
+		if running {
+			s.Log.Info(fmt.Sprintf("restarting %s", service))
+			if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, "restart", service); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
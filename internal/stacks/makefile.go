@@ -0,0 +1,58 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+)
+
+// Each target wraps the equivalent CLI command for this stack. FF can be
+// overridden on the make command line to point at a different binary.
+const makefileTemplate = `# Generated by the FireFly CLI for stack '%[1]s'
+
+FF ?= ff
+STACK := %[1]s
+
+.PHONY: start stop logs logs-follow reset remove info upgrade
+
+start:
+	$(FF) start $(STACK)
+stop:
+	$(FF) stop $(STACK)
+logs:
+	$(FF) logs $(STACK)
+logs-follow:
+	$(FF) logs -f $(STACK)
+reset:
+	$(FF) reset -f $(STACK)
+remove:
+	$(FF) remove -f $(STACK)
+info:
+	$(FF) info $(STACK)
+upgrade:
+	$(FF) upgrade $(STACK)
+`
+
+func (s *StackManager) writeMakefile() error {
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	makefile := fmt.Sprintf(makefileTemplate, s.Stack.Name)
+	return ioutil.WriteFile(filepath.Join(stackDir, "Makefile"), []byte(makefile), 0755)
+}
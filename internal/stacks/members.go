@@ -0,0 +1,252 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// AddMember adds a new member to the stack, allocating the next block of ports. If the stack
+// has been run before, the new member's services are started and its org and node are
+// registered with the existing network. The new member's database can be an external
+// PostgreSQL server, which is required if the other members each have their own.
+func (s *StackManager) AddMember(postgresURL string, verbose bool) (*types.Member, error) {
+	if err := s.checkGenerated(); err != nil {
+		return nil, err
+	}
+	if s.Stack.BlockchainNodes == types.BlockchainNodePerMember {
+		return nil, fmt.Errorf("members cannot be added to a stack with a blockchain node per member, as the set of signers is fixed in the genesis block")
+	}
+	if s.Stack.Signer != "" {
+		return nil, fmt.Errorf("members cannot be added to a stack with an external signer, as the signer of each member is set up when the stack is first started")
+	}
+	nextIndex := 0
+	for _, member := range s.Stack.Members {
+		if *member.Index >= nextIndex {
+			nextIndex = *member.Index + 1
+		}
+	}
+	firstMember := s.Stack.Members[0]
+	options := &InitOptions{
+		FireFlyBasePort:  firstMember.ExposedFireflyPort - *firstMember.Index,
+		ServicesBasePort: s.Stack.ExposedBlockchainPort,
+		PostgresReplicas: s.Stack.PostgresReplicas,
+		NoUI:             s.Stack.NoUI,
+		Gateway:          s.Stack.Gateway,
+		Sandbox:          s.Stack.Sandbox && !s.Stack.SharedSandbox,
+	}
+	for _, name := range s.Stack.TokensProviders {
+		tokensProvider, err := TokensProviderFromString(name)
+		if err != nil {
+			return nil, err
+		}
+		options.TokensProviders = append(options.TokensProviders, tokensProvider)
+	}
+	member := createMember(fmt.Sprint(nextIndex), nextIndex, options, false, rand.Reader)
+	member.Hostname = s.Stack.Hostname
+	member.TLS = s.Stack.TLS
+	if s.Stack.APIAuth != "" {
+		if err := generateAPICredentials(member, rand.Reader); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.setNewMemberPostgres(member, postgresURL); err != nil {
+		return nil, err
+	}
+	if member.PostgresURL != "" {
+		member.ExposedPostgresReplicaPort = 0
+	}
+
+	if err := checkPortsListAvailable(s.Stack.Host(), getMemberPorts(member)); err != nil {
+		return nil, err
+	}
+	s.Stack.Members = append(s.Stack.Members, member)
+	if err := reserveStackPorts(s.Stack); err != nil {
+		s.Stack.Members = s.Stack.Members[:len(s.Stack.Members)-1]
+		return nil, err
+	}
+
+	runBefore, err := s.StackHasRunBefore()
+	if err != nil {
+		return nil, err
+	}
+	if runBefore {
+		s.Log.Info(fmt.Sprintf("adding signing account for member %s", member.ID))
+		if err := s.blockchainProvider.AddMember(member); err != nil {
+			s.Stack.Members = s.Stack.Members[:len(s.Stack.Members)-1]
+			if releaseErr := reserveStackPorts(s.Stack); releaseErr != nil {
+				s.Log.Error(releaseErr)
+			}
+			return nil, fmt.Errorf("failed to add member - please make sure the stack is running: %s", err)
+		}
+	}
+
+	if err := s.writeStackFiles(verbose); err != nil {
+		return nil, err
+	}
+	if !runBefore {
+		return member, nil
+	}
+
+	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	if err := s.createPostgresSchema(member, verbose); err != nil {
+		return nil, err
+	}
+	if !s.Stack.Gateway {
+		s.Log.Info(fmt.Sprintf("writing data exchange certs for member %s", member.ID))
+		if err := s.writeDataExchangeCert(member, verbose); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.copyFireflyConfigToVolume(member, verbose); err != nil {
+		return nil, err
+	}
+
+	s.Log.Info(fmt.Sprintf("starting services for member %s", member.ID))
+	if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, "up", "-d"); err != nil {
+		return nil, err
+	}
+	if err := s.blockchainProvider.PostStart(); err != nil {
+		return nil, err
+	}
+
+	// Contracts already deployed to the network are only registered with the new member
+	if err := s.blockchainProvider.DeploySmartContracts(); err != nil {
+		return nil, err
+	}
+	if err := s.deployTokensContracts(); err != nil {
+		return nil, err
+	}
+
+	if err := s.patchConfigAndRestartFireflyNode(member); err != nil {
+		return nil, err
+	}
+	if !s.Stack.Gateway {
+		if err := s.registerFireflyIdentity(member, verbose); err != nil {
+			return nil, err
+		}
+	}
+	for _, tokensProvider := range s.tokensProviders {
+		if err := tokensProvider.AddMember(member); err != nil {
+			return nil, err
+		}
+	}
+	return member, nil
+}
+
+func (s *StackManager) setNewMemberPostgres(member *types.Member, postgresURL string) error {
+	if postgresURL != "" {
+		if s.Stack.Database != PostgreSQL.String() {
+			return fmt.Errorf("an external PostgreSQL server can only be used by members of a stack using the postgres database")
+		}
+		if err := ValidatePostgresURL(postgresURL); err != nil {
+			return err
+		}
+		member.PostgresURL = postgresURL
+		return nil
+	}
+	first := s.Stack.Members[0]
+	switch {
+	case first.PostgresSchema != "":
+		member.PostgresURL = first.PostgresURL
+		member.PostgresSchema = getPostgresSchemaName(s.Stack.Name, member.ID)
+	case first.PostgresURL != "":
+		return fmt.Errorf("the members of stack '%s' each use their own external PostgreSQL server - give the new member's with --postgres-url", s.Stack.Name)
+	}
+	return nil
+}
+
+// RemoveMember removes a member from the stack, along with its containers, volumes and local data
+func (s *StackManager) RemoveMember(memberID string, verbose bool) error {
+	if err := s.checkGenerated(); err != nil {
+		return err
+	}
+	var member *types.Member
+	remaining := make([]*types.Member, 0, len(s.Stack.Members))
+	remainingCores := 0
+	for _, m := range s.Stack.Members {
+		if m.ID == memberID {
+			member = m
+			continue
+		}
+		remaining = append(remaining, m)
+		if !m.External {
+			remainingCores++
+		}
+	}
+	if member == nil {
+		return fmt.Errorf("member '%s' does not exist in stack '%s'", memberID, s.Stack.Name)
+	}
+	if remainingCores == 0 {
+		return fmt.Errorf("cannot remove member '%s' - a stack must have at least one member running FireFly core in docker", memberID)
+	}
+
+	runBefore, err := s.StackHasRunBefore()
+	if err != nil {
+		return err
+	}
+
+	oldCompose := s.buildDockerCompose()
+	s.Stack.Members = remaining
+	newCompose := s.buildDockerCompose()
+
+	if runBefore {
+		workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+		removedServices := make([]string, 0)
+		for name := range oldCompose.Services {
+			if _, ok := newCompose.Services[name]; !ok {
+				removedServices = append(removedServices, name)
+			}
+		}
+		sort.Strings(removedServices)
+		s.Log.Info(fmt.Sprintf("removing services for member %s", member.ID))
+		command := append([]string{"rm", "-f", "-s", "-v"}, removedServices...)
+		if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, command...); err != nil {
+			return err
+		}
+		for name := range oldCompose.Volumes {
+			if _, ok := newCompose.Volumes[name]; !ok {
+				if err := docker.RemoveVolume(fmt.Sprintf("%s_%s", s.Stack.Name, name), verbose); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	for _, p := range []string{
+		filepath.Join(stackDir, "data", "dataexchange_"+member.ID),
+		filepath.Join(stackDir, "blockchain", member.ID),
+		filepath.Join(stackDir, "configs", fmt.Sprintf("firefly_core_%s.yml", member.ID)),
+	} {
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+	if err := s.writeStackFiles(verbose); err != nil {
+		return err
+	}
+	return reserveStackPorts(s.Stack)
+}
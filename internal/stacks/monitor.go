@@ -0,0 +1,167 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/docker"
+)
+
+// A container which dies this many times within crashLoopWindow is reported as crash-looping
+const (
+	crashLoopThreshold = 3
+	crashLoopWindow    = 2 * time.Minute
+)
+
+type ContainerProblem struct {
+	Container  string `json:"container" yaml:"container"`
+	Service    string `json:"service" yaml:"service"`
+	Problem    string `json:"problem" yaml:"problem"`
+	Suggestion string `json:"suggestion,omitempty" yaml:"suggestion,omitempty"`
+}
+
+func (p *ContainerProblem) String() string {
+	if p.Suggestion == "" {
+		return fmt.Sprintf("%s (%s) %s", p.Service, p.Container, p.Problem)
+	}
+	return fmt.Sprintf("%s (%s) %s - %s", p.Service, p.Container, p.Problem, p.Suggestion)
+}
+
+// GetContainerProblems inspects every container in the stack, and returns those which have been
+// OOM-killed, have exited with an error, or are restarting
+func (s *StackManager) GetContainerProblems(verbose bool) ([]*ContainerProblem, error) {
+	containers, err := docker.ListProjectContainers(s.Stack.Name, verbose)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(containers))
+	for i, c := range containers {
+		ids[i] = c.ID
+	}
+	states, err := docker.InspectContainers(ids, verbose)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := make([]*ContainerProblem, 0)
+	for _, state := range states {
+		switch {
+		case state.OOMKilled:
+			problems = append(problems, &ContainerProblem{
+				Container:  state.Name,
+				Service:    state.Service,
+				Problem:    "was killed because it ran out of memory",
+				Suggestion: memorySuggestion(state.MemoryLimit),
+			})
+		case isCrashLooping(state):
+			problems = append(problems, &ContainerProblem{
+				Container: state.Name,
+				Service:   state.Service,
+				Problem:   fmt.Sprintf("is crash-looping (restarted %d times)", state.RestartCount),
+			})
+		case state.Status == "exited" && state.ExitCode != 0:
+			problems = append(problems, &ContainerProblem{
+				Container:  state.Name,
+				Service:    state.Service,
+				Problem:    fmt.Sprintf("exited with code %d", state.ExitCode),
+				Suggestion: fmt.Sprintf("run 'ff logs %s' to see why", s.Stack.Name),
+			})
+		}
+	}
+	return problems, nil
+}
+
+// isCrashLooping reports whether a container is waiting to be restarted, or has restarted crashLoopThreshold times
+// and was restarted again within crashLoopWindow. A container which restarted while the stack was starting up,
+// but has been running since, is not.
+func isCrashLooping(state *docker.ContainerState) bool {
+	if state.Status == "restarting" {
+		return true
+	}
+	return state.Status == "running" && state.RestartCount >= crashLoopThreshold && time.Since(state.StartedAt) < crashLoopWindow
+}
+
+// MonitorStack watches the containers in the stack until the stop channel is closed, and reports
+// each time a container is OOM-killed or starts crash-looping
+func (s *StackManager) MonitorStack(verbose bool, stop <-chan struct{}, report func(p *ContainerProblem)) error {
+	deaths := make(map[string][]time.Time)
+	return docker.WatchProjectEvents(s.Stack.Name, verbose, stop, func(e *docker.ContainerEvent) {
+		switch e.Status {
+		case "oom":
+			limit := int64(0)
+			if states, err := docker.InspectContainers([]string{e.ID}, verbose); err == nil && len(states) == 1 {
+				limit = states[0].MemoryLimit
+			}
+			report(&ContainerProblem{
+				Container:  e.ContainerName(),
+				Service:    e.Service(),
+				Problem:    "was killed because it ran out of memory",
+				Suggestion: memorySuggestion(limit),
+			})
+		case "die":
+			now := time.Unix(e.Time, 0)
+			recent := []time.Time{now}
+			for _, t := range deaths[e.ID] {
+				if now.Sub(t) < crashLoopWindow {
+					recent = append(recent, t)
+				}
+			}
+			deaths[e.ID] = recent
+			if len(recent) == crashLoopThreshold {
+				report(&ContainerProblem{
+					Container: e.ContainerName(),
+					Service:   e.Service(),
+					Problem:   fmt.Sprintf("is crash-looping (stopped %d times in %s)", len(recent), crashLoopWindow),
+				})
+			}
+		}
+	})
+}
+
+func memorySuggestion(limit int64) string {
+	if limit == 0 {
+		return "the container has no memory limit, so increase the memory available to Docker (for Docker Desktop, in Settings > Resources)"
+	}
+	return fmt.Sprintf("increase its memory limit from %s, for example set 'mem_limit: %s' on the service in docker-compose.yml", formatBytes(limit), formatBytes(limit*2))
+}
+
+func formatBytes(b int64) string {
+	units := []string{"b", "k", "m", "g"}
+	i := 0
+	for b >= 1024 && b%1024 == 0 && i < len(units)-1 {
+		b /= 1024
+		i++
+	}
+	return fmt.Sprintf("%d%s", b, units[i])
+}
+
+// withContainerProblems adds any containers which have been OOM-killed or have crashed to an error,
+// as these are often the underlying cause of a failure to start
+func (s *StackManager) withContainerProblems(err error, verbose bool) error {
+	problems, inspectErr := s.GetContainerProblems(verbose)
+	if inspectErr != nil || len(problems) == 0 {
+		return err
+	}
+	msg := err.Error()
+	for _, p := range problems {
+		msg += "\n  " + p.String()
+	}
+	return errors.New(msg)
+}
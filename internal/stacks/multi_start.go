@@ -0,0 +1,183 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/internal/sandbox"
+)
+
+const (
+	MultiStartReady     = "ready"
+	MultiStartUnhealthy = "unhealthy"
+	MultiStartFailed    = "failed"
+	MultiStartSkipped   = "skipped"
+)
+
+// MultiStartResult is the outcome of starting one of several stacks together
+type MultiStartResult struct {
+	Stack      string   `json:"stack" yaml:"stack"`
+	Status     string   `json:"status" yaml:"status"`
+	DurationMS int64    `json:"durationMs" yaml:"durationMs"`
+	Error      string   `json:"error,omitempty" yaml:"error,omitempty"`
+	Problems   []string `json:"problems,omitempty" yaml:"problems,omitempty"`
+	// Web UI of each member of a stack which has started, by member ID
+	UI map[string]string `json:"ui,omitempty" yaml:"ui,omitempty"`
+	// FireFly Sandbox of each member of a stack which has started, by member ID, if the stack has sandboxes
+	Sandbox map[string]string `json:"sandbox,omitempty" yaml:"sandbox,omitempty"`
+
+	stackManager *StackManager
+}
+
+// StartStacks starts several stacks at once. A stack listed in after is only started once all of the stacks
+// it waits for are ready - for example, stacks which join the chain of another stack wait for that stack - and
+// is skipped if any of them fail. The progress of each stack is logged with its name as a prefix. Returns the
+// result of each stack, in the order given, or an error if a stack cannot be loaded or the order is invalid.
+func StartStacks(logger log.Logger, stackNames []string, after map[string][]string, verbose bool, options *StartOptions) ([]*MultiStartResult, error) {
+	results := make(map[string]*MultiStartResult, len(stackNames))
+	for _, stackName := range stackNames {
+		if _, ok := results[stackName]; ok {
+			return nil, fmt.Errorf("stack '%s' is given more than once", stackName)
+		}
+		stackManager := NewStackManager(&log.PrefixLogger{Logger: logger, Prefix: fmt.Sprintf("[%s] ", stackName)})
+		if err := stackManager.LoadStack(stackName); err != nil {
+			return nil, err
+		}
+		results[stackName] = &MultiStartResult{Stack: stackName, stackManager: stackManager}
+	}
+	if err := checkStartOrder(stackNames, after); err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]chan struct{}, len(stackNames))
+	for _, stackName := range stackNames {
+		done[stackName] = make(chan struct{})
+	}
+	var wg sync.WaitGroup
+	for _, stackName := range stackNames {
+		wg.Add(1)
+		go func(result *MultiStartResult) {
+			defer wg.Done()
+			defer close(done[result.Stack])
+			for _, dependency := range after[result.Stack] {
+				<-done[dependency]
+				if status := results[dependency].Status; status != MultiStartReady {
+					reason := map[string]string{
+						MultiStartUnhealthy: "is unhealthy",
+						MultiStartFailed:    "failed to start",
+						MultiStartSkipped:   "was not started",
+					}[status]
+					result.Status = MultiStartSkipped
+					result.Error = fmt.Sprintf("not started, as stack '%s' %s", dependency, reason)
+					return
+				}
+			}
+			result.stackManager.Log.Info("starting")
+			startTime := time.Now()
+			err := result.stackManager.StartStack(false, verbose, options)
+			result.DurationMS = time.Since(startTime).Milliseconds()
+			if err != nil {
+				result.Status = MultiStartFailed
+				result.Error = err.Error()
+				result.stackManager.Log.Error(err)
+				return
+			}
+			result.Status = MultiStartReady
+			result.UI = make(map[string]string, len(result.stackManager.Stack.Members))
+			for _, member := range result.stackManager.Stack.Members {
+				if !result.stackManager.Stack.NoUI {
+					result.UI[member.ID] = member.FireflyURL() + "/ui"
+				}
+				if url := sandbox.GetSandboxURL(member); url != "" {
+					if result.Sandbox == nil {
+						result.Sandbox = make(map[string]string)
+					}
+					result.Sandbox[member.ID] = url
+				}
+			}
+			if problems, err := result.stackManager.GetContainerProblems(verbose); err == nil && len(problems) > 0 {
+				result.Status = MultiStartUnhealthy
+				for _, p := range problems {
+					result.Problems = append(result.Problems, p.String())
+				}
+			}
+			result.stackManager.Log.Info(result.Status)
+		}(results[stackName])
+	}
+	wg.Wait()
+
+	ordered := make([]*MultiStartResult, len(stackNames))
+	for i, stackName := range stackNames {
+		ordered[i] = results[stackName]
+	}
+	return ordered, nil
+}
+
+// checkStartOrder checks that every stack in the order is being started, and that no stack waits on itself
+func checkStartOrder(stackNames []string, after map[string][]string) error {
+	starting := make(map[string]bool, len(stackNames))
+	for _, stackName := range stackNames {
+		starting[stackName] = true
+	}
+	stacks := make([]string, 0, len(after))
+	for stackName, dependencies := range after {
+		stacks = append(stacks, stackName)
+		for _, dependency := range append([]string{stackName}, dependencies...) {
+			if !starting[dependency] {
+				return fmt.Errorf("stack '%s' is in the start order, but is not being started", dependency)
+			}
+		}
+	}
+	sort.Strings(stacks)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(stackNames))
+	var visit func(stackName string, path []string) error
+	visit = func(stackName string, path []string) error {
+		path = append(path, stackName)
+		switch state[stackName] {
+		case visiting:
+			return fmt.Errorf("the start order has a cycle: %s", strings.Join(path, " waits for "))
+		case visited:
+			return nil
+		}
+		state[stackName] = visiting
+		for _, dependency := range after[stackName] {
+			if err := visit(dependency, path); err != nil {
+				return err
+			}
+		}
+		state[stackName] = visited
+		return nil
+	}
+	for _, stackName := range stacks {
+		if err := visit(stackName, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
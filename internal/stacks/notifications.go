@@ -0,0 +1,83 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/firefly-cli/internal/notify"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// AddNotificationTarget adds a target that the stack's lifecycle events are posted to, replacing any
+// existing target with the same URL
+func (s *StackManager) AddNotificationTarget(target *types.NotificationTarget) error {
+	if err := notify.ValidateTarget(target); err != nil {
+		return err
+	}
+	s.removeNotificationTarget(target.URL)
+	s.Stack.Notifications = append(s.Stack.Notifications, target)
+	return s.writeStackConfig()
+}
+
+func (s *StackManager) RemoveNotificationTarget(url string) error {
+	if !s.removeNotificationTarget(url) {
+		return fmt.Errorf("stack '%s' has no notification target %s", s.Stack.Name, url)
+	}
+	return s.writeStackConfig()
+}
+
+func (s *StackManager) removeNotificationTarget(url string) bool {
+	for i, target := range s.Stack.Notifications {
+		if target.URL == url {
+			s.Stack.Notifications = append(s.Stack.Notifications[:i], s.Stack.Notifications[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// TestNotifications sends a test event to every notification target, returning the first failure
+func (s *StackManager) TestNotifications() error {
+	if len(s.Stack.Notifications) == 0 {
+		return fmt.Errorf("stack '%s' has no notification targets", s.Stack.Name)
+	}
+	event := notify.NewEvent(s.Stack.Name, notify.TestEvent, "notifications are working", nil)
+	for _, target := range s.Stack.Notifications {
+		if err := notify.Send(target, event); err != nil {
+			return fmt.Errorf("failed to notify %s: %s", target.URL, err)
+		}
+	}
+	return nil
+}
+
+// notify posts a lifecycle event to each notification target that wants it. A notification that cannot
+// be delivered is only logged, so it never fails the command that caused it.
+func (s *StackManager) notify(eventType string, message string, err error) {
+	var event *notify.Event
+	for _, target := range s.Stack.Notifications {
+		if !notify.Wants(target, eventType) {
+			continue
+		}
+		if event == nil {
+			event = notify.NewEvent(s.Stack.Name, eventType, message, err)
+		}
+		if sendErr := notify.Send(target, event); sendErr != nil {
+			s.Log.Warn(fmt.Sprintf("failed to notify %s: %s", target.URL, sendErr))
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// offline is set for commands run with --offline. Stacks are then created and started only from images
+// already pulled and release manifests already cached, and nothing is fetched from the network.
+var offline bool
+
+func SetOffline(enabled bool) {
+	offline = enabled
+}
+
+func IsOffline() bool {
+	return offline
+}
+
+func getReleaseManifest(release string) (*types.VersionManifest, error) {
+	if offline {
+		return core.GetCachedReleaseManifest(release)
+	}
+	return core.GetReleaseManifest(release)
+}
+
+// missingImages returns those of the images which have not been pulled
+func missingImages(images []string, verbose bool) ([]string, error) {
+	missing := make([]string, 0)
+	for _, image := range images {
+		if exists, err := docker.ImageExists(image, verbose); err != nil {
+			return nil, err
+		} else if !exists {
+			missing = append(missing, image)
+		}
+	}
+	return missing, nil
+}
+
+// checkOfflineImages fails if any image needed to run the stack, including the utility image used to set
+// up its volumes, has not been pulled. The images are taken from the compose config the stack will be
+// written with, so the check can run before any of its files exist.
+func (s *StackManager) checkOfflineImages(verbose bool) error {
+	found := map[string]bool{docker.UtilityImage: true}
+	images := []string{docker.UtilityImage}
+	for _, service := range s.buildDockerCompose().Services {
+		if service.Image != "" && !found[service.Image] {
+			found[service.Image] = true
+			images = append(images, service.Image)
+		}
+	}
+	sort.Strings(images)
+	missing, err := missingImages(images, verbose)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("running offline, but these images of stack '%s' have not been pulled: %s - pull them while online, or run 'ff offline prepare' for a stack that uses them", s.Stack.Name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// PrepareOffline pulls every image the stack needs and caches its release manifest, so that it can later
+// be started, or recreated with the same release, with --offline
+func (s *StackManager) PrepareOffline(verbose bool) error {
+	if offline {
+		return fmt.Errorf("cannot prepare stack '%s' for offline use while running offline", s.Stack.Name)
+	}
+	if err := s.loginToRegistry(verbose); err != nil {
+		return err
+	}
+	images := append(getComposeImages(s.Stack), docker.UtilityImage)
+	if err := s.pullImages(filepath.Join(constants.StacksDir, s.Stack.Name), images, verbose, DefaultPullConcurrency); err != nil {
+		return err
+	}
+	if s.Stack.VersionManifest != nil {
+		if err := core.CacheReleaseManifest(s.Stack.VersionManifest); err != nil {
+			return fmt.Errorf("failed to cache the version manifest of stack '%s': %s", s.Stack.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+	"gopkg.in/yaml.v2"
+)
+
+// StackSpec describes a stack to create. The keys match the flags of the init command.
+type StackSpec struct {
+	Name               string            `yaml:"name" json:"name,omitempty"`
+	Members            int               `yaml:"members" json:"members,omitempty"`
+	Database           string            `yaml:"database" json:"database,omitempty"`
+	BlockchainProvider string            `yaml:"blockchain-provider" json:"blockchain-provider,omitempty"`
+	TokensProviders    []string          `yaml:"tokens-provider" json:"tokens-provider,omitempty"`
+	EventBridge        string            `yaml:"event-bridge" json:"event-bridge,omitempty"`
+	WebhookRelay       int               `yaml:"webhook-relay" json:"webhook-relay,omitempty"`
+	Monitoring         bool              `yaml:"monitoring" json:"monitoring,omitempty"`
+	Domain             string            `yaml:"domain" json:"domain,omitempty"`
+	EdgePort           int               `yaml:"edge-port" json:"edge-port,omitempty"`
+	ExposeNodeRPC      bool              `yaml:"expose-node-rpc" json:"expose-node-rpc,omitempty"`
+	NodeRPCPort        int               `yaml:"node-rpc-port" json:"node-rpc-port,omitempty"`
+	FireFlyBasePort    int               `yaml:"firefly-base-port" json:"firefly-base-port,omitempty"`
+	ServicesBasePort   int               `yaml:"services-base-port" json:"services-base-port,omitempty"`
+	AutoPorts          bool              `yaml:"auto-ports" json:"auto-ports,omitempty"`
+	External           int               `yaml:"external" json:"external,omitempty"`
+	Release            string            `yaml:"release" json:"release,omitempty"`
+	Seed               string            `yaml:"seed" json:"seed,omitempty"`
+	Registry           string            `yaml:"registry" json:"registry,omitempty"`
+	Images             map[string]string `yaml:"images" json:"images,omitempty"`
+	PostgresURLs       []string          `yaml:"postgres-url" json:"postgres-url,omitempty"`
+	SharedIPFS         bool              `yaml:"shared-ipfs" json:"shared-ipfs,omitempty"`
+	IPFSStorageMax     string            `yaml:"ipfs-storage-max" json:"ipfs-storage-max,omitempty"`
+	IPFSLowWater       int               `yaml:"ipfs-low-water" json:"ipfs-low-water,omitempty"`
+	IPFSHighWater      int               `yaml:"ipfs-high-water" json:"ipfs-high-water,omitempty"`
+	IPFSDisableMDNS    bool              `yaml:"ipfs-disable-mdns" json:"ipfs-disable-mdns,omitempty"`
+	Gateway            bool              `yaml:"gateway" json:"gateway,omitempty"`
+	NoUI               bool              `yaml:"no-ui" json:"no-ui,omitempty"`
+	UIPath             string            `yaml:"ui-path" json:"ui-path,omitempty"`
+	Sandbox            bool              `yaml:"sandbox" json:"sandbox,omitempty"`
+	SharedSandbox      bool              `yaml:"shared-sandbox" json:"shared-sandbox,omitempty"`
+	BlockchainNodes    string            `yaml:"blockchain-nodes" json:"blockchain-nodes,omitempty"`
+	Signer             string            `yaml:"signer" json:"signer,omitempty"`
+	TLS                bool              `yaml:"tls" json:"tls,omitempty"`
+	APIAuth            string            `yaml:"api-auth" json:"api-auth,omitempty"`
+	MemoryLimit        string            `yaml:"memory-limit" json:"memory-limit,omitempty"`
+	CPULimit           float64           `yaml:"cpu-limit" json:"cpu-limit,omitempty"`
+	ServiceMemoryLimit map[string]string `yaml:"service-memory-limit" json:"service-memory-limit,omitempty"`
+	ServiceCPULimit    map[string]string `yaml:"service-cpu-limit" json:"service-cpu-limit,omitempty"`
+	// Accounts and contracts to allocate in the genesis block of a geth chain
+	GenesisAccounts []*GenesisAccountSpec `yaml:"genesis-accounts" json:"genesis-accounts,omitempty"`
+	// Extra accounts to fund in the genesis block, and the balance of each in wei
+	PrefundedAccounts int    `yaml:"prefunded-accounts" json:"prefunded-accounts,omitempty"`
+	InitialBalance    string `yaml:"initial-balance" json:"initial-balance,omitempty"`
+	// Parameters of a geth chain, and a JSON file of genesis parameters to merge into its genesis file
+	ChainID         int    `yaml:"chain-id" json:"chain-id,omitempty"`
+	BlockPeriod     int    `yaml:"block-period" json:"block-period,omitempty"`
+	GasLimit        uint64 `yaml:"gas-limit" json:"gas-limit,omitempty"`
+	GenesisFragment string `yaml:"genesis-fragment" json:"genesis-fragment,omitempty"`
+	// When to pull images before the stack is started
+	Pull string `yaml:"pull" json:"pull,omitempty"`
+}
+
+// GenesisAccountSpec is an account to allocate in the genesis block. The code of a contract is given either
+// as hex, or as a file containing the hex of its deployed bytecode.
+type GenesisAccountSpec struct {
+	Address  string            `yaml:"address" json:"address"`
+	Balance  string            `yaml:"balance" json:"balance,omitempty"`
+	Code     string            `yaml:"code" json:"code,omitempty"`
+	CodeFile string            `yaml:"code-file" json:"code-file,omitempty"`
+	Storage  map[string]string `yaml:"storage" json:"storage,omitempty"`
+}
+
+// NewStackSpec returns a spec with the same defaults as the init command
+func NewStackSpec(name string) *StackSpec {
+	return &StackSpec{
+		Name:               name,
+		Members:            1,
+		Database:           SQLite3.String(),
+		BlockchainProvider: GoEthereum.String(),
+		BlockchainNodes:    SharedBlockchainNode.String(),
+		Signer:             NodeSigner.String(),
+		APIAuth:            NoAPIAuth.String(),
+		TokensProviders:    []string{ERC1155.String()},
+		EventBridge:        NoEventBridge.String(),
+		EdgePort:           443,
+		NodeRPCPort:        8545,
+		FireFlyBasePort:    5000,
+		ServicesBasePort:   5100,
+	}
+}
+
+// ParseStackSpec parses a YAML (or JSON) stack spec. The source is only used in error messages.
+func ParseStackSpec(d []byte, source string) (*StackSpec, error) {
+	spec := NewStackSpec("")
+	if err := yaml.UnmarshalStrict(d, spec); err != nil {
+		return nil, fmt.Errorf("invalid stack spec %s: %s", source, err)
+	}
+	if strings.TrimSpace(spec.Name) == "" {
+		return nil, fmt.Errorf("invalid stack spec %s: name must be set", source)
+	}
+	if spec.Members <= 0 {
+		return nil, fmt.Errorf("invalid stack spec %s: number of members must be greater than zero", source)
+	}
+	if spec.External >= spec.Members {
+		return nil, fmt.Errorf("invalid stack spec %s: at least one member must run FireFly core in docker", source)
+	}
+	return spec, nil
+}
+
+// InitStackFromSpec creates the stack described by the spec, as the init command would
+func (s *StackManager) InitStackFromSpec(spec *StackSpec, verbose bool) error {
+	if exists, err := CheckExists(spec.Name); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("stack '%s' already exists", spec.Name)
+	}
+	options, err := spec.initOptions()
+	if err != nil {
+		return err
+	}
+	options.Verbose = verbose
+	return s.InitStack(spec.Name, spec.Members, options)
+}
+
+func (spec *StackSpec) initOptions() (*InitOptions, error) {
+	options := &InitOptions{
+		FireFlyBasePort:        spec.FireFlyBasePort,
+		ServicesBasePort:       spec.ServicesBasePort,
+		AutoAdjustPorts:        spec.AutoPorts,
+		ExternalProcesses:      spec.External,
+		WebhookRelayTargetPort: spec.WebhookRelay,
+		Monitoring:             spec.Monitoring,
+		Domain:                 spec.Domain,
+		EdgePort:               spec.EdgePort,
+		Release:                spec.Release,
+		Seed:                   spec.Seed,
+		ImageOverrides:         spec.Images,
+		PostgresURLs:           spec.PostgresURLs,
+		SharedIPFS:             spec.SharedIPFS,
+		Gateway:                spec.Gateway,
+		NoUI:                   spec.NoUI,
+		UIPath:                 spec.UIPath,
+		Sandbox:                spec.Sandbox,
+		SharedSandbox:          spec.SharedSandbox,
+		TLS:                    spec.TLS,
+		PrefundedAccounts:      spec.PrefundedAccounts,
+		InitialBalance:         spec.InitialBalance,
+		ChainID:                spec.ChainID,
+		BlockPeriod:            spec.BlockPeriod,
+		GasLimit:               spec.GasLimit,
+		GenesisFragment:        spec.GenesisFragment,
+	}
+	if spec.ExposeNodeRPC {
+		options.NodeRPCPort = spec.NodeRPCPort
+	}
+	var err error
+	if options.DatabaseSelection, err = DatabaseSelectionFromString(spec.Database); err != nil {
+		return nil, err
+	}
+	if options.BlockchainProvider, err = BlockchainProviderFromString(spec.BlockchainProvider); err != nil {
+		return nil, err
+	}
+	if options.BlockchainNodes, err = BlockchainNodeTopologyFromString(spec.BlockchainNodes); err != nil {
+		return nil, err
+	}
+	if options.Signer, err = SignerSelectionFromString(spec.Signer); err != nil {
+		return nil, err
+	}
+	if options.APIAuth, err = APIAuthSelectionFromString(spec.APIAuth); err != nil {
+		return nil, err
+	}
+	if options.ResourceLimits, err = GetResourceLimits(spec.MemoryLimit, spec.CPULimit, spec.ServiceMemoryLimit, spec.ServiceCPULimit); err != nil {
+		return nil, err
+	}
+	if options.TokensProviders, err = TokensProvidersFromStrings(spec.TokensProviders); err != nil {
+		return nil, err
+	}
+	if options.EventBridge, err = EventBridgeSelectionFromString(spec.EventBridge); err != nil {
+		return nil, err
+	}
+	if spec.Pull != "" {
+		policy, err := PullPolicyFromString(spec.Pull)
+		if err != nil {
+			return nil, err
+		}
+		options.PullPolicy = policy.String()
+	}
+	if spec.Registry != "" {
+		options.Registry = &types.RegistryConfig{URL: spec.Registry}
+	}
+	ipfsConfig := types.IPFSConfig{
+		StorageMax:  spec.IPFSStorageMax,
+		LowWater:    spec.IPFSLowWater,
+		HighWater:   spec.IPFSHighWater,
+		DisableMDNS: spec.IPFSDisableMDNS,
+	}
+	if ipfsConfig != (types.IPFSConfig{}) {
+		options.IPFS = &ipfsConfig
+	}
+	for _, account := range spec.GenesisAccounts {
+		genesisAccount := &types.GenesisAccount{
+			Address: account.Address,
+			Balance: account.Balance,
+			Code:    account.Code,
+			Storage: account.Storage,
+		}
+		if account.CodeFile != "" {
+			if account.Code != "" {
+				return nil, fmt.Errorf("genesis account %s has both code and a code file", account.Address)
+			}
+			code, err := ioutil.ReadFile(account.CodeFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the code of genesis account %s: %s", account.Address, err)
+			}
+			genesisAccount.Code = strings.TrimSpace(string(code))
+		}
+		options.GenesisAccounts = append(options.GenesisAccounts, genesisAccount)
+	}
+	return options, nil
+}
+
+// Rough resource usage of each kind of service when idle with light dev traffic, in MB
+type resourceEstimate struct {
+	memory int
+	image  int
+	data   int
+}
+
+var resourceEstimates = map[string]resourceEstimate{
+	"firefly_core":  {memory: 128, image: 100, data: 50},
+	"postgres":      {memory: 64, image: 380, data: 100},
+	"ipfs":          {memory: 256, image: 80, data: 100},
+	"dataexchange":  {memory: 96, image: 250, data: 10},
+	"ethconnect":    {memory: 128, image: 150, data: 50},
+	"tokens":        {memory: 96, image: 350, data: 0},
+	"geth":          {memory: 512, image: 50, data: 500},
+	"besu":          {memory: 1024, image: 500, data: 500},
+	"kafka":         {memory: 512, image: 600, data: 200},
+	"nats":          {memory: 32, image: 20, data: 0},
+	"event_bridge":  {memory: 32, image: 120, data: 0},
+	"webhook_relay": {memory: 32, image: 20, data: 50},
+	"prometheus":    {memory: 256, image: 230, data: 500},
+	"grafana":       {memory: 128, image: 300, data: 50},
+	"edge":          {memory: 16, image: 40, data: 0},
+}
+
+var defaultResourceEstimate = resourceEstimate{memory: 128, image: 200, data: 50}
+
+func getResourceEstimate(serviceName string) resourceEstimate {
+	if estimate, ok := resourceEstimates[serviceName]; ok {
+		return estimate
+	}
+	// Strip the member ID, and any provider name, e.g. tokens_erc20_erc721_0
+	for name := serviceName; strings.LastIndex(name, "_") > 0; {
+		name = name[:strings.LastIndex(name, "_")]
+		if estimate, ok := resourceEstimates[name]; ok {
+			return estimate
+		}
+	}
+	return defaultResourceEstimate
+}
+
+type PlannedService struct {
+	Name     string   `json:"name" yaml:"name"`
+	Image    string   `json:"image" yaml:"image"`
+	Ports    []int    `json:"ports,omitempty" yaml:"ports,omitempty"`
+	Volumes  []string `json:"volumes,omitempty" yaml:"volumes,omitempty"`
+	MemoryMB int      `json:"memoryMB" yaml:"memoryMB"`
+}
+
+type PlanConflict struct {
+	Resource string `json:"resource" yaml:"resource"`
+	Reason   string `json:"reason" yaml:"reason"`
+}
+
+type StackPlan struct {
+	Name      string            `json:"name" yaml:"name"`
+	Services  []*PlannedService `json:"services" yaml:"services"`
+	Volumes   []string          `json:"volumes" yaml:"volumes"`
+	Ports     []int             `json:"ports" yaml:"ports"`
+	MemoryMB  int               `json:"memoryMB" yaml:"memoryMB"`
+	DiskMB    int               `json:"diskMB" yaml:"diskMB"`
+	Conflicts []*PlanConflict   `json:"conflicts" yaml:"conflicts"`
+	Warnings  []string          `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+// PlanStack works out what creating the stack described by the spec would consume, and what it would
+// conflict with, without creating anything. Memory and disk usage are rough estimates - the disk
+// estimate counts each image once, plus the initial data of each service.
+func (s *StackManager) PlanStack(spec *StackSpec) (*StackPlan, error) {
+	options, err := spec.initOptions()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.createStack(spec.Name, spec.Members, options); err != nil {
+		return nil, err
+	}
+	compose := s.buildDockerCompose()
+
+	plan := &StackPlan{
+		Name:      spec.Name,
+		Services:  make([]*PlannedService, 0, len(compose.Services)),
+		Volumes:   make([]string, 0, len(compose.Volumes)),
+		Ports:     getStackPorts(s.Stack),
+		Conflicts: make([]*PlanConflict, 0),
+	}
+	images := make(map[string]bool)
+	for name, service := range compose.Services {
+		estimate := getResourceEstimate(name)
+		planned := &PlannedService{
+			Name:     name,
+			Image:    service.Image,
+			Volumes:  service.Volumes,
+			MemoryMB: estimate.memory,
+		}
+		for _, mapping := range service.Ports {
+			if port, err := strconv.Atoi(strings.Split(mapping, ":")[0]); err == nil {
+				planned.Ports = append(planned.Ports, port)
+			}
+		}
+		plan.Services = append(plan.Services, planned)
+		plan.MemoryMB += estimate.memory
+		plan.DiskMB += estimate.data
+		if !images[service.Image] {
+			images[service.Image] = true
+			plan.DiskMB += estimate.image
+		}
+	}
+	sort.Slice(plan.Services, func(i, j int) bool { return plan.Services[i].Name < plan.Services[j].Name })
+	for name := range compose.Volumes {
+		plan.Volumes = append(plan.Volumes, fmt.Sprintf("%s_%s", spec.Name, name))
+	}
+	sort.Strings(plan.Volumes)
+	sort.Ints(plan.Ports)
+
+	s.findConflicts(plan)
+	return plan, nil
+}
+
+func (s *StackManager) findConflicts(plan *StackPlan) {
+	if exists, _ := CheckExists(plan.Name); exists {
+		plan.Conflicts = append(plan.Conflicts, &PlanConflict{
+			Resource: "stack " + plan.Name,
+			Reason:   "a stack with this name already exists",
+		})
+	}
+
+	seen := make(map[int]bool)
+	for _, port := range plan.Ports {
+		if seen[port] {
+			plan.Conflicts = append(plan.Conflicts, &PlanConflict{
+				Resource: fmt.Sprintf("port %d", port),
+				Reason:   "allocated to more than one service in the spec",
+			})
+		}
+		seen[port] = true
+	}
+
+	claimed := make(map[int]string)
+	if registry, err := readPortRegistry(); err != nil {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("the ports of other stacks could not be checked: %s", err))
+	} else {
+		claimed = registry.findConflicts(s.Stack)
+	}
+
+	checked := make(map[int]bool)
+	for _, port := range plan.Ports {
+		if checked[port] {
+			continue
+		}
+		checked[port] = true
+		if name, ok := claimed[port]; ok {
+			plan.Conflicts = append(plan.Conflicts, &PlanConflict{
+				Resource: fmt.Sprintf("port %d", port),
+				Reason:   fmt.Sprintf("used by stack '%s' - the stacks cannot run at the same time", name),
+			})
+		} else if available, err := checkPortAvailable(s.Stack.Host(), port); err == nil && !available {
+			plan.Conflicts = append(plan.Conflicts, &PlanConflict{
+				Resource: fmt.Sprintf("port %d", port),
+				Reason:   "another process is listening on this port",
+			})
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// portRegistry records the host ports reserved by every stack, whether it is running or not, so that
+// stacks created later are not given the same ports
+type portRegistry struct {
+	Stacks map[string]*portReservation `json:"stacks"`
+}
+
+type portReservation struct {
+	Host  string `json:"host"`
+	Ports []int  `json:"ports"`
+}
+
+// lockPortRegistry takes an exclusive lock on the registry, so that stacks being created at the same time
+// cannot both reserve the same ports
+func lockPortRegistry() (unlock func(), err error) {
+	return lockFile(constants.PortRegistryFile)
+}
+
+// lockFile takes an exclusive lock on a file shared between firefly-cli processes. Locks left behind by a
+// process that died are broken after a while.
+func lockFile(filename string) (unlock func(), err error) {
+	lockPath := filename + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, err
+	}
+	for retries := 100; ; retries-- {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > 30*time.Second {
+			os.Remove(lockPath)
+			continue
+		}
+		if retries == 0 {
+			return nil, fmt.Errorf("timed out waiting for another firefly-cli process to release %s", lockPath)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// readPortRegistry reads the registry, dropping stacks which no longer exist, and adding any existing stacks
+// which were created before the registry was
+func readPortRegistry() (*portRegistry, error) {
+	registry := &portRegistry{}
+	d, err := ioutil.ReadFile(constants.PortRegistryFile)
+	if err == nil {
+		if err := json.Unmarshal(d, registry); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %s", constants.PortRegistryFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if registry.Stacks == nil {
+		registry.Stacks = make(map[string]*portReservation)
+	}
+
+	stackNames, _ := ListStacks()
+	exists := make(map[string]bool)
+	for _, name := range stackNames {
+		exists[name] = true
+		if _, ok := registry.Stacks[name]; ok {
+			continue
+		}
+		d, err := stateStore.Read(name)
+		if err != nil {
+			continue
+		}
+		var stack *types.Stack
+		if parseEncryptedFile(d) != nil || json.Unmarshal(d, &stack) != nil {
+			continue
+		}
+		registry.reserve(stack)
+	}
+	for name := range registry.Stacks {
+		if !exists[name] {
+			delete(registry.Stacks, name)
+		}
+	}
+	return registry, nil
+}
+
+func (r *portRegistry) write() error {
+	d, _ := json.MarshalIndent(r, "", " ")
+	return ioutil.WriteFile(constants.PortRegistryFile, d, 0644)
+}
+
+func (r *portRegistry) reserve(stack *types.Stack) {
+	ports := getStackPorts(stack)
+	sort.Ints(ports)
+	r.Stacks[stack.Name] = &portReservation{
+		Host:  stack.Host(),
+		Ports: ports,
+	}
+}
+
+// findConflicts returns the ports of the stack which are reserved by another stack on the same host,
+// mapped to the name of that stack
+func (r *portRegistry) findConflicts(stack *types.Stack) map[int]string {
+	conflicts := make(map[int]string)
+	wanted := make(map[int]bool)
+	for _, port := range getStackPorts(stack) {
+		wanted[port] = true
+	}
+	for name, reservation := range r.Stacks {
+		if name == stack.Name || reservation.Host != stack.Host() {
+			continue
+		}
+		for _, port := range reservation.Ports {
+			if wanted[port] {
+				conflicts[port] = name
+			}
+		}
+	}
+	return conflicts
+}
+
+// hostProcess marks a port conflict with a process listening on the host, rather than with another stack
+const hostProcess = ""
+
+// findListeningPorts adds the ports of the stack which another process is already listening on to the conflicts
+func findListeningPorts(stack *types.Stack, conflicts map[int]string) {
+	for _, port := range getStackPorts(stack) {
+		if _, ok := conflicts[port]; ok {
+			continue
+		}
+		if available, err := checkPortAvailable(stack.Host(), port); err == nil && !available {
+			conflicts[port] = hostProcess
+		}
+	}
+}
+
+func describePortConflicts(conflicts map[int]string) string {
+	byStack := make(map[string][]int)
+	for port, name := range conflicts {
+		byStack[name] = append(byStack[name], port)
+	}
+	names := make([]string, 0, len(byStack))
+	for name := range byStack {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	descs := make([]string, len(names))
+	for i, name := range names {
+		ports := byStack[name]
+		sort.Ints(ports)
+		list := make([]string, 0, 4)
+		for j, port := range ports {
+			if j == 3 {
+				list = append(list, "...")
+				break
+			}
+			list = append(list, fmt.Sprint(port))
+		}
+		if name == hostProcess {
+			descs[i] = fmt.Sprintf("%d in use by other processes (%s)", len(ports), strings.Join(list, ", "))
+		} else {
+			descs[i] = fmt.Sprintf("%d reserved by stack '%s' (%s)", len(ports), name, strings.Join(list, ", "))
+		}
+	}
+	return strings.Join(descs, ", ")
+}
+
+// reserveStackPorts records the current ports of the stack in the registry, failing if any of them are
+// reserved by another stack
+func reserveStackPorts(stack *types.Stack) error {
+	unlock, err := lockPortRegistry()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	registry, err := readPortRegistry()
+	if err != nil {
+		return err
+	}
+	if conflicts := registry.findConflicts(stack); len(conflicts) > 0 {
+		return fmt.Errorf("port conflicts: %s", describePortConflicts(conflicts))
+	}
+	registry.reserve(stack)
+	return registry.write()
+}
+
+func releaseStackPorts(stackName string) error {
+	unlock, err := lockPortRegistry()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	registry, err := readPortRegistry()
+	if err != nil {
+		return err
+	}
+	delete(registry.Stacks, stackName)
+	return registry.write()
+}
+
+// Stacks are moved up in blocks of 1000 ports when auto-adjusting, so each gets its own block
+const portAdjustment = 1000
+
+// allocatePorts reserves the ports of a new stack in the registry. If any are reserved by another stack, or
+// another process is listening on them, either all the ports of the new stack are moved up to the next free
+// block, or an error is returned.
+func (s *StackManager) allocatePorts(registry *portRegistry, autoAdjust bool) error {
+	for {
+		conflicts := registry.findConflicts(s.Stack)
+		findListeningPorts(s.Stack, conflicts)
+		if len(conflicts) == 0 {
+			registry.reserve(s.Stack)
+			return nil
+		}
+		if !autoAdjust {
+			return fmt.Errorf("port conflicts: %s - choose different ports with --firefly-base-port, --services-base-port or --edge-port, or use --auto-ports to move the stack to the next free ports", describePortConflicts(conflicts))
+		}
+		// The edge proxy port is never moved, as it is usually a well-known port such as 443
+		if _, edgeConflict := conflicts[s.Stack.ExposedEdgePort]; edgeConflict {
+			return fmt.Errorf("port conflicts: %s - choose a different port with --edge-port", describePortConflicts(conflicts))
+		}
+		// Nor are the blockchain node RPC ports, as wallets and tools are configured with them
+		for _, port := range getNodeRPCPorts(s.Stack) {
+			if _, nodeConflict := conflicts[port]; nodeConflict {
+				return fmt.Errorf("port conflicts: %s - choose a different port with --node-rpc-port", describePortConflicts(conflicts))
+			}
+		}
+		shiftStackPorts(s.Stack, portAdjustment)
+		for _, port := range getStackPorts(s.Stack) {
+			if port > 65535 {
+				return fmt.Errorf("no free block of ports left for stack '%s'", s.Stack.Name)
+			}
+		}
+		s.Log.Info(fmt.Sprintf("port conflicts: %s - moving stack '%s' to FireFly port %d", describePortConflicts(conflicts), s.Stack.Name, s.Stack.Members[0].ExposedFireflyPort))
+	}
+}
+
+func getNodeRPCPorts(stack *types.Stack) []int {
+	ports := make([]int, 0)
+	if stack.ExposedNodeRPCPort != 0 {
+		ports = append(ports, stack.ExposedNodeRPCPort, stack.ExposedNodeWSPort)
+	}
+	for _, member := range stack.Members {
+		if member.ExposedNodeRPCPort != 0 {
+			ports = append(ports, member.ExposedNodeRPCPort, member.ExposedNodeWSPort)
+		}
+	}
+	return ports
+}
+
+// shiftStackPorts moves every port of the stack, other than the edge proxy and blockchain node RPC ports, up by the offset
+func shiftStackPorts(stack *types.Stack, offset int) {
+	for _, port := range []*int{
+		&stack.ExposedBlockchainPort,
+		&stack.ExposedEventBrokerPort,
+		&stack.ExposedWebhookRelayPort,
+		&stack.ExposedPrometheusPort,
+		&stack.ExposedGrafanaPort,
+	} {
+		if *port != 0 {
+			*port += offset
+		}
+	}
+	for _, member := range stack.Members {
+		for _, port := range []*int{
+			&member.ExposedFireflyPort,
+			&member.ExposedFireflyAdminPort,
+			&member.ExposedEthconnectPort,
+			&member.ExposedPostgresPort,
+			&member.ExposedIPFSApiPort,
+			&member.ExposedIPFSGWPort,
+		} {
+			*port += offset
+		}
+		if member.ExposedUIPort != 0 {
+			member.ExposedUIPort += offset
+		}
+		if member.ExposedDataexchangePort != 0 {
+			member.ExposedDataexchangePort += offset
+		}
+		if member.ExposedBlockchainPort != 0 {
+			member.ExposedBlockchainPort += offset
+		}
+		if member.ExposedPostgresReplicaPort != 0 {
+			member.ExposedPostgresReplicaPort += offset
+		}
+		if member.ExposedSandboxPort != 0 {
+			member.ExposedSandboxPort += offset
+		}
+		for provider := range member.ExposedTokensPorts {
+			member.ExposedTokensPorts[provider] += offset
+		}
+	}
+}
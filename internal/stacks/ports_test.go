@@ -0,0 +1,150 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// usePortRegistryDir points the stacks directory and port registry at a temporary directory for a test
+func usePortRegistryDir(t *testing.T) {
+	dir := t.TempDir()
+	stacksDir, registryFile := constants.StacksDir, constants.PortRegistryFile
+	constants.StacksDir = filepath.Join(dir, "stacks")
+	constants.PortRegistryFile = filepath.Join(dir, "ports.json")
+	t.Cleanup(func() {
+		constants.StacksDir, constants.PortRegistryFile = stacksDir, registryFile
+	})
+}
+
+func newTestStack(t *testing.T, name string, firstPort int) *types.Stack {
+	stack := &types.Stack{
+		Name: name,
+		Members: []*types.Member{{
+			ID:                      "0",
+			ExposedFireflyPort:      firstPort,
+			ExposedFireflyAdminPort: firstPort + 100,
+			ExposedEthconnectPort:   firstPort + 200,
+			ExposedIPFSApiPort:      firstPort + 300,
+		}},
+	}
+	stackDir := filepath.Join(constants.StacksDir, name)
+	if err := os.MkdirAll(stackDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	d, _ := json.Marshal(stack)
+	if err := ioutil.WriteFile(filepath.Join(stackDir, "stack.json"), d, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return stack
+}
+
+func TestReserveAndReleaseStackPorts(t *testing.T) {
+	usePortRegistryDir(t)
+	first := newTestStack(t, "first", 5000)
+	separate := newTestStack(t, "separate", 6000)
+	// Stacks are added to the registry when it is read, so this one is only created once first is reserved
+	var overlapping *types.Stack
+
+	tests := []struct {
+		name    string
+		action  func() error
+		wantErr string
+	}{
+		{name: "reserve first stack", action: func() error { return reserveStackPorts(first) }},
+		{name: "reserve first stack again", action: func() error { return reserveStackPorts(first) }},
+		{name: "reserve conflicting ports", action: func() error {
+			overlapping = newTestStack(t, "overlapping", 5000)
+			return reserveStackPorts(overlapping)
+		}, wantErr: "reserved by stack 'first'"},
+		{name: "reserve separate ports", action: func() error { return reserveStackPorts(separate) }},
+		// Ports are released when a stack is removed, after its directory is deleted
+		{name: "remove first stack", action: func() error {
+			if err := os.RemoveAll(filepath.Join(constants.StacksDir, "first")); err != nil {
+				return err
+			}
+			return releaseStackPorts("first")
+		}},
+		{name: "reserve released ports", action: func() error { return reserveStackPorts(overlapping) }},
+	}
+	for _, test := range tests {
+		err := test.action()
+		switch {
+		case test.wantErr == "" && err != nil:
+			t.Fatalf("%s: %s", test.name, err)
+		case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+			t.Fatalf("%s: expected an error containing %q, got %v", test.name, test.wantErr, err)
+		}
+	}
+
+	registry, err := readPortRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registry.Stacks["overlapping"]; !ok {
+		t.Error("expected the overlapping stack to hold the released ports")
+	}
+	if _, ok := registry.Stacks["separate"]; !ok {
+		t.Error("expected the separate stack to still be reserved")
+	}
+}
+
+func TestReadPortRegistryDropsRemovedStacks(t *testing.T) {
+	usePortRegistryDir(t)
+	stack := newTestStack(t, "removed", 5000)
+	if err := reserveStackPorts(stack); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(constants.StacksDir, "removed")); err != nil {
+		t.Fatal(err)
+	}
+	registry, err := readPortRegistry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registry.Stacks["removed"]; ok {
+		t.Error("expected the reservation of a removed stack to be dropped")
+	}
+}
+
+func TestFindConflictsOnlyOnTheSameHost(t *testing.T) {
+	usePortRegistryDir(t)
+	local := newTestStack(t, "local", 5000)
+	remote := newTestStack(t, "remote", 5000)
+	remote.Hostname = "devbox.example.com"
+
+	registry := &portRegistry{Stacks: make(map[string]*portReservation)}
+	registry.reserve(local)
+	if conflicts := registry.findConflicts(remote); len(conflicts) != 0 {
+		t.Errorf("expected no conflicts with a stack on another host, got %v", conflicts)
+	}
+	sameHost := newTestStack(t, "same", 5000)
+	if conflicts := registry.findConflicts(sameHost); len(conflicts) != 4 || conflicts[5000] != "local" {
+		t.Errorf("expected every port to conflict with stack 'local', got %v", conflicts)
+	}
+	if conflicts := registry.findConflicts(local); len(conflicts) != 0 {
+		t.Errorf("expected a stack not to conflict with itself, got %v", conflicts)
+	}
+}
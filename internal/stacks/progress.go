@@ -0,0 +1,191 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/tracing"
+)
+
+// ensureImages pulls the images of the stack before it is started, following the pull policy given to start,
+// or else the policy of the stack. Without either, images are only pulled on first start, and afterwards
+// docker compose pulls any which are missing. When running offline, nothing is pulled, and a missing image
+// is an error.
+func (s *StackManager) ensureImages(workingDir string, verbose bool, firstTimeSetup bool, options *StartOptions) (err error) {
+	span := tracing.StartSpan("pull")
+	defer func() { span.End(err) }()
+	policy := options.PullPolicy
+	if policy == "" {
+		policy = s.Stack.PullPolicy
+	}
+	if offline {
+		// Nothing is pulled when offline, but the stack still fails fast if an image is missing
+		if policy == PullAlways.String() {
+			return fmt.Errorf("cannot pull images with the pull policy always while running offline")
+		}
+		policy = PullNever.String()
+	}
+	if policy == "" {
+		if !firstTimeSetup || options.NoPull {
+			return nil
+		}
+		policy = PullAlways.String()
+	}
+	span.SetAttribute("ff.pull_policy", policy)
+	images := getComposeImages(s.Stack)
+	if policy == PullAlways.String() {
+		s.Log.Info("pulling latest versions")
+		return s.pullImages(workingDir, images, verbose, options.PullConcurrency)
+	}
+
+	missing, err := missingImages(images, verbose)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(missing) == 0:
+		return nil
+	case offline:
+		return fmt.Errorf("running offline, but these images of stack '%s' have not been pulled: %s - run 'ff offline prepare %s' while online", s.Stack.Name, strings.Join(missing, ", "), s.Stack.Name)
+	case policy == PullNever.String():
+		return fmt.Errorf("the pull policy is never, but these images of stack '%s' have not been pulled: %s", s.Stack.Name, strings.Join(missing, ", "))
+	default:
+		s.Log.Info("pulling missing images")
+		return s.pullImages(workingDir, missing, verbose, options.PullConcurrency)
+	}
+}
+
+// pullImages pulls the images of the stack before it is started, a few at a time, so a first start on a fresh
+// machine does not wait on each download in turn. A line is logged as each image finishes. Once an image fails
+// to pull, no more are started, and the error is returned when the pulls already running have finished.
+func (s *StackManager) pullImages(workingDir string, images []string, verbose bool, concurrency int) error {
+	if len(images) == 0 {
+		return docker.RunDockerComposeCommand(workingDir, verbose, verbose, "pull")
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultPullConcurrency
+	}
+	if concurrency > len(images) {
+		concurrency = len(images)
+	}
+	s.Log.Info(fmt.Sprintf("pulling %d images, %d at a time", len(images), concurrency))
+
+	queue := make(chan string)
+	results := make(chan error)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			for image := range queue {
+				results <- docker.RunDockerCommand(workingDir, verbose, false, "pull", image)
+			}
+		}()
+	}
+
+	var failed []string
+	next, pulled, running := 0, 0, 0
+	for next < len(images) || running > 0 {
+		var send chan string
+		if next < len(images) && len(failed) == 0 {
+			send = queue
+		} else if running == 0 {
+			break
+		}
+		var image string
+		if send != nil {
+			image = images[next]
+		}
+		select {
+		case send <- image:
+			next++
+			running++
+		case err := <-results:
+			running--
+			if err != nil {
+				failed = append(failed, err.Error())
+				continue
+			}
+			pulled++
+			s.Log.Info(fmt.Sprintf("pulled image %d of %d", pulled, len(images)))
+		}
+	}
+	close(queue)
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "\n"))
+	}
+	return nil
+}
+
+// composeUp starts the services of the stack, reporting how many are running and which are still waiting to
+// become healthy while docker compose waits on their dependencies. The progress is logged at debug level, so
+// it is shown by the spinner without filling plain output. In verbose mode, the output of docker compose is shown instead.
+func (s *StackManager) composeUp(workingDir string, verbose bool) (err error) {
+	span := tracing.StartSpan("create")
+	defer func() { span.End(err) }()
+	if verbose {
+		return docker.RunDockerComposeCommand(workingDir, verbose, verbose, "up", "-d")
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- docker.RunDockerComposeCommand(workingDir, false, false, "up", "-d")
+	}()
+	total := len(getComposeServices(s.Stack))
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	last := ""
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-ticker.C:
+			containers, err := docker.ListProjectContainers(s.Stack.Name, false)
+			if err != nil {
+				continue
+			}
+			if progress := describeStartupProgress(containers, total); progress != last {
+				s.Log.Debug(progress)
+				last = progress
+			}
+		}
+	}
+}
+
+func describeStartupProgress(containers []*docker.ContainerStatus, total int) string {
+	running := 0
+	waiting := make([]string, 0)
+	for _, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		running++
+		if strings.Contains(c.Status, "health: starting") {
+			waiting = append(waiting, c.Service)
+		}
+	}
+	if total < len(containers) {
+		total = len(containers)
+	}
+	progress := fmt.Sprintf("starting services: %d of %d running", running, total)
+	if len(waiting) > 0 {
+		sort.Strings(waiting)
+		progress += fmt.Sprintf(", waiting for %s to be healthy", strings.Join(waiting, ", "))
+	}
+	return progress
+}
@@ -0,0 +1,176 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+// Provider statuses
+const (
+	ProviderStable       = "stable"
+	ProviderExperimental = "experimental"
+	// Unavailable providers are recognized, but cannot be used to create a stack yet
+	ProviderUnavailable = "unavailable"
+)
+
+type ProviderOption struct {
+	Flag        string `json:"flag" yaml:"flag"`
+	Description string `json:"description" yaml:"description"`
+}
+
+type ProviderInfo struct {
+	Type        string            `json:"type" yaml:"type"`
+	Name        string            `json:"name" yaml:"name"`
+	Description string            `json:"description" yaml:"description"`
+	Status      string            `json:"status" yaml:"status"`
+	Default     bool              `json:"default" yaml:"default"`
+	Source      string            `json:"source" yaml:"source"`
+	Features    []string          `json:"features" yaml:"features"`
+	Options     []*ProviderOption `json:"options" yaml:"options"`
+}
+
+// ListProviders describes every provider that can be selected for each part of a stack. All providers
+// are currently built into the CLI, which is reported as their source.
+func ListProviders() []*ProviderInfo {
+	return []*ProviderInfo{
+		{
+			Type:        "blockchain",
+			Name:        GoEthereum.String(),
+			Description: "A private Ethereum network run by a single geth node, with an ethconnect instance for each member",
+			Source:      "builtin",
+			Status:      ProviderStable,
+			Default:     true,
+			Features:    []string{"accounts", "add-member", "deploy", "queues"},
+			Options: []*ProviderOption{
+				{Flag: "--geth-image", Description: "Image for the geth node"},
+				{Flag: "--ethconnect-image", Description: "Image for each member's ethconnect instance"},
+				{Flag: "--blockchain-nodes", Description: "Give each member its own geth node, joined into a clique network, instead of sharing one"},
+			},
+		},
+		{
+			Type:        "blockchain",
+			Name:        HyperledgerBesu.String(),
+			Description: "A private Ethereum network run by Hyperledger Besu",
+			Source:      "builtin",
+			Status:      ProviderUnavailable,
+			Features:    []string{},
+			Options:     []*ProviderOption{},
+		},
+		{
+			Type:        "blockchain",
+			Name:        HyperledgerFabric.String(),
+			Description: "A Hyperledger Fabric network",
+			Source:      "builtin",
+			Status:      ProviderUnavailable,
+			Features:    []string{},
+			Options:     []*ProviderOption{},
+		},
+		{
+			Type:        "blockchain",
+			Name:        Corda.String(),
+			Description: "A Corda network",
+			Source:      "builtin",
+			Status:      ProviderUnavailable,
+			Features:    []string{},
+			Options:     []*ProviderOption{},
+		},
+		{
+			Type:        "database",
+			Name:        PostgreSQL.String(),
+			Description: "A PostgreSQL database container for each member",
+			Source:      "builtin",
+			Status:      ProviderStable,
+			Features:    []string{},
+			Options: []*ProviderOption{
+				{Flag: "--postgres-image", Description: "Image for each member's database"},
+				{Flag: "--postgres-url", Description: "External PostgreSQL server to use instead of the containers - one for each member, or one shared by all members"},
+				{Flag: "--postgres-replica", Description: "Run a streaming read replica of each member's database"},
+				{Flag: "--postgres-replica-lag", Description: "Delay with which the replicas apply changes"},
+			},
+		},
+		{
+			Type:        "database",
+			Name:        SQLite3.String(),
+			Description: "A SQLite database file inside each member's FireFly core container",
+			Source:      "builtin",
+			Status:      ProviderStable,
+			Default:     true,
+			Features:    []string{},
+			Options:     []*ProviderOption{},
+		},
+		{
+			Type:        "tokens",
+			Name:        NilTokens.String(),
+			Description: "No token connector",
+			Source:      "builtin",
+			Status:      ProviderStable,
+			Features:    []string{},
+			Options:     []*ProviderOption{},
+		},
+		{
+			Type:        "tokens",
+			Name:        ERC1155.String(),
+			Description: "An ERC1155 token connector for each member, supporting fungible and non-fungible tokens",
+			Source:      "builtin",
+			Status:      ProviderStable,
+			Default:     true,
+			Features:    []string{"fungible", "non-fungible"},
+			Options: []*ProviderOption{
+				{Flag: "--tokens-image", Description: "Image for each member's token connector"},
+			},
+		},
+		{
+			Type:        "tokens",
+			Name:        ERC20ERC721.String(),
+			Description: "An ERC20 and ERC721 token connector for each member, deploying a new contract for each token pool",
+			Source:      "builtin",
+			Status:      ProviderExperimental,
+			Features:    []string{"fungible", "non-fungible"},
+			Options: []*ProviderOption{
+				{Flag: "--tokens-image", Description: "Image for each member's token connector"},
+			},
+		},
+		{
+			Type:        "storage",
+			Name:        "ipfs",
+			Description: "A private IPFS network with a node for each member",
+			Source:      "builtin",
+			Status:      ProviderStable,
+			Default:     true,
+			Features:    []string{},
+			Options: []*ProviderOption{
+				{Flag: "--ipfs-image", Description: "Image for each member's IPFS node"},
+				{Flag: "--shared-ipfs", Description: "Run one IPFS node shared by all members"},
+			},
+		},
+		{
+			Type:        "eventBridge",
+			Name:        Kafka.String(),
+			Description: "Republishes each member's FireFly events to a Kafka broker",
+			Source:      "builtin",
+			Status:      ProviderExperimental,
+			Features:    []string{},
+			Options:     []*ProviderOption{},
+		},
+		{
+			Type:        "eventBridge",
+			Name:        NATS.String(),
+			Description: "Republishes each member's FireFly events to a NATS server",
+			Source:      "builtin",
+			Status:      ProviderExperimental,
+			Features:    []string{},
+			Options:     []*ProviderOption{},
+		},
+	}
+}
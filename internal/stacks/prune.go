@@ -0,0 +1,146 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+)
+
+var resourceTypeOrder = map[string]int{
+	docker.ContainerResource: 0,
+	docker.VolumeResource:    1,
+	docker.NetworkResource:   2,
+}
+
+// FindOrphanedResources returns the containers, volumes and networks left behind by stacks that no longer exist,
+// in the order they can be removed, along with the orphaned stacks that are left alone because some of their
+// containers are still running.
+func FindOrphanedResources(verbose bool) (orphaned []*docker.ComposeResource, running []string, err error) {
+	resources, err := docker.ListComposeResources(verbose)
+	if err != nil {
+		return nil, nil, err
+	}
+	known, err := knownProjects()
+	if err != nil {
+		return nil, nil, err
+	}
+	orphaned, running = findOrphanedResources(resources, known)
+	return orphaned, running, nil
+}
+
+// findOrphanedResources picks out the resources of compose projects that were created by the CLI, which is known
+// from the working directory compose records on their containers being a directory of the stacks directory, and
+// that are not a known stack. A FireFly deployment run from anywhere else, such as one waiting to be adopted, is
+// never touched, however its volumes are named.
+func findOrphanedResources(resources []*docker.ComposeResource, known map[string]bool) (orphaned []*docker.ComposeResource, running []string) {
+	stackProjects := make(map[string]bool)
+	runningProjects := make(map[string]bool)
+	for _, resource := range resources {
+		if resource.Type != docker.ContainerResource || resource.WorkingDir == "" {
+			continue
+		}
+		if filepath.Clean(filepath.Dir(resource.WorkingDir)) == filepath.Clean(constants.StacksDir) {
+			stackProjects[resource.Project] = true
+			if resource.Running {
+				runningProjects[resource.Project] = true
+			}
+		}
+	}
+
+	orphaned = make([]*docker.ComposeResource, 0)
+	for _, resource := range resources {
+		if resource.Type == docker.VolumeResource && resource.Project == "" {
+			resource.Project = volumeProject(resource.Name, stackProjects, known)
+		}
+		if resource.Project == "" || !stackProjects[resource.Project] || known[resource.Project] {
+			continue
+		}
+		if runningProjects[resource.Project] {
+			continue
+		}
+		orphaned = append(orphaned, resource)
+	}
+	sort.SliceStable(orphaned, func(i, j int) bool {
+		if orphaned[i].Type != orphaned[j].Type {
+			return resourceTypeOrder[orphaned[i].Type] < resourceTypeOrder[orphaned[j].Type]
+		}
+		return orphaned[i].Name < orphaned[j].Name
+	})
+	running = make([]string, 0)
+	for project := range runningProjects {
+		if !known[project] {
+			running = append(running, project)
+		}
+	}
+	sort.Strings(running)
+	return orphaned, running
+}
+
+// knownProjects returns the names of all stacks, along with any directories in the stacks directory, so that
+// the resources of a stack that is still being created are never taken to be orphaned
+func knownProjects() (map[string]bool, error) {
+	names, err := ListStacks()
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, name := range names {
+		known[name] = true
+	}
+	entries, _ := ioutil.ReadDir(constants.StacksDir)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			known[entry.Name()] = true
+		}
+	}
+	return known, nil
+}
+
+// volumeProject finds the project of a volume without compose labels from its name, which is prefixed with the
+// project name. The longest matching project wins, so a stack named after the prefix of another is not confused with it.
+func volumeProject(volumeName string, projects ...map[string]bool) string {
+	owner := ""
+	for _, set := range projects {
+		for project := range set {
+			if strings.HasPrefix(volumeName, project+"_") && len(project) > len(owner) {
+				owner = project
+			}
+		}
+	}
+	return owner
+}
+
+// PruneResources removes the given resources, carrying on past failures so that as much as possible is reclaimed
+func (s *StackManager) PruneResources(resources []*docker.ComposeResource, verbose bool) (removed int, failed int) {
+	for _, resource := range resources {
+		s.Log.Info(fmt.Sprintf("removing %s %s", resource.Type, resource.Name))
+		if err := docker.RemoveComposeResource(resource, verbose); err != nil {
+			s.Log.Error(err)
+			failed++
+			continue
+		}
+		removed++
+	}
+	return removed, failed
+}
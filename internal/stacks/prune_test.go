@@ -0,0 +1,60 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+)
+
+func TestFindOrphanedResources(t *testing.T) {
+	stackDir := func(name string) string { return filepath.Join(constants.StacksDir, name) }
+	resources := []*docker.ComposeResource{
+		// A deleted stack, stopped
+		{Type: docker.ContainerResource, Name: "gone_firefly_core_0_1", Project: "gone", WorkingDir: stackDir("gone")},
+		{Type: docker.VolumeResource, Name: "gone_firefly_core_0", Project: "gone"},
+		{Type: docker.VolumeResource, Name: "gone_ipfs_copy"},
+		{Type: docker.NetworkResource, Name: "gone_default", Project: "gone"},
+		// A deleted stack that is still running
+		{Type: docker.ContainerResource, Name: "live_firefly_core_0_1", Project: "live", WorkingDir: stackDir("live"), Running: true},
+		{Type: docker.VolumeResource, Name: "live_firefly_core_0", Project: "live"},
+		// An existing stack
+		{Type: docker.ContainerResource, Name: "kept_firefly_core_0_1", Project: "kept", WorkingDir: stackDir("kept")},
+		{Type: docker.VolumeResource, Name: "kept_dataexchange_0", Project: "kept"},
+		// A FireFly deployment run from elsewhere, with volumes named like a stack's
+		{Type: docker.ContainerResource, Name: "mine_firefly_core_0_1", Project: "mine", WorkingDir: "/home/me/firefly"},
+		{Type: docker.VolumeResource, Name: "mine_firefly_core_0", Project: "mine"},
+		{Type: docker.VolumeResource, Name: "other_dataexchange_0", Project: "other"},
+	}
+	orphaned, running := findOrphanedResources(resources, map[string]bool{"kept": true})
+
+	expected := []string{"gone_firefly_core_0_1", "gone_firefly_core_0", "gone_ipfs_copy", "gone_default"}
+	if len(orphaned) != len(expected) {
+		t.Fatalf("got %d orphaned resources, expected %d: %v", len(orphaned), len(expected), orphaned)
+	}
+	for i, name := range expected {
+		if orphaned[i].Name != name {
+			t.Errorf("orphaned resource %d is %s, expected %s", i, orphaned[i].Name, name)
+		}
+	}
+	if len(running) != 1 || running[0] != "live" {
+		t.Errorf("got running projects %v, expected [live]", running)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// The sources of the queue depths, which are each queried separately
+const (
+	QueueSourceCore         = "core"
+	QueueSourceEthconnect   = "ethconnect"
+	QueueSourceDataExchange = "dataexchange"
+)
+
+type QueueDepths struct {
+	Member string `json:"member" yaml:"member"`
+	// Pending FireFly core operations, by operation type
+	PendingOperations map[string]int `json:"pendingOperations" yaml:"pendingOperations"`
+	// Transactions submitted to ethconnect which have no receipt yet
+	InFlightTransactions int `json:"inFlightTransactions" yaml:"inFlightTransactions"`
+	// Messages and blobs queued by data exchange to be sent to other members
+	PendingDataExchangeTransfers int `json:"pendingDataExchangeTransfers" yaml:"pendingDataExchangeTransfers"`
+	// The error of each source that could not be queried
+	Errors map[string]string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// dataExchangeStatus is the part of data exchange's status with the size of its outbound queues
+type dataExchangeStatus struct {
+	MessageQueueSize int `json:"messageQueueSize"`
+	BlobQueueSize    int `json:"blobQueueSize"`
+}
+
+// GetQueueDepths reports the work that is waiting on each member's FireFly core, ethconnect and data exchange.
+// If memberID is empty, all members are included. A source that cannot be queried is reported in the member's
+// errors, without affecting the others.
+func (s *StackManager) GetQueueDepths(memberID string) ([]*QueueDepths, error) {
+	members := s.Stack.Members
+	if memberID != "" {
+		member, err := s.getMember(memberID)
+		if err != nil {
+			return nil, err
+		}
+		members = []*types.Member{member}
+	}
+
+	queues := make([]*QueueDepths, 0, len(members))
+	for _, member := range members {
+		q := &QueueDepths{
+			Member:            member.ID,
+			PendingOperations: make(map[string]int),
+			Errors:            make(map[string]string),
+		}
+		queues = append(queues, q)
+
+		// FireFly core uses the ID of each blockchain operation as the ID of its request to ethconnect
+		var requestIDs []string
+		operations, err := core.GetOperations(member, "status=Pending")
+		if err != nil {
+			q.Errors[QueueSourceCore] = err.Error()
+		}
+		for _, op := range operations {
+			q.PendingOperations[op.Type]++
+			if strings.HasPrefix(op.Type, "blockchain_") {
+				requestIDs = append(requestIDs, op.ID)
+			}
+		}
+
+		if err != nil {
+			q.Errors[QueueSourceEthconnect] = "the requests to check are the pending operations of FireFly core, which could not be listed"
+		} else if q.InFlightTransactions, err = s.blockchainProvider.GetInFlightTransactionCount(member, requestIDs); err != nil {
+			q.Errors[QueueSourceEthconnect] = err.Error()
+		}
+
+		// Gateway stacks have no data exchange
+		if member.ExposedDataexchangePort != 0 {
+			if status, err := getDataExchangeStatus(member); err != nil {
+				q.Errors[QueueSourceDataExchange] = err.Error()
+			} else {
+				q.PendingDataExchangeTransfers = status.MessageQueueSize + status.BlobQueueSize
+			}
+		}
+	}
+	return queues, nil
+}
+
+func getDataExchangeStatus(member *types.Member) (*dataExchangeStatus, error) {
+	resp, err := http.Get(fmt.Sprintf("http://%s:%d/api/v1/status", member.Host(), member.ExposedDataexchangePort))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%d %s", resp.StatusCode, body)
+	}
+	var status *dataExchangeStatus
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
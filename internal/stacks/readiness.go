@@ -0,0 +1,78 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/tracing"
+)
+
+// WaitForReady polls the status of the FireFly node of each member until every node is up with its org and
+// node registered, so the stack can be used. A zero timeout waits forever.
+func (s *StackManager) WaitForReady(timeout time.Duration) (err error) {
+	span := tracing.StartSpan("wait")
+	defer func() { span.End(err) }()
+	s.Log.Info("waiting for the FireFly nodes to be ready")
+	deadline := time.Now().Add(timeout)
+	ready := make(map[string]bool, len(s.Stack.Members))
+	last := ""
+	for {
+		notReady := make(map[string]string)
+		for _, member := range s.Stack.Members {
+			if ready[member.ID] {
+				continue
+			}
+			status, err := core.GetStatus(member)
+			switch {
+			case err != nil:
+				notReady[member.ID] = err.Error()
+			case !status.Org.Registered:
+				notReady[member.ID] = "org not registered"
+			case !status.Node.Registered:
+				notReady[member.ID] = "node not registered"
+			default:
+				ready[member.ID] = true
+			}
+		}
+		if len(notReady) == 0 {
+			return nil
+		}
+
+		ids := make([]string, 0, len(notReady))
+		for id := range notReady {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		if progress := fmt.Sprintf("%d of %d FireFly nodes ready, waiting for member %s", len(ready), len(s.Stack.Members), strings.Join(ids, ", ")); progress != last {
+			s.Log.Debug(progress)
+			last = progress
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			reasons := make([]string, len(ids))
+			for i, id := range ids {
+				reasons[i] = fmt.Sprintf("member %s: %s", id, notReady[id])
+			}
+			return fmt.Errorf("stack '%s' was not ready within %s - %s", s.Stack.Name, timeout, strings.Join(reasons, "; "))
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/notify"
+)
+
+// RecoveryCheck lists the containers and volumes of a stack that has been run before, which no longer exist
+type RecoveryCheck struct {
+	MissingContainers []string `json:"missingContainers" yaml:"missingContainers"`
+	MissingVolumes    []string `json:"missingVolumes" yaml:"missingVolumes"`
+}
+
+func (c *RecoveryCheck) NeedsRecovery() bool {
+	return len(c.MissingContainers) > 0
+}
+
+// ContainersMissing is a quick check, which does not need the stack to be loaded, for whether a stack that
+// has been run before has lost all of its containers - which happens when the Docker VM is restarted or
+// recreated, for example by Docker Desktop
+func ContainersMissing(stackName string, verbose bool) (bool, error) {
+	if runBefore, err := setUpFilesExist(stackName); err != nil || !runBefore {
+		return false, err
+	}
+	containers, err := docker.ListProjectContainers(stackName, verbose)
+	if err != nil {
+		return false, err
+	}
+	return len(containers) == 0, nil
+}
+
+// setUpFilesExist checks for the files written when a stack is first set up - the data exchange certificates
+// of its members, or the setup marker of a gateway stack, which has no data exchange
+func setUpFilesExist(stackName string) (bool, error) {
+	dataDir := filepath.Join(constants.StacksDir, stackName, "data")
+	certs, err := filepath.Glob(filepath.Join(dataDir, "dataexchange_*", "cert.pem"))
+	if err != nil || len(certs) > 0 {
+		return len(certs) > 0, err
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, GatewaySetupMarker)); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CheckRecovery compares the services and volumes of the stack with those docker has, using the compose
+// project label of the containers
+func (s *StackManager) CheckRecovery(verbose bool) (*RecoveryCheck, error) {
+	check := &RecoveryCheck{
+		MissingContainers: make([]string, 0),
+		MissingVolumes:    make([]string, 0),
+	}
+	if runBefore, err := s.StackHasRunBefore(); err != nil || !runBefore {
+		return check, err
+	}
+	compose := s.buildDockerCompose()
+
+	containers, err := docker.ListProjectContainers(s.Stack.Name, verbose)
+	if err != nil {
+		return nil, err
+	}
+	services := make(map[string]bool)
+	for _, c := range containers {
+		services[c.Service] = true
+	}
+	for name := range compose.Services {
+		if !services[name] {
+			check.MissingContainers = append(check.MissingContainers, name)
+		}
+	}
+
+	volumes, err := docker.ListVolumes(s.Stack.Name+"_", verbose)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	for _, v := range volumes {
+		existing[strings.TrimPrefix(v, s.Stack.Name+"_")] = true
+	}
+	for name := range compose.Volumes {
+		if !existing[name] {
+			check.MissingVolumes = append(check.MissingVolumes, name)
+		}
+	}
+	sort.Strings(check.MissingContainers)
+	sort.Strings(check.MissingVolumes)
+	return check, nil
+}
+
+// RecoverStack re-creates any missing containers of the stack against its existing volumes, and waits for
+// FireFly to come back up. Stacks which have also lost volumes cannot be recovered, as their blockchain,
+// databases and keys would no longer agree with each other.
+func (s *StackManager) RecoverStack(verbose bool) (*RecoveryCheck, error) {
+	check, err := s.recoverStack(verbose)
+	if err != nil {
+		s.notify(notify.RecoverFailed, fmt.Sprintf("stack '%s' could not be recovered", s.Stack.Name), err)
+	} else if check.NeedsRecovery() {
+		s.notify(notify.Recovered, fmt.Sprintf("re-created %d missing containers of stack '%s'", len(check.MissingContainers), s.Stack.Name), nil)
+	}
+	return check, err
+}
+
+func (s *StackManager) recoverStack(verbose bool) (*RecoveryCheck, error) {
+	check, err := s.CheckRecovery(verbose)
+	if err != nil || !check.NeedsRecovery() {
+		return check, err
+	}
+	if len(check.MissingVolumes) > 0 {
+		return nil, fmt.Errorf("stack '%s' cannot be recovered as its volumes were also lost (%s) - run 'ff reset %s' to start again", s.Stack.Name, strings.Join(check.MissingVolumes, ", "), s.Stack.Name)
+	}
+	if err := s.loginToRegistry(verbose); err != nil {
+		return nil, err
+	}
+	s.Log.Info(fmt.Sprintf("re-creating %d containers", len(check.MissingContainers)))
+	if err := s.runStartupSequence(filepath.Join(constants.StacksDir, s.Stack.Name), verbose, false); err != nil {
+		return nil, s.withContainerProblems(err, verbose)
+	}
+	return check, nil
+}
@@ -0,0 +1,296 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum/ethconnect"
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/secrets"
+	"gopkg.in/yaml.v2"
+)
+
+// Reasons a service is restarted by a reload
+const (
+	ReloadDefinitionChanged = "definition changed"
+	ReloadConfigChanged     = "config changed"
+	ReloadDependencyChanged = "depends on %s"
+)
+
+// ServiceReload is a service restarted by a reload. Services whose compose definition changed are recreated,
+// and the others are restarted.
+type ServiceReload struct {
+	Service  string `json:"service" yaml:"service"`
+	Reason   string `json:"reason" yaml:"reason"`
+	Recreate bool   `json:"recreate" yaml:"recreate"`
+}
+
+// serviceState is the generated definition and config of each service, for finding what a reload changed
+type serviceState struct {
+	definitions map[string]string
+	dependsOn   map[string][]string
+	configs     map[string]string
+}
+
+// ReloadStack regenerates the compose file and configs of the stack, and restarts only the services whose
+// definition or config changed, along with every service that depends on them directly or indirectly. The
+// services are restarted in dependency order, and each must be healthy (or running, if it has no health
+// check) before the services depending on it are restarted. With dryRun, nothing is written or restarted.
+func (s *StackManager) ReloadStack(dryRun bool, timeout time.Duration, verbose bool) ([]*ServiceReload, error) {
+	if err := s.checkGenerated(); err != nil {
+		return nil, err
+	}
+	if s.Stack.AdoptedFrom != "" {
+		return nil, fmt.Errorf("stack '%s' was adopted from %s - its compose file is not generated, so it cannot be reloaded", s.Stack.Name, s.Stack.AdoptedFrom)
+	}
+	before, err := s.readServiceState()
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		after, err := s.generatedServiceState()
+		if err != nil {
+			return nil, err
+		}
+		return planReload(before, after), nil
+	}
+
+	if err := s.writeStackFiles(verbose); err != nil {
+		return nil, err
+	}
+	after, err := s.readServiceState()
+	if err != nil {
+		return nil, err
+	}
+	plan := planReload(before, after)
+	if len(plan) == 0 {
+		return plan, nil
+	}
+	runBefore, err := s.StackHasRunBefore()
+	if err != nil || !runBefore {
+		return plan, err
+	}
+	running, err := s.IsRunning(verbose)
+	if err != nil {
+		return nil, err
+	}
+	// Configs read from volumes are copied in whether or not the stack is running, so they are used on the next start
+	for _, reload := range plan {
+		if reload.Reason != ReloadConfigChanged {
+			continue
+		}
+		for _, member := range s.Stack.Members {
+			switch reload.Service {
+			case "firefly_core_" + member.ID:
+				if err := s.copyFireflyConfigToVolume(member, verbose); err != nil {
+					return nil, err
+				}
+			case "ethconnect_" + member.ID:
+				if err := secrets.CopyFileToVolume(fmt.Sprintf("%s_ethconnect_config_%s", s.Stack.Name, member.ID), ethconnect.ConfigPath(s.Stack, member), "ethconnect.yml", verbose); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if !running {
+		return plan, nil
+	}
+
+	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	for _, reload := range plan {
+		if reload.Recreate {
+			s.Log.Info(fmt.Sprintf("recreating %s (%s)", reload.Service, reload.Reason))
+			err = docker.RunDockerComposeCommand(workingDir, verbose, verbose, "up", "-d", "--no-deps", reload.Service)
+		} else {
+			s.Log.Info(fmt.Sprintf("restarting %s (%s)", reload.Service, reload.Reason))
+			err = docker.RunDockerComposeCommand(workingDir, verbose, verbose, "restart", reload.Service)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := s.waitForServiceHealthy(reload.Service, timeout, verbose); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+func (s *StackManager) waitForServiceHealthy(service string, timeout time.Duration, verbose bool) error {
+	containers, err := docker.ListProjectContainers(s.Stack.Name, verbose)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if c.Service == service {
+			return docker.WaitForHealthy(c.Name, timeout, verbose)
+		}
+	}
+	return fmt.Errorf("no container found for service %s", service)
+}
+
+// serviceConfigFiles returns the generated config files each service reads, by service name
+func (s *StackManager) serviceConfigFiles() map[string]string {
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	files := make(map[string]string)
+	for _, member := range s.Stack.Members {
+		if !member.External {
+			files["firefly_core_"+member.ID] = filepath.Join(stackDir, "configs", fmt.Sprintf("firefly_core_%s.yml", member.ID))
+		}
+		if s.Stack.BlockchainProvider == GoEthereum.String() && ethconnect.HasConfig(s.Stack) {
+			files["ethconnect_"+member.ID] = ethconnect.ConfigPath(s.Stack, member)
+		}
+	}
+	return files
+}
+
+// readServiceState reads the compose file and configs of the stack as they are on disk
+func (s *StackManager) readServiceState() (*serviceState, error) {
+	d, err := ioutil.ReadFile(filepath.Join(constants.StacksDir, s.Stack.Name, "docker-compose.yml"))
+	if err != nil {
+		return nil, err
+	}
+	var compose *docker.DockerComposeConfig
+	if err := yaml.Unmarshal(d, &compose); err != nil {
+		return nil, fmt.Errorf("failed to parse docker-compose.yml: %s", err)
+	}
+	state := newServiceState(compose)
+	for service, filename := range s.serviceConfigFiles() {
+		// A config that is missing is compared as empty, so it counts as changed once it is written
+		d, _ := ioutil.ReadFile(filename)
+		state.configs[service] = string(d)
+	}
+	return state, nil
+}
+
+// generatedServiceState builds the compose file and configs the stack would be reloaded with, without writing them
+func (s *StackManager) generatedServiceState() (*serviceState, error) {
+	state := newServiceState(s.buildDockerCompose())
+	current, err := s.readServiceState()
+	if err != nil {
+		return nil, err
+	}
+	state.configs = current.configs
+	configs, err := s.buildFireflyCoreConfigs()
+	if err != nil {
+		return nil, err
+	}
+	if s.Stack.BlockchainProvider == GoEthereum.String() && ethconnect.HasConfig(s.Stack) {
+		config, err := ethconnect.BuildConfig(s.Stack)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range s.Stack.Members {
+			configs["ethconnect_"+member.ID] = config
+		}
+	}
+	for service, config := range configs {
+		if _, ok := state.configs[service]; ok {
+			state.configs[service] = string(config)
+		}
+	}
+	return state, nil
+}
+
+func newServiceState(compose *docker.DockerComposeConfig) *serviceState {
+	state := &serviceState{
+		definitions: make(map[string]string),
+		dependsOn:   make(map[string][]string),
+		configs:     make(map[string]string),
+	}
+	if compose == nil {
+		return state
+	}
+	for name, service := range compose.Services {
+		d, _ := yaml.Marshal(service)
+		state.definitions[name] = string(d)
+		for dependency := range service.DependsOn {
+			state.dependsOn[name] = append(state.dependsOn[name], dependency)
+		}
+	}
+	return state
+}
+
+// planReload finds the services whose definition or config changed, adds every service that depends on them,
+// and orders them so that each service comes after the services it depends on
+func planReload(before, after *serviceState) []*ServiceReload {
+	reasons := make(map[string]*ServiceReload)
+	for service, definition := range after.definitions {
+		if before.definitions[service] != definition {
+			reasons[service] = &ServiceReload{Service: service, Reason: ReloadDefinitionChanged, Recreate: true}
+		} else if config, ok := after.configs[service]; ok && before.configs[service] != config {
+			reasons[service] = &ServiceReload{Service: service, Reason: ReloadConfigChanged}
+		}
+	}
+
+	dependents := make(map[string][]string)
+	for service, dependencies := range after.dependsOn {
+		for _, dependency := range dependencies {
+			dependents[dependency] = append(dependents[dependency], service)
+		}
+	}
+	queue := make([]string, 0, len(reasons))
+	for service := range reasons {
+		queue = append(queue, service)
+	}
+	sort.Strings(queue)
+	for len(queue) > 0 {
+		service := queue[0]
+		queue = queue[1:]
+		next := dependents[service]
+		sort.Strings(next)
+		for _, dependent := range next {
+			if _, ok := reasons[dependent]; !ok {
+				reasons[dependent] = &ServiceReload{Service: dependent, Reason: fmt.Sprintf(ReloadDependencyChanged, service)}
+				queue = append(queue, dependent)
+			}
+		}
+	}
+
+	// Order the services depth first, each after the services it depends on
+	plan := make([]*ServiceReload, 0, len(reasons))
+	visited := make(map[string]bool)
+	var visit func(service string)
+	visit = func(service string) {
+		if visited[service] {
+			return
+		}
+		visited[service] = true
+		dependencies := append([]string{}, after.dependsOn[service]...)
+		sort.Strings(dependencies)
+		for _, dependency := range dependencies {
+			visit(dependency)
+		}
+		if reload, ok := reasons[service]; ok {
+			plan = append(plan, reload)
+		}
+	}
+	services := make([]string, 0, len(reasons))
+	for service := range reasons {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		visit(service)
+	}
+	return plan
+}
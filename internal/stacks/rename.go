@@ -0,0 +1,140 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/keychain"
+)
+
+// RenameStack gives the stack a new name. The containers of the stack are removed and its files are moved
+// and regenerated under the new name. As docker cannot rename volumes, the volumes are copied to volumes
+// named after the new compose project, and the old volumes are only removed once the copies are made. A
+// running stack is started again under the new name.
+func (s *StackManager) RenameStack(newName string, verbose bool) error {
+	if err := s.checkGenerated(); err != nil {
+		return err
+	}
+	if err := s.checkNotArchived(); err != nil {
+		return err
+	}
+	oldName := s.Stack.Name
+	if s.Stack.ABPair != "" {
+		return fmt.Errorf("stack '%s' is part of the blue/green pair '%s', whose stacks are named after the pair", oldName, s.Stack.ABPair)
+	}
+	oldDir := filepath.Join(constants.StacksDir, oldName)
+	newDir := filepath.Join(constants.StacksDir, newName)
+	if exists, err := CheckExists(newName); err != nil {
+		return err
+	} else if _, statErr := os.Stat(newDir); exists || statErr == nil {
+		return fmt.Errorf("stack '%s' already exists", newName)
+	}
+	if existing, err := docker.ListVolumes(newName+"_", verbose); err != nil {
+		return err
+	} else if len(existing) > 0 {
+		return fmt.Errorf("volumes of an earlier stack named '%s' still exist - remove them, or choose another name", newName)
+	}
+	running, err := s.IsRunning(verbose)
+	if err != nil {
+		return err
+	}
+	volumes, err := s.getExistingVolumes(verbose)
+	if err != nil {
+		return err
+	}
+
+	s.Log.Info(fmt.Sprintf("removing the containers of stack '%s'", oldName))
+	if err := docker.RunDockerComposeCommand(oldDir, verbose, verbose, "down"); err != nil {
+		return err
+	}
+	copiedVolumes := make([]string, 0, len(volumes))
+	for _, volume := range volumes {
+		s.Log.Info(fmt.Sprintf("copying volume %s_%s to %s_%s", oldName, volume, newName, volume))
+		if err := docker.CopyVolume(oldName+"_"+volume, newName+"_"+volume, verbose); err != nil {
+			for _, copied := range copiedVolumes {
+				docker.RemoveVolume(copied, verbose)
+			}
+			return err
+		}
+		copiedVolumes = append(copiedVolumes, newName+"_"+volume)
+	}
+
+	if err := os.Rename(oldDir, newDir); err != nil {
+		return err
+	}
+	s.Stack.Name = newName
+	if s.useKeychain {
+		if err := keychain.Set(newName, s.passphrase); err != nil {
+			return err
+		}
+	}
+	if err := s.writeStackFiles(verbose); err != nil {
+		return err
+	}
+	if err := stateStore.Delete(oldName); err != nil {
+		return err
+	}
+	if s.useKeychain {
+		if err := keychain.Delete(oldName); err != nil {
+			s.Log.Info(err.Error())
+		}
+	}
+	if err := s.renamePortReservation(oldName); err != nil {
+		return err
+	}
+
+	if len(volumes) > 0 {
+		for _, member := range s.Stack.Members {
+			if member.External {
+				continue
+			}
+			if err := s.copyFireflyConfigToVolume(member, verbose); err != nil {
+				return err
+			}
+		}
+		for _, volume := range volumes {
+			if err := docker.RemoveVolume(oldName+"_"+volume, verbose); err != nil {
+				s.Log.Info(fmt.Sprintf("failed to remove volume %s_%s: %s", oldName, volume, err))
+			}
+		}
+	}
+	if running {
+		return s.StartStack(false, verbose, &StartOptions{})
+	}
+	return nil
+}
+
+// renamePortReservation moves the ports reserved by the stack under its old name to its current name
+func (s *StackManager) renamePortReservation(oldName string) error {
+	unlock, err := lockPortRegistry()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	registry, err := readPortRegistry()
+	if err != nil {
+		return err
+	}
+	delete(registry.Stacks, oldName)
+	registry.reserve(s.Stack)
+	return registry.write()
+}
@@ -0,0 +1,102 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+var memoryLimitRegex = regexp.MustCompile(`^[0-9]+[bkmg]?$`)
+
+// GetResourceLimits combines the limits for all services with the limits for each service, which are
+// given as maps from a service name or prefix to the memory or number of CPUs
+func GetResourceLimits(memory string, cpus float64, serviceMemory map[string]string, serviceCPUs map[string]string) (map[string]*types.ResourceLimits, error) {
+	limits := make(map[string]*types.ResourceLimits)
+	setLimit := func(service, memory string, cpus float64) error {
+		memory = strings.ToLower(strings.TrimSpace(memory))
+		if memory != "" && !memoryLimitRegex.MatchString(memory) {
+			return fmt.Errorf("invalid memory limit '%s' - use a number of bytes with an optional b, k, m or g unit, such as 512m", memory)
+		}
+		if cpus < 0 {
+			return fmt.Errorf("invalid CPU limit %v - the number of CPUs must be greater than zero", cpus)
+		}
+		if memory == "" && cpus == 0 {
+			return nil
+		}
+		if limits[service] == nil {
+			limits[service] = &types.ResourceLimits{}
+		}
+		if memory != "" {
+			limits[service].Memory = memory
+		}
+		if cpus != 0 {
+			limits[service].CPUs = cpus
+		}
+		return nil
+	}
+	if err := setLimit(types.AllServices, memory, cpus); err != nil {
+		return nil, err
+	}
+	for service, memory := range serviceMemory {
+		if err := setLimit(service, memory, 0); err != nil {
+			return nil, err
+		}
+	}
+	for service, value := range serviceCPUs {
+		cpus, err := strconv.ParseFloat(value, 64)
+		if err != nil || cpus <= 0 {
+			return nil, fmt.Errorf("invalid CPU limit '%s' for %s - the number of CPUs must be greater than zero", value, service)
+		}
+		if err := setLimit(service, "", cpus); err != nil {
+			return nil, err
+		}
+	}
+	return limits, nil
+}
+
+// checkResourceLimits makes sure every service a limit is set for exists in the stack, so a typo does
+// not silently leave a service unconstrained
+func (s *StackManager) checkResourceLimits() error {
+	services := s.buildDockerCompose().Services
+	unknown := make([]string, 0)
+	for name := range s.Stack.ResourceLimits {
+		if name == types.AllServices {
+			continue
+		}
+		found := false
+		for serviceName := range services {
+			if serviceName == name || strings.HasPrefix(serviceName, name+"_") {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("resource limits set for services that are not in the stack: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+// seededReader is a deterministic source of randomness. It produces the stream of
+// SHA-256(seed || counter) blocks, for counter = 0, 1, 2...
+type seededReader struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func newSeededReader(seed string) io.Reader {
+	return &seededReader{seed: []byte(seed)}
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			counterBytes := make([]byte, 8)
+			binary.BigEndian.PutUint64(counterBytes, r.counter)
+			block := sha256.Sum256(append(append([]byte{}, r.seed...), counterBytes...))
+			r.buf = block[:]
+			r.counter++
+		}
+		copied := copy(p[n:], r.buf)
+		r.buf = r.buf[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+// normalizeSeed collapses the whitespace in a seed, so that a mnemonic phrase gives the same keys however it
+// is spaced or wrapped
+func normalizeSeed(seed string) string {
+	return strings.Join(strings.Fields(seed), " ")
+}
+
+// deriveSeed returns a seed for one purpose, from which the stack's seed cannot be recovered
+func deriveSeed(seed string, purpose string) string {
+	digest := sha256.Sum256([]byte(purpose + "\x00" + seed))
+	return hex.EncodeToString(digest[:])
+}
@@ -0,0 +1,38 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/simulator"
+)
+
+// SimulateMember runs a mock counterparty against the FireFly API of the given member until the stop channel is closed
+func (s *StackManager) SimulateMember(memberID string, replyText string, pollInterval time.Duration, stop <-chan struct{}) error {
+	member, err := s.getMember(memberID)
+	if err != nil {
+		return err
+	}
+	sim := &simulator.Simulator{
+		Log:          s.Log,
+		Member:       member,
+		PollInterval: pollInterval,
+		ReplyText:    replyText,
+	}
+	return sim.Run(stop)
+}
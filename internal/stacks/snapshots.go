@@ -0,0 +1,170 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+)
+
+var snapshotNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+const snapshotExtension = ".tar.gz"
+
+// Snapshot is a named checkpoint of the data of a stack, kept in the snapshots directory of the stack
+type Snapshot struct {
+	Name    string    `json:"name" yaml:"name"`
+	Created time.Time `json:"created" yaml:"created"`
+	Size    int64     `json:"size" yaml:"size"`
+}
+
+func (s *StackManager) snapshotsDir() string {
+	return filepath.Join(constants.StacksDir, s.Stack.Name, "snapshots")
+}
+
+func (s *StackManager) snapshotFile(name string) (string, error) {
+	if !snapshotNameRegex.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name '%s' - use letters, numbers, '.', '_' and '-'", name)
+	}
+	return filepath.Join(s.snapshotsDir(), name+snapshotExtension), nil
+}
+
+// CreateSnapshot saves the data of the stack as a named snapshot. A running stack is stopped while the
+// snapshot is taken, and started again afterwards.
+func (s *StackManager) CreateSnapshot(name string, verbose bool) error {
+	if err := s.checkNotArchived(); err != nil {
+		return err
+	}
+	filename, err := s.snapshotFile(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("snapshot '%s' of stack '%s' already exists", name, s.Stack.Name)
+	}
+	if err := os.MkdirAll(s.snapshotsDir(), 0755); err != nil {
+		return err
+	}
+	return s.whileStopped(verbose, func() error {
+		tmp, err := ioutil.TempFile(s.snapshotsDir(), ".snapshot-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if err := s.BackupStack(tmp, verbose); err != nil {
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), filename)
+	})
+}
+
+// RestoreSnapshot replaces the data of the stack with a snapshot. A running stack is stopped while the
+// snapshot is restored, and started again afterwards.
+func (s *StackManager) RestoreSnapshot(name string, verbose bool) error {
+	if err := s.checkNotArchived(); err != nil {
+		return err
+	}
+	filename, err := s.snapshotFile(name)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("snapshot '%s' of stack '%s' does not exist", name, s.Stack.Name)
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.whileStopped(verbose, func() error {
+		return s.RestoreStack(f, verbose)
+	})
+}
+
+// ListSnapshots returns the snapshots of the stack, oldest first
+func (s *StackManager) ListSnapshots() ([]*Snapshot, error) {
+	files, err := ioutil.ReadDir(s.snapshotsDir())
+	if os.IsNotExist(err) {
+		return []*Snapshot{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	snapshots := make([]*Snapshot, 0, len(files))
+	for _, f := range files {
+		name := strings.TrimSuffix(f.Name(), snapshotExtension)
+		if f.IsDir() || name == f.Name() || !snapshotNameRegex.MatchString(name) {
+			continue
+		}
+		snapshots = append(snapshots, &Snapshot{
+			Name:    name,
+			Created: f.ModTime(),
+			Size:    f.Size(),
+		})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Created.Before(snapshots[j].Created)
+	})
+	return snapshots, nil
+}
+
+func (s *StackManager) DeleteSnapshot(name string) error {
+	filename, err := s.snapshotFile(name)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filename)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("snapshot '%s' of stack '%s' does not exist", name, s.Stack.Name)
+	}
+	return err
+}
+
+// whileStopped runs fn with the stack stopped, starting the stack again afterwards if it was running
+func (s *StackManager) whileStopped(verbose bool, fn func() error) error {
+	if err := s.checkOperation("change", verbose); err != nil {
+		return err
+	}
+	running, err := s.IsRunning(verbose)
+	if err != nil {
+		return err
+	}
+	if running {
+		s.Log.Info(fmt.Sprintf("stopping stack '%s'", s.Stack.Name))
+		if err := s.StopStack(verbose); err != nil {
+			return err
+		}
+	}
+	err = fn()
+	if running {
+		s.Log.Info(fmt.Sprintf("starting stack '%s' again", s.Stack.Name))
+		if startErr := s.StartStack(false, verbose, &StartOptions{}); startErr != nil && err == nil {
+			err = startErr
+		}
+	}
+	return err
+}
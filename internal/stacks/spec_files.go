@@ -0,0 +1,118 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ReadStackSpecFiles reads a stack spec made up of a base file and any number of overlays, filling in the same
+// defaults as the init command. Each file is first rendered as a Go template with the variables, so {{ .name }}
+// is replaced with the value of the variable "name". The files are then merged in order: maps are merged key by
+// key, any other value in a later file replaces the value in an earlier one, and a key set to null is removed.
+func ReadStackSpecFiles(filenames []string, vars map[string]interface{}) (*StackSpec, error) {
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("no spec file specified")
+	}
+	merged := map[interface{}]interface{}{}
+	for _, filename := range filenames {
+		d, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if d, err = renderSpecTemplate(filename, d, vars); err != nil {
+			return nil, err
+		}
+		var overlay map[interface{}]interface{}
+		if err := yaml.Unmarshal(d, &overlay); err != nil {
+			return nil, fmt.Errorf("invalid stack spec %s: %s", filename, err)
+		}
+		mergeSpecMaps(merged, overlay)
+	}
+	d, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStackSpec(d, strings.Join(filenames, " + "))
+}
+
+func renderSpecTemplate(filename string, d []byte, vars map[string]interface{}) ([]byte, error) {
+	if !bytes.Contains(d, []byte("{{")) {
+		return d, nil
+	}
+	t, err := template.New(filename).Option("missingkey=error").Parse(string(d))
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack spec %s: %s", filename, err)
+	}
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, vars); err != nil {
+		return nil, fmt.Errorf("invalid stack spec %s: %s - set each variable the spec uses with --set or --values", filename, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func mergeSpecMaps(base, overlay map[interface{}]interface{}) {
+	for k, v := range overlay {
+		if v == nil {
+			delete(base, k)
+			continue
+		}
+		baseMap, baseIsMap := base[k].(map[interface{}]interface{})
+		overlayMap, overlayIsMap := v.(map[interface{}]interface{})
+		if baseIsMap && overlayIsMap {
+			mergeSpecMaps(baseMap, overlayMap)
+		} else {
+			base[k] = v
+		}
+	}
+}
+
+// ReadSpecVariables reads the variables for stack spec templates from YAML files of names and values, followed
+// by any settings of the form name=value, each of which overrides the value of the variable from the files
+func ReadSpecVariables(filenames []string, settings []string) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for _, filename := range filenames {
+		d, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		var fileVars map[string]interface{}
+		if err := yaml.Unmarshal(d, &fileVars); err != nil {
+			return nil, fmt.Errorf("invalid variables file %s: %s", filename, err)
+		}
+		for k, v := range fileVars {
+			vars[k] = v
+		}
+	}
+	for _, setting := range settings {
+		parts := strings.SplitN(setting, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid variable '%s' - expected name=value", setting)
+		}
+		vars[parts[0]] = parts[1]
+	}
+	return vars, nil
+}
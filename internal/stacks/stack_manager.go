@@ -17,6 +17,7 @@
 package stacks
 
 import (
+	"crypto/rand"
 	_ "embed"
 	"encoding/hex"
 	"encoding/json"
@@ -28,21 +29,33 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"syscall"
 	"time"
 
-	secp256k1 "github.com/btcsuite/btcd/btcec"
 	"github.com/hyperledger/firefly-cli/internal/blockchain"
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
 	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum/besu"
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum/ethconnect"
 	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum/geth"
+	"github.com/hyperledger/firefly-cli/internal/certs"
 	"github.com/hyperledger/firefly-cli/internal/constants"
 	"github.com/hyperledger/firefly-cli/internal/core"
 	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/edge"
+	"github.com/hyperledger/firefly-cli/internal/eventbridge"
+	"github.com/hyperledger/firefly-cli/internal/keychain"
+	"github.com/hyperledger/firefly-cli/internal/monitoring"
+	"github.com/hyperledger/firefly-cli/internal/notify"
+	"github.com/hyperledger/firefly-cli/internal/sandbox"
+	"github.com/hyperledger/firefly-cli/internal/secrets"
 	"github.com/hyperledger/firefly-cli/internal/tokens"
 	"github.com/hyperledger/firefly-cli/internal/tokens/erc1155"
+	"github.com/hyperledger/firefly-cli/internal/tokens/erc20erc721"
 	"github.com/hyperledger/firefly-cli/internal/tokens/niltokens"
+	"github.com/hyperledger/firefly-cli/internal/tracing"
+	"github.com/hyperledger/firefly-cli/internal/webhookrelay"
 	"github.com/hyperledger/firefly-cli/pkg/types"
-	"golang.org/x/crypto/sha3"
 
 	"gopkg.in/yaml.v2"
 
@@ -52,15 +65,30 @@ import (
 type StackManager struct {
 	Log                log.Logger
 	Stack              *types.Stack
+	passphrase         string
+	useKeychain        bool
 	blockchainProvider blockchain.IBlockchainProvider
-	tokensProvider     tokens.ITokensProvider
+	tokensProviders    []tokens.ITokensProvider
 }
 
 type StartOptions struct {
 	NoPull     bool
 	NoRollback bool
+	// Pull policy for this start, instead of the policy of the stack
+	PullPolicy string
+	// Number of images pulled at once before first start - DefaultPullConcurrency when not set
+	PullConcurrency int
+	// Whether to wait for the FireFly node of each member to be ready, and for how long (zero waits forever)
+	Wait        bool
+	WaitTimeout time.Duration
 }
 
+const DefaultPullConcurrency = 4
+
+// GatewaySetupMarker is the file in the data directory of a gateway stack that records it has been set up,
+// as it has no data exchange certificates to show this
+const GatewaySetupMarker = "gateway_setup"
+
 type InitOptions struct {
 	FireFlyBasePort    int
 	ServicesBasePort   int
@@ -68,26 +96,83 @@ type InitOptions struct {
 	Verbose            bool
 	ExternalProcesses  int
 	BlockchainProvider BlockchainProvider
-	TokensProvider     TokensProvider
+	TokensProviders    []TokensProvider
+	EventBridge        EventBridgeSelection
+	// Port of an app on the host which FireFly webhooks should be relayed to
+	WebhookRelayTargetPort int
+	Monitoring             bool
+	Domain                 string
+	EdgePort               int
+	// If set, the JSON-RPC and WebSocket endpoints of the blockchain nodes are published from this port upwards
+	NodeRPCPort int
+	// Release whose component versions are pinned in the stack - "stable", "head" or "vX.Y.Z"
+	Release string
+	// Images to run instead of the default (or release) image of a component, keyed by component
+	ImageOverrides map[string]string
+	Registry       *types.RegistryConfig
+	// HTTP proxy the services and the CLI reach the internet through
+	Proxy *types.ProxyConfig
+	// YAML file of FireFly core config to merge into the generated config of each member
+	CoreConfig string
+	// Tuning of the ethconnect of each member, and a YAML file of ethconnect config to merge into it
+	Ethconnect       *types.EthconnectConfig
+	EthconnectConfig string
+	// If set, all keys are derived from the seed so that the same stack is generated every time
+	Seed string
+	// If set, the stack's keys and credentials are encrypted at rest with this passphrase
+	Passphrase string
+	// If set, a passphrase is generated and stored in the OS keychain, and used to encrypt the stack
+	UseKeychain bool
+	// If set, services are configured to only use FIPS-approved crypto wherever they support it
+	FIPS bool
+	// If set, all members use one IPFS node instead of a node each
+	SharedIPFS bool
+	// Settings applied to the config of the IPFS nodes, if any
+	IPFS *types.IPFSConfig
+	// Whether the members share one blockchain node, or each run their own
+	BlockchainNodes BlockchainNodeTopology
+	// What signs the members' transactions - their blockchain node, or an external signer holding their keys
+	Signer SignerSelection
+	// If set, the FireFly APIs are served over HTTPS, with certificates issued by a CA generated for the stack
+	TLS bool
+	// If set, each member is a single-org gateway, without data exchange or multiparty networking
+	Gateway bool
+	// If set, FireFly core does not serve the FireFly UI
+	NoUI bool
+	// Directory of a custom build of the FireFly UI, served by FireFly core instead of its bundled UI
+	UIPath string
+	// If set, the FireFly Sandbox is run for each member, or only for the first member if SharedSandbox is set
+	Sandbox       bool
+	SharedSandbox bool
+	// Whether the FireFly APIs of each member require credentials
+	APIAuth APIAuthSelection
+	// Memory and CPU limits, by service name or prefix, or types.AllServices
+	ResourceLimits map[string]*types.ResourceLimits
+	// If set, the stack is moved to a free block of ports if its ports are reserved by another stack
+	AutoAdjustPorts bool
+	// External PostgreSQL servers to use instead of database containers - one for each member, or one shared by all
+	PostgresURLs []string
+	// If set, each member's database container is followed by a read replica, which applies changes after the lag
+	PostgresReplicas   bool
+	PostgresReplicaLag time.Duration
+	// Accounts and contracts to allocate in the genesis block
+	GenesisAccounts []*types.GenesisAccount
+	// Number of accounts to generate and fund in the genesis block, beyond the members' own accounts, and the
+	// amount of wei each is funded with (the default balance if empty)
+	PrefundedAccounts int
+	InitialBalance    string
+	// Parameters of the chain, to mimic a target network - the defaults are used where they are zero
+	ChainID     int
+	BlockPeriod int
+	GasLimit    uint64
+	// JSON file of genesis parameters to merge into the generated genesis file
+	GenesisFragment string
+	// When images are pulled before the stack is started, or empty to pull them on first start only
+	PullPolicy string
 }
 
 func ListStacks() ([]string, error) {
-	files, err := ioutil.ReadDir(constants.StacksDir)
-	if err != nil {
-		return nil, err
-	}
-
-	stacks := make([]string, 0)
-	i := 0
-	for _, f := range files {
-		if f.IsDir() {
-			if exists, err := CheckExists(f.Name()); err == nil && exists {
-				stacks = append(stacks, f.Name())
-				i++
-			}
-		}
-	}
-	return stacks, nil
+	return stateStore.List()
 }
 
 func NewStackManager(logger log.Logger) *StackManager {
@@ -97,26 +182,339 @@ func NewStackManager(logger log.Logger) *StackManager {
 }
 
 func (s *StackManager) InitStack(stackName string, memberCount int, options *InitOptions) error {
+	if err := s.createStack(stackName, memberCount, options); err != nil {
+		return err
+	}
+	if offline {
+		if err := s.checkOfflineImages(options.Verbose); err != nil {
+			return err
+		}
+	}
+	// Hold the port registry lock until the stack exists, so that stacks created at the same time get different ports
+	unlock, err := lockPortRegistry()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	registry, err := readPortRegistry()
+	if err != nil {
+		return err
+	}
+	if err := s.allocatePorts(registry, options.AutoAdjustPorts); err != nil {
+		return err
+	}
+	if options.CoreConfig != "" {
+		if err := s.copyConfigOverrides(options.CoreConfig, coreConfigOverridesFile); err != nil {
+			return err
+		}
+	}
+	if options.EthconnectConfig != "" {
+		if err := s.copyConfigOverrides(options.EthconnectConfig, ethconnect.ConfigOverridesFile); err != nil {
+			return err
+		}
+	}
+	if err := s.writeStackFiles(options.Verbose); err != nil {
+		return err
+	}
+	return registry.write()
+}
+
+// createStack builds the stack definition in memory, without writing any files
+func (s *StackManager) createStack(stackName string, memberCount int, options *InitOptions) error {
+	random := rand.Reader
+	certificateSeed := ""
+	if options.Seed != "" {
+		seed := normalizeSeed(options.Seed)
+		random = newSeededReader(seed)
+		certificateSeed = deriveSeed(seed, "certificates")
+	}
 	s.Stack = &types.Stack{
 		Name:                  stackName,
+		Version:               currentStackVersion,
 		Members:               make([]*types.Member, memberCount),
-		SwarmKey:              GenerateSwarmKey(),
+		SwarmKey:              GenerateSwarmKey(random),
+		CertificateSeed:       certificateSeed,
 		ExposedBlockchainPort: options.ServicesBasePort,
 		Database:              options.DatabaseSelection.String(),
 		BlockchainProvider:    options.BlockchainProvider.String(),
-		TokensProvider:        options.TokensProvider.String(),
+		TokensProviders:       make([]string, len(options.TokensProviders)),
+		FIPS:                  options.FIPS,
+		SharedIPFS:            options.SharedIPFS,
+		TLS:                   options.TLS,
+		PullPolicy:            options.PullPolicy,
+		State:                 StateCreated,
+	}
+
+	for i, tokensProvider := range options.TokensProviders {
+		s.Stack.TokensProviders[i] = tokensProvider.String()
+	}
+
+	if options.BlockchainNodes == BlockchainNodePerMember {
+		if options.BlockchainProvider != GoEthereum {
+			return fmt.Errorf("a blockchain node per member is only supported by the %s blockchain provider", GoEthereum)
+		}
+		s.Stack.BlockchainNodes = BlockchainNodePerMember.String()
+	}
+
+	if options.Signer != NodeSigner {
+		if options.BlockchainProvider != GoEthereum {
+			return fmt.Errorf("external signers are only supported by the %s blockchain provider", GoEthereum)
+		}
+		s.Stack.Signer = options.Signer.String()
+		if options.Signer == VaultSigner {
+			token, err := generateVaultToken(random)
+			if err != nil {
+				return err
+			}
+			s.Stack.VaultToken = token
+		}
+	}
+
+	s.Stack.Gateway = options.Gateway
+
+	if options.NoUI && options.UIPath != "" {
+		return fmt.Errorf("a custom UI cannot be served when the UI is disabled")
+	}
+	s.Stack.NoUI = options.NoUI
+	if options.UIPath != "" {
+		uiPath, err := filepath.Abs(options.UIPath)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(uiPath); err != nil || !info.IsDir() {
+			return fmt.Errorf("the UI path %s is not a directory", uiPath)
+		}
+		if _, err := os.Stat(filepath.Join(uiPath, "index.html")); err != nil {
+			return fmt.Errorf("the UI path %s is not a build of the FireFly UI, as it has no index.html", uiPath)
+		}
+		s.Stack.UIPath = uiPath
+	}
+
+	if options.SharedSandbox {
+		options.Sandbox = true
+	}
+	if options.Sandbox {
+		if options.APIAuth != NoAPIAuth {
+			return fmt.Errorf("the FireFly Sandbox does not support API auth")
+		}
+		if options.ExternalProcesses >= memberCount {
+			return fmt.Errorf("the FireFly Sandbox needs at least one member whose FireFly core runs in docker")
+		}
+		s.Stack.Sandbox = true
+		s.Stack.SharedSandbox = options.SharedSandbox
+	}
+
+	if options.NodeRPCPort != 0 {
+		if options.BlockchainProvider != GoEthereum {
+			return fmt.Errorf("exposing the blockchain node RPC is only supported by the %s blockchain provider", GoEthereum)
+		}
+		if s.Stack.BlockchainNodes != BlockchainNodePerMember.String() {
+			s.Stack.ExposedNodeRPCPort = options.NodeRPCPort
+			s.Stack.ExposedNodeWSPort = options.NodeRPCPort + 1
+		}
+	}
+
+	if dockerHost, dockerContext := docker.GetRemoteDaemon(); dockerHost != "" {
+		if options.EventBridge != NoEventBridge || options.WebhookRelayTargetPort != 0 || options.Monitoring || options.Domain != "" {
+			return fmt.Errorf("the event bridge, webhook relay, monitoring and custom domains are not supported on a remote docker host (%s)", dockerHost)
+		}
+		if options.UIPath != "" {
+			return fmt.Errorf("a custom UI cannot be mounted from this machine into containers on a remote docker host (%s)", dockerHost)
+		}
+		s.Stack.DockerHost = dockerHost
+		s.Stack.DockerContext = dockerContext
+		s.Stack.Hostname = docker.GetDaemonHostname(dockerHost)
+		docker.DockerHost = s.Stack.DockerHost
+		docker.DockerContext = s.Stack.DockerContext
+		s.Log.Info(fmt.Sprintf("creating stack on remote docker host %s", dockerHost))
+	}
+
+	if options.EventBridge != NoEventBridge {
+		s.Stack.EventBridge = options.EventBridge.String()
+		// Stack-wide services are allocated ports from the top half of the first member's range
+		s.Stack.ExposedEventBrokerPort = options.ServicesBasePort + 50
+	}
+
+	if options.WebhookRelayTargetPort != 0 {
+		s.Stack.WebhookRelayTargetPort = options.WebhookRelayTargetPort
+		s.Stack.ExposedWebhookRelayPort = options.ServicesBasePort + 51
+	}
+
+	if options.Monitoring {
+		s.Stack.ExposedPrometheusPort = options.ServicesBasePort + 52
+		s.Stack.ExposedGrafanaPort = options.ServicesBasePort + 53
+	}
+
+	if options.Domain != "" {
+		domain, err := edge.ValidateDomain(options.Domain)
+		if err != nil {
+			return err
+		}
+		s.Stack.Domain = domain
+		s.Stack.ExposedEdgePort = options.EdgePort
+	}
+
+	if options.Passphrase != "" || options.UseKeychain {
+		if err := s.setupEncryption(options); err != nil {
+			return err
+		}
+	}
+
+	if options.CoreConfig != "" {
+		if _, err := core.ReadFireflyConfigOverrides(options.CoreConfig); err != nil {
+			return fmt.Errorf("failed to read FireFly core config overrides: %s", err)
+		}
+	}
+	if options.Ethconnect != nil || options.EthconnectConfig != "" {
+		if options.BlockchainProvider != GoEthereum {
+			return fmt.Errorf("ethconnect is only used by the %s blockchain provider", GoEthereum)
+		}
+		if options.EthconnectConfig != "" {
+			if _, err := ethconnect.ReadConfigOverrides(options.EthconnectConfig); err != nil {
+				return fmt.Errorf("failed to read ethconnect config overrides: %s", err)
+			}
+		}
+		s.Stack.Ethconnect = options.Ethconnect
+	}
+
+	if options.IPFS != nil {
+		if err := validateIPFSConfig(options.IPFS); err != nil {
+			return err
+		}
+		s.Stack.IPFS = options.IPFS
+	}
+
+	if options.Proxy != nil && (options.Proxy.HTTP != "" || options.Proxy.HTTPS != "") {
+		s.Stack.Proxy = options.Proxy
+	}
+	if err := core.SetProxy(s.Stack.Proxy); err != nil {
+		return fmt.Errorf("invalid proxy: %s", err)
+	}
+
+	if options.Release != "" {
+		s.Log.Info(fmt.Sprintf("resolving component versions for release %s", options.Release))
+		manifest, err := getReleaseManifest(options.Release)
+		if err != nil {
+			return err
+		}
+		s.Stack.VersionManifest = manifest
+		if options.Gateway && options.ImageOverrides[types.FireFlyComponent] == "" && manifest.FireFly != nil && core.IsVersionBefore(manifest.FireFly.Tag, "v1.1.0") {
+			return fmt.Errorf("gateway mode requires FireFly v1.1.0 or later, but release %s has FireFly %s", options.Release, manifest.FireFly.Tag)
+		}
+	}
+
+	if options.Registry != nil && options.Registry.URL != "" {
+		for _, value := range []string{options.Registry.Username, options.Registry.Password} {
+			if _, err := secrets.Resolve(value); err != nil {
+				return err
+			}
+		}
+		s.Stack.Registry = options.Registry
+		docker.UtilityImage = s.Stack.MirrorImage("alpine")
+	}
+
+	for component, image := range options.ImageOverrides {
+		if image != "" {
+			if s.Stack.ImageOverrides == nil {
+				s.Stack.ImageOverrides = make(map[string]string)
+			}
+			s.Stack.ImageOverrides[component] = image
+		}
 	}
 
 	s.blockchainProvider = s.getBlockchainProvider(false)
-	s.tokensProvider = s.getTokensProvider(false)
+	s.tokensProviders = s.getTokensProviders(false)
 
 	for i := 0; i < memberCount; i++ {
 		externalProcess := i < options.ExternalProcesses
-		s.Stack.Members[i] = createMember(fmt.Sprint(i), i, options, externalProcess)
+		s.Stack.Members[i] = createMember(fmt.Sprint(i), i, options, externalProcess, random)
+		s.Stack.Members[i].Hostname = s.Stack.Hostname
+		s.Stack.Members[i].TLS = s.Stack.TLS
+		if options.APIAuth != NoAPIAuth {
+			if err := generateAPICredentials(s.Stack.Members[i], random); err != nil {
+				return err
+			}
+		}
+	}
+	if options.APIAuth != NoAPIAuth {
+		s.Stack.APIAuth = options.APIAuth.String()
+	}
+
+	if len(options.PostgresURLs) > 0 {
+		s.Stack.Database = PostgreSQL.String()
+		if err := useExternalPostgres(s.Stack, options.PostgresURLs); err != nil {
+			return err
+		}
+	}
+
+	if options.PrefundedAccounts > 0 {
+		if options.BlockchainProvider != GoEthereum {
+			return fmt.Errorf("prefunded accounts are only supported by the %s blockchain provider", GoEthereum)
+		}
+		if err := s.createPrefundedAccounts(options.PrefundedAccounts, options.InitialBalance, random); err != nil {
+			return err
+		}
+	} else if options.InitialBalance != "" {
+		return fmt.Errorf("an initial balance can only be given with prefunded accounts")
+	}
+
+	if options.ChainID != 0 || options.BlockPeriod != 0 || options.GasLimit != 0 || options.GenesisFragment != "" {
+		if options.BlockchainProvider != GoEthereum {
+			return fmt.Errorf("chain parameters are only supported by the %s blockchain provider", GoEthereum)
+		}
+		if err := s.setChainParams(options); err != nil {
+			return err
+		}
+	}
+
+	if len(options.GenesisAccounts) > 0 {
+		if options.BlockchainProvider != GoEthereum {
+			return fmt.Errorf("genesis accounts are only supported by the %s blockchain provider", GoEthereum)
+		}
+		if err := s.setGenesisAccounts(options.GenesisAccounts); err != nil {
+			return err
+		}
+	}
+
+	if options.PostgresReplicas {
+		s.Stack.PostgresReplicas = true
+		if options.PostgresReplicaLag > 0 {
+			s.Stack.PostgresReplicaLag = options.PostgresReplicaLag.String()
+		}
+	}
+
+	if len(options.ResourceLimits) > 0 {
+		s.Stack.ResourceLimits = options.ResourceLimits
+		if err := s.checkResourceLimits(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStackFiles (re)generates the docker compose file and all configuration files from the stack definition
+func (s *StackManager) writeStackFiles(verbose bool) error {
+	if err := s.checkGenerated(); err != nil {
+		return err
 	}
+	if err := s.ensureDirectories(); err != nil {
+		return err
+	}
+	if err := s.writeDockerCompose(s.buildDockerCompose()); err != nil {
+		return fmt.Errorf("failed to write docker-compose.yml: %s", err)
+	}
+	if err := s.writeMakefile(); err != nil {
+		return fmt.Errorf("failed to write Makefile: %s", err)
+	}
+	return s.writeConfigs(verbose)
+}
+
+func (s *StackManager) buildDockerCompose() *docker.DockerComposeConfig {
 	compose := docker.CreateDockerCompose(s.Stack)
 	extraServices := s.blockchainProvider.GetDockerServiceDefinitions()
-	extraServices = append(extraServices, s.tokensProvider.GetDockerServiceDefinitions()...)
+	for _, tokensProvider := range s.tokensProviders {
+		extraServices = append(extraServices, tokensProvider.GetDockerServiceDefinitions()...)
+	}
 
 	for _, serviceDefinition := range extraServices {
 		// Add each service definition to the docker compose file
@@ -138,17 +536,76 @@ func (s *StackManager) InitStack(stackName string, memberCount int, options *Ini
 		}
 	}
 
-	if err := s.ensureDirectories(); err != nil {
-		return err
+	// FireFly core does not depend on these services (the event bridge depends on FireFly core instead)
+	optionalServices := eventbridge.GetDockerServiceDefinitions(s.Stack)
+	optionalServices = append(optionalServices, webhookrelay.GetDockerServiceDefinitions(s.Stack)...)
+	optionalServices = append(optionalServices, monitoring.GetDockerServiceDefinitions(s.Stack)...)
+	optionalServices = append(optionalServices, edge.GetDockerServiceDefinitions(s.Stack)...)
+	optionalServices = append(optionalServices, sandbox.GetDockerServiceDefinitions(s.Stack)...)
+	for _, serviceDefinition := range optionalServices {
+		compose.Services[serviceDefinition.ServiceName] = serviceDefinition.Service
+		for _, volumeName := range serviceDefinition.VolumeNames {
+			compose.Volumes[volumeName] = struct{}{}
+		}
 	}
-	if err := s.writeDockerCompose(compose); err != nil {
-		return fmt.Errorf("failed to write docker-compose.yml: %s", err)
+
+	// Every service is reachable by its alias, which is what the generated configs refer to it by
+	for name, service := range compose.Services {
+		service.Networks = map[string]*docker.ServiceNetwork{
+			"default": {Aliases: []string{types.ServiceAlias(name)}},
+		}
+		if limits := s.Stack.GetResourceLimits(name); limits != nil {
+			service.MemLimit = limits.Memory
+			service.CPUs = limits.CPUs
+			// cpus was added in version 2.2 of the compose file format
+			compose.Version = "2.2"
+		}
+		if envVar, level := getLogLevelEnv(name, s.Stack.LogLevels[name]); envVar != "" {
+			if service.Environment == nil {
+				service.Environment = make(map[string]string)
+			}
+			service.Environment[envVar] = level
+		}
+	}
+	if s.Stack.Proxy != nil {
+		s.addProxyEnvironment(compose)
+	}
+	return compose
+}
+
+// addProxyEnvironment passes the stack's proxy to every service, which reach each other directly by
+// their service names and aliases
+func (s *StackManager) addProxyEnvironment(compose *docker.DockerComposeConfig) {
+	direct := make([]string, 0, len(compose.Services)+1)
+	for name := range compose.Services {
+		direct = append(direct, name)
+	}
+	sort.Strings(direct)
+	direct = append(direct, "."+types.InternalDomain)
+	env := s.Stack.Proxy.Environment(direct...)
+	for _, service := range compose.Services {
+		if service.Environment == nil {
+			service.Environment = make(map[string]string)
+		}
+		for name, value := range env {
+			if _, ok := service.Environment[name]; !ok {
+				service.Environment[name] = value
+			}
+		}
 	}
-	return s.writeConfigs(options.Verbose)
+}
+
+// GetServiceImages returns the image of each service in the stack, by service name
+func (s *StackManager) GetServiceImages() map[string]string {
+	images := make(map[string]string)
+	for name, service := range s.buildDockerCompose().Services {
+		images[name] = service.Image
+	}
+	return images
 }
 
 func CheckExists(stackName string) (bool, error) {
-	_, err := os.Stat(filepath.Join(constants.StacksDir, stackName, "stack.json"))
+	_, err := stateStore.Read(stackName)
 	if os.IsNotExist(err) {
 		return false, nil
 	} else if err != nil {
@@ -166,17 +623,36 @@ func (s *StackManager) LoadStack(stackName string) error {
 	if !exists {
 		return fmt.Errorf("stack '%s' does not exist", stackName)
 	}
-	fmt.Printf("reading stack config... ")
-	if d, err := ioutil.ReadFile(filepath.Join(constants.StacksDir, stackName, "stack.json")); err != nil {
+	s.Log.Info("reading stack config")
+	if d, err := stateStore.Read(stackName); err != nil {
 		return err
 	} else {
+		if encrypted := parseEncryptedFile(d); encrypted != nil {
+			if d, err = s.unlockStack(stackName, encrypted); err != nil {
+				return err
+			}
+		}
 		var stack *types.Stack
-		if err := json.Unmarshal(d, &stack); err == nil {
-			fmt.Printf("done\n")
+		if err := json.Unmarshal(d, &stack); err != nil {
+			return err
 		}
 		s.Stack = stack
+		migrateStack(s.Stack)
+		docker.UtilityImage = s.Stack.MirrorImage("alpine")
+		docker.DockerHost = s.Stack.DockerHost
+		docker.DockerContext = s.Stack.DockerContext
+		if err := core.SetProxy(s.Stack.Proxy); err != nil {
+			return fmt.Errorf("invalid proxy: %s", err)
+		}
 		s.blockchainProvider = s.getBlockchainProvider(false)
-		s.tokensProvider = s.getTokensProvider(false)
+		s.tokensProviders = s.getTokensProviders(false)
+		if s.Stack.TLS {
+			client, err := certs.NewClient(s.Stack)
+			if err != nil {
+				return fmt.Errorf("failed to trust the CA of the stack: %s", err)
+			}
+			core.SetStackClient(s.Stack, client)
+		}
 	}
 	return nil
 }
@@ -189,10 +665,15 @@ func (s *StackManager) ensureDirectories() error {
 	if err := os.MkdirAll(filepath.Join(stackDir, "configs"), 0755); err != nil {
 		return err
 	}
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return err
+	}
 
 	for _, member := range s.Stack.Members {
-		if err := os.MkdirAll(filepath.Join(dataDir, "dataexchange_"+member.ID, "peer-certs"), 0755); err != nil {
-			return err
+		if !s.Stack.Gateway {
+			if err := os.MkdirAll(filepath.Join(dataDir, "dataexchange_"+member.ID, "peer-certs"), 0755); err != nil {
+				return err
+			}
 		}
 		if err := os.MkdirAll(filepath.Join(stackDir, "blockchain", member.ID), 0755); err != nil {
 			return err
@@ -213,19 +694,19 @@ func (s *StackManager) writeDockerCompose(compose *docker.DockerComposeConfig) e
 }
 
 func (s *StackManager) writeConfigs(verbose bool) error {
-	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	if err := certs.WriteCertificates(s.Stack); err != nil {
+		return fmt.Errorf("failed to write TLS certificates: %s", err)
+	}
 
-	for _, member := range s.Stack.Members {
-		config := core.NewFireflyConfig(s.Stack, member)
-		config.Blockchain = s.blockchainProvider.GetFireflyConfig(member)
-		config.Tokens = s.tokensProvider.GetFireflyConfig(member)
-		if err := core.WriteFireflyConfig(config, filepath.Join(stackDir, "configs", fmt.Sprintf("firefly_core_%s.yml", member.ID))); err != nil {
-			return err
-		}
+	if err := s.writeAPIPasswordFiles(); err != nil {
+		return err
 	}
 
-	stackConfigBytes, _ := json.MarshalIndent(s.Stack, "", " ")
-	if err := ioutil.WriteFile(filepath.Join(stackDir, "stack.json"), stackConfigBytes, 0755); err != nil {
+	if err := s.writeFireflyCoreConfigs(); err != nil {
+		return err
+	}
+
+	if err := s.writeStackConfig(); err != nil {
 		return err
 	}
 
@@ -233,86 +714,253 @@ func (s *StackManager) writeConfigs(verbose bool) error {
 		return err
 	}
 
+	if err := eventbridge.WriteConfig(s.Stack); err != nil {
+		return err
+	}
+
+	if err := webhookrelay.WriteConfig(s.Stack); err != nil {
+		return err
+	}
+
+	if err := monitoring.WriteConfig(s.Stack); err != nil {
+		return err
+	}
+
+	if err := edge.WriteConfig(s.Stack); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+func (s *StackManager) writeStackConfig() error {
+	stackConfigBytes, _ := json.MarshalIndent(s.Stack, "", " ")
+	if s.Stack.Encrypted {
+		data, err := encryptData(stackConfigBytes, s.passphrase, s.useKeychain)
+		if err != nil {
+			return err
+		}
+		stackConfigBytes, _ = json.MarshalIndent(&encryptedFile{Encrypted: data, State: s.Stack.State}, "", " ")
+	}
+	return stateStore.Write(s.Stack.Name, stackConfigBytes)
+}
+
 func (s *StackManager) writeDataExchangeCerts(verbose bool) error {
-	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	if s.Stack.Gateway {
+		// Without data exchange certs, a marker records that the stack has been set up
+		return ioutil.WriteFile(filepath.Join(constants.StacksDir, s.Stack.Name, "data", GatewaySetupMarker), []byte{}, 0644)
+	}
 	for _, member := range s.Stack.Members {
+		if err := s.writeDataExchangeCert(member, verbose); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		memberDXDir := path.Join(stackDir, "data", "dataexchange_"+member.ID)
+func (s *StackManager) writeDataExchangeCert(member *types.Member, verbose bool) error {
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	memberDXDir := path.Join(stackDir, "data", "dataexchange_"+member.ID)
 
+	commonName := types.ServiceAlias("dataexchange_" + member.ID)
+	if s.Stack.CertificateSeed != "" {
+		// openssl cannot derive the key from a seed
+		if err := certs.WriteSelfSignedCertificate(memberDXDir, commonName, "member_"+member.ID, s.Stack.CertificateSeed); err != nil {
+			return err
+		}
+	} else {
 		// TODO: remove dependency on openssl here
-		opensslCmd := exec.Command("openssl", "req", "-new", "-x509", "-nodes", "-days", "365", "-subj", fmt.Sprintf("/CN=dataexchange_%s/O=member_%s", member.ID, member.ID), "-keyout", "key.pem", "-out", "cert.pem")
+		opensslCmd := exec.Command("openssl", "req", "-new", "-x509", "-nodes", "-days", "365", "-subj", fmt.Sprintf("/CN=%s/O=member_%s", commonName, member.ID), "-keyout", "key.pem", "-out", "cert.pem")
 		opensslCmd.Dir = filepath.Join(stackDir, "data", "dataexchange_"+member.ID)
 		if err := opensslCmd.Run(); err != nil {
 			return err
 		}
+	}
 
-		dataExchangeConfig := s.GenerateDataExchangeHTTPSConfig(member.ID)
-		configBytes, err := json.Marshal(dataExchangeConfig)
+	dataExchangeConfig := s.GenerateDataExchangeHTTPSConfig(member.ID)
+	configBytes, err := json.Marshal(dataExchangeConfig)
+	if err != nil {
+		return err
+	}
+	ioutil.WriteFile(path.Join(memberDXDir, "config.json"), configBytes, 0755)
+
+	// Copy files into docker volumes
+	volumeName := fmt.Sprintf("%s_dataexchange_%s", s.Stack.Name, member.ID)
+	docker.MkdirInVolume(volumeName, "peer-certs", verbose)
+	docker.CopyFileToVolume(volumeName, path.Join(memberDXDir, "config.json"), "/config.json", verbose)
+	docker.CopyFileToVolume(volumeName, path.Join(memberDXDir, "cert.pem"), "/cert.pem", verbose)
+	docker.CopyFileToVolume(volumeName, path.Join(memberDXDir, "key.pem"), "/key.pem", verbose)
+
+	// The plaintext key is only kept in the data exchange volume of an encrypted stack
+	if s.Stack.Encrypted {
+		keyBytes, err := ioutil.ReadFile(path.Join(memberDXDir, "key.pem"))
 		if err != nil {
 			return err
 		}
-		ioutil.WriteFile(path.Join(memberDXDir, "config.json"), configBytes, 0755)
+		if err := s.writeSensitiveFile(path.Join(memberDXDir, "key.pem"), keyBytes); err != nil {
+			return err
+		}
+		return os.Remove(path.Join(memberDXDir, "key.pem"))
+	}
+	return nil
+}
 
-		// Copy files into docker volumes
-		volumeName := fmt.Sprintf("%s_dataexchange_%s", s.Stack.Name, member.ID)
-		docker.MkdirInVolume(volumeName, "peer-certs", verbose)
-		docker.CopyFileToVolume(volumeName, path.Join(memberDXDir, "config.json"), "/config.json", verbose)
-		docker.CopyFileToVolume(volumeName, path.Join(memberDXDir, "cert.pem"), "/cert.pem", verbose)
-		docker.CopyFileToVolume(volumeName, path.Join(memberDXDir, "key.pem"), "/key.pem", verbose)
+func (s *StackManager) copyFireflyConfigToVolume(member *types.Member, verbose bool) error {
+	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	s.Log.Info(fmt.Sprintf("copying firefly.core to firefly_core_%s", member.ID))
+	volumeName := fmt.Sprintf("%s_firefly_core_%s", s.Stack.Name, member.ID)
+	// Secrets referred to by the config are only resolved in the copy in the volume
+	if err := secrets.CopyFileToVolume(volumeName, path.Join(workingDir, "configs", fmt.Sprintf("firefly_core_%s.yml", member.ID)), "/firefly.core", verbose); err != nil {
+		return err
+	}
+	if member.APIUsername != "" {
+		if err := docker.CopyFileToVolume(volumeName, core.GetPasswordFilePath(s.Stack, member), "/htpasswd", verbose); err != nil {
+			return err
+		}
+	}
+	if !member.TLS {
+		return nil
+	}
+	// The certificate is copied in along with the config, rather than mounted, so that it also works on a remote docker host
+	if err := docker.MkdirInVolume(volumeName, "tls", verbose); err != nil {
+		return err
+	}
+	certDir := certs.GetServiceCertDir(s.Stack, "firefly_core_"+member.ID)
+	for _, filename := range []string{"cert.pem", "key.pem"} {
+		if err := docker.CopyFileToVolume(volumeName, path.Join(certDir, filename), path.Join("tls", filename), verbose); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func createMember(id string, index int, options *InitOptions, external bool) *types.Member {
-	privateKey, _ := secp256k1.NewPrivateKey(secp256k1.S256())
-	privateKeyBytes := privateKey.Serialize()
-	encodedPrivateKey := "0x" + hex.EncodeToString(privateKeyBytes)
-	// Remove the "04" Suffix byte when computing the address. This byte indicates that it is an uncompressed public key.
-	publicKeyBytes := privateKey.PubKey().SerializeUncompressed()[1:]
-	// Take the hash of the public key to generate the address
-	hash := sha3.NewLegacyKeccak256()
-	hash.Write(publicKeyBytes)
-	// Ethereum addresses only use the lower 20 bytes, so toss the rest away
-	encodedAddress := "0x" + hex.EncodeToString(hash.Sum(nil)[12:32])
+// generateVaultToken generates the root token of the stack's Vault server
+func generateVaultToken(random io.Reader) (string, error) {
+	token := make([]byte, 16)
+	if _, err := io.ReadFull(random, token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
 
+func createMember(id string, index int, options *InitOptions, external bool, random io.Reader) *types.Member {
+	account := ethereum.GenerateAccountFromSource(random)
 	serviceBase := options.ServicesBasePort + (index * 100)
-	return &types.Member{
+	member := &types.Member{
 		ID:                      id,
 		Index:                   &index,
-		Address:                 encodedAddress,
-		PrivateKey:              encodedPrivateKey,
+		Address:                 account.Address,
+		PrivateKey:              account.PrivateKey,
 		ExposedFireflyPort:      options.FireFlyBasePort + index,
 		ExposedFireflyAdminPort: serviceBase + 1, // note shared blockchain node is on zero
 		ExposedEthconnectPort:   serviceBase + 2,
-		ExposedUIPort:           serviceBase + 3,
 		ExposedPostgresPort:     serviceBase + 4,
-		ExposedDataexchangePort: serviceBase + 5,
 		ExposedIPFSApiPort:      serviceBase + 6,
 		ExposedIPFSGWPort:       serviceBase + 7,
-		ExposedTokensPort:       serviceBase + 8,
+		ExposedTokensPorts:      getTokensPorts(options.TokensProviders, serviceBase),
 		External:                external,
 	}
+	if !options.NoUI {
+		member.ExposedUIPort = serviceBase + 3
+	}
+	if !options.Gateway {
+		member.ExposedDataexchangePort = serviceBase + 5
+	}
+	if options.PostgresReplicas {
+		member.ExposedPostgresReplicaPort = serviceBase + 9
+	}
+	// A shared sandbox is run for the first member whose FireFly core runs in docker
+	if options.Sandbox && !external && (!options.SharedSandbox || index == options.ExternalProcesses) {
+		member.ExposedSandboxPort = serviceBase + 10
+	}
+	if options.Signer != NodeSigner {
+		// The member's key is only held by its signer, so its node seals blocks with a key of its own
+		sealer := ethereum.GenerateAccountFromSource(random)
+		member.SealerAddress = sealer.Address
+		member.SealerPrivateKey = sealer.PrivateKey
+	}
+	if options.BlockchainNodes == BlockchainNodePerMember {
+		// The member's own node takes the port of the shared node, so the first member's node is on the stack's blockchain port
+		member.ExposedBlockchainPort = serviceBase
+		member.NodeKey = ethereum.GenerateAccountFromSource(random).PrivateKey
+		if options.NodeRPCPort != 0 {
+			member.ExposedNodeRPCPort = options.NodeRPCPort + (index * 2)
+			member.ExposedNodeWSPort = options.NodeRPCPort + (index * 2) + 1
+		}
+	}
+	return member
+}
+
+// getTokensPorts allocates a port for each tokens connector of a member, from the eighth port of the
+// member's block of service ports onwards in steps of ten
+func getTokensPorts(providers []TokensProvider, serviceBase int) map[string]int {
+	ports := make(map[string]int)
+	for i, provider := range providers {
+		if provider != NilTokens {
+			ports[provider.String()] = serviceBase + 8 + (i * 10)
+		}
+	}
+	return ports
 }
 
 func (s *StackManager) StartStack(fancyFeatures bool, verbose bool, options *StartOptions) error {
-	fmt.Printf("starting FireFly stack '%s'... ", s.Stack.Name)
+	if err := s.checkOperation("start", verbose); err != nil {
+		return err
+	}
+	if err := s.setState(StateStarting, nil); err != nil {
+		return err
+	}
+	runBefore, _ := s.StackHasRunBefore()
+	err := s.startStack(verbose, options)
+	if err == nil && options.Wait {
+		err = s.WaitForReady(options.WaitTimeout)
+	}
+	state := StateRunning
+	if err != nil {
+		state = StateError
+	}
+	if stateErr := s.setState(state, err); stateErr != nil && err == nil {
+		err = stateErr
+	}
+	if err != nil {
+		s.notify(notify.StartFailed, fmt.Sprintf("stack '%s' failed to start", s.Stack.Name), err)
+	} else if !runBefore {
+		s.notify(notify.Started, fmt.Sprintf("stack '%s' started for the first time", s.Stack.Name), nil)
+	} else {
+		s.notify(notify.Started, fmt.Sprintf("stack '%s' started", s.Stack.Name), nil)
+	}
+	return err
+}
+
+func (s *StackManager) startStack(verbose bool, options *StartOptions) error {
+	if err := s.checkNotArchived(); err != nil {
+		return err
+	}
+	s.Log.Info(fmt.Sprintf("starting FireFly stack '%s'", s.Stack.Name))
 	// Check to make sure all of our ports are available
 	if err := s.checkPortsAvailable(); err != nil {
 		return err
 	}
+	if err := s.loginToRegistry(verbose); err != nil {
+		return err
+	}
+	if err := s.applyCoreConfigOverrides(verbose); err != nil {
+		return err
+	}
+	if err := s.applyEthconnectConfig(verbose); err != nil {
+		return err
+	}
 	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
 	if hasBeenRun, err := s.StackHasRunBefore(); !hasBeenRun && err == nil {
 		if err := s.runFirstTimeSetup(verbose, options); err != nil {
+			err = s.withContainerProblems(err, verbose)
 			// Something bad happened during setup
 			if options.NoRollback {
 				return err
 			} else {
 				// Rollback changes
 				s.Log.Error(fmt.Errorf("an error occurred - rolling back changes"))
-				resetErr := s.ResetStack(verbose)
+				resetErr := s.resetStack(verbose)
 
 				var finalErr error
 
@@ -328,19 +976,48 @@ func (s *StackManager) StartStack(fancyFeatures bool, verbose bool, options *Sta
 
 		return nil
 	} else if err == nil {
-		return s.runStartupSequence(workingDir, verbose, false)
+		if err := s.ensureImages(workingDir, verbose, false, options); err != nil {
+			return err
+		}
+		if err := s.runStartupSequence(workingDir, verbose, false); err != nil {
+			return s.withContainerProblems(err, verbose)
+		}
+		return nil
 	} else {
 		return err
 	}
 }
 
+// loginToRegistry authenticates docker with the stack's private registry, if it has one with credentials
+func (s *StackManager) loginToRegistry(verbose bool) error {
+	if offline || s.Stack.Registry == nil || s.Stack.Registry.Username == "" {
+		return nil
+	}
+	s.Log.Info(fmt.Sprintf("logging in to %s", s.Stack.Registry.Host()))
+	username, err := secrets.Resolve(s.Stack.Registry.Username)
+	if err != nil {
+		return err
+	}
+	password, err := secrets.Resolve(s.Stack.Registry.Password)
+	if err != nil {
+		return err
+	}
+	return docker.Login(s.Stack.Registry.Host(), username, password, verbose)
+}
+
 func (s *StackManager) runStartupSequence(workingDir string, verbose bool, firstTimeSetup bool) error {
+	// The blockchain of an adopted deployment is set up by its own compose file
+	if s.Stack.AdoptedFrom != "" {
+		s.Log.Info("starting adopted deployment")
+		return s.composeUp(workingDir, verbose)
+	}
+
 	if err := s.blockchainProvider.PreStart(); err != nil {
 		return err
 	}
 
 	s.Log.Info("starting FireFly dependencies")
-	if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, "up", "-d"); err != nil {
+	if err := s.composeUp(workingDir, verbose); err != nil {
 		return err
 	}
 
@@ -355,44 +1032,151 @@ func (s *StackManager) runStartupSequence(workingDir string, verbose bool, first
 }
 
 func (s *StackManager) StopStack(verbose bool) error {
-	return docker.RunDockerComposeCommand(filepath.Join(constants.StacksDir, s.Stack.Name), verbose, verbose, "stop")
+	if err := docker.RunDockerComposeCommand(filepath.Join(constants.StacksDir, s.Stack.Name), verbose, verbose, "stop"); err != nil {
+		return err
+	}
+	if err := s.setState(StateStopped, nil); err != nil {
+		return err
+	}
+	s.notify(notify.Stopped, fmt.Sprintf("stack '%s' stopped", s.Stack.Name), nil)
+	return nil
+}
+
+// GetLogs returns the last lines of the logs of each of the stack's containers, or of one service if a name is given
+func (s *StackManager) GetLogs(service string, tail int, verbose bool) (string, error) {
+	command := []string{"logs", "--no-color", "--tail", fmt.Sprint(tail)}
+	if service != "" {
+		command = append(command, service)
+	}
+	return docker.RunDockerComposeCommandBuffered(filepath.Join(constants.StacksDir, s.Stack.Name), verbose, command...)
 }
 
+// ResetStack stops the stack if it is running, and clears all of its data
 func (s *StackManager) ResetStack(verbose bool) error {
+	if err := s.checkNotArchived(); err != nil {
+		return err
+	}
+	if err := s.checkOperation("reset", verbose); err != nil {
+		return err
+	}
+	if err := s.resetStack(verbose); err != nil {
+		return err
+	}
+	if err := s.setState(StateCreated, nil); err != nil {
+		return err
+	}
+	s.notify(notify.Reset, fmt.Sprintf("all data in stack '%s' was cleared", s.Stack.Name), nil)
+	return nil
+}
+
+func (s *StackManager) resetStack(verbose bool) error {
 	if err := docker.RunDockerComposeCommand(filepath.Join(constants.StacksDir, s.Stack.Name), verbose, verbose, "down", "--volumes"); err != nil {
 		return err
 	}
+	if err := s.clearExternalPostgres(verbose); err != nil {
+		s.Log.Error(fmt.Errorf("failed to clear external database: %s", err))
+	}
 	if err := os.RemoveAll(filepath.Join(constants.StacksDir, s.Stack.Name, "data")); err != nil {
 		return err
 	}
 	return s.ensureDirectories()
 }
 
+// RemoveStack stops the stack if it is running, and removes its data and state
 func (s *StackManager) RemoveStack(verbose bool) error {
-	if err := s.ResetStack(verbose); err != nil {
+	if err := s.checkOperation("remove", verbose); err != nil {
+		return err
+	}
+	if err := s.resetStack(verbose); err != nil {
 		return err
 	}
-	return os.RemoveAll(filepath.Join(constants.StacksDir, s.Stack.Name))
+	if s.useKeychain {
+		if err := keychain.Delete(s.Stack.Name); err != nil {
+			s.Log.Info(err.Error())
+		}
+	}
+	if err := deleteStackFiles(s.Stack.Name); err != nil {
+		return err
+	}
+	return releaseStackPorts(s.Stack.Name)
 }
 
 func (s *StackManager) checkPortsAvailable() error {
-	ports := make([]int, 1)
-	ports[0] = s.Stack.ExposedBlockchainPort
-	for _, member := range s.Stack.Members {
-		ports = append(ports, member.ExposedDataexchangePort)
-		ports = append(ports, member.ExposedEthconnectPort)
-		if !member.External {
-			ports = append(ports, member.ExposedFireflyAdminPort)
-			ports = append(ports, member.ExposedFireflyPort)
+	return checkPortsListAvailable(s.Stack.Host(), getStackPorts(s.Stack))
+}
+
+// getStackPorts returns every host port the stack publishes or, for external members, expects to be free
+func getStackPorts(stack *types.Stack) []int {
+	ports := make([]int, 0)
+	if stack.BlockchainNodes != types.BlockchainNodePerMember {
+		ports = append(ports, stack.ExposedBlockchainPort)
+	}
+	if stack.ExposedEventBrokerPort != 0 {
+		ports = append(ports, stack.ExposedEventBrokerPort)
+	}
+	if stack.ExposedWebhookRelayPort != 0 {
+		ports = append(ports, stack.ExposedWebhookRelayPort)
+	}
+	if stack.ExposedPrometheusPort != 0 {
+		ports = append(ports, stack.ExposedPrometheusPort, stack.ExposedGrafanaPort)
+	}
+	if stack.ExposedEdgePort != 0 {
+		ports = append(ports, stack.ExposedEdgePort)
+	}
+	if stack.ExposedNodeRPCPort != 0 {
+		ports = append(ports, stack.ExposedNodeRPCPort, stack.ExposedNodeWSPort)
+	}
+	for _, member := range stack.Members {
+		ports = append(ports, getMemberPorts(member)...)
+	}
+	// Adopted deployments do not necessarily publish every service
+	published := ports[:0]
+	for _, port := range ports {
+		if port != 0 {
+			published = append(published, port)
 		}
-		ports = append(ports, member.ExposedIPFSApiPort)
-		ports = append(ports, member.ExposedIPFSGWPort)
-		ports = append(ports, member.ExposedPostgresPort)
+	}
+	return published
+}
+
+func getMemberPorts(member *types.Member) []int {
+	ports := []int{member.ExposedEthconnectPort}
+	if member.ExposedDataexchangePort != 0 {
+		ports = append(ports, member.ExposedDataexchangePort)
+	}
+	if member.ExposedBlockchainPort != 0 {
+		ports = append(ports, member.ExposedBlockchainPort)
+	}
+	if !member.External {
+		ports = append(ports, member.ExposedFireflyAdminPort)
+		ports = append(ports, member.ExposedFireflyPort)
+	}
+	ports = append(ports, member.ExposedIPFSApiPort)
+	ports = append(ports, member.ExposedIPFSGWPort)
+	ports = append(ports, member.ExposedPostgresPort)
+	if member.ExposedPostgresReplicaPort != 0 {
+		ports = append(ports, member.ExposedPostgresReplicaPort)
+	}
+	if member.ExposedNodeRPCPort != 0 {
+		ports = append(ports, member.ExposedNodeRPCPort, member.ExposedNodeWSPort)
+	}
+	if member.ExposedUIPort != 0 {
 		ports = append(ports, member.ExposedUIPort)
-		ports = append(ports, member.ExposedTokensPort)
 	}
+	if member.ExposedSandboxPort != 0 {
+		ports = append(ports, member.ExposedSandboxPort)
+	}
+	tokensPorts := make([]int, 0, len(member.ExposedTokensPorts))
+	for _, port := range member.ExposedTokensPorts {
+		tokensPorts = append(tokensPorts, port)
+	}
+	sort.Ints(tokensPorts)
+	return append(ports, tokensPorts...)
+}
+
+func checkPortsListAvailable(host string, ports []int) error {
 	for _, port := range ports {
-		available, err := checkPortAvailable(port)
+		available, err := checkPortAvailable(host, port)
 		if err != nil {
 			return err
 		}
@@ -403,9 +1187,9 @@ func (s *StackManager) checkPortsAvailable() error {
 	return nil
 }
 
-func checkPortAvailable(port int) (bool, error) {
+func checkPortAvailable(host string, port int) (bool, error) {
 	timeout := time.Millisecond * 500
-	conn, err := net.DialTimeout("tcp", net.JoinHostPort("127.0.0.1", fmt.Sprint(port)), timeout)
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, fmt.Sprint(port)), timeout)
 
 	if netError, ok := err.(net.Error); ok && netError.Timeout() {
 		return true, nil
@@ -443,7 +1227,10 @@ func (s *StackManager) runFirstTimeSetup(verbose bool, options *StartOptions) er
 	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
 
 	s.Log.Info("initializing blockchain node")
-	if err := s.blockchainProvider.FirstTimeSetup(); err != nil {
+	span := tracing.StartSpan("blockchain setup")
+	err := s.blockchainProvider.FirstTimeSetup()
+	span.End(err)
+	if err != nil {
 		return err
 	}
 
@@ -452,32 +1239,32 @@ func (s *StackManager) runFirstTimeSetup(verbose bool, options *StartOptions) er
 		return err
 	}
 
+	if err := s.createPostgresSchemas(verbose); err != nil {
+		return err
+	}
+
 	// write firefly configs to volumes
 	for _, member := range s.Stack.Members {
 		if !member.External {
-			s.Log.Info(fmt.Sprintf("copying firefly.core to firefly_core_%s", member.ID))
-			volumeName := fmt.Sprintf("%s_firefly_core_%s", s.Stack.Name, member.ID)
-			if err := docker.CopyFileToVolume(volumeName, path.Join(workingDir, "configs", fmt.Sprintf("firefly_core_%s.yml", member.ID)), "/firefly.core", verbose); err != nil {
+			if err := s.copyFireflyConfigToVolume(member, verbose); err != nil {
 				return err
 			}
 		}
 	}
 
-	if !options.NoPull {
-		s.Log.Info("pulling latest versions")
-		if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, "pull"); err != nil {
-			return err
-		}
+	if err := s.copyIPFSConfigToVolumes(verbose); err != nil {
+		return err
 	}
 
-	if err := s.runStartupSequence(workingDir, verbose, true); err != nil {
+	if err := s.ensureImages(workingDir, verbose, true, options); err != nil {
 		return err
 	}
 
-	if err := s.blockchainProvider.DeploySmartContracts(); err != nil {
+	if err := s.runStartupSequence(workingDir, verbose, true); err != nil {
 		return err
 	}
-	if err := s.tokensProvider.DeploySmartContracts(); err != nil {
+
+	if err := s.deployContracts(); err != nil {
 		return err
 	}
 
@@ -485,18 +1272,38 @@ func (s *StackManager) runFirstTimeSetup(verbose bool, options *StartOptions) er
 		return err
 	}
 
-	s.Log.Info("registering FireFly identities")
-	if err := s.registerFireflyIdentities(verbose); err != nil {
-		return err
+	if !s.Stack.Gateway {
+		s.Log.Info("registering FireFly identities")
+		if err := s.registerFireflyIdentities(verbose); err != nil {
+			return err
+		}
 	}
 
-	s.Log.Info("initializing token providers")
-	if err := s.tokensProvider.FirstTimeSetup(); err != nil {
+	if len(s.Stack.TokensProviders) > 0 && s.Stack.TokensProviders[0] != NilTokens.String() {
+		s.Log.Info("initializing token providers")
+	}
+	for _, tokensProvider := range s.tokensProviders {
+		if err := tokensProvider.FirstTimeSetup(); err != nil {
+			return err
+		}
+	}
+
+	if err := s.createTenantNamespaces(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// deployContracts deploys the FireFly contract and the contracts of the tokens connectors
+func (s *StackManager) deployContracts() (err error) {
+	span := tracing.StartSpan("deploy")
+	defer func() { span.End(err) }()
+	if err := s.blockchainProvider.DeploySmartContracts(); err != nil {
+		return err
+	}
+	return s.deployTokensContracts()
+}
+
 func (s *StackManager) ensureFireflyNodesUp(firstTimeSetup bool) error {
 	for _, member := range s.Stack.Members {
 		if member.External {
@@ -508,7 +1315,7 @@ func (s *StackManager) ensureFireflyNodesUp(firstTimeSetup bool) error {
 				port = member.ExposedFireflyPort
 			}
 			// Check process running
-			available, err := checkPortAvailable(port)
+			available, err := checkPortAvailable("127.0.0.1", port)
 			if err != nil {
 				return err
 			}
@@ -529,7 +1336,7 @@ func (s *StackManager) waitForFireflyStart(port int) error {
 	retriesRemaining := retries
 	for retriesRemaining > 0 {
 		time.Sleep(time.Duration(retryPeriod) * time.Millisecond)
-		available, err := checkPortAvailable(port)
+		available, err := checkPortAvailable("127.0.0.1", port)
 		if err != nil {
 			return err
 		}
@@ -541,22 +1348,14 @@ func (s *StackManager) waitForFireflyStart(port int) error {
 	return fmt.Errorf("waited for %v seconds for firefly to start on port %v but it was never available", retries*retryPeriod/1000, port)
 }
 
-func (s *StackManager) UpgradeStack(verbose bool) error {
-	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
-	if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, "down"); err != nil {
-		return err
-	}
-	return docker.RunDockerComposeCommand(workingDir, verbose, verbose, "pull")
-}
-
 func (s *StackManager) PrintStackInfo(verbose bool) error {
 	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
 	fmt.Print("\n")
-	if err := docker.RunDockerComposeCommand(workingDir, verbose, true, "images"); err != nil {
+	if err := docker.RunDockerComposeCommandAttached(workingDir, verbose, "images"); err != nil {
 		return err
 	}
 	fmt.Print("\n")
-	if err := docker.RunDockerComposeCommand(workingDir, verbose, true, "ps"); err != nil {
+	if err := docker.RunDockerComposeCommandAttached(workingDir, verbose, "ps"); err != nil {
 		return err
 	}
 	fmt.Printf("\nYour docker compose file for this stack can be found at: %s\n\n", filepath.Join(constants.StacksDir, s.Stack.Name, "docker-compose.yml"))
@@ -565,21 +1364,32 @@ func (s *StackManager) PrintStackInfo(verbose bool) error {
 
 func (s *StackManager) patchConfigAndRestartFireflyNodes(verbose bool) error {
 	for _, member := range s.Stack.Members {
-		s.Log.Info(fmt.Sprintf("applying configuration changes to %s", member.ID))
-		configRecordUrl := fmt.Sprintf("http://localhost:%d/admin/api/v1/config/records/admin", member.ExposedFireflyAdminPort)
-		if err := core.RequestWithRetry("PUT", configRecordUrl, "{\"preInit\": false}", nil); err != nil && err != io.EOF {
-			return err
-		}
-		resetUrl := fmt.Sprintf("http://localhost:%d/admin/api/v1/config/reset", member.ExposedFireflyAdminPort)
-		if err := core.RequestWithRetry("POST", resetUrl, "{}", nil); err != nil {
+		if err := s.patchConfigAndRestartFireflyNode(member); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func (s *StackManager) patchConfigAndRestartFireflyNode(member *types.Member) error {
+	s.Log.Info(fmt.Sprintf("applying configuration changes to %s", member.ID))
+	configRecordUrl := member.FireflyAdminClientURL() + "/admin/api/v1/config/records/admin"
+	if err := core.RequestWithRetry("PUT", configRecordUrl, "{\"preInit\": false}", nil); err != nil && err != io.EOF {
+		return err
+	}
+	resetUrl := member.FireflyAdminClientURL() + "/admin/api/v1/config/reset"
+	return core.RequestWithRetry("POST", resetUrl, "{}", nil)
+}
+
 func (s *StackManager) StackHasRunBefore() (bool, error) {
+	// An adopted deployment was already set up before it was adopted
+	if s.Stack.AdoptedFrom != "" {
+		return true, nil
+	}
 	path := filepath.Join(constants.StacksDir, s.Stack.Name, "data", fmt.Sprintf("dataexchange_%s", s.Stack.Members[0].ID), "cert.pem")
+	if s.Stack.Gateway {
+		path = filepath.Join(constants.StacksDir, s.Stack.Name, "data", GatewaySetupMarker)
+	}
 	_, err := os.Stat(path)
 	if os.IsNotExist(err) {
 		return false, nil
@@ -609,8 +1419,41 @@ func (s *StackManager) getBlockchainProvider(verbose bool) blockchain.IBlockchai
 	}
 }
 
-func (s *StackManager) getTokensProvider(verbose bool) tokens.ITokensProvider {
-	switch s.Stack.TokensProvider {
+// getTokensConfig combines the connectors of all of the stack's tokens providers into one tokens config
+func (s *StackManager) getTokensConfig(member *types.Member) *core.TokensConfig {
+	tokensConfig := core.TokensConfig{}
+	for _, tokensProvider := range s.tokensProviders {
+		if connectors := tokensProvider.GetFireflyConfig(member); connectors != nil {
+			tokensConfig = append(tokensConfig, *connectors...)
+		}
+	}
+	if len(tokensConfig) == 0 {
+		return nil
+	}
+	return &tokensConfig
+}
+
+func (s *StackManager) deployTokensContracts() error {
+	for _, tokensProvider := range s.tokensProviders {
+		if err := tokensProvider.DeploySmartContracts(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *StackManager) getTokensProviders(verbose bool) []tokens.ITokensProvider {
+	providers := make([]tokens.ITokensProvider, 0, len(s.Stack.TokensProviders))
+	for _, name := range s.Stack.TokensProviders {
+		if provider := s.getTokensProvider(name, verbose); provider != nil {
+			providers = append(providers, provider)
+		}
+	}
+	return providers
+}
+
+func (s *StackManager) getTokensProvider(name string, verbose bool) tokens.ITokensProvider {
+	switch name {
 	case NilTokens.String():
 		return &niltokens.NilTokensProvider{
 			Verbose: verbose,
@@ -623,6 +1466,12 @@ func (s *StackManager) getTokensProvider(verbose bool) tokens.ITokensProvider {
 			Log:     s.Log,
 			Stack:   s.Stack,
 		}
+	case ERC20ERC721.String():
+		return &erc20erc721.ERC20ERC721Provider{
+			Verbose: verbose,
+			Log:     s.Log,
+			Stack:   s.Stack,
+		}
 	default:
 		return nil
 	}
@@ -0,0 +1,248 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+)
+
+// StateStore persists the state of each stack, which is the contents of its stack.json (encrypted, if the
+// stack is). The other files of a stack, such as its compose file and configs, always live in its directory.
+type StateStore interface {
+	// Read returns the state of the stack, or an error satisfying os.IsNotExist if the stack has none
+	Read(stackName string) ([]byte, error)
+	Write(stackName string, data []byte) error
+	Delete(stackName string) error
+	// List returns the names of every stack with state in the store, in order
+	List() ([]string, error)
+}
+
+var StateStoreStrings = []string{"files", "single-file"}
+
+var stateStore StateStore = &filesStateStore{}
+
+// SetStateStore selects where the state of every stack is kept
+func SetStateStore(name string) error {
+	store, err := getStateStore(name)
+	if err != nil {
+		return err
+	}
+	stateStore = store
+	return nil
+}
+
+func getStateStore(name string) (StateStore, error) {
+	switch name {
+	case "files":
+		return &filesStateStore{}, nil
+	case "single-file":
+		return &singleFileStateStore{filename: constants.StateFile}, nil
+	default:
+		return nil, fmt.Errorf("\"%s\" is not a valid state store. valid options are: %v", name, StateStoreStrings)
+	}
+}
+
+// MigrateState moves the state of every stack from the current store to another, and returns the names of
+// the stacks moved. The state of a stack is only removed from the current store once it has been written
+// to the other.
+func MigrateState(to string) ([]string, error) {
+	target, err := getStateStore(to)
+	if err != nil {
+		return nil, err
+	}
+	stackNames, err := stateStore.List()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, name := range stackNames {
+		d, err := stateStore.Read(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := target.Write(name, d); err != nil {
+			return nil, fmt.Errorf("failed to write the state of stack '%s': %s", name, err)
+		}
+		if err := stateStore.Delete(name); err != nil {
+			return nil, err
+		}
+	}
+	return stackNames, nil
+}
+
+// writeFileAtomic writes the file via a temporary file in the same directory, so that other processes never
+// read a partially written file
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// filesStateStore keeps the state of each stack in the stack.json file in the stack's directory
+type filesStateStore struct{}
+
+func (f *filesStateStore) path(stackName string) string {
+	return filepath.Join(constants.StacksDir, stackName, "stack.json")
+}
+
+func (f *filesStateStore) Read(stackName string) ([]byte, error) {
+	return ioutil.ReadFile(f.path(stackName))
+}
+
+func (f *filesStateStore) Write(stackName string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(f.path(stackName)), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path(stackName), data, 0755)
+}
+
+func (f *filesStateStore) Delete(stackName string) error {
+	if err := os.Remove(f.path(stackName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *filesStateStore) List() ([]string, error) {
+	files, err := ioutil.ReadDir(constants.StacksDir)
+	if err != nil {
+		return nil, err
+	}
+	stackNames := make([]string, 0)
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(f.path(file.Name())); err == nil {
+			stackNames = append(stackNames, file.Name())
+		}
+	}
+	return stackNames, nil
+}
+
+// singleFileStateStore keeps the state of every stack in one file, which is locked while it is changed.
+// Listing the stacks only reads that file, rather than the directory of every stack.
+type singleFileStateStore struct {
+	filename string
+}
+
+type singleFileState struct {
+	Stacks map[string]json.RawMessage `json:"stacks"`
+}
+
+func (f *singleFileStateStore) read() (*singleFileState, error) {
+	state := &singleFileState{}
+	d, err := ioutil.ReadFile(f.filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		if err := json.Unmarshal(d, state); err != nil {
+			return nil, fmt.Errorf("invalid state file %s: %s", f.filename, err)
+		}
+	}
+	if state.Stacks == nil {
+		state.Stacks = make(map[string]json.RawMessage)
+	}
+	return state, nil
+}
+
+// update applies a change to the state while holding the lock on the file
+func (f *singleFileStateStore) update(change func(state *singleFileState)) error {
+	unlock, err := lockFile(f.filename)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	state, err := f.read()
+	if err != nil {
+		return err
+	}
+	change(state)
+	d, err := json.MarshalIndent(state, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.filename, d, 0600)
+}
+
+func (f *singleFileStateStore) Read(stackName string) ([]byte, error) {
+	state, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	d, ok := state.Stacks[stackName]
+	if !ok {
+		return nil, &os.PathError{Op: "read", Path: fmt.Sprintf("%s#%s", f.filename, stackName), Err: os.ErrNotExist}
+	}
+	return d, nil
+}
+
+func (f *singleFileStateStore) Write(stackName string, data []byte) error {
+	if !json.Valid(data) {
+		return fmt.Errorf("the state of stack '%s' is not valid JSON", stackName)
+	}
+	return f.update(func(state *singleFileState) {
+		state.Stacks[stackName] = data
+	})
+}
+
+func (f *singleFileStateStore) Delete(stackName string) error {
+	return f.update(func(state *singleFileState) {
+		delete(state.Stacks, stackName)
+	})
+}
+
+func (f *singleFileStateStore) List() ([]string, error) {
+	state, err := f.read()
+	if err != nil {
+		return nil, err
+	}
+	stackNames := make([]string, 0, len(state.Stacks))
+	for name := range state.Stacks {
+		stackNames = append(stackNames, name)
+	}
+	sort.Strings(stackNames)
+	return stackNames, nil
+}
+
+// deleteStackFiles removes the directory and the state of a stack
+func deleteStackFiles(stackName string) error {
+	if err := os.RemoveAll(filepath.Join(constants.StacksDir, stackName)); err != nil {
+		return err
+	}
+	return stateStore.Delete(stackName)
+}
@@ -0,0 +1,246 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+	"gopkg.in/yaml.v2"
+)
+
+// SupportBundle summarizes what was collected into a support bundle, and anything that could not be
+type SupportBundle struct {
+	Stack      string                    `json:"stack" yaml:"stack"`
+	Created    time.Time                 `json:"created" yaml:"created"`
+	Redacted   bool                      `json:"redacted" yaml:"redacted"`
+	Containers []*docker.ContainerStatus `json:"containers" yaml:"containers"`
+	Problems   []*ContainerProblem       `json:"problems,omitempty" yaml:"problems,omitempty"`
+	Members    []*SupportBundleMember    `json:"members" yaml:"members"`
+	Errors     []string                  `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+type SupportBundleMember struct {
+	Member string `json:"member" yaml:"member"`
+	// Number of rows in each collection of the default namespace
+	Counts map[string]int64 `json:"counts,omitempty" yaml:"counts,omitempty"`
+	Errors []string         `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+// The status and diagnostics endpoints of the default namespace of FireFly core that are collected, by file name
+var supportBundleEndpoints = map[string]string{
+	"namespace-status": "/status",
+	"batchmanager":     "/status/batchmanager",
+	"operations":       "/operations?sort=created&descending&limit=100",
+	"events":           "/events?sort=sequence&descending&limit=100",
+	"messages":         "/messages?sort=created&descending&limit=100",
+	"transactions":     "/transactions?sort=created&descending&limit=100",
+	"blockchainevents": "/blockchainevents?sort=created&descending&limit=100",
+	"subscriptions":    "/subscriptions",
+	"tokenpools":       "/tokens/pools",
+}
+
+var supportBundleCounts = []string{"messages", "data", "batches", "events", "operations", "transactions", "blockchainevents", "subscriptions", "tokens/pools", "tokens/transfers"}
+
+// Fields of FireFly core resources that hold payloads, such as the value of data and the input and output of
+// operations and blockchain events, which are removed from the bundle when it is redacted
+var payloadFields = map[string]bool{"value": true, "input": true, "output": true}
+
+// WriteSupportBundle collects everything needed to file a bug against FireFly core into a gzipped tar archive:
+// the stack definition and configs without their secrets, the state and logs of each container, and the status,
+// recent activity and row counts of each member's FireFly core. With redact, payloads are removed from the
+// FireFly core resources. Members that cannot be reached are recorded in the summary, rather than failing.
+func (s *StackManager) WriteSupportBundle(w io.Writer, redact bool, verbose bool) (*SupportBundle, error) {
+	bundle := &SupportBundle{
+		Stack:    s.Stack.Name,
+		Created:  time.Now().UTC(),
+		Redacted: redact,
+		Members:  make([]*SupportBundleMember, 0, len(s.Stack.Members)),
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	addJSON := func(name string, v interface{}) error {
+		d, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeArchiveFile(tw, name, d, 0644)
+	}
+	stackDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+
+	if err := addJSON("stack.json", withoutSecrets(s.Stack)); err != nil {
+		return nil, err
+	}
+	if d, err := ioutil.ReadFile(filepath.Join(stackDir, "docker-compose.yml")); err != nil {
+		bundle.Errors = append(bundle.Errors, err.Error())
+	} else if err := writeArchiveFile(tw, "docker-compose.yml", d, 0644); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if bundle.Containers, err = docker.ListProjectContainers(s.Stack.Name, verbose); err != nil {
+		bundle.Errors = append(bundle.Errors, err.Error())
+	}
+	if bundle.Problems, err = s.GetContainerProblems(verbose); err != nil {
+		bundle.Errors = append(bundle.Errors, err.Error())
+	}
+	for _, c := range bundle.Containers {
+		logs, err := s.GetLogs(c.Service, 1000, verbose)
+		if err != nil {
+			bundle.Errors = append(bundle.Errors, fmt.Sprintf("logs of %s: %s", c.Service, err))
+			continue
+		}
+		if err := writeArchiveFile(tw, "logs/"+c.Service+".log", []byte(logs), 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, member := range s.Stack.Members {
+		m := &SupportBundleMember{Member: member.ID, Counts: make(map[string]int64)}
+		bundle.Members = append(bundle.Members, m)
+		memberDir := "members/" + member.ID + "/"
+
+		configFile := filepath.Join(stackDir, "configs", fmt.Sprintf("firefly_core_%s.yml", member.ID))
+		if config, err := core.ReadFireflyConfig(configFile); err != nil {
+			m.Errors = append(m.Errors, err.Error())
+		} else if d, err := yaml.Marshal(withoutDatabasePassword(config)); err == nil {
+			if err := writeArchiveFile(tw, memberDir+"firefly.core.yml", d, 0644); err != nil {
+				return nil, err
+			}
+		}
+
+		status, err := core.GetDiagnostics(member.FireflyClientURL() + "/api/v1/status")
+		if err != nil {
+			m.Errors = append(m.Errors, fmt.Sprintf("FireFly core is not reachable: %s", err))
+			continue
+		}
+		if err := writeArchiveFile(tw, memberDir+"status.json", status, 0644); err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(supportBundleEndpoints))
+		for name := range supportBundleEndpoints {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			result, err := core.GetDiagnostics(core.FireflyURL(member, supportBundleEndpoints[name]))
+			if err != nil {
+				m.Errors = append(m.Errors, fmt.Sprintf("%s: %s", name, err))
+				continue
+			}
+			var v interface{}
+			if err := json.Unmarshal(result, &v); err != nil {
+				m.Errors = append(m.Errors, fmt.Sprintf("%s: %s", name, err))
+				continue
+			}
+			if redact {
+				v = redactPayloads(v)
+			}
+			if err := addJSON(memberDir+name+".json", v); err != nil {
+				return nil, err
+			}
+		}
+		for _, collection := range supportBundleCounts {
+			if count, err := core.GetCount(member, collection); err != nil {
+				m.Errors = append(m.Errors, fmt.Sprintf("count of %s: %s", collection, err))
+			} else {
+				m.Counts[collection] = count
+			}
+		}
+	}
+
+	if err := addJSON("bundle.json", bundle); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return bundle, gz.Close()
+}
+
+// withoutSecrets returns a copy of the stack without its keys, passwords and webhook URLs
+func withoutSecrets(stack *types.Stack) *types.Stack {
+	var redacted *types.Stack
+	d, _ := json.Marshal(stack)
+	_ = json.Unmarshal(d, &redacted)
+	redacted.SwarmKey = ""
+	redacted.VaultToken = ""
+	redacted.Notifications = nil
+	if redacted.Registry != nil {
+		redacted.Registry.Password = ""
+	}
+	for _, member := range redacted.Members {
+		member.PrivateKey = ""
+		member.NodeKey = ""
+		member.SealerPrivateKey = ""
+		member.APIPassword = ""
+		member.PostgresURL = redactURLPassword(member.PostgresURL)
+	}
+	for _, account := range redacted.Accounts {
+		account.PrivateKey = ""
+	}
+	for _, tenant := range redacted.Tenants {
+		tenant.Password = ""
+	}
+	return redacted
+}
+
+func withoutDatabasePassword(config *core.FireflyConfig) *core.FireflyConfig {
+	if config.Database != nil && config.Database.PostgreSQL != nil {
+		config.Database.PostgreSQL.URL = redactURLPassword(config.Database.PostgreSQL.URL)
+	}
+	return config
+}
+
+func redactURLPassword(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// redactPayloads replaces the payload fields of FireFly core resources, at any depth, with a placeholder
+func redactPayloads(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if payloadFields[k] && field != nil {
+				v[k] = "[redacted]"
+			} else {
+				v[k] = redactPayloads(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactPayloads(item)
+		}
+	}
+	return v
+}
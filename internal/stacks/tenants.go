@@ -0,0 +1,175 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/edge"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+type TenantInfo struct {
+	Name      string   `json:"name" yaml:"name"`
+	Namespace string   `json:"namespace" yaml:"namespace"`
+	Username  string   `json:"username,omitempty" yaml:"username,omitempty"`
+	Password  string   `json:"password,omitempty" yaml:"password,omitempty"`
+	APIs      []string `json:"apis" yaml:"apis"`
+}
+
+// GetTenantInfo returns the tenant along with the URL of their namespace on each member. Stacks with a
+// custom domain return the URLs of the edge proxy, which is where credentials are enforced.
+func (s *StackManager) GetTenantInfo(tenant *types.Tenant) *TenantInfo {
+	info := &TenantInfo{
+		Name:      tenant.Name,
+		Namespace: tenant.Namespace,
+		Username:  tenant.Username,
+		Password:  tenant.Password,
+		APIs:      make([]string, 0, len(s.Stack.Members)),
+	}
+	for _, member := range s.Stack.Members {
+		if s.Stack.Domain != "" && !member.External {
+			info.APIs = append(info.APIs, fmt.Sprintf("%s/api/v1/namespaces/%s", edge.GetMemberURL(s.Stack, member), tenant.Namespace))
+		} else {
+			info.APIs = append(info.APIs, fmt.Sprintf("%s/api/v1/namespaces/%s", member.FireflyURL(), tenant.Namespace))
+		}
+	}
+	return info
+}
+
+func (s *StackManager) GetTenants() []*TenantInfo {
+	tenants := make([]*TenantInfo, 0, len(s.Stack.Tenants))
+	for _, tenant := range s.Stack.Tenants {
+		tenants = append(tenants, s.GetTenantInfo(tenant))
+	}
+	return tenants
+}
+
+func validateTenantName(name string) error {
+	if name == "" || len(name) > 64 || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-_") != "" ||
+		strings.HasPrefix(name, "-") || strings.HasPrefix(name, "_") {
+		return fmt.Errorf("\"%s\" is not a valid tenant name - use up to 64 lowercase letters, numbers, dashes and underscores", name)
+	}
+	if name == "default" || name == "ff_system" {
+		return fmt.Errorf("\"%s\" is a reserved namespace and cannot be used as a tenant name", name)
+	}
+	return nil
+}
+
+// AddTenant gives a developer their own namespace on the stack. If the stack has been run before, it
+// must be running and the namespace is broadcast immediately, otherwise it is created on first start.
+// Credentials are enforced by the edge proxy, so are only available on stacks with a custom domain.
+func (s *StackManager) AddTenant(name string, withCredentials bool, verbose bool) (*types.Tenant, error) {
+	if err := s.checkGenerated(); err != nil {
+		return nil, err
+	}
+	if s.Stack.Gateway {
+		return nil, fmt.Errorf("tenants cannot be added to a gateway stack, as their namespaces are broadcast to the network")
+	}
+	if err := validateTenantName(name); err != nil {
+		return nil, err
+	}
+	for _, t := range s.Stack.Tenants {
+		if t.Name == name {
+			return nil, fmt.Errorf("tenant '%s' already exists in stack '%s'", name, s.Stack.Name)
+		}
+	}
+	tenant := &types.Tenant{
+		Name:      name,
+		Namespace: name,
+	}
+	if withCredentials {
+		if s.Stack.Domain == "" {
+			return nil, fmt.Errorf("tenant credentials are enforced by the edge proxy - initialize the stack with --domain to use them")
+		}
+		password := make([]byte, 16)
+		if _, err := rand.Read(password); err != nil {
+			return nil, err
+		}
+		tenant.Username = name
+		tenant.Password = hex.EncodeToString(password)
+	}
+
+	runBefore, err := s.StackHasRunBefore()
+	if err != nil {
+		return nil, err
+	}
+	if runBefore {
+		if err := s.createTenantNamespace(tenant); err != nil {
+			return nil, fmt.Errorf("failed to create namespace - please make sure the stack is running: %s", err)
+		}
+	}
+
+	s.Stack.Tenants = append(s.Stack.Tenants, tenant)
+	if err := s.writeStackFiles(verbose); err != nil {
+		return nil, err
+	}
+	if runBefore && withCredentials {
+		// Recreate the proxy if its definition has changed, then pick up the new password file
+		workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+		if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, "up", "-d", "--no-deps", "edge"); err != nil {
+			return nil, err
+		}
+		if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, "exec", "-T", "edge", "nginx", "-s", "reload"); err != nil {
+			return nil, err
+		}
+	}
+	return tenant, nil
+}
+
+func (s *StackManager) createTenantNamespaces() error {
+	for _, tenant := range s.Stack.Tenants {
+		if err := s.createTenantNamespace(tenant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTenantNamespace broadcasts the tenant's namespace from the first member running FireFly core in
+// docker, unless it already exists on the network
+func (s *StackManager) createTenantNamespace(tenant *types.Tenant) error {
+	var member *types.Member
+	for _, m := range s.Stack.Members {
+		if !m.External {
+			member = m
+			break
+		}
+	}
+	if member == nil {
+		return fmt.Errorf("no FireFly core members found in stack '%s'", s.Stack.Name)
+	}
+	namespaces, err := core.GetNamespaces(member)
+	if err != nil {
+		return err
+	}
+	for _, ns := range namespaces {
+		if ns.Name == tenant.Namespace {
+			return nil
+		}
+	}
+	s.Log.Info(fmt.Sprintf("creating namespace %s for tenant %s", tenant.Namespace, tenant.Name))
+	_, err = core.CreateNamespace(member, tenant.Namespace, fmt.Sprintf("Namespace for tenant %s", tenant.Name))
+	return err
+}
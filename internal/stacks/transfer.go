@@ -0,0 +1,114 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/firefly-cli/internal/log"
+)
+
+const transferProgressInterval = 10 * time.Second
+
+// ParseByteSize parses a size such as 512k, 64m, 1g or 10MB, in bytes
+func ParseByteSize(size string) (int64, error) {
+	units := map[string]int64{"": 1, "b": 1, "k": 1 << 10, "m": 1 << 20, "g": 1 << 30}
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(size)), "b"), "i")
+	i := strings.IndexFunc(trimmed, func(r rune) bool { return r < '0' || r > '9' })
+	if i < 0 {
+		i = len(trimmed)
+	}
+	multiplier, ok := units[trimmed[i:]]
+	value, err := strconv.ParseInt(trimmed[:i], 10, 64)
+	if !ok || err != nil || value <= 0 {
+		return 0, fmt.Errorf("\"%s\" is not a valid size - use a number of bytes, optionally followed by k, m or g", size)
+	}
+	return value * multiplier, nil
+}
+
+func describeBytes(b int64) string {
+	switch {
+	case b >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(b)/(1<<30))
+	case b >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(b)/(1<<20))
+	case b >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(b)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", b)
+	}
+}
+
+// transferWriter limits the rate data is written at, if given a limit, and logs how much has been written
+// every few seconds, so a long transfer over a slow link can be followed
+type transferWriter struct {
+	w              io.Writer
+	bytesPerSecond int64
+	log            log.Logger
+	what           string
+	start          time.Time
+	lastProgress   time.Time
+	written        int64
+}
+
+// NewTransferWriter wraps w to write at no more than bytesPerSecond, or without a limit if it is 0, logging
+// the progress of writing what is described
+func NewTransferWriter(w io.Writer, bytesPerSecond int64, logger log.Logger, what string) io.Writer {
+	now := time.Now()
+	return &transferWriter{
+		w:              w,
+		bytesPerSecond: bytesPerSecond,
+		log:            logger,
+		what:           what,
+		start:          now,
+		lastProgress:   now,
+	}
+}
+
+func (t *transferWriter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		// Write a tenth of a second's worth at a time, so the rate stays even
+		n := len(p)
+		if t.bytesPerSecond > 0 && int64(n) > t.bytesPerSecond/10+1 {
+			n = int(t.bytesPerSecond/10 + 1)
+		}
+		written, err := t.w.Write(p[:n])
+		total += written
+		t.written += int64(written)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+		if t.bytesPerSecond > 0 {
+			due := t.start.Add(time.Duration(float64(t.written) / float64(t.bytesPerSecond) * float64(time.Second)))
+			if wait := time.Until(due); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+	}
+	if time.Since(t.lastProgress) >= transferProgressInterval {
+		t.lastProgress = time.Now()
+		elapsed := time.Since(t.start).Seconds()
+		t.log.Info(fmt.Sprintf("%s: %s written, at %s/s", t.what, describeBytes(t.written), describeBytes(int64(float64(t.written)/elapsed))))
+	}
+	return total, nil
+}
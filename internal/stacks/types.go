@@ -19,6 +19,8 @@ package stacks
 import (
 	"fmt"
 	"strings"
+
+	"github.com/hyperledger/firefly-cli/pkg/types"
 )
 
 type DatabaseSelection int
@@ -72,9 +74,10 @@ type TokensProvider int
 const (
 	NilTokens TokensProvider = iota
 	ERC1155
+	ERC20ERC721
 )
 
-var TokensProviderStrings = []string{"none", "erc1155"}
+var TokensProviderStrings = []string{"none", "erc1155", "erc20_erc721"}
 
 func (tokensProvider TokensProvider) String() string {
 	return TokensProviderStrings[tokensProvider]
@@ -88,3 +91,168 @@ func TokensProviderFromString(s string) (TokensProvider, error) {
 	}
 	return ERC1155, fmt.Errorf("\"%s\" is not a valid tokens provider selection. valid options are: %v", s, TokensProviderStrings)
 }
+
+// TokensProvidersFromStrings parses the tokens providers selected for a stack, which runs a connector for
+// each of them. "none" cannot be combined with any other provider.
+func TokensProvidersFromStrings(selections []string) ([]TokensProvider, error) {
+	providers := make([]TokensProvider, 0, len(selections))
+	for _, s := range selections {
+		provider, err := TokensProviderFromString(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range providers {
+			if p == provider {
+				return nil, fmt.Errorf("tokens provider \"%s\" is selected more than once", s)
+			}
+		}
+		providers = append(providers, provider)
+	}
+	if len(providers) == 0 {
+		return []TokensProvider{NilTokens}, nil
+	}
+	if len(providers) > 1 {
+		for _, p := range providers {
+			if p == NilTokens {
+				return nil, fmt.Errorf("tokens provider \"none\" cannot be combined with other tokens providers")
+			}
+		}
+	}
+	return providers, nil
+}
+
+type EventBridgeSelection int
+
+const (
+	NoEventBridge EventBridgeSelection = iota
+	Kafka
+	NATS
+)
+
+var EventBridgeSelectionStrings = []string{"none", "kafka", "nats"}
+
+func (eventBridge EventBridgeSelection) String() string {
+	return EventBridgeSelectionStrings[eventBridge]
+}
+
+func EventBridgeSelectionFromString(s string) (EventBridgeSelection, error) {
+	for i, eventBridgeSelection := range EventBridgeSelectionStrings {
+		if strings.ToLower(s) == eventBridgeSelection {
+			return EventBridgeSelection(i), nil
+		}
+	}
+	return NoEventBridge, fmt.Errorf("\"%s\" is not a valid event bridge selection. valid options are: %v", s, EventBridgeSelectionStrings)
+}
+
+type APIAuthSelection int
+
+const (
+	NoAPIAuth APIAuthSelection = iota
+	BasicAPIAuth
+)
+
+var APIAuthSelectionStrings = []string{"none", "basic"}
+
+func (apiAuth APIAuthSelection) String() string {
+	return APIAuthSelectionStrings[apiAuth]
+}
+
+func APIAuthSelectionFromString(s string) (APIAuthSelection, error) {
+	for i, apiAuth := range APIAuthSelectionStrings {
+		if strings.ToLower(s) == apiAuth {
+			return APIAuthSelection(i), nil
+		}
+	}
+	return NoAPIAuth, fmt.Errorf("\"%s\" is not a valid API auth selection. valid options are: %v", s, APIAuthSelectionStrings)
+}
+
+type BlockchainNodeTopology int
+
+const (
+	SharedBlockchainNode BlockchainNodeTopology = iota
+	BlockchainNodePerMember
+)
+
+var BlockchainNodeTopologyStrings = []string{"shared", types.BlockchainNodePerMember}
+
+func (topology BlockchainNodeTopology) String() string {
+	return BlockchainNodeTopologyStrings[topology]
+}
+
+func BlockchainNodeTopologyFromString(s string) (BlockchainNodeTopology, error) {
+	for i, topology := range BlockchainNodeTopologyStrings {
+		if strings.ToLower(s) == topology {
+			return BlockchainNodeTopology(i), nil
+		}
+	}
+	return SharedBlockchainNode, fmt.Errorf("\"%s\" is not a valid blockchain node topology. valid options are: %v", s, BlockchainNodeTopologyStrings)
+}
+
+type SignerSelection int
+
+const (
+	NodeSigner SignerSelection = iota
+	EthsignerSigner
+	VaultSigner
+)
+
+var SignerSelectionStrings = []string{"node", types.SignerEthsigner, types.SignerVault}
+
+func (signer SignerSelection) String() string {
+	return SignerSelectionStrings[signer]
+}
+
+func SignerSelectionFromString(s string) (SignerSelection, error) {
+	for i, signer := range SignerSelectionStrings {
+		if strings.ToLower(s) == signer {
+			return SignerSelection(i), nil
+		}
+	}
+	return NodeSigner, fmt.Errorf("\"%s\" is not a valid signer. valid options are: %v", s, SignerSelectionStrings)
+}
+
+type PullPolicy int
+
+const (
+	PullAlways PullPolicy = iota
+	PullMissing
+	PullNever
+)
+
+var PullPolicyStrings = []string{"always", "missing", "never"}
+
+func (policy PullPolicy) String() string {
+	return PullPolicyStrings[policy]
+}
+
+func PullPolicyFromString(s string) (PullPolicy, error) {
+	for i, policy := range PullPolicyStrings {
+		if strings.ToLower(s) == policy {
+			return PullPolicy(i), nil
+		}
+	}
+	return PullAlways, fmt.Errorf("\"%s\" is not a valid pull policy. valid options are: %v", s, PullPolicyStrings)
+}
+
+type DataComponent int
+
+const (
+	DatabaseData DataComponent = iota
+	IPFSData
+	DataExchangeData
+)
+
+var DataComponentStrings = []string{"database", "ipfs", "dataexchange"}
+
+func (component DataComponent) String() string {
+	return DataComponentStrings[component]
+}
+
+func DataComponentFromString(s string) (DataComponent, error) {
+	for i, component := range DataComponentStrings {
+		if strings.ToLower(s) == component {
+			return DataComponent(i), nil
+		}
+	}
+	return DatabaseData, fmt.Errorf("\"%s\" is not a valid data component. valid options are: %v", s, DataComponentStrings)
+}
@@ -0,0 +1,259 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stacks
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/notify"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// currentStackVersion is the version of the stack.json format written by this version of the CLI
+const currentStackVersion = 2
+
+// stackMigrations[i] migrates a stack from version i to version i+1
+var stackMigrations = []func(stack *types.Stack){
+	// Version 0 stacks were created before the blockchain, tokens and database could be chosen,
+	// and before members had an index or a tokens connector
+	func(stack *types.Stack) {
+		if stack.Database == "" {
+			stack.Database = PostgreSQL.String()
+		}
+		if stack.BlockchainProvider == "" {
+			stack.BlockchainProvider = GoEthereum.String()
+		}
+		if stack.TokensProvider == "" {
+			stack.TokensProvider = NilTokens.String()
+		}
+		for i, member := range stack.Members {
+			if member.Index == nil {
+				index := i
+				member.Index = &index
+			}
+			if member.ExposedTokensPort == 0 {
+				// The admin port is the first port of the member's block of service ports
+				member.ExposedTokensPort = member.ExposedFireflyAdminPort + 7
+			}
+		}
+	},
+	// Version 1 stacks had a single tokens connector for each member
+	func(stack *types.Stack) {
+		if len(stack.TokensProviders) == 0 {
+			stack.TokensProviders = []string{stack.TokensProvider}
+		}
+		for _, member := range stack.Members {
+			if member.ExposedTokensPorts == nil && stack.TokensProvider != NilTokens.String() {
+				member.ExposedTokensPorts = map[string]int{stack.TokensProvider: member.ExposedTokensPort}
+			}
+			member.ExposedTokensPort = 0
+		}
+		stack.TokensProvider = ""
+	},
+}
+
+// migrateStack brings a stack loaded from an older stack.json up to the current version in memory, by
+// running each migration from the stack's version in turn. It is run every time a stack is loaded, and
+// the migrated stack is written by the next command that saves the stack's config.
+func migrateStack(stack *types.Stack) {
+	for stack.Version < currentStackVersion && stack.Version < len(stackMigrations) {
+		stackMigrations[stack.Version](stack)
+		stack.Version++
+	}
+}
+
+// VersionChange is a component whose version is changed by an upgrade, along with the notes of the
+// releases of the component between the two versions
+type VersionChange struct {
+	Component    string               `json:"component" yaml:"component"`
+	From         string               `json:"from" yaml:"from"`
+	To           string               `json:"to" yaml:"to"`
+	ReleaseNotes []*core.ReleaseNotes `json:"releaseNotes,omitempty" yaml:"releaseNotes,omitempty"`
+}
+
+// HasBreakingChanges returns true if the release notes of any of the changes mention a breaking change
+func HasBreakingChanges(changes []*VersionChange) bool {
+	for _, change := range changes {
+		for _, notes := range change.ReleaseNotes {
+			if len(notes.BreakingChanges) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetUpgradeManifest resolves the version manifest the stack is upgraded to. If a release is given, the
+// stack is pinned to it. Otherwise stacks pinned to the stable or head release move to its current
+// versions, and all other stacks keep their manifest.
+func (s *StackManager) GetUpgradeManifest(release string) (*types.VersionManifest, error) {
+	if release == "" && s.Stack.VersionManifest != nil {
+		switch s.Stack.VersionManifest.Release {
+		case "stable", "head":
+			release = s.Stack.VersionManifest.Release
+		}
+	}
+	if release == "" {
+		return s.Stack.VersionManifest, nil
+	}
+	if err := core.ValidateRelease(release); err != nil {
+		return nil, err
+	}
+	s.Log.Info(fmt.Sprintf("resolving component versions for release %s", release))
+	return getReleaseManifest(release)
+}
+
+// GetVersionChanges compares the versions of the stack's components with those of the manifest, and
+// fetches the release notes of each component that changes. Components whose image was overridden at
+// init are not changed by the manifest, so are skipped.
+func (s *StackManager) GetVersionChanges(manifest *types.VersionManifest) []*VersionChange {
+	components := []string{types.FireFlyComponent, types.DataExchangeComponent}
+	if s.Stack.BlockchainProvider == GoEthereum.String() {
+		components = append(components, types.EthconnectComponent)
+	}
+	for _, tokensProvider := range s.Stack.TokensProviders {
+		switch tokensProvider {
+		case ERC1155.String():
+			components = append(components, types.TokensERC1155Component)
+		case ERC20ERC721.String():
+			components = append(components, types.TokensERC20ERC721Component)
+		}
+	}
+
+	changes := make([]*VersionChange, 0)
+	for _, component := range components {
+		if _, ok := s.Stack.ImageOverrides[component]; ok {
+			continue
+		}
+		from := getManifestTag(s.Stack.VersionManifest, component)
+		to := getManifestTag(manifest, component)
+		if from == to {
+			continue
+		}
+		change := &VersionChange{Component: component, From: from, To: to}
+		notes, err := core.GetReleaseNotes(component, from, to)
+		if err != nil {
+			s.Log.Warn(fmt.Sprintf("failed to fetch the release notes of %s: %s", component, err))
+		}
+		change.ReleaseNotes = notes
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func getManifestTag(manifest *types.VersionManifest, component string) string {
+	if entry := manifest.GetEntry(component); entry != nil && entry.Tag != "" {
+		return entry.Tag
+	}
+	return "latest"
+}
+
+// UpgradeStack pulls the latest images for the stack, and rewrites the stack's docker compose file and
+// configuration in the current format. If the stack is running, its containers are recreated from the new
+// images - stateless services first, each without downtime, then the rest of the stack unless only
+// stateless services are to be upgraded. Data is kept in the stack's volumes, so is preserved. If a
+// manifest is given, the stack's components are pinned to its versions.
+func (s *StackManager) UpgradeStack(manifest *types.VersionManifest, statelessOnly bool, verbose bool) error {
+	if err := s.upgradeStack(manifest, statelessOnly, verbose); err != nil {
+		s.notify(notify.UpgradeFailed, fmt.Sprintf("upgrade of stack '%s' failed", s.Stack.Name), err)
+		return err
+	}
+	message := fmt.Sprintf("stack '%s' upgraded", s.Stack.Name)
+	if s.Stack.VersionManifest != nil && s.Stack.VersionManifest.Release != "" {
+		message = fmt.Sprintf("%s to release %s", message, s.Stack.VersionManifest.Release)
+	}
+	s.notify(notify.Upgraded, message, nil)
+	return nil
+}
+
+func (s *StackManager) upgradeStack(manifest *types.VersionManifest, statelessOnly bool, verbose bool) error {
+	if err := s.checkGenerated(); err != nil {
+		return err
+	}
+	if err := s.checkOperation("upgrade", verbose); err != nil {
+		return err
+	}
+	workingDir := filepath.Join(constants.StacksDir, s.Stack.Name)
+	if manifest != nil {
+		s.Stack.VersionManifest = manifest
+	}
+
+	s.Log.Info("writing stack configuration")
+	if err := s.writeStackFiles(verbose); err != nil {
+		return err
+	}
+
+	runBefore, err := s.StackHasRunBefore()
+	if err != nil {
+		return err
+	}
+	if runBefore {
+		for _, member := range s.Stack.Members {
+			if !member.External {
+				if err := s.copyFireflyConfigToVolume(member, verbose); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := s.loginToRegistry(verbose); err != nil {
+		return err
+	}
+	pullCommand := []string{"pull"}
+	if statelessOnly {
+		for _, serviceDefinition := range s.statelessServices() {
+			pullCommand = append(pullCommand, serviceDefinition.ServiceName)
+		}
+	}
+	s.Log.Info("pulling latest versions")
+	if err := docker.RunDockerComposeCommand(workingDir, verbose, verbose, pullCommand...); err != nil {
+		return err
+	}
+
+	running, err := s.IsRunning(verbose)
+	if err != nil || !running {
+		return err
+	}
+	for _, serviceDefinition := range s.statelessServices() {
+		if err := s.handoverService(serviceDefinition, verbose); err != nil {
+			return err
+		}
+	}
+	if statelessOnly {
+		return nil
+	}
+	s.Log.Info("recreating containers")
+	return s.runStartupSequence(workingDir, verbose, false)
+}
+
+func (s *StackManager) IsRunning(verbose bool) (bool, error) {
+	containers, err := docker.ListProjectContainers(s.Stack.Name, verbose)
+	if err != nil {
+		return false, fmt.Errorf("failed to list containers: %s", err)
+	}
+	for _, c := range containers {
+		if c.State == "running" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
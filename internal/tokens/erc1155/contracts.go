@@ -18,11 +18,11 @@ package erc1155
 
 import (
 	"errors"
-	"fmt"
 	"path/filepath"
 
 	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
 	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
 	"github.com/hyperledger/firefly-cli/internal/log"
 	"github.com/hyperledger/firefly-cli/pkg/types"
 )
@@ -31,7 +31,7 @@ func DeployContracts(s *types.Stack, log log.Logger, verbose bool) error {
 	var containerName string
 	for _, member := range s.Members {
 		if !member.External {
-			containerName = fmt.Sprintf("%s_tokens_%s_1", s.Name, member.ID)
+			containerName = docker.ContainerName(s.Name, s.TokensServiceName(connectorName, member))
 			break
 		}
 	}
@@ -49,23 +49,6 @@ func DeployContracts(s *types.Stack, log log.Logger, verbose bool) error {
 		return err
 	}
 
-	var tokenContractAddress string
-	for _, member := range s.Members {
-		if tokenContractAddress == "" {
-			// TODO: version the registered name
-			log.Info(fmt.Sprintf("deploying ERC1155 contract on '%s'", member.ID))
-			tokenContractAddress, err = ethereum.DeployContract(member, tokenContract, "erc1155", map[string]string{"uri": ""})
-			if err != nil {
-				return err
-			}
-		} else {
-			log.Info(fmt.Sprintf("registering ERC1155 contract on '%s'", member.ID))
-			err = ethereum.RegisterContract(member, tokenContract, tokenContractAddress, "erc1155", map[string]string{"uri": ""})
-			if err != nil {
-				return err
-			}
-		}
-	}
-
-	return nil
+	// TODO: version the registered name
+	return ethereum.DeployOrRegisterContract(s, tokenContract, "erc1155", map[string]string{"uri": ""}, log)
 }
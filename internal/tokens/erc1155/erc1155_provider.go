@@ -32,35 +32,42 @@ type ERC1155Provider struct {
 	Stack   *types.Stack
 }
 
+// connectorName is the name of the connector in FireFly core, and of the provider in the stack
+const connectorName = "erc1155"
+
 func (p *ERC1155Provider) DeploySmartContracts() error {
 	return DeployContracts(p.Stack, p.Log, p.Verbose)
 }
 
 func (p *ERC1155Provider) FirstTimeSetup() error {
 	for _, member := range p.Stack.Members {
-		p.Log.Info(fmt.Sprintf("initializing tokens on member %s", member.ID))
-		tokenInitUrl := fmt.Sprintf("http://localhost:%d/api/v1/init", member.ExposedTokensPort)
-		if err := core.RequestWithRetry("POST", tokenInitUrl, nil, nil); err != nil {
+		if err := p.AddMember(member); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func (p *ERC1155Provider) AddMember(member *types.Member) error {
+	p.Log.Info(fmt.Sprintf("initializing %s tokens on member %s", connectorName, member.ID))
+	tokenInitUrl := fmt.Sprintf("http://%s:%d/api/v1/init", member.Host(), member.ExposedTokensPorts[connectorName])
+	return core.RequestWithRetry("POST", tokenInitUrl, nil, nil)
+}
+
 func (p *ERC1155Provider) GetDockerServiceDefinitions() []*docker.ServiceDefinition {
 	serviceDefinitions := make([]*docker.ServiceDefinition, 0, len(p.Stack.Members))
 	for _, member := range p.Stack.Members {
 		serviceDefinitions = append(serviceDefinitions, &docker.ServiceDefinition{
-			ServiceName: "tokens_" + member.ID,
+			ServiceName: p.Stack.TokensServiceName(connectorName, member),
 			Service: &docker.Service{
-				Image: "ghcr.io/hyperledger/firefly-tokens-erc1155:latest",
-				Ports: []string{fmt.Sprintf("%d:3000", member.ExposedTokensPort)},
-				Environment: map[string]string{
+				Image: p.Stack.GetImage(types.TokensERC1155Component),
+				Ports: []string{fmt.Sprintf("%d:3000", member.ExposedTokensPorts[connectorName])},
+				Environment: p.Stack.GetNodeEnvironment(map[string]string{
 					"ETHCONNECT_URL":      p.getEthconnectURL(member),
 					"ETHCONNECT_INSTANCE": "/contracts/erc1155",
 					"ETHCONNECT_IDENTITY": strings.TrimPrefix(member.Address, "0x"),
 					"AUTO_INIT":           "false",
-				},
+				}),
 				DependsOn: map[string]map[string]string{
 					"ethconnect_" + member.ID: {"condition": "service_started"},
 				},
@@ -78,20 +85,20 @@ func (p *ERC1155Provider) GetFireflyConfig(m *types.Member) *core.TokensConfig {
 	return &core.TokensConfig{
 		&core.TokenConnector{
 			Plugin: "fftokens",
-			Name:   "erc1155",
+			Name:   connectorName,
 			URL:    p.getTokensURL(m),
 		},
 	}
 }
 
 func (p *ERC1155Provider) getEthconnectURL(member *types.Member) string {
-	return fmt.Sprintf("http://ethconnect_%s:8080", member.ID)
+	return fmt.Sprintf("http://%s:8080", p.Stack.ServiceHost("ethconnect_"+member.ID))
 }
 
 func (p *ERC1155Provider) getTokensURL(member *types.Member) string {
 	if !member.External {
-		return fmt.Sprintf("http://tokens_%s:3000", member.ID)
+		return fmt.Sprintf("http://%s:3000", p.Stack.ServiceHost(p.Stack.TokensServiceName(connectorName, member)))
 	} else {
-		return fmt.Sprintf("http://127.0.0.1:%v", member.ExposedTokensPort)
+		return fmt.Sprintf("http://%s:%v", member.Host(), member.ExposedTokensPorts[connectorName])
 	}
 }
@@ -0,0 +1,55 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erc20erc721
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/blockchain/ethereum"
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+// DeployContracts deploys the token factory, which the connector uses to deploy a new ERC20 or ERC721
+// contract for each token pool
+func DeployContracts(s *types.Stack, log log.Logger, verbose bool) error {
+	var containerName string
+	for _, member := range s.Members {
+		if !member.External {
+			containerName = docker.ContainerName(s.Name, s.TokensServiceName(connectorName, member))
+			break
+		}
+	}
+	if containerName == "" {
+		return errors.New("unable to extract contracts from container - no valid tokens containers found in stack")
+	}
+	log.Info("extracting smart contracts")
+
+	if err := ethereum.ExtractContracts(s.Name, containerName, "/root/contracts", verbose); err != nil {
+		return err
+	}
+
+	factoryContract, err := ethereum.ReadCompiledContract(filepath.Join(constants.StacksDir, s.Name, "contracts", "TokenFactory.json"))
+	if err != nil {
+		return err
+	}
+
+	return ethereum.DeployOrRegisterContract(s, factoryContract, "erc20_erc721", map[string]string{}, log)
+}
@@ -0,0 +1,105 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package erc20erc721
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/firefly-cli/internal/core"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/internal/log"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+type ERC20ERC721Provider struct {
+	Log     log.Logger
+	Verbose bool
+	Stack   *types.Stack
+}
+
+// connectorName is the name of the connector in FireFly core, and of the provider in the stack
+const connectorName = "erc20_erc721"
+
+func (p *ERC20ERC721Provider) DeploySmartContracts() error {
+	return DeployContracts(p.Stack, p.Log, p.Verbose)
+}
+
+func (p *ERC20ERC721Provider) FirstTimeSetup() error {
+	for _, member := range p.Stack.Members {
+		if err := p.AddMember(member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *ERC20ERC721Provider) AddMember(member *types.Member) error {
+	p.Log.Info(fmt.Sprintf("initializing %s tokens on member %s", connectorName, member.ID))
+	tokenInitUrl := fmt.Sprintf("http://%s:%d/api/v1/init", member.Host(), member.ExposedTokensPorts[connectorName])
+	return core.RequestWithRetry("POST", tokenInitUrl, nil, nil)
+}
+
+func (p *ERC20ERC721Provider) GetDockerServiceDefinitions() []*docker.ServiceDefinition {
+	serviceDefinitions := make([]*docker.ServiceDefinition, 0, len(p.Stack.Members))
+	for _, member := range p.Stack.Members {
+		serviceDefinitions = append(serviceDefinitions, &docker.ServiceDefinition{
+			ServiceName: p.Stack.TokensServiceName(connectorName, member),
+			Service: &docker.Service{
+				Image: p.Stack.GetImage(types.TokensERC20ERC721Component),
+				Ports: []string{fmt.Sprintf("%d:3000", member.ExposedTokensPorts[connectorName])},
+				Environment: p.Stack.GetNodeEnvironment(map[string]string{
+					"ETHCONNECT_URL": p.getEthconnectURL(member),
+					// Token pools are deployed as new ERC20 or ERC721 contracts by the factory
+					"ETHCONNECT_INSTANCE": "/contracts/erc20_erc721",
+					"ETHCONNECT_IDENTITY": strings.TrimPrefix(member.Address, "0x"),
+					"AUTO_INIT":           "false",
+				}),
+				DependsOn: map[string]map[string]string{
+					"ethconnect_" + member.ID: {"condition": "service_started"},
+				},
+				HealthCheck: &docker.HealthCheck{
+					Test: []string{"CMD", "curl", "http://localhost:3000/api"},
+				},
+				Logging: docker.StandardLogOptions,
+			},
+		})
+	}
+	return serviceDefinitions
+}
+
+func (p *ERC20ERC721Provider) GetFireflyConfig(m *types.Member) *core.TokensConfig {
+	return &core.TokensConfig{
+		&core.TokenConnector{
+			Plugin: "fftokens",
+			Name:   connectorName,
+			URL:    p.getTokensURL(m),
+		},
+	}
+}
+
+func (p *ERC20ERC721Provider) getEthconnectURL(member *types.Member) string {
+	return fmt.Sprintf("http://%s:8080", p.Stack.ServiceHost("ethconnect_"+member.ID))
+}
+
+func (p *ERC20ERC721Provider) getTokensURL(member *types.Member) string {
+	if !member.External {
+		return fmt.Sprintf("http://%s:3000", p.Stack.ServiceHost(p.Stack.TokensServiceName(connectorName, member)))
+	} else {
+		return fmt.Sprintf("http://%s:%v", member.Host(), member.ExposedTokensPorts[connectorName])
+	}
+}
@@ -37,6 +37,10 @@ func (p *NilTokensProvider) FirstTimeSetup() error {
 	return nil
 }
 
+func (p *NilTokensProvider) AddMember(member *types.Member) error {
+	return nil
+}
+
 func (p *NilTokensProvider) GetDockerServiceDefinitions() []*docker.ServiceDefinition {
 	return nil
 }
@@ -25,6 +25,7 @@ import (
 type ITokensProvider interface {
 	DeploySmartContracts() error
 	FirstTimeSetup() error
+	AddMember(member *types.Member) error
 	GetDockerServiceDefinitions() []*docker.ServiceDefinition
 	GetFireflyConfig(m *types.Member) *core.TokensConfig
 }
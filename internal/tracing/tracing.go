@@ -0,0 +1,266 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing records the phases of a CLI command as OpenTelemetry spans, and exports them
+// to an OTLP/HTTP collector (such as the OpenTelemetry collector or Jaeger) when the command ends.
+// Spans are only recorded once Start has been called with an endpoint.
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultServiceName = "firefly-cli"
+	exportTimeout      = 10 * time.Second
+	scopeName          = "github.com/hyperledger/firefly-cli"
+)
+
+var traceparentRegex = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-([0-9a-f]{16})-[0-9a-f]{2}$`)
+
+type Span struct {
+	name       string
+	traceID    string
+	spanID     string
+	parentID   string
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        error
+	ended      bool
+}
+
+type tracer struct {
+	endpoint    string
+	serviceName string
+	root        *Span
+	spans       []*Span
+}
+
+var current *tracer
+var mux sync.Mutex
+
+// Start begins recording the spans of a command, under a root span with the given name. If the
+// TRACEPARENT environment variable holds a W3C trace context, such as one set by a CI job, the
+// command's spans join that trace.
+func Start(endpoint string, commandName string, args []string) {
+	if endpoint == "" {
+		return
+	}
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	root := &Span{
+		name:    commandName,
+		traceID: randomID(16),
+		spanID:  randomID(8),
+		start:   time.Now(),
+	}
+	if match := traceparentRegex.FindStringSubmatch(os.Getenv("TRACEPARENT")); match != nil {
+		root.traceID = match[1]
+		root.parentID = match[2]
+	}
+	if len(args) > 0 {
+		root.SetAttribute("ff.args", strings.Join(args, " "))
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	current = &tracer{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		root:        root,
+		spans:       []*Span{root},
+	}
+}
+
+// StartSpan starts a span that is a child of the command's root span. It returns nil if tracing is
+// not enabled, and all the methods of Span do nothing on a nil span.
+func StartSpan(name string) *Span {
+	mux.Lock()
+	t := current
+	mux.Unlock()
+	if t == nil {
+		return nil
+	}
+	return t.root.StartChild(name)
+}
+
+// StartChild starts a span that is a child of this one
+func (s *Span) StartChild(name string) *Span {
+	if s == nil {
+		return nil
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if current == nil {
+		return nil
+	}
+	child := &Span{
+		name:     name,
+		traceID:  s.traceID,
+		spanID:   randomID(8),
+		parentID: s.spanID,
+		start:    time.Now(),
+	}
+	current.spans = append(current.spans, child)
+	return child
+}
+
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	if s.attributes == nil {
+		s.attributes = make(map[string]string)
+	}
+	s.attributes[key] = value
+}
+
+// End ends the span, marking it as failed if err is not nil. Only the first call has any effect.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	s.endLocked(err)
+}
+
+func (s *Span) endLocked(err error) {
+	if s.ended {
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.err = err
+}
+
+// Finish ends the root span, and any others still open, and exports them all to the collector
+func Finish(err error) error {
+	mux.Lock()
+	t := current
+	current = nil
+	if t == nil {
+		mux.Unlock()
+		return nil
+	}
+	for _, span := range t.spans {
+		if span != t.root {
+			span.endLocked(nil)
+		}
+	}
+	t.root.endLocked(err)
+	mux.Unlock()
+	return t.export()
+}
+
+// tracesURL follows the OTLP exporter convention of adding the signal path to a base endpoint
+func (t *tracer) tracesURL() string {
+	if strings.HasSuffix(t.endpoint, "/v1/traces") {
+		return t.endpoint
+	}
+	return strings.TrimSuffix(t.endpoint, "/") + "/v1/traces"
+}
+
+// export posts the spans using the JSON encoding of OTLP/HTTP
+func (t *tracer) export() error {
+	spans := make([]interface{}, len(t.spans))
+	for i, span := range t.spans {
+		spans[i] = span.toOTLP()
+	}
+	body := map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]string{"service.name": t.serviceName}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": scopeName},
+						"spans": spans,
+					},
+				},
+			},
+		},
+	}
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: exportTimeout}
+	resp, err := client.Post(t.tracesURL(), "application/json", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		responseBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%d %s", resp.StatusCode, responseBody)
+	}
+	return nil
+}
+
+func (s *Span) toOTLP() map[string]interface{} {
+	span := map[string]interface{}{
+		"traceId":           s.traceID,
+		"spanId":            s.spanID,
+		"name":              s.name,
+		"kind":              1, // SPAN_KIND_INTERNAL
+		"startTimeUnixNano": fmt.Sprintf("%d", s.start.UnixNano()),
+		"endTimeUnixNano":   fmt.Sprintf("%d", s.end.UnixNano()),
+		"attributes":        otlpAttributes(s.attributes),
+	}
+	if s.parentID != "" {
+		span["parentSpanId"] = s.parentID
+	}
+	if s.err != nil {
+		span["status"] = map[string]interface{}{"code": 2, "message": s.err.Error()} // STATUS_CODE_ERROR
+	} else {
+		span["status"] = map[string]interface{}{"code": 1} // STATUS_CODE_OK
+	}
+	return span
+}
+
+func otlpAttributes(attributes map[string]string) []interface{} {
+	result := make([]interface{}, 0, len(attributes))
+	for key, value := range attributes {
+		result = append(result, map[string]interface{}{
+			"key":   key,
+			"value": map[string]string{"stringValue": value},
+		})
+	}
+	return result
+}
+
+func randomID(size int) string {
+	b := make([]byte, size)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
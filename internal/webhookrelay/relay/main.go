@@ -0,0 +1,312 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This program is run inside the webhook relay container of a stack. It accepts
+// webhook deliveries from FireFly, buffers them, and forwards them in order to an
+// app running on the host. A delivery the app keeps rejecting is given up on, so it
+// does not hold up the ones after it. Deliveries can be inspected and replayed from
+// /_relay/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDeliveries is the number of deliveries kept. Finished deliveries are dropped
+// to make room, but pending ones never are - new deliveries are refused instead.
+const maxDeliveries = 1000
+
+// maxErrorResponses is the number of error responses from the app after which a
+// delivery is given up on. While the app cannot be reached at all, deliveries
+// wait for it in order however long it takes.
+const maxErrorResponses = 5
+
+type Delivery struct {
+	ID         int               `json:"id"`
+	Received   time.Time         `json:"received"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	Attempts   int               `json:"attempts"`
+	Delivered  bool              `json:"delivered"`
+	Failed     bool              `json:"failed"`
+	Errors     int               `json:"errors"`
+	StatusCode int               `json:"statusCode,omitempty"`
+	LastError  string            `json:"lastError,omitempty"`
+}
+
+type Relay struct {
+	mux        sync.Mutex
+	targetURL  string
+	dataFile   string
+	nextID     int
+	deliveries []*Delivery
+	wake       chan struct{}
+}
+
+func main() {
+	targetURL := strings.TrimSuffix(os.Getenv("TARGET_URL"), "/")
+	if targetURL == "" {
+		log.Fatal("TARGET_URL must be set")
+	}
+	dataDir := os.Getenv("DATA_DIR")
+	if dataDir == "" {
+		dataDir = "/data"
+	}
+	r := &Relay{
+		targetURL: targetURL,
+		dataFile:  filepath.Join(dataDir, "deliveries.json"),
+		wake:      make(chan struct{}, 1),
+	}
+	r.load()
+	go r.forward()
+
+	http.HandleFunc("/_relay/", r.serveUI)
+	http.HandleFunc("/_relay/deliveries", r.serveDeliveries)
+	http.HandleFunc("/_relay/replay", r.serveReplay)
+	http.HandleFunc("/", r.receive)
+	log.Printf("relaying webhooks to %s", targetURL)
+	log.Fatal(http.ListenAndServe(":8080", nil))
+}
+
+func (r *Relay) load() {
+	data, err := ioutil.ReadFile(r.dataFile)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &r.deliveries); err != nil {
+		log.Printf("ignoring unreadable %s: %s", r.dataFile, err)
+		return
+	}
+	for _, d := range r.deliveries {
+		if d.ID >= r.nextID {
+			r.nextID = d.ID + 1
+		}
+	}
+}
+
+// save must be called with the lock held
+func (r *Relay) save() {
+	data, _ := json.Marshal(r.deliveries)
+	if err := ioutil.WriteFile(r.dataFile, data, 0644); err != nil {
+		log.Printf("failed to save deliveries: %s", err)
+	}
+}
+
+func (r *Relay) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Relay) receive(w http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	headers := make(map[string]string)
+	for k := range req.Header {
+		headers[k] = req.Header.Get(k)
+	}
+	r.mux.Lock()
+	r.trim()
+	if len(r.deliveries) >= maxDeliveries {
+		r.mux.Unlock()
+		http.Error(w, "the relay is full of deliveries that have not been forwarded yet", http.StatusServiceUnavailable)
+		return
+	}
+	d := &Delivery{
+		ID:       r.nextID,
+		Received: time.Now(),
+		Method:   req.Method,
+		Path:     req.URL.RequestURI(),
+		Headers:  headers,
+		Body:     string(body),
+	}
+	r.nextID++
+	r.deliveries = append(r.deliveries, d)
+	r.save()
+	r.mux.Unlock()
+	r.notify()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// trim drops the oldest finished deliveries to make room for a new one, and must be
+// called with the lock held
+func (r *Relay) trim() {
+	excess := len(r.deliveries) - maxDeliveries + 1
+	if excess <= 0 {
+		return
+	}
+	kept := r.deliveries[:0]
+	for _, d := range r.deliveries {
+		if excess > 0 && (d.Delivered || d.Failed) {
+			excess--
+			continue
+		}
+		kept = append(kept, d)
+	}
+	r.deliveries = kept
+}
+
+// forward delivers buffered requests to the target in the order they were received,
+// backing off while the target is unavailable
+func (r *Relay) forward() {
+	backoff := time.Second
+	for {
+		r.mux.Lock()
+		var next *Delivery
+		for _, d := range r.deliveries {
+			if !d.Delivered && !d.Failed {
+				next = d
+				break
+			}
+		}
+		r.mux.Unlock()
+		if next == nil {
+			<-r.wake
+			continue
+		}
+		err := r.send(next)
+		r.mux.Lock()
+		failed := next.Failed
+		r.mux.Unlock()
+		if err != nil && !failed {
+			time.Sleep(backoff)
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		} else {
+			backoff = time.Second
+		}
+	}
+}
+
+func (r *Relay) send(d *Delivery) error {
+	req, err := http.NewRequest(d.Method, r.targetURL+d.Path, bytes.NewReader([]byte(d.Body)))
+	if err != nil {
+		return err
+	}
+	for k, v := range d.Headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	d.Attempts++
+	if err == nil {
+		resp.Body.Close()
+		d.StatusCode = resp.StatusCode
+		if resp.StatusCode >= 300 {
+			err = fmt.Errorf("target returned %d", resp.StatusCode)
+			d.Errors++
+			// Other than timeouts and rate limiting, the app will give the same answer to a client error
+			permanent := resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests
+			if permanent || d.Errors >= maxErrorResponses {
+				d.Failed = true
+				log.Printf("giving up on delivery %d: %s", d.ID, err)
+			}
+		}
+	}
+	if err != nil {
+		d.LastError = err.Error()
+	} else {
+		d.LastError = ""
+		d.Delivered = true
+	}
+	r.save()
+	return err
+}
+
+func (r *Relay) serveDeliveries(w http.ResponseWriter, req *http.Request) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(r.deliveries)
+}
+
+func (r *Relay) serveReplay(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := req.FormValue("id")
+	r.mux.Lock()
+	for _, d := range r.deliveries {
+		if fmt.Sprint(d.ID) == id {
+			d.Delivered = false
+			d.Failed = false
+			d.Errors = 0
+			d.LastError = ""
+		}
+	}
+	r.save()
+	r.mux.Unlock()
+	r.notify()
+	http.Redirect(w, req, "/_relay/", http.StatusSeeOther)
+}
+
+var uiTemplate = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html>
+<head><title>FireFly webhook relay</title><meta http-equiv="refresh" content="5"></head>
+<body style="font-family: sans-serif">
+<h2>Webhook deliveries to {{.Target}}</h2>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>ID</th><th>Received</th><th>Request</th><th>Attempts</th><th>Status</th><th>Body</th><th></th></tr>
+{{range .Deliveries}}
+<tr>
+<td>{{.ID}}</td>
+<td>{{.Received.Format "15:04:05"}}</td>
+<td>{{.Method}} {{.Path}}</td>
+<td>{{.Attempts}}</td>
+<td>{{if .Delivered}}delivered ({{.StatusCode}}){{else if .Failed}}failed: {{.LastError}}{{else if .LastError}}pending: {{.LastError}}{{else}}pending{{end}}</td>
+<td><pre style="max-width: 600px; overflow: auto">{{.Body}}</pre></td>
+<td><form method="POST" action="/_relay/replay"><input type="hidden" name="id" value="{{.ID}}"><button>Replay</button></form></td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>`))
+
+func (r *Relay) serveUI(w http.ResponseWriter, req *http.Request) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	// Show the most recent deliveries first
+	deliveries := make([]*Delivery, len(r.deliveries))
+	for i, d := range r.deliveries {
+		deliveries[len(r.deliveries)-1-i] = d
+	}
+	uiTemplate.Execute(w, map[string]interface{}{
+		"Target":     r.targetURL,
+		"Deliveries": deliveries,
+	})
+}
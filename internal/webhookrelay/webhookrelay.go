@@ -0,0 +1,89 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhookrelay
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hyperledger/firefly-cli/internal/constants"
+	"github.com/hyperledger/firefly-cli/internal/docker"
+	"github.com/hyperledger/firefly-cli/pkg/types"
+)
+
+//go:embed relay/main.go
+var relaySource []byte
+
+// GetWebhookURL returns the base URL that FireFly webhook subscriptions should be pointed at
+func GetWebhookURL(stack *types.Stack) string {
+	return fmt.Sprintf("http://%s:8080", stack.ServiceHost("webhook_relay"))
+}
+
+func GetReplayUIURL(stack *types.Stack) string {
+	return fmt.Sprintf("http://%s:%d/_relay/", stack.Host(), stack.ExposedWebhookRelayPort)
+}
+
+func WriteConfig(stack *types.Stack) error {
+	if stack.WebhookRelayTargetPort == 0 {
+		return nil
+	}
+	relayDir := filepath.Join(constants.StacksDir, stack.Name, "webhook_relay")
+	if err := os.MkdirAll(relayDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(relayDir, "main.go"), relaySource, 0755)
+}
+
+// relayScript builds the relay into its data volume the first time the container starts with a version of
+// the source, so later starts run the binary straight away
+const relayScript = `relay=/data/relay-%x
+if [ ! -x $$relay ]; then
+  rm -f /data/relay-*
+  go build -o $$relay /relay/main.go || exit 1
+fi
+exec $$relay`
+
+func GetDockerServiceDefinitions(stack *types.Stack) []*docker.ServiceDefinition {
+	if stack.WebhookRelayTargetPort == 0 {
+		return nil
+	}
+	return []*docker.ServiceDefinition{
+		{
+			ServiceName: "webhook_relay",
+			Service: &docker.Service{
+				Image:      stack.MirrorImage("golang:1.16-alpine"),
+				Entrypoint: []string{"sh", "-c", fmt.Sprintf(relayScript, sha256.Sum256(relaySource))},
+				Environment: map[string]string{
+					"TARGET_URL": fmt.Sprintf("http://host.docker.internal:%d", stack.WebhookRelayTargetPort),
+					"DATA_DIR":   "/data",
+				},
+				Ports: []string{fmt.Sprintf("%d:8080", stack.ExposedWebhookRelayPort)},
+				Volumes: []string{
+					"./webhook_relay/main.go:/relay/main.go",
+					"webhook_relay:/data",
+				},
+				ExtraHosts: []string{"host.docker.internal:host-gateway"},
+				Logging:    docker.StandardLogOptions,
+			},
+			VolumeNames: []string{"webhook_relay"},
+		},
+	}
+}
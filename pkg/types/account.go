@@ -0,0 +1,33 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+type Account struct {
+	Address    string `json:"address,omitempty"`
+	PrivateKey string `json:"privateKey,omitempty"`
+	// Amount of wei, as 0x hex, the account is funded with in the genesis block, if not the default
+	Balance string `json:"balance,omitempty"`
+}
+
+// GenesisAccount is an account allocated in the genesis block of the stack's chain, with a balance, and with the
+// code and storage of a contract so that the contract exists from block 0
+type GenesisAccount struct {
+	Address string            `json:"address"`
+	Balance string            `json:"balance,omitempty"`
+	Code    string            `json:"code,omitempty"`
+	Storage map[string]string `json:"storage,omitempty"`
+}
@@ -21,3 +21,12 @@ type Contract struct {
 	ABI          interface{} `json:"abi"`
 	Bytecode     string      `json:"bytecode"`
 }
+
+// EthconnectConfig tunes the ethconnect REST gateway of each member. Unset values keep the ethconnect defaults.
+type EthconnectConfig struct {
+	MaxInFlight int `json:"maxInFlight,omitempty"`
+	// Seconds to wait for a transaction receipt before replying without one
+	MaxTXWaitTime int `json:"maxTXWaitTime,omitempty"`
+	// Number of receipts kept by the in-memory receipt store
+	ReceiptMaxDocs int `json:"receiptMaxDocs,omitempty"`
+}
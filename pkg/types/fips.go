@@ -0,0 +1,34 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// FIPSNodeOptions restricts the TLS of Node.js services to TLS 1.2 and above, with only FIPS-approved
+// AES-GCM cipher suites. Whether the ciphers themselves are FIPS validated depends on the OpenSSL
+// build in the image.
+const FIPSNodeOptions = "--tls-min-v1.2 --tls-cipher-list=TLS_AES_256_GCM_SHA384:TLS_AES_128_GCM_SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384:ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256"
+
+// GetNodeEnvironment adds the environment variables that configure the crypto of a Node.js service
+// for the stack's mode
+func (s *Stack) GetNodeEnvironment(env map[string]string) map[string]string {
+	if s.FIPS {
+		if env == nil {
+			env = make(map[string]string)
+		}
+		env["NODE_OPTIONS"] = FIPSNodeOptions
+	}
+	return env
+}
@@ -0,0 +1,39 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import "strings"
+
+// InternalDomain is the domain of the hostnames services in a stack use to reach each other
+const InternalDomain = "firefly.internal"
+
+// ServiceAlias returns the network alias of a docker compose service, which is the service name
+// without its firefly_ prefix in the internal domain - for example core-0.firefly.internal
+func ServiceAlias(serviceName string) string {
+	name := strings.ReplaceAll(strings.TrimPrefix(serviceName, "firefly_"), "_", "-")
+	return name + "." + InternalDomain
+}
+
+// ServiceHost returns the host that generated configs use to reach a service. This is the service's
+// alias, unless the alias has been mapped to an external endpoint in the stack's host aliases.
+func (s *Stack) ServiceHost(serviceName string) string {
+	alias := ServiceAlias(serviceName)
+	if host, ok := s.HostAliases[alias]; ok && host != "" {
+		return host
+	}
+	return alias
+}
@@ -0,0 +1,60 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// The services in a stack whose image can be pinned by a release or overridden at init
+const (
+	FireFlyComponent           = "firefly"
+	EthconnectComponent        = "ethconnect"
+	DataExchangeComponent      = "dataexchange"
+	TokensERC1155Component     = "tokens-erc1155"
+	TokensERC20ERC721Component = "tokens-erc20-erc721"
+	GethComponent              = "geth"
+	IPFSComponent              = "ipfs"
+	PostgresComponent          = "postgres"
+	EthsignerComponent         = "ethsigner"
+	VaultComponent             = "vault"
+	SandboxComponent           = "sandbox"
+)
+
+var defaultImages = map[string]string{
+	FireFlyComponent:           "ghcr.io/hyperledger/firefly:latest",
+	EthconnectComponent:        "ghcr.io/hyperledger/firefly-ethconnect:latest",
+	DataExchangeComponent:      "ghcr.io/hyperledger/firefly-dataexchange-https:latest",
+	TokensERC1155Component:     "ghcr.io/hyperledger/firefly-tokens-erc1155:latest",
+	TokensERC20ERC721Component: "ghcr.io/hyperledger/firefly-tokens-erc20-erc721:latest",
+	GethComponent:              "ethereum/client-go:release-1.9",
+	IPFSComponent:              "ipfs/go-ipfs",
+	PostgresComponent:          "postgres",
+	EthsignerComponent:         "consensys/ethsigner:latest",
+	VaultComponent:             "vault:1.8.4",
+	SandboxComponent:           "ghcr.io/hyperledger/firefly-sandbox:latest",
+}
+
+// GetImage returns the image to run for a component. An image override set at init takes precedence
+// over the version pinned by the stack's release, which takes precedence over the default image.
+// Overrides are used exactly as given, while other images are pulled from the stack's private
+// registry if it has one.
+func (s *Stack) GetImage(component string) string {
+	if image, ok := s.ImageOverrides[component]; ok {
+		return image
+	}
+	if entry := s.VersionManifest.GetEntry(component); entry != nil {
+		return s.MirrorImage(entry.GetImageName())
+	}
+	return s.MirrorImage(defaultImages[component])
+}
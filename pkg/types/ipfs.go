@@ -0,0 +1,29 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// IPFSConfig tunes the IPFS nodes of the stack, which are otherwise run with the go-ipfs defaults. Unset
+// values keep the defaults.
+type IPFSConfig struct {
+	// Size the datastore of each node is kept under by garbage collection (e.g. 2GB)
+	StorageMax string `json:"storageMax,omitempty"`
+	// Number of connections each node trims its connections down to, once it has more than the high water mark
+	LowWater  int `json:"lowWater,omitempty"`
+	HighWater int `json:"highWater,omitempty"`
+	// If set, the nodes do not look for peers on the local network with mDNS
+	DisableMDNS bool `json:"disableMDNS,omitempty"`
+}
@@ -0,0 +1,66 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import "fmt"
+
+// VersionManifest pins the image of each FireFly component in a stack to a specific version
+type VersionManifest struct {
+	Release           string         `json:"release,omitempty"`
+	FireFly           *ManifestEntry `json:"firefly,omitempty"`
+	Ethconnect        *ManifestEntry `json:"ethconnect,omitempty"`
+	DataExchange      *ManifestEntry `json:"dataexchange-https,omitempty"`
+	TokensERC1155     *ManifestEntry `json:"tokens-erc1155,omitempty"`
+	TokensERC20ERC721 *ManifestEntry `json:"tokens-erc20-erc721,omitempty"`
+}
+
+type ManifestEntry struct {
+	Image string `json:"image,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+	SHA   string `json:"sha,omitempty"`
+}
+
+func (e *ManifestEntry) GetImageName() string {
+	if e.Tag == "" {
+		return e.Image + ":latest"
+	}
+	return fmt.Sprintf("%s:%s", e.Image, e.Tag)
+}
+
+// GetEntry returns the manifest entry for a component, or nil if the component is not pinned by the manifest
+func (m *VersionManifest) GetEntry(component string) *ManifestEntry {
+	if m == nil {
+		return nil
+	}
+	var entry *ManifestEntry
+	switch component {
+	case FireFlyComponent:
+		entry = m.FireFly
+	case EthconnectComponent:
+		entry = m.Ethconnect
+	case DataExchangeComponent:
+		entry = m.DataExchange
+	case TokensERC1155Component:
+		entry = m.TokensERC1155
+	case TokensERC20ERC721Component:
+		entry = m.TokensERC20ERC721
+	}
+	if entry == nil || entry.Image == "" {
+		return nil
+	}
+	return entry
+}
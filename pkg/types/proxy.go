@@ -0,0 +1,55 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import "strings"
+
+// ProxyConfig is the HTTP proxy the services of a stack, and the CLI when working on the stack, reach
+// the internet through
+type ProxyConfig struct {
+	HTTP    string `json:"http,omitempty"`
+	HTTPS   string `json:"https,omitempty"`
+	NoProxy string `json:"noProxy,omitempty"`
+}
+
+// NoProxyHosts returns the hosts that are reached directly, which always include the local machine, along
+// with any extra hosts given, such as the services of the stack
+func (p *ProxyConfig) NoProxyHosts(extra ...string) []string {
+	hosts := []string{"localhost", "127.0.0.1"}
+	for _, host := range strings.Split(p.NoProxy, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return append(hosts, extra...)
+}
+
+// Environment returns the proxy environment variables to set in a container, in both the upper and
+// lower case forms, as tools differ in which they read
+func (p *ProxyConfig) Environment(extraNoProxy ...string) map[string]string {
+	env := make(map[string]string)
+	set := func(name, value string) {
+		if value != "" {
+			env[name] = value
+			env[strings.ToLower(name)] = value
+		}
+	}
+	set("HTTP_PROXY", p.HTTP)
+	set("HTTPS_PROXY", p.HTTPS)
+	set("NO_PROXY", strings.Join(p.NoProxyHosts(extraNoProxy...), ","))
+	return env
+}
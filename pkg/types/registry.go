@@ -0,0 +1,50 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import "strings"
+
+// RegistryConfig is a private registry which mirrors all of the images used by a stack
+type RegistryConfig struct {
+	URL string `json:"url"`
+	// If no username is set, pulls are authenticated by the credentials already configured in docker (such as a credential helper)
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Host returns the registry host to log in to, without any path prefix of the mirror
+func (r *RegistryConfig) Host() string {
+	return strings.SplitN(r.URL, "/", 2)[0]
+}
+
+// MirrorImage rewrites a public image reference to pull the same repository from the stack's
+// private registry. Images are returned unchanged if the stack does not use a private registry.
+func (s *Stack) MirrorImage(image string) string {
+	if s.Registry == nil || s.Registry.URL == "" {
+		return image
+	}
+	parts := strings.SplitN(image, "/", 2)
+	repository := image
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		// Drop the public registry host
+		repository = parts[1]
+	} else if len(parts) == 1 {
+		// Official images on Docker Hub live under library/
+		repository = "library/" + image
+	}
+	return strings.TrimSuffix(s.Registry.URL, "/") + "/" + repository
+}
@@ -0,0 +1,54 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import "strings"
+
+// AllServices is the key of the resource limits that apply to every service without a more specific limit
+const AllServices = "*"
+
+// ResourceLimits caps the memory and CPU a service's container can use. Memory is in docker's format, such as 512m or 2g.
+type ResourceLimits struct {
+	Memory string  `json:"memory,omitempty" yaml:"memory,omitempty"`
+	CPUs   float64 `json:"cpus,omitempty" yaml:"cpus,omitempty"`
+}
+
+// GetResourceLimits returns the limits of a docker compose service. Limits can be set for a service by
+// its name, by a prefix of its name such as firefly_core for the core of every member, or for all
+// services. Each limit is taken from the longest name that matches the service and sets it.
+func (s *Stack) GetResourceLimits(serviceName string) *ResourceLimits {
+	limits := &ResourceLimits{}
+	memoryMatch, cpusMatch := -1, -1
+	for name, l := range s.ResourceLimits {
+		length := len(name)
+		if name == AllServices {
+			length = 0
+		} else if name != serviceName && !strings.HasPrefix(serviceName, name+"_") {
+			continue
+		}
+		if l.Memory != "" && length > memoryMatch {
+			limits.Memory, memoryMatch = l.Memory, length
+		}
+		if l.CPUs != 0 && length > cpusMatch {
+			limits.CPUs, cpusMatch = l.CPUs, length
+		}
+	}
+	if limits.Memory == "" && limits.CPUs == 0 {
+		return nil
+	}
+	return limits
+}
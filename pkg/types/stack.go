@@ -16,14 +16,104 @@
 
 package types
 
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
 type Stack struct {
-	Name                  string    `json:"name,omitempty"`
-	Members               []*Member `json:"members,omitempty"`
-	SwarmKey              string    `json:"swarmKey,omitempty"`
-	ExposedBlockchainPort int       `json:"exposedGethPort,omitempty"`
-	Database              string    `json:"database"`
-	BlockchainProvider    string    `json:"blockchainProvider"`
-	TokensProvider        string    `json:"tokensProvider"`
+	Name                    string            `json:"name,omitempty"`
+	Version                 int               `json:"version,omitempty"`
+	Members                 []*Member         `json:"members,omitempty"`
+	SwarmKey                string            `json:"swarmKey,omitempty"`
+	ExposedBlockchainPort   int               `json:"exposedGethPort,omitempty"`
+	Database                string            `json:"database"`
+	BlockchainProvider      string            `json:"blockchainProvider"`
+	TokensProviders         []string          `json:"tokensProviders,omitempty"`
+	EventBridge             string            `json:"eventBridge,omitempty"`
+	ExposedEventBrokerPort  int               `json:"exposedEventBrokerPort,omitempty"`
+	Accounts                []*Account        `json:"accounts,omitempty"`
+	Tenants                 []*Tenant         `json:"tenants,omitempty"`
+	WebhookRelayTargetPort  int               `json:"webhookRelayTargetPort,omitempty"`
+	ExposedWebhookRelayPort int               `json:"exposedWebhookRelayPort,omitempty"`
+	ExposedPrometheusPort   int               `json:"exposedPrometheusPort,omitempty"`
+	ExposedGrafanaPort      int               `json:"exposedGrafanaPort,omitempty"`
+	Domain                  string            `json:"domain,omitempty"`
+	ExposedEdgePort         int               `json:"exposedEdgePort,omitempty"`
+	VersionManifest         *VersionManifest  `json:"versionManifest,omitempty"`
+	ImageOverrides          map[string]string `json:"imageOverrides,omitempty"`
+	Registry                *RegistryConfig   `json:"registry,omitempty"`
+	Proxy                   *ProxyConfig      `json:"proxy,omitempty"`
+	Ethconnect              *EthconnectConfig `json:"ethconnect,omitempty"`
+	DockerHost              string            `json:"dockerHost,omitempty"`
+	DockerContext           string            `json:"dockerContext,omitempty"`
+	Hostname                string            `json:"hostname,omitempty"`
+	HostAliases             map[string]string `json:"hostAliases,omitempty"`
+	Encrypted               bool              `json:"encrypted,omitempty"`
+	FIPS                    bool              `json:"fips,omitempty"`
+	SharedIPFS              bool              `json:"sharedIPFS,omitempty"`
+	IPFS                    *IPFSConfig       `json:"ipfs,omitempty"`
+	BlockchainNodes         string            `json:"blockchainNodes,omitempty"`
+	TLS                     bool              `json:"tls,omitempty"`
+	APIAuth                 string            `json:"apiAuth,omitempty"`
+	// Accounts and contracts allocated in the genesis block, in addition to the members and accounts
+	GenesisAccounts []*GenesisAccount `json:"genesisAccounts,omitempty"`
+	// Seed the keys of the stack's certificates are derived from, if the stack was created from a seed
+	CertificateSeed string `json:"certificateSeed,omitempty"`
+	// Parameters of the chain, mimicking a target network - the defaults of the blockchain provider are used
+	// where they are not set
+	ChainID     int    `json:"chainId,omitempty"`
+	BlockPeriod int    `json:"blockPeriod,omitempty"`
+	GasLimit    uint64 `json:"gasLimit,omitempty"`
+	// JSON merged into the generated genesis file
+	GenesisOverrides map[string]interface{} `json:"genesisOverrides,omitempty"`
+	// Host ports the JSON-RPC and WebSocket endpoints of the shared blockchain node are published on for
+	// wallets and tools, if enabled
+	ExposedNodeRPCPort int `json:"exposedNodeRpcPort,omitempty"`
+	ExposedNodeWSPort  int `json:"exposedNodeWsPort,omitempty"`
+	// Whether each member's database container is followed by a streaming read replica, and how far
+	// behind the primary the replica applies changes (e.g. 2s)
+	PostgresReplicas   bool   `json:"postgresReplicas,omitempty"`
+	PostgresReplicaLag string `json:"postgresReplicaLag,omitempty"`
+	// Memory and CPU limits of the stack's services, by service name or prefix
+	ResourceLimits map[string]*ResourceLimits `json:"resourceLimits,omitempty"`
+	// Log levels set with the loglevel command, by service name
+	LogLevels map[string]string `json:"logLevels,omitempty"`
+	// Directory of the hand-written docker compose deployment the stack was adopted from, whose compose
+	// file is used as is instead of being generated
+	AdoptedFrom string `json:"adoptedFrom,omitempty"`
+	// Name of the blue/green pair the stack was created in, for comparing two releases
+	ABPair string `json:"abPair,omitempty"`
+	// When images are pulled before the stack is started - always, missing or never
+	PullPolicy string `json:"pullPolicy,omitempty"`
+	// Archive holding the data of the stack while it is archived, with its docker resources removed
+	ArchivedTo string `json:"archivedTo,omitempty"`
+	// What the FireFly API of each member offered before the stack was last upgraded, by member ID
+	APIExpectations map[string]*APIExpectations `json:"apiExpectations,omitempty"`
+	// Where to post lifecycle events of the stack
+	Notifications []*NotificationTarget `json:"notifications,omitempty"`
+	// Lifecycle state of the stack - created, starting, running, stopped or error - and the error that put
+	// it in the error state
+	State      string `json:"state,omitempty"`
+	StateError string `json:"stateError,omitempty"`
+	// External service the members' transactions are signed by, instead of their blockchain node - ethsigner,
+	// or ethsigner backed by a Vault server holding the keys
+	Signer string `json:"signer,omitempty"`
+	// Root token of the stack's Vault server, if it has one
+	VaultToken string `json:"vaultToken,omitempty"`
+	// If set, each member is a single-org gateway, without data exchange or multiparty networking
+	Gateway bool `json:"gateway,omitempty"`
+	// Whether the FireFly UI is disabled, or the directory of a custom build of the UI served instead of the
+	// one bundled with FireFly core
+	NoUI   bool   `json:"noUI,omitempty"`
+	UIPath string `json:"uiPath,omitempty"`
+	// Whether the FireFly Sandbox is run for each member, or once for the first member
+	Sandbox       bool `json:"sandbox,omitempty"`
+	SharedSandbox bool `json:"sharedSandbox,omitempty"`
+	// Single tokens provider of version 1 stacks, replaced by TokensProviders
+	TokensProvider string `json:"tokensProvider,omitempty"`
 }
 
 type Member struct {
@@ -39,6 +129,276 @@ type Member struct {
 	ExposedIPFSApiPort      int    `json:"exposedIPFSApiPort,omitempty"`
 	ExposedIPFSGWPort       int    `json:"exposedIPFSGWPort,omitempty"`
 	ExposedUIPort           int    `json:"exposedUiPort,omitempty"`
-	ExposedTokensPort       int    `json:"exposedTokensPort,omitempty"`
+	ExposedBlockchainPort   int    `json:"exposedBlockchainPort,omitempty"`
 	External                bool   `json:"external,omitempty"`
+	Hostname                string `json:"hostname,omitempty"`
+	// Whether the member's FireFly APIs are served over HTTPS
+	TLS bool `json:"tls,omitempty"`
+	// Credentials required by the member's FireFly API and admin API, if the stack has API auth enabled
+	APIUsername string `json:"apiUsername,omitempty"`
+	APIPassword string `json:"apiPassword,omitempty"`
+	// Private key identifying the member's own blockchain node to its peers
+	NodeKey string `json:"nodeKey,omitempty"`
+	// Account the member's blockchain node seals blocks with, if the member's transactions are signed by an
+	// external signer that holds the member's own key
+	SealerAddress    string `json:"sealerAddress,omitempty"`
+	SealerPrivateKey string `json:"sealerPrivateKey,omitempty"`
+	// Port the FireFly Sandbox of the member is published on, if the member has one
+	ExposedSandboxPort int `json:"exposedSandboxPort,omitempty"`
+	// Port of each of the member's tokens connectors, by tokens provider
+	ExposedTokensPorts map[string]int `json:"exposedTokensPorts,omitempty"`
+	// Port of the single tokens connector of version 1 stacks, replaced by ExposedTokensPorts
+	ExposedTokensPort int `json:"exposedTokensPort,omitempty"`
+	// Connection URL of an external PostgreSQL server to use instead of a database container
+	PostgresURL string `json:"postgresUrl,omitempty"`
+	// Schema of the member's tables, when the external server is shared by several members
+	PostgresSchema string `json:"postgresSchema,omitempty"`
+	// Port of the read replica of the member's database container, if the stack has replicas
+	ExposedPostgresReplicaPort int `json:"exposedPostgresReplicaPort,omitempty"`
+	// Parameters of the chain, mimicking a target network - the defaults of the blockchain provider are used
+	// where they are not set
+	ChainID     int    `json:"chainId,omitempty"`
+	BlockPeriod int    `json:"blockPeriod,omitempty"`
+	GasLimit    uint64 `json:"gasLimit,omitempty"`
+	// JSON merged into the generated genesis file
+	GenesisOverrides map[string]interface{} `json:"genesisOverrides,omitempty"`
+	// Host ports the JSON-RPC and WebSocket endpoints of the member's own blockchain node are published
+	// on for wallets and tools, if enabled
+	ExposedNodeRPCPort int `json:"exposedNodeRpcPort,omitempty"`
+	ExposedNodeWSPort  int `json:"exposedNodeWsPort,omitempty"`
+}
+
+// NotificationTarget is a Slack incoming webhook, or a generic webhook, that lifecycle events of the
+// stack are posted to. A target with no list of events is sent all of them.
+type NotificationTarget struct {
+	Type   string   `json:"type" yaml:"type"`
+	URL    string   `json:"url" yaml:"url"`
+	Events []string `json:"events,omitempty" yaml:"events,omitempty"`
+}
+
+// APIExpectations are the namespaces, token connectors and contract APIs a member's FireFly API offered,
+// which are checked to still work after an upgrade
+type APIExpectations struct {
+	Namespaces      []string `json:"namespaces,omitempty"`
+	TokenConnectors []string `json:"tokenConnectors,omitempty"`
+	ContractAPIs    []string `json:"contractAPIs,omitempty"`
+}
+
+// Tenant is a developer sharing the stack, who is given their own FireFly namespace. The credentials
+// are optional, and are enforced by the edge proxy.
+type Tenant struct {
+	Name      string `json:"name" yaml:"name"`
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Username  string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password  string `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+// Host is the hostname on which the ports of the stack's containers are published
+func (s *Stack) Host() string {
+	if s.Hostname != "" {
+		return s.Hostname
+	}
+	return "127.0.0.1"
+}
+
+// Host is the hostname on which the ports of the member's containers are published
+func (m *Member) Host() string {
+	if m.Hostname != "" {
+		return m.Hostname
+	}
+	return "127.0.0.1"
+}
+
+// FireflyHost is the hostname of the member's FireFly API. FireFly core processes of external
+// members are always run on the local machine, even if the rest of the stack is on a remote host.
+func (m *Member) FireflyHost() string {
+	if m.External {
+		return "127.0.0.1"
+	}
+	return m.Host()
+}
+
+// FireflyURL is the base URL of the member's FireFly API server
+func (m *Member) FireflyURL() string {
+	return fmt.Sprintf("%s://%s:%d", m.scheme(), m.FireflyHost(), m.ExposedFireflyPort)
+}
+
+// FireflyAdminURL is the base URL of the member's FireFly admin server
+func (m *Member) FireflyAdminURL() string {
+	return fmt.Sprintf("%s://%s:%d", m.scheme(), m.FireflyHost(), m.ExposedFireflyAdminPort)
+}
+
+// FireflyClientURL is the base URL the CLI calls the member's FireFly API on, including its credentials
+func (m *Member) FireflyClientURL() string {
+	return m.withCredentials(m.FireflyURL())
+}
+
+// FireflyAdminClientURL is the base URL the CLI calls the member's FireFly admin API on, including its credentials
+func (m *Member) FireflyAdminClientURL() string {
+	return m.withCredentials(m.FireflyAdminURL())
+}
+
+func (m *Member) withCredentials(baseURL string) string {
+	if m.APIUsername == "" {
+		return baseURL
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return baseURL
+	}
+	u.User = url.UserPassword(m.APIUsername, m.APIPassword)
+	return u.String()
+}
+
+func (m *Member) scheme() string {
+	if m.TLS {
+		return "https"
+	}
+	return "http"
+}
+
+// DockerHostName is the name containers reach the docker host by
+const DockerHostName = "host.docker.internal"
+
+// DockerHostMapping is the extra host entry that resolves DockerHostName in a container, on engines that
+// do not define it themselves
+const DockerHostMapping = DockerHostName + ":host-gateway"
+
+// UseDockerHost points a URL on the loopback interface at the docker host, which is where a server given
+// as localhost is from inside a container. It returns whether the URL was changed.
+func UseDockerHost(u *url.URL) bool {
+	host := u.Hostname()
+	if !strings.EqualFold(host, "localhost") && !net.ParseIP(host).IsLoopback() {
+		return false
+	}
+	port := u.Port()
+	u.Host = DockerHostName
+	if port != "" {
+		u.Host += ":" + port
+	}
+	return true
+}
+
+// GetPostgresURL returns the connection URL FireFly core uses for the member's external database, which
+// selects the member's schema if it has one, and reaches a server on localhost through the docker host
+func (m *Member) GetPostgresURL() string {
+	return m.postgresURL(true)
+}
+
+// GetHostPostgresURL returns the connection URL of the member's external database from the docker host
+func (m *Member) GetHostPostgresURL() string {
+	return m.postgresURL(false)
+}
+
+func (m *Member) postgresURL(fromContainer bool) string {
+	u, err := url.Parse(m.PostgresURL)
+	if err != nil || !isPostgresScheme(u.Scheme) {
+		return m.PostgresURL
+	}
+	if fromContainer {
+		UseDockerHost(u)
+	}
+	if m.PostgresSchema != "" {
+		query := u.Query()
+		query.Set("search_path", m.PostgresSchema)
+		u.RawQuery = query.Encode()
+	}
+	return u.String()
+}
+
+// PostgresOnDockerHost returns whether FireFly core needs to reach the member's external database on the
+// docker host. The URL in a secret reference is not known until the config is copied into the volume, so
+// a member whose URL is a reference is given the docker host as well.
+func (m *Member) PostgresOnDockerHost() bool {
+	if m.PostgresURL == "" {
+		return false
+	}
+	u, err := url.Parse(m.PostgresURL)
+	if err != nil || !isPostgresScheme(u.Scheme) {
+		return true
+	}
+	return UseDockerHost(u) || strings.EqualFold(u.Hostname(), DockerHostName)
+}
+
+func isPostgresScheme(scheme string) bool {
+	return scheme == "postgres" || scheme == "postgresql"
+}
+
+// IPFSNode returns the name of the IPFS service the member uses, and the member on whose ports the
+// node is published. A shared IPFS node is published on the ports of the first member.
+func (s *Stack) IPFSNode(member *Member) (serviceName string, owner *Member) {
+	if s.SharedIPFS {
+		return "ipfs", s.Members[0]
+	}
+	return "ipfs_" + member.ID, member
+}
+
+// CustomUIMountPath is where a custom build of the FireFly UI is mounted in the FireFly core containers
+const CustomUIMountPath = "/firefly/ui-custom"
+
+// SandboxService returns the name of the FireFly Sandbox service of the member, if the member has one. A
+// shared sandbox is named after the stack rather than the member.
+func (s *Stack) SandboxService(member *Member) string {
+	if member.ExposedSandboxPort == 0 {
+		return ""
+	}
+	if s.SharedSandbox {
+		return "sandbox"
+	}
+	return "sandbox_" + member.ID
+}
+
+// BlockchainNodePerMember is the blockchain node topology in which each member runs its own node
+const BlockchainNodePerMember = "per-member"
+
+// BlockchainNode returns the name of the blockchain node service the member uses, and the host port the
+// node's RPC endpoint is published on
+func (s *Stack) BlockchainNode(member *Member) (serviceName string, port int) {
+	if s.BlockchainNodes == BlockchainNodePerMember {
+		return s.BlockchainProvider + "_" + member.ID, member.ExposedBlockchainPort
+	}
+	return s.BlockchainProvider, s.ExposedBlockchainPort
+}
+
+// The external signers the members' transactions can be signed by
+const (
+	SignerEthsigner = "ethsigner"
+	SignerVault     = "vault"
+)
+
+// NodeAccount returns the account the member's blockchain node signs with - its sealer account if the
+// member's transactions are signed externally, or the member's own account
+func (m *Member) NodeAccount() (address string, privateKey string) {
+	if m.SealerAddress != "" {
+		return m.SealerAddress, m.SealerPrivateKey
+	}
+	return m.Address, m.PrivateKey
+}
+
+// TransactionEndpoint returns the name of the service the member's transactions are submitted to - its
+// signer if the stack has an external signer, or its blockchain node
+func (s *Stack) TransactionEndpoint(member *Member) string {
+	if s.Signer != "" {
+		return "ethsigner_" + member.ID
+	}
+	nodeName, _ := s.BlockchainNode(member)
+	return nodeName
+}
+
+// NodeRPCPorts returns the host ports the JSON-RPC and WebSocket endpoints of the member's blockchain node
+// are published on for wallets and tools, which are zero unless the stack was created with them exposed
+func (s *Stack) NodeRPCPorts(member *Member) (rpcPort int, wsPort int) {
+	if s.BlockchainNodes == BlockchainNodePerMember {
+		return member.ExposedNodeRPCPort, member.ExposedNodeWSPort
+	}
+	return s.ExposedNodeRPCPort, s.ExposedNodeWSPort
+}
+
+// TokensServiceName returns the name of the member's docker compose service for a tokens provider. The
+// connector of the stack's first tokens provider keeps the tokens_<member> name of single connector stacks.
+func (s *Stack) TokensServiceName(provider string, member *Member) string {
+	if len(s.TokensProviders) > 0 && s.TokensProviders[0] == provider {
+		return "tokens_" + member.ID
+	}
+	return fmt.Sprintf("tokens_%s_%s", provider, member.ID)
 }
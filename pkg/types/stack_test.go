@@ -0,0 +1,42 @@
+// Copyright © 2021 Kaleido, Inc.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import "testing"
+
+func TestGetPostgresURL(t *testing.T) {
+	tests := []struct {
+		member   Member
+		url      string
+		onDocker bool
+	}{
+		{member: Member{PostgresURL: "postgres://ff:pw@db.example.com:5432/firefly"}, url: "postgres://ff:pw@db.example.com:5432/firefly"},
+		{member: Member{PostgresURL: "postgres://ff:pw@localhost:5432/firefly"}, url: "postgres://ff:pw@host.docker.internal:5432/firefly", onDocker: true},
+		{member: Member{PostgresURL: "postgres://ff:pw@127.0.0.1/firefly", PostgresSchema: "s_member_0"}, url: "postgres://ff:pw@host.docker.internal/firefly?search_path=s_member_0", onDocker: true},
+		{member: Member{PostgresURL: "postgresql://ff@host.docker.internal:5433/firefly"}, url: "postgresql://ff@host.docker.internal:5433/firefly", onDocker: true},
+		{member: Member{PostgresURL: "secret://env/FF_DB"}, url: "secret://env/FF_DB", onDocker: true},
+		{member: Member{}, url: ""},
+	}
+	for _, test := range tests {
+		if url := test.member.GetPostgresURL(); url != test.url {
+			t.Errorf("%s: got URL %q, expected %q", test.member.PostgresURL, url, test.url)
+		}
+		if onDocker := test.member.PostgresOnDockerHost(); onDocker != test.onDocker {
+			t.Errorf("%s: got on docker host %t, expected %t", test.member.PostgresURL, onDocker, test.onDocker)
+		}
+	}
+}